@@ -0,0 +1,445 @@
+package ai
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+
+	aiFunctions "sententiawebapi/handlers/apis/ai/functions"
+	aiKnowledge "sententiawebapi/handlers/apis/ai/knowledge"
+	aiUsage "sententiawebapi/handlers/apis/ai/usage"
+	aiVersions "sententiawebapi/handlers/apis/ai/versions"
+	"sententiawebapi/handlers/apis/tenantManagement"
+	"sententiawebapi/handlers/models"
+	"sententiawebapi/utilities"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/openai/openai-go/responses"
+)
+
+var assistantNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// validateAssistantConfig decodes the config strictly into AssistantParams, unknown fields are rejected.
+// Warnings are returned for settings that are valid but won't work for the tenant, e.g. vector stores it doesn't have.
+func validateAssistantConfig(rawConfig json.RawMessage, openAiConfig *models.OpenAiConfig) (*models.AssistantParams, []string, error) {
+	if len(bytes.TrimSpace(rawConfig)) == 0 || bytes.TrimSpace(rawConfig)[0] != '{' {
+		return nil, nil, fmt.Errorf("config must be a JSON object")
+	}
+
+	var params models.AssistantParams
+	decoder := json.NewDecoder(bytes.NewReader(rawConfig))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&params); err != nil {
+		return nil, nil, fmt.Errorf("invalid config: %v", err)
+	}
+
+	warnings := []string{}
+
+	if params.Model != "" {
+		if _, ok := aiUsage.FindModelPrice(string(params.Model)); !ok {
+			warnings = append(warnings, fmt.Sprintf("model %s has no price, its usage is recorded without cost", params.Model))
+		}
+	}
+	if params.Temperature.IsPresent() && (params.Temperature.Value < 0 || params.Temperature.Value > 2) {
+		return nil, nil, fmt.Errorf("temperature must be between 0 and 2")
+	}
+	if params.TopP.IsPresent() && (params.TopP.Value < 0 || params.TopP.Value > 1) {
+		return nil, nil, fmt.Errorf("top_p must be between 0 and 1")
+	}
+	if params.MaxOutputTokens.IsPresent() && params.MaxOutputTokens.Value < 16 {
+		return nil, nil, fmt.Errorf("max_output_tokens must be at least 16")
+	}
+	switch params.Truncation {
+	case "", responses.ResponseNewParamsTruncationAuto, responses.ResponseNewParamsTruncationDisabled:
+	default:
+		return nil, nil, fmt.Errorf("truncation must be auto or disabled")
+	}
+
+	if params.FileSearch != nil {
+		if len(params.FileSearch.VectorStoreIDs) == 0 {
+			return nil, nil, fmt.Errorf("file_search needs at least one vector store name")
+		}
+
+		// Configs name the vector stores, every tenant maps the names to its own store IDs
+		vectorStores := aiKnowledge.VectorStores(openAiConfig)
+		for _, name := range params.FileSearch.VectorStoreIDs {
+			if name == "" {
+				return nil, nil, fmt.Errorf("file_search vector store names must not be empty")
+			}
+			if _, ok := vectorStores[name]; !ok {
+				warnings = append(warnings, fmt.Sprintf("vector store %s is not configured for this tenant", name))
+			}
+		}
+	}
+
+	// Function calls are executed by the registered tools, other names would fail when called
+	names := make(map[string]bool)
+	for i, function := range params.FunctionCalls {
+		if function.Name == "" {
+			return nil, nil, fmt.Errorf("function_calls[%d]: name is required", i)
+		}
+		if names[function.Name] {
+			return nil, nil, fmt.Errorf("function_calls[%d]: duplicate function %s", i, function.Name)
+		}
+		names[function.Name] = true
+
+		if _, ok := aiFunctions.GetTool(function.Name); !ok {
+			return nil, nil, fmt.Errorf("function_calls[%d]: unknown function %s", i, function.Name)
+		}
+		if function.Parameters != nil && function.Parameters["type"] != "object" {
+			return nil, nil, fmt.Errorf("function_calls[%d]: parameters must be a JSON schema of type object", i)
+		}
+	}
+
+	return &params, warnings, nil
+}
+
+func getAssistant(name string) (*models.Assistant, error) {
+	var assistant models.Assistant
+	err := tenantManagement.DB.QueryRow(`
+		SELECT name, config, is_active, version FROM st_schema.assistants WHERE name = $1
+	`, name).Scan(&assistant.Name, &assistant.Config, &assistant.IsActive, &assistant.Version)
+	if err != nil {
+		return nil, err
+	}
+	return &assistant, nil
+}
+
+// GetAssistantsHandler lists all assistants, inactive ones included
+func GetAssistantsHandler(c *gin.Context) {
+	rows, err := tenantManagement.DB.Query(`
+		SELECT name, config, is_active, version FROM st_schema.assistants ORDER BY name
+	`)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+	defer rows.Close()
+
+	assistants := []models.Assistant{}
+	for rows.Next() {
+		var assistant models.Assistant
+		if err := rows.Scan(&assistant.Name, &assistant.Config, &assistant.IsActive, &assistant.Version); err != nil {
+			log.Printf(models.DatabaseError, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+			return
+		}
+		assistants = append(assistants, assistant)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    assistants,
+		"message": "Assistants retrieved successfully!",
+	})
+}
+
+func GetAssistantHandler(c *gin.Context) {
+	name := c.Query("name")
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Assistant name is required"})
+		return
+	}
+
+	assistant, err := getAssistant(name)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Assistant not found"})
+		return
+	}
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    assistant,
+		"message": "Assistant retrieved successfully!",
+	})
+}
+
+// NewAssistantHandler creates an assistant with its config as version 1
+func NewAssistantHandler(c *gin.Context) {
+	userID, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	var req models.AssistantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if !assistantNamePattern.MatchString(req.Name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Assistant name must be 1-64 letters, digits, dashes or underscores"})
+		return
+	}
+
+	_, openAiConfig, err := GetOpenAiClient(tenantID)
+	if err != nil {
+		log.Printf("Failed to get OpenAI client: %v", err)
+	}
+	_, warnings, err := validateAssistantConfig(req.Config, openAiConfig)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	isActive := true
+	if req.IsActive != nil {
+		isActive = *req.IsActive
+	}
+	if req.Changelog == nil {
+		req.Changelog = utilities.Ptr("Initial version")
+	}
+
+	tx, err := tenantManagement.DB.Begin()
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO st_schema.assistants (name, config, is_active) VALUES ($1, $2, $3)
+	`, req.Name, []byte(req.Config), isActive)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Assistant with this name already exists"})
+			return
+		}
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	if _, err := aiVersions.CreateAssistantVersion(tx, req.Name, &userID, req.Changelog); err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	assistant, err := getAssistant(req.Name)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data":     assistant,
+		"warnings": warnings,
+		"message":  "Assistant created successfully!",
+	})
+}
+
+// UpdateAssistantHandler saves a new config version and/or (de)activates the assistant
+func UpdateAssistantHandler(c *gin.Context) {
+	userID, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	name := c.Query("name")
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Assistant name is required"})
+		return
+	}
+
+	var req models.AssistantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if req.Config == nil && req.IsActive == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No updatable fields provided"})
+		return
+	}
+
+	warnings := []string{}
+	if req.Config != nil {
+		_, openAiConfig, err := GetOpenAiClient(tenantID)
+		if err != nil {
+			log.Printf("Failed to get OpenAI client: %v", err)
+		}
+		if _, warnings, err = validateAssistantConfig(req.Config, openAiConfig); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	tx, err := tenantManagement.DB.Begin()
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+	defer tx.Rollback()
+
+	// A nil []byte is sent as an empty value, only an untyped nil keeps the config
+	var config any
+	if req.Config != nil {
+		config = []byte(req.Config)
+	}
+	result, err := tx.Exec(`
+		UPDATE st_schema.assistants
+		SET config = COALESCE($1, config), is_active = COALESCE($2, is_active)
+		WHERE name = $3
+	`, config, req.IsActive, name)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Assistant not found"})
+		return
+	}
+
+	if req.Config != nil {
+		if _, err := aiVersions.CreateAssistantVersion(tx, name, &userID, req.Changelog); err != nil {
+			log.Printf(models.DatabaseError, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	assistant, err := getAssistant(name)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":     assistant,
+		"warnings": warnings,
+		"message":  "Assistant updated successfully!",
+	})
+}
+
+// DeactivateAssistantHandler stops prompts from using the assistant. It is not deleted,
+// conversations keep their pinned versions and it can be activated again.
+func DeactivateAssistantHandler(c *gin.Context) {
+	name := c.Query("name")
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Assistant name is required"})
+		return
+	}
+
+	result, err := tenantManagement.DB.Exec(`UPDATE st_schema.assistants SET is_active = false WHERE name = $1`, name)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Assistant not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Assistant deactivated successfully!",
+	})
+}
+
+// AssistantDryRunHandler returns the exact params a prompt with the assistant would send, nothing is sent.
+// The params are built like NewPromptHandler builds them for a prompt without conversation.
+func AssistantDryRunHandler(c *gin.Context) {
+	userID, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	name := c.Query("name")
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Assistant name is required"})
+		return
+	}
+
+	var req models.AssistantDryRunRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+	}
+
+	assistant, err := getAssistant(name)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Assistant not found"})
+		return
+	}
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	rawConfig := assistant.Config
+	switch {
+	case req.Config != nil:
+		rawConfig = req.Config
+	case req.Version != nil:
+		version, err := aiVersions.GetAssistantVersion(name, *req.Version)
+		if errors.Is(err, aiVersions.ErrVersionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Assistant version not found"})
+			return
+		}
+		if err != nil {
+			log.Printf(models.DatabaseError, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+			return
+		}
+		rawConfig = version.Config
+	}
+
+	_, openAiConfig, err := GetOpenAiClient(tenantID)
+	if err != nil {
+		log.Printf("Failed to get OpenAI client: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	assistantParams, warnings, err := validateAssistantConfig(rawConfig, openAiConfig)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !assistant.IsActive {
+		warnings = append(warnings, "assistant is not active, prompts can't use it")
+	}
+
+	modelRoute := utilities.ResolveModelRoute(openAiConfig, models.AiTaskChat)
+	responseParams := getDefaultResponseParams(userID, req.Prompt, modelRoute.Primary)
+	mergeAssistantParams(responseParams, assistantParams, openAiConfig)
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"params":      responseParams,
+			"model_route": modelRoute.WithPrimary(string(responseParams.Model)),
+			"warnings":    warnings,
+		},
+		"message": "Assistant params built successfully!",
+	})
+}
@@ -0,0 +1,388 @@
+package ai
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	aiUsage "sententiawebapi/handlers/apis/ai/usage"
+	"sententiawebapi/handlers/apis/tenantManagement"
+	"sententiawebapi/handlers/models"
+	"sententiawebapi/utilities"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const defaultSoftThreshold = 80
+
+// Bounds of the numeric columns of st_schema.ai_budgets, values outside of them fail its checks
+const (
+	minMonthlyLimit = 0.01         // numeric(14, 2), smaller limits round to 0
+	maxMonthlyLimit = 999999999999 // numeric(14, 2)
+	maxThreshold    = 9999         // numeric(6, 2)
+)
+
+func validateBudgetRequest(req *models.AiBudgetRequest) error {
+	if !req.Scope.IsValid() {
+		return fmt.Errorf("invalid budget scope: %s", req.Scope)
+	}
+
+	hasScopeID := req.ScopeID != nil && *req.ScopeID != ""
+	if req.Scope == models.BudgetScopeTenant && hasScopeID {
+		return fmt.Errorf("tenant budget does not take a scope_id")
+	}
+	if req.Scope != models.BudgetScopeTenant && !hasScopeID {
+		return fmt.Errorf("scope_id is required for %s budgets", req.Scope)
+	}
+	// scope_id is the uuid of the user or the project
+	if hasScopeID {
+		if _, err := uuid.Parse(*req.ScopeID); err != nil {
+			return fmt.Errorf("scope_id must be a uuid")
+		}
+	}
+	if req.Scope == models.BudgetScopeTenant {
+		req.ScopeID = nil
+	}
+
+	if req.MonthlyLimit < minMonthlyLimit || req.MonthlyLimit > maxMonthlyLimit {
+		return fmt.Errorf("monthly_limit must be between %.2f and %d", minMonthlyLimit, maxMonthlyLimit)
+	}
+
+	if req.SoftThreshold == nil {
+		req.SoftThreshold = utilities.Ptr(float64(defaultSoftThreshold))
+	}
+	if *req.SoftThreshold <= 0 || *req.SoftThreshold > maxThreshold {
+		return fmt.Errorf("soft_threshold must be positive and at most %d", maxThreshold)
+	}
+	if req.HardThreshold != nil && *req.HardThreshold < *req.SoftThreshold {
+		return fmt.Errorf("hard_threshold must not be lower than soft_threshold")
+	}
+	if req.HardThreshold != nil && *req.HardThreshold > maxThreshold {
+		return fmt.Errorf("hard_threshold must be at most %d", maxThreshold)
+	}
+
+	return nil
+}
+
+func getBudget(tenantID string, budgetID string) (*models.AiBudget, error) {
+	rows, err := tenantManagement.DB.Query(aiUsage.BudgetSelect+`
+		WHERE b.id = $1 AND b.tenant_id = $2
+	`, budgetID, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	budgets, err := aiUsage.ScanBudgets(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(budgets) == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	return &budgets[0], nil
+}
+
+// GetAiBudgetsHandler lists the budgets of the tenant with the spending of the current month
+func GetAiBudgetsHandler(c *gin.Context) {
+	_, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	rows, err := tenantManagement.DB.Query(aiUsage.BudgetSelect+`
+		WHERE b.tenant_id = $1
+		ORDER BY b.scope, b.scope_id
+	`, tenantID)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+	defer rows.Close()
+
+	budgets, err := aiUsage.ScanBudgets(rows)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": budgets})
+}
+
+// NewAiBudgetHandler creates a monthly budget, each scope (tenant, user, project) has at most one
+func NewAiBudgetHandler(c *gin.Context) {
+	_, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	var req models.AiBudgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if err := validateBudgetRequest(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var budgetID string
+	err := tenantManagement.DB.QueryRow(`
+		INSERT INTO st_schema.ai_budgets (
+			tenant_id, scope, scope_id, monthly_limit, soft_threshold, hard_threshold, created_at, updated_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, NOW(), NOW()
+		) RETURNING id
+	`, tenantID, req.Scope, req.ScopeID, req.MonthlyLimit, *req.SoftThreshold, req.HardThreshold).Scan(&budgetID)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Budget for this scope already exists"})
+			return
+		}
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	budget, err := getBudget(tenantID, budgetID)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data":    budget,
+		"message": "Budget created successfully!",
+	})
+}
+
+// UpdateAiBudgetHandler changes the limit and the thresholds, the scope of a budget is fixed
+func UpdateAiBudgetHandler(c *gin.Context) {
+	_, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	budgetID := c.Query("id")
+	if budgetID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Budget ID is required"})
+		return
+	}
+
+	var req models.AiBudgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if err := validateBudgetRequest(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := tenantManagement.DB.Exec(`
+		UPDATE st_schema.ai_budgets
+		SET monthly_limit = $1, soft_threshold = $2, hard_threshold = $3, updated_at = NOW()
+		WHERE id = $4 AND tenant_id = $5 AND scope = $6 AND scope_id IS NOT DISTINCT FROM $7
+	`, req.MonthlyLimit, *req.SoftThreshold, req.HardThreshold, budgetID, tenantID, req.Scope, req.ScopeID)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Budget not found"})
+		return
+	}
+
+	budget, err := getBudget(tenantID, budgetID)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    budget,
+		"message": "Budget updated successfully!",
+	})
+}
+
+func DeleteAiBudgetHandler(c *gin.Context) {
+	_, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	budgetID := c.Query("id")
+	if budgetID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Budget ID is required"})
+		return
+	}
+
+	result, err := tenantManagement.DB.Exec(`
+		DELETE FROM st_schema.ai_budgets
+		WHERE id = $1 AND tenant_id = $2
+	`, budgetID, tenantID)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Budget not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Budget deleted successfully!"})
+}
+
+// GetModelPricesHandler returns the price catalogue used for the usage costs
+func GetModelPricesHandler(c *gin.Context) {
+	prices := aiUsage.ModelPrices()
+
+	data := make([]models.ModelPrice, 0, len(prices))
+	for _, price := range prices {
+		data = append(data, price)
+	}
+	sort.Slice(data, func(i, j int) bool { return data[i].Model < data[j].Model })
+
+	c.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+// Columns the usage report can be grouped by
+var usageReportGroups = map[string]string{
+	"user":    "u.user_id::text",
+	"model":   "u.ai_model",
+	"feature": "COALESCE(u.feature, '')",
+	"day":     "to_char(u.created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD')",
+}
+
+// AiUsageReportHandler sums the token usage and cost of the tenant.
+// Query: from, to (YYYY-MM-DD, default current month), group_by (comma separated: user, model, feature, day)
+func AiUsageReportHandler(c *gin.Context) {
+	_, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now().UTC()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := now.AddDate(0, 0, 1).Truncate(24 * time.Hour)
+
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date, expected YYYY-MM-DD"})
+			return
+		}
+		from = parsed
+	}
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date, expected YYYY-MM-DD"})
+			return
+		}
+		to = parsed.AddDate(0, 0, 1) // Inclusive
+	}
+
+	groupBy := []string{"user", "model", "feature", "day"}
+	if value := c.Query("group_by"); value != "" {
+		groupBy = strings.Split(value, ",")
+	}
+
+	selected := make(map[string]bool)
+	var groupColumns []string
+	for _, group := range groupBy {
+		group = strings.TrimSpace(group)
+		column, ok := usageReportGroups[group]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid group_by: %s", group)})
+			return
+		}
+		if !selected[group] {
+			selected[group] = true
+			groupColumns = append(groupColumns, column)
+		}
+	}
+
+	// Columns that are not grouped by stay NULL in every row
+	columnOrNull := func(group string) string {
+		if selected[group] {
+			return usageReportGroups[group]
+		}
+		return "NULL"
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			%s, %s, %s, %s,
+			COUNT(*),
+			COALESCE(SUM(u.prompt_tokens), 0),
+			COALESCE(SUM(u.cached_tokens), 0),
+			COALESCE(SUM(u.completion_tokens), 0),
+			COALESCE(SUM(u.cost), 0)
+		FROM st_schema.tenant_token_usage u
+		WHERE u.tenant_id = $1
+		AND u.created_at >= $2 AND u.created_at < $3
+		GROUP BY %s
+		ORDER BY 9 DESC
+	`,
+		columnOrNull("user"), columnOrNull("model"), columnOrNull("feature"), columnOrNull("day"),
+		strings.Join(groupColumns, ", "),
+	)
+
+	rows, err := tenantManagement.DB.Query(query, tenantID, from, to)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+	defer rows.Close()
+
+	report := []models.AiUsageReportRow{}
+	var total models.AiUsageReportRow
+	for rows.Next() {
+		var row models.AiUsageReportRow
+		if err := rows.Scan(
+			&row.UserID, &row.Model, &row.Feature, &row.Day,
+			&row.Requests, &row.PromptTokens, &row.CachedTokens, &row.CompletionTokens, &row.Cost,
+		); err != nil {
+			log.Printf(models.DatabaseError, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+			return
+		}
+
+		total.Requests += row.Requests
+		total.PromptTokens += row.PromptTokens
+		total.CachedTokens += row.CachedTokens
+		total.CompletionTokens += row.CompletionTokens
+		total.Cost += row.Cost
+
+		report = append(report, row)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":     report,
+		"total":    total,
+		"from":     from.Format(time.DateOnly),
+		"to":       to.AddDate(0, 0, -1).Format(time.DateOnly),
+		"group_by": groupBy,
+	})
+}
@@ -0,0 +1,67 @@
+package ai
+
+import (
+	"testing"
+
+	"sententiawebapi/handlers/models"
+	"sententiawebapi/utilities"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateBudgetRequest(t *testing.T) {
+	const projectID = "3f1c2a56-2b7e-4c1a-9d7e-0a4c5b6d7e8f"
+
+	tests := []struct {
+		name    string
+		req     models.AiBudgetRequest
+		wantErr string
+	}{
+		{
+			name: "tenant budget",
+			req:  models.AiBudgetRequest{Scope: models.BudgetScopeTenant, MonthlyLimit: 100},
+		},
+		{
+			name: "one cent",
+			req:  models.AiBudgetRequest{Scope: models.BudgetScopeProject, ScopeID: utilities.Ptr(projectID), MonthlyLimit: 0.01},
+		},
+		{
+			name:    "less than a cent",
+			req:     models.AiBudgetRequest{Scope: models.BudgetScopeTenant, MonthlyLimit: 0.001},
+			wantErr: "monthly_limit must be between",
+		},
+		{
+			name:    "too large for the column",
+			req:     models.AiBudgetRequest{Scope: models.BudgetScopeTenant, MonthlyLimit: 1e13},
+			wantErr: "monthly_limit must be between",
+		},
+		{
+			name:    "missing scope_id",
+			req:     models.AiBudgetRequest{Scope: models.BudgetScopeUser, MonthlyLimit: 10},
+			wantErr: "scope_id is required",
+		},
+		{
+			name:    "soft threshold too large",
+			req:     models.AiBudgetRequest{Scope: models.BudgetScopeTenant, MonthlyLimit: 10, SoftThreshold: utilities.Ptr(10000.0)},
+			wantErr: "soft_threshold must be positive",
+		},
+		{
+			name:    "hard threshold below soft threshold",
+			req:     models.AiBudgetRequest{Scope: models.BudgetScopeTenant, MonthlyLimit: 10, HardThreshold: utilities.Ptr(50.0)},
+			wantErr: "hard_threshold must not be lower",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateBudgetRequest(&tt.req)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package ai
+
+// OpenAI calls run with the request context, a closed browser tab or a timeout stops the
+// generation instead of letting it consume tokens nobody reads. Aborted generations are stored
+// in tenant_token_usage with status "cancelled".
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+	"unicode/utf8"
+
+	aiUsage "sententiawebapi/handlers/apis/ai/usage"
+	"sententiawebapi/handlers/models"
+
+	"github.com/openai/openai-go/responses"
+)
+
+const (
+	completionTimeout       = 3 * time.Minute  // One model round of a prompt, function calls have their own limit
+	magicianTimeout         = 2 * time.Minute  // Document magician text operations
+	databaseDesignTimeout   = 10 * time.Minute // Reasoning model over the whole database schema
+	streamGenerationTimeout = 15 * time.Minute // All rounds of a background (SSE) generation
+	evalRunTimeout          = 30 * time.Minute // All variants of a background template evaluation
+)
+
+// isCancellation reports whether the call failed because its context was cancelled or timed out
+func isCancellation(ctx context.Context, err error) bool {
+	if err == nil {
+		return false
+	}
+	return ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// estimateTokens approximates the token count of a text (~4 characters per token).
+// The API reports usage only for finished responses, aborted ones have to be estimated.
+func estimateTokens(text string) int32 {
+	return int32((utf8.RuneCountInString(text) + 3) / 4)
+}
+
+// estimateInputTokens counts the input sent with the request. History referenced by
+// previous_response_id is not known here, the estimate is a lower bound.
+func estimateInputTokens(params *responses.ResponseNewParams) int32 {
+	tokens := estimateTokens(params.Instructions.Value)
+
+	if params.Input.OfString.IsPresent() {
+		tokens += estimateTokens(params.Input.OfString.Value)
+	}
+	for _, item := range params.Input.OfInputItemList {
+		if item.OfFunctionCallOutput != nil {
+			tokens += estimateTokens(item.OfFunctionCallOutput.Output)
+		}
+	}
+
+	return tokens
+}
+
+// recordCancelledUsage stores the partial usage of an aborted generation,
+// partialOutput is the text streamed to the client before the abort
+func recordCancelledUsage(chatCtx *models.ChatContext, params *responses.ResponseNewParams, partialOutput string, cause error) {
+	log.Printf("AI generation cancelled for user %s: %v", chatCtx.UserID, cause)
+
+	usage := chatUsage(chatCtx)
+	usage.AiModel = string(params.Model)
+	usage.PromptTokens = estimateInputTokens(params)
+	usage.CompletionTokens = estimateTokens(partialOutput)
+	usage.Status = models.TokenUsageCancelled
+
+	err := aiUsage.Record(usage)
+	if err == nil {
+		log.Printf("Cancelled token usage stored successfully")
+	}
+}
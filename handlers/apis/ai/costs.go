@@ -0,0 +1,82 @@
+package ai
+
+// Every tenant_token_usage row gets a cost from the model price catalogue (aiUsage).
+// Monthly budgets (tenant, user, project) are checked before a model is called: the soft
+// threshold only warns (X-AI-Budget-Warning header), the hard threshold refuses the call.
+// Embeddings check the budgets themselves (aiUsage.ExhaustedBudget).
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	aiUsage "sententiawebapi/handlers/apis/ai/usage"
+	"sententiawebapi/handlers/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openai/openai-go/responses"
+)
+
+// chatProjectID returns the project of the chat, usage outside of projects has none
+func chatProjectID(chatCtx *models.ChatContext) *string {
+	if chatCtx.ResourceGroupType == models.ResourceGroupProject {
+		return chatCtx.ResourceGroupID
+	}
+	return nil
+}
+
+// chatUsage returns a usage row of the chat, the caller sets the model and the tokens
+func chatUsage(chatCtx *models.ChatContext) *models.TenantTokenUsageRequest {
+	return &models.TenantTokenUsageRequest{
+		TenantID:       chatCtx.TenantID,
+		UserID:         chatCtx.UserID,
+		ConversationID: chatCtx.ConversationID,
+		AiVendor:       "openai",
+		RequestedModel: chatCtx.ModelRoute.Primary,
+		Tools:          map[string]interface{}{},
+		Feature:        chatCtx.Feature,
+		ProjectID:      chatProjectID(chatCtx),
+	}
+}
+
+// responseUsage returns the usage row of a finished response
+func responseUsage(chatCtx *models.ChatContext, response *responses.Response) *models.TenantTokenUsageRequest {
+	usage := chatUsage(chatCtx)
+	usage.AiModel = response.Model
+	usage.PromptTokens = int32(response.Usage.InputTokens)
+	usage.CachedTokens = int32(response.Usage.InputTokensDetails.CachedTokens)
+	usage.CompletionTokens = int32(response.Usage.OutputTokens)
+	return usage
+}
+
+// checkAiBudget refuses the request with 402 when a hard threshold is reached.
+// Reached soft thresholds are reported in the X-AI-Budget-Warning header.
+func checkAiBudget(c *gin.Context, chatCtx *models.ChatContext) bool {
+	budgets, err := aiUsage.ApplicableBudgets(chatCtx.TenantID, chatCtx.UserID, chatProjectID(chatCtx))
+	if err != nil {
+		// Budgets protect from overspend, a failed check must not take the AI down
+		log.Printf("Failed to check AI budgets: %v", err)
+		return true
+	}
+
+	var warnings []string
+	for _, budget := range budgets {
+		switch budget.State {
+		case models.BudgetStateExceeded:
+			c.JSON(http.StatusPaymentRequired, gin.H{
+				"error":  fmt.Sprintf("Monthly AI budget of the %s is exhausted", budget.Scope),
+				"budget": budget,
+			})
+			return false
+		case models.BudgetStateWarning:
+			warnings = append(warnings, fmt.Sprintf("%s budget at %.0f%%", budget.Scope, budget.Percent))
+		}
+	}
+
+	if len(warnings) > 0 {
+		c.Header("X-AI-Budget-Warning", strings.Join(warnings, "; "))
+	}
+
+	return true
+}
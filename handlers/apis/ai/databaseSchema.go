@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	aiUsage "sententiawebapi/handlers/apis/ai/usage"
 	"sententiawebapi/handlers/apis/cloud"
 	"sententiawebapi/handlers/models"
 	"sententiawebapi/utilities"
@@ -206,7 +207,7 @@ const initialEdges = [];
 `
 
 // getSchemaMetadata runs the catalog query for one schema and returns per-column metadata.
-func getSchemaMetadata(db *sql.DB, schemaNames []string) ([]ColumnMeta, error) {
+func getSchemaMetadata(ctx context.Context, db *sql.DB, schemaNames []string) ([]ColumnMeta, error) {
 	var args []interface{}
 	var whereClause string
 
@@ -273,7 +274,7 @@ func getSchemaMetadata(db *sql.DB, schemaNames []string) ([]ColumnMeta, error) {
 		ORDER BY c.relname, a.attnum;
 	`, whereClause)
 
-	rows, err := db.Query(query, args...)
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("querying metadata: %w", err)
 	}
@@ -337,14 +338,21 @@ func buildSchemasJSON(cols []ColumnMeta) ([]byte, error) {
 	return json.Marshal(out)
 }
 
-func generateDiagram(tenantID string, userID string, schemaJSON []byte) (*string, error) {
-	client, _, err := GetOpenAiClient(tenantID)
+func generateDiagram(ctx context.Context, tenantID string, userID string, schemaJSON []byte) (*string, error) {
+	client, openAiConfig, err := GetOpenAiClient(tenantID)
 	if err != nil {
 		return nil, err
 	}
 
+	chatCtx := &models.ChatContext{
+		UserID:     userID,
+		TenantID:   tenantID,
+		ModelRoute: utilities.ResolveModelRoute(openAiConfig, models.AiTaskSchemaDesign),
+		Feature:    models.AiTaskSchemaDesign,
+	}
+
 	var params = &responses.ResponseNewParams{
-		Model:        "o3",
+		Model:        chatCtx.ModelRoute.Primary,
 		User:         openai.String(userID),
 		Instructions: openai.String(SYSTEM_INSTRUCTIONS),
 		// Temperature:     openai.Float(0.5),
@@ -355,10 +363,7 @@ func generateDiagram(tenantID string, userID string, schemaJSON []byte) (*string
 		},
 	}
 
-	response, err := client.Responses.New(
-		context.Background(),
-		*params,
-	)
+	response, err := newResponse(ctx, client, chatCtx, params, databaseDesignTimeout)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create chat response: %v", err)
 	}
@@ -366,15 +371,7 @@ func generateDiagram(tenantID string, userID string, schemaJSON []byte) (*string
 	defer func() {
 		log.Printf("Token usage: prompt=%d, completion=%d", response.Usage.InputTokens, response.Usage.OutputTokens)
 
-		err := newTokenUsageResource(&models.TenantTokenUsageRequest{
-			TenantID:         tenantID,
-			UserID:           userID,
-			AiVendor:         "openai",
-			AiModel:          response.Model,
-			Tools:            map[string]interface{}{},
-			PromptTokens:     int32(response.Usage.InputTokens),
-			CompletionTokens: int32(response.Usage.OutputTokens),
-		})
+		err := aiUsage.Record(responseUsage(chatCtx, response))
 		if err == nil {
 			log.Printf("Token usage stored successfully")
 		}
@@ -408,6 +405,10 @@ func GenerateDatabaseDesignHandler(c *gin.Context) {
 	}
 	log.Printf("Parsed payload in %v", time.Since(startParse))
 
+	if !checkAiBudget(c, &models.ChatContext{UserID: userID, TenantID: tenantID}) {
+		return
+	}
+
 	startConnect := time.Now()
 	db, err := cloud.ConnectToPostgres(tenantID, payload.CredentialID)
 	if err != nil {
@@ -419,7 +420,7 @@ func GenerateDatabaseDesignHandler(c *gin.Context) {
 	log.Printf("Connected to PostgreSQL in %v", time.Since(startConnect))
 
 	startMetadata := time.Now()
-	schemaData, err := getSchemaMetadata(db, payload.SchemaNames)
+	schemaData, err := getSchemaMetadata(c.Request.Context(), db, payload.SchemaNames)
 	if err != nil {
 		log.Printf("Failed to get schema metadata: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
@@ -445,7 +446,7 @@ func GenerateDatabaseDesignHandler(c *gin.Context) {
 	log.Printf("Built schema JSON in %v", time.Since(startBuild))
 
 	startGen := time.Now()
-	diagramJS, err := generateDiagram(tenantID, userID, schemaJSON)
+	diagramJS, err := generateDiagram(c.Request.Context(), tenantID, userID, schemaJSON)
 	if err != nil {
 		log.Printf("Failed to generate diagram: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
@@ -1,17 +1,16 @@
 package ai
 
 import (
-	"context"
 	"log"
 	"net/http"
-	"sententiawebapi/handlers/apis/tenantManagement"
+	aiRedaction "sententiawebapi/handlers/apis/ai/redaction"
+	aiUsage "sententiawebapi/handlers/apis/ai/usage"
 	"sententiawebapi/handlers/models"
 	"sententiawebapi/utilities"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/openai/openai-go"
-	"github.com/openai/openai-go/option"
 	"github.com/openai/openai-go/packages/param"
 	"github.com/openai/openai-go/responses"
 )
@@ -58,19 +57,17 @@ func streamChunkedResponse(c *gin.Context, text string, aiConfiguration *models.
 		return
 	}
 
-	openAiConfig, err := utilities.GetOpenAiConfig(tenantManagement.DB, tenantID)
+	client, openAiConfig, err := GetOpenAiClient(tenantID)
 	if err != nil {
-		log.Printf("Failed to get OpenAI config: %v", err)
+		log.Printf("Failed to get OpenAI client: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
 		return
 	}
 
-	client := openai.NewClient(
-		option.WithAPIKey(openAiConfig.OpenAIApiKey),
-	)
+	modelRoute := utilities.ResolveModelRoute(openAiConfig, models.AiTaskMagician)
 
 	var responseParams = &responses.ResponseNewParams{
-		Model:           "gpt-4o-mini",
+		Model:           modelRoute.Primary,
 		User:            openai.String(userID),
 		Temperature:     openai.Float(0.5),
 		TopP:            openai.Float(1),
@@ -81,7 +78,24 @@ func streamChunkedResponse(c *gin.Context, text string, aiConfiguration *models.
 	}
 	mergeAssistantParams(responseParams, aiConfiguration, nil)
 
-	stream := client.Responses.NewStreaming(context.Background(), *responseParams)
+	chatCtx := &models.ChatContext{
+		UserID:     userID,
+		TenantID:   tenantID,
+		ModelRoute: modelRoute.WithPrimary(string(responseParams.Model)),
+		Feature:    models.AiTaskMagician,
+	}
+
+	// The resource identifier is optional here, it assigns the usage to the project of the document
+	if resourceIdentifier, err := utilities.ResolveResourceIdentifier(c); err == nil {
+		chatCtx.ResourceIdentifier = *resourceIdentifier
+	}
+
+	if !checkAiBudget(c, chatCtx) {
+		return
+	}
+
+	useRedaction(chatCtx, openAiConfig, responseParams)
+	defer aiRedaction.RecordAudit(chatCtx)
 
 	// Set headers for chunked streaming
 	c.Header("Content-Type", "text/plain; charset=UTF-8") // Tiptap expects plain text streaming, even if it contains HTML.
@@ -98,53 +112,39 @@ func streamChunkedResponse(c *gin.Context, text string, aiConfiguration *models.
 		return
 	}
 
-	var started bool
-	var ended bool
-
 	// Stream out the response tokens one by one
-	for stream.Next() {
-		event := stream.Current()
-		token := event.Delta
-
-		if event.JSON.Text.IsPresent() {
-			ended = true
-		}
-
-		if outputType == OutputTypeHTML && !started && !ended {
+	var started bool
+	restorer := aiRedaction.NewStreamRestorer(chatCtx.Redactor)
+	response, streamErr := newStreamingResponse(c.Request.Context(), client, chatCtx, responseParams, magicianTimeout, func(token string) {
+		if outputType == OutputTypeHTML && !started {
 			writer.Write([]byte("<body>\n"))
 			started = true
 		}
 
-		if !ended {
-			writer.Write([]byte(token))
-			flusher.Flush()
-		}
-	}
-
-	if err := stream.Err(); err != nil {
-		log.Printf("Error during streaming: %v", err)
+		writer.Write([]byte(restorer.Write(token)))
+		flusher.Flush()
+	})
+	writer.Write([]byte(restorer.Flush()))
+	if streamErr != nil {
+		log.Printf("Error during streaming: %v", streamErr)
 	}
 
 	// Finalize the HTML output
 	if outputType == OutputTypeHTML {
+		if !started {
+			writer.Write([]byte("<body>\n"))
+		}
 		writer.Write([]byte("\n</body>"))
 		flusher.Flush()
 	}
 
-	// Save token usage after the stream is finished
+	// Save token usage after the stream is finished, cancelled and filtered attempts are already stored
 	defer func() {
-		response := stream.Current().Response
-
-		err := newTokenUsageResource(&models.TenantTokenUsageRequest{
-			TenantID:         tenantID,
-			UserID:           userID,
-			ConversationID:   nil,
-			AiVendor:         "openai",
-			AiModel:          response.Model,
-			Tools:            map[string]interface{}{},
-			PromptTokens:     int32(response.Usage.InputTokens),
-			CompletionTokens: int32(response.Usage.OutputTokens),
-		})
+		if streamErr != nil {
+			return
+		}
+
+		err := aiUsage.Record(responseUsage(chatCtx, response))
 		if err == nil {
 			log.Printf("Token usage stored successfully")
 			log.Printf("Total usage tokens: %v", response.Usage.TotalTokens)
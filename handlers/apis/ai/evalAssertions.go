@@ -0,0 +1,403 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	aiUsage "sententiawebapi/handlers/apis/ai/usage"
+	"sententiawebapi/handlers/models"
+	"sententiawebapi/utilities"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/responses"
+)
+
+const rubricPassScore = 0.7
+
+// validateEvalAssertions rejects assertions that could never be checked
+func validateEvalAssertions(assertions []models.EvalAssertion) error {
+	for i, assertion := range assertions {
+		if !assertion.Type.IsValid() {
+			return fmt.Errorf("assertion %d: invalid type %q", i+1, assertion.Type)
+		}
+
+		switch assertion.Type {
+		case models.EvalAssertionJSONSchema:
+			var schema map[string]any
+			if err := json.Unmarshal(assertion.Schema, &schema); err != nil {
+				return fmt.Errorf("assertion %d: schema must be a JSON object", i+1)
+			}
+			if err := checkSchemaKeywords(schema, "$"); err != nil {
+				return fmt.Errorf("assertion %d: %v", i+1, err)
+			}
+		case models.EvalAssertionRegex:
+			if _, err := regexp.Compile(assertion.Value); err != nil {
+				return fmt.Errorf("assertion %d: invalid regular expression: %v", i+1, err)
+			}
+		default:
+			if strings.TrimSpace(assertion.Value) == "" {
+				return fmt.Errorf("assertion %d: value is required", i+1)
+			}
+		}
+	}
+	return nil
+}
+
+// checkEvalAssertion checks one expectation on the output, rubric assertions are graded by grade
+func checkEvalAssertion(assertion models.EvalAssertion, output string, grade func(rubric string) models.EvalAssertionResult) models.EvalAssertionResult {
+	result := models.EvalAssertionResult{Type: assertion.Type}
+
+	switch assertion.Type {
+	case models.EvalAssertionContains, models.EvalAssertionNotContains:
+		haystack, needle := output, assertion.Value
+		if !assertion.CaseSensitive {
+			haystack, needle = strings.ToLower(haystack), strings.ToLower(needle)
+		}
+		found := strings.Contains(haystack, needle)
+
+		result.Passed = found == (assertion.Type == models.EvalAssertionContains)
+		if !result.Passed && found {
+			result.Detail = fmt.Sprintf("output contains %q", assertion.Value)
+		} else if !result.Passed {
+			result.Detail = fmt.Sprintf("output doesn't contain %q", assertion.Value)
+		}
+
+	case models.EvalAssertionRegex:
+		pattern := assertion.Value
+		if !assertion.CaseSensitive {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			result.Detail = err.Error()
+			break
+		}
+		result.Passed = re.MatchString(output)
+		if !result.Passed {
+			result.Detail = "output doesn't match the regular expression"
+		}
+
+	case models.EvalAssertionJSONSchema:
+		var schema map[string]any
+		if err := json.Unmarshal(assertion.Schema, &schema); err != nil {
+			result.Detail = "invalid schema"
+			break
+		}
+		var document any
+		if err := json.Unmarshal([]byte(extractJSON(output)), &document); err != nil {
+			result.Detail = fmt.Sprintf("output is not JSON: %v", err)
+			break
+		}
+		if err := validateJSONSchema(schema, document, "$"); err != nil {
+			result.Detail = err.Error()
+			break
+		}
+		result.Passed = true
+
+	case models.EvalAssertionRubric:
+		return grade(assertion.Value)
+	}
+
+	return result
+}
+
+// extractJSON strips a markdown code fence around the JSON of the output
+func extractJSON(output string) string {
+	text := strings.TrimSpace(output)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```json")
+		text = strings.TrimPrefix(text, "```")
+		text = strings.TrimSuffix(strings.TrimSpace(text), "```")
+	}
+	return strings.TrimSpace(text)
+}
+
+// Keywords checked by validateJSONSchema, annotations don't constrain the document
+var (
+	supportedSchemaKeywords = map[string]bool{
+		"type": true, "enum": true, "const": true, "properties": true, "required": true,
+		"additionalProperties": true, "items": true, "minItems": true, "maxItems": true,
+		"minLength": true, "maxLength": true, "pattern": true, "minimum": true, "maximum": true,
+	}
+	schemaAnnotations = map[string]bool{
+		"$schema": true, "$comment": true, "title": true, "description": true, "default": true, "examples": true,
+	}
+)
+
+// checkSchemaKeywords rejects schemas with keywords validateJSONSchema doesn't check,
+// an assertion using them would pass without being checked
+func checkSchemaKeywords(schema map[string]any, path string) error {
+	for keyword, value := range schema {
+		if schemaAnnotations[keyword] {
+			continue
+		}
+		if !supportedSchemaKeywords[keyword] {
+			return fmt.Errorf("%s: unsupported schema keyword %q", path, keyword)
+		}
+
+		switch keyword {
+		case "properties":
+			properties, ok := value.(map[string]any)
+			if !ok {
+				return fmt.Errorf("%s: properties must be an object", path)
+			}
+			for name, property := range properties {
+				propertySchema, ok := property.(map[string]any)
+				if !ok {
+					return fmt.Errorf("%s.%s: schema must be an object", path, name)
+				}
+				if err := checkSchemaKeywords(propertySchema, path+"."+name); err != nil {
+					return err
+				}
+			}
+		case "items":
+			items, ok := value.(map[string]any)
+			if !ok {
+				return fmt.Errorf("%s: items must be a schema object", path)
+			}
+			if err := checkSchemaKeywords(items, path+"[]"); err != nil {
+				return err
+			}
+		case "additionalProperties":
+			if _, ok := value.(bool); !ok {
+				return fmt.Errorf("%s: additionalProperties must be true or false", path)
+			}
+		case "pattern":
+			pattern, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("%s: pattern must be a string", path)
+			}
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("%s: invalid pattern: %v", path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateJSONSchema checks the document against the commonly used subset of JSON Schema:
+// type, enum, const, properties, required, additionalProperties, items, pattern and the length,
+// size and range keywords. Schemas with other keywords are rejected by validateEvalAssertions.
+func validateJSONSchema(schema map[string]any, document any, path string) error {
+	if expected, ok := schema["type"]; ok && !matchesSchemaType(expected, document) {
+		return fmt.Errorf("%s: expected type %v", path, expected)
+	}
+
+	if values, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, value := range values {
+			if jsonEqual(value, document) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: value is not one of %v", path, values)
+		}
+	}
+	if value, ok := schema["const"]; ok && !jsonEqual(value, document) {
+		return fmt.Errorf("%s: value must be %v", path, value)
+	}
+
+	switch value := document.(type) {
+	case map[string]any:
+		if required, ok := schema["required"].([]any); ok {
+			for _, name := range required {
+				if _, ok := value[fmt.Sprint(name)]; !ok {
+					return fmt.Errorf("%s: missing required property %q", path, name)
+				}
+			}
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		for name, property := range value {
+			propertySchema, ok := properties[name].(map[string]any)
+			if !ok {
+				if additional, ok := schema["additionalProperties"].(bool); ok && !additional {
+					return fmt.Errorf("%s: unexpected property %q", path, name)
+				}
+				continue
+			}
+			if err := validateJSONSchema(propertySchema, property, path+"."+name); err != nil {
+				return err
+			}
+		}
+
+	case []any:
+		if minItems, ok := schemaNumber(schema, "minItems"); ok && float64(len(value)) < minItems {
+			return fmt.Errorf("%s: expected at least %v items", path, minItems)
+		}
+		if maxItems, ok := schemaNumber(schema, "maxItems"); ok && float64(len(value)) > maxItems {
+			return fmt.Errorf("%s: expected at most %v items", path, maxItems)
+		}
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range value {
+				if err := validateJSONSchema(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+
+	case string:
+		length := float64(len([]rune(value)))
+		if minLength, ok := schemaNumber(schema, "minLength"); ok && length < minLength {
+			return fmt.Errorf("%s: expected at least %v characters", path, minLength)
+		}
+		if maxLength, ok := schemaNumber(schema, "maxLength"); ok && length > maxLength {
+			return fmt.Errorf("%s: expected at most %v characters", path, maxLength)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("%s: invalid pattern in schema: %v", path, err)
+			}
+			if !re.MatchString(value) {
+				return fmt.Errorf("%s: value doesn't match %s", path, pattern)
+			}
+		}
+
+	case float64:
+		if minimum, ok := schemaNumber(schema, "minimum"); ok && value < minimum {
+			return fmt.Errorf("%s: expected at least %v", path, minimum)
+		}
+		if maximum, ok := schemaNumber(schema, "maximum"); ok && value > maximum {
+			return fmt.Errorf("%s: expected at most %v", path, maximum)
+		}
+	}
+
+	return nil
+}
+
+func matchesSchemaType(expected any, document any) bool {
+	switch types := expected.(type) {
+	case string:
+		return isSchemaType(types, document)
+	case []any:
+		for _, t := range types {
+			if name, ok := t.(string); ok && isSchemaType(name, document) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func isSchemaType(name string, document any) bool {
+	switch value := document.(type) {
+	case nil:
+		return name == "null"
+	case bool:
+		return name == "boolean"
+	case string:
+		return name == "string"
+	case float64:
+		return name == "number" || (name == "integer" && value == math.Trunc(value))
+	case []any:
+		return name == "array"
+	case map[string]any:
+		return name == "object"
+	}
+	return false
+}
+
+func schemaNumber(schema map[string]any, keyword string) (float64, bool) {
+	value, ok := schema[keyword].(float64)
+	return value, ok
+}
+
+func jsonEqual(a any, b any) bool {
+	aJSON, errA := json.Marshal(a)
+	bJSON, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(aJSON) == string(bJSON)
+}
+
+var rubricGraderInstructions = lines(
+	"You grade answers of an AI assistant against a rubric written by the author of the assistant.",
+	"Judge only what the rubric asks for, not your own preferences.",
+	"Score from 0 (fails the rubric) to 1 (fully meets it) and explain the score in one or two sentences.",
+)
+
+var rubricGradeSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"score":     map[string]any{"type": "number"},
+		"reasoning": map[string]any{"type": "string"},
+	},
+	"required":             []string{"score", "reasoning"},
+	"additionalProperties": false,
+}
+
+// gradeRubric lets the evaluation model grade the output, the grading usage is stored like any other
+// and returned so it counts towards the test case. The usage is nil when the model wasn't answering.
+func gradeRubric(ctx context.Context, client *openai.Client, openAiConfig *models.OpenAiConfig, chatCtx *models.ChatContext, prompt string, output string, rubric string) (models.EvalAssertionResult, *models.TenantTokenUsageRequest) {
+	result := models.EvalAssertionResult{Type: models.EvalAssertionRubric}
+
+	route := utilities.ResolveModelRoute(openAiConfig, models.AiTaskEvaluation)
+	graderCtx := &models.ChatContext{
+		UserID:             chatCtx.UserID,
+		TenantID:           chatCtx.TenantID,
+		ModelRoute:         route,
+		Feature:            models.AiTaskEvaluation,
+		ResourceIdentifier: chatCtx.ResourceIdentifier,
+		Redactor:           chatCtx.Redactor,
+	}
+
+	// The grader sees the same redacted text as the graded model
+	redact := func(text string) string {
+		if chatCtx.Redactor == nil {
+			return text
+		}
+		return chatCtx.Redactor.Redact(text)
+	}
+
+	params := &responses.ResponseNewParams{
+		Model:        route.Primary,
+		User:         openai.String(chatCtx.UserID),
+		Instructions: openai.String(rubricGraderInstructions),
+		Temperature:  openai.Float(0), // Left out for reasoning models, see attemptParams
+		Input: responses.ResponseNewParamsInputUnion{
+			OfString: openai.String(lines(
+				"Rubric:", rubric, "",
+				"User prompt:", redact(prompt), "",
+				"Answer:", redact(output),
+			)),
+		},
+		Text: responses.ResponseTextConfigParam{
+			Format: responses.ResponseFormatTextConfigUnionParam{
+				OfJSONSchema: &responses.ResponseFormatTextJSONSchemaConfigParam{
+					Name:   "rubric_grade",
+					Schema: rubricGradeSchema,
+					Strict: openai.Bool(true),
+				},
+			},
+		},
+	}
+
+	response, err := newResponse(ctx, client, graderCtx, params, completionTimeout)
+	if err != nil {
+		result.Detail = fmt.Sprintf("grading failed: %v", err)
+		return result, nil
+	}
+	usage := responseUsage(graderCtx, response)
+	aiUsage.Record(usage)
+
+	var grade struct {
+		Score     float64 `json:"score"`
+		Reasoning string  `json:"reasoning"`
+	}
+	if err := json.Unmarshal([]byte(response.OutputText()), &grade); err != nil {
+		result.Detail = fmt.Sprintf("invalid grade: %v", err)
+		return result, usage
+	}
+
+	score := math.Max(0, math.Min(1, grade.Score))
+	result.Score = &score
+	result.Passed = score >= rubricPassScore
+	result.Detail = restoreText(chatCtx, grade.Reasoning)
+
+	return result, usage
+}
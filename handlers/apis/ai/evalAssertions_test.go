@@ -0,0 +1,120 @@
+package ai
+
+import (
+	"encoding/json"
+	"testing"
+
+	"sententiawebapi/handlers/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateJSONSchema(t *testing.T) {
+	const person = `{
+		"type": "object",
+		"required": ["name", "age"],
+		"additionalProperties": false,
+		"properties": {
+			"name": {"type": "string", "minLength": 2, "maxLength": 5},
+			"age": {"type": "integer", "minimum": 0, "maximum": 150},
+			"role": {"enum": ["admin", "member"]},
+			"kind": {"const": "person"},
+			"email": {"type": ["string", "null"], "pattern": "^[^@]+@[^@]+$"},
+			"tags": {"type": "array", "minItems": 1, "maxItems": 2, "items": {"type": "string"}}
+		}
+	}`
+
+	tests := []struct {
+		name     string
+		schema   string
+		document string
+		wantErr  string
+	}{
+		{"valid", person, `{"name":"Ada","age":36,"role":"admin","kind":"person","email":"ada@example.com","tags":["math"]}`, ""},
+		{"nullable property", person, `{"name":"Ada","age":36,"email":null}`, ""},
+		{"wrong root type", person, `["Ada"]`, "$: expected type object"},
+		{"missing required property", person, `{"name":"Ada"}`, `$: missing required property "age"`},
+		{"unexpected property", person, `{"name":"Ada","age":36,"nickname":"A"}`, `$: unexpected property "nickname"`},
+		{"integer with a fraction", person, `{"name":"Ada","age":36.5}`, "$.age: expected type integer"},
+		{"below the minimum", person, `{"name":"Ada","age":-1}`, "$.age: expected at least 0"},
+		{"above the maximum", person, `{"name":"Ada","age":200}`, "$.age: expected at most 150"},
+		{"too short", person, `{"name":"A","age":36}`, "$.name: expected at least 2 characters"},
+		{"length counts characters", person, `{"name":"Zoë","age":36}`, ""},
+		{"too long", person, `{"name":"Adaline","age":36}`, "$.name: expected at most 5 characters"},
+		{"not in the enum", person, `{"name":"Ada","age":36,"role":"owner"}`, "$.role: value is not one of [admin member]"},
+		{"wrong const", person, `{"name":"Ada","age":36,"kind":"robot"}`, "$.kind: value must be person"},
+		{"pattern mismatch", person, `{"name":"Ada","age":36,"email":"ada"}`, "$.email: value doesn't match ^[^@]+@[^@]+$"},
+		{"too few items", person, `{"name":"Ada","age":36,"tags":[]}`, "$.tags: expected at least 1 items"},
+		{"too many items", person, `{"name":"Ada","age":36,"tags":["a","b","c"]}`, "$.tags: expected at most 2 items"},
+		{"invalid item", person, `{"name":"Ada","age":36,"tags":["a",1]}`, "$.tags[1]: expected type string"},
+		{"empty schema accepts anything", `{}`, `[1,"a",null]`, ""},
+		{"unknown keywords are ignored", `{"type":"string","format":"email"}`, `"not an email"`, ""},
+		{"additional properties allowed by default", `{"type":"object","properties":{"a":{"type":"number"}}}`, `{"a":1,"b":"x"}`, ""},
+		{"nested objects", `{"properties":{"a":{"properties":{"b":{"type":"boolean"}}}}}`, `{"a":{"b":"yes"}}`, "$.a.b: expected type boolean"},
+		{"enum of objects", `{"enum":[{"a":1}]}`, `{"a":1}`, ""},
+		{"invalid pattern in the schema", `{"pattern":"("}`, `"a"`, "$: invalid pattern in schema"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var schema map[string]any
+			var document any
+			assert.NoError(t, json.Unmarshal([]byte(tt.schema), &schema))
+			assert.NoError(t, json.Unmarshal([]byte(tt.document), &document))
+
+			err := validateJSONSchema(schema, document, "$")
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateEvalAssertionsSchemaKeywords(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		wantErr string
+	}{
+		{"supported keywords", `{"type":"object","required":["a"],"properties":{"a":{"type":"string","pattern":"^a"}}}`, ""},
+		{"annotations", `{"title":"Person","description":"A person","type":"object"}`, ""},
+		{"unsupported keyword", `{"oneOf":[{"type":"string"}]}`, `$: unsupported schema keyword "oneOf"`},
+		{"reference", `{"$ref":"#/definitions/a"}`, `$: unsupported schema keyword "$ref"`},
+		{"nested unsupported keyword", `{"properties":{"a":{"allOf":[]}}}`, `$.a: unsupported schema keyword "allOf"`},
+		{"unsupported keyword of items", `{"items":{"format":"email"}}`, `$[]: unsupported schema keyword "format"`},
+		{"tuple items", `{"items":[{"type":"string"}]}`, "$: items must be a schema object"},
+		{"additional properties schema", `{"additionalProperties":{"type":"string"}}`, "$: additionalProperties must be true or false"},
+		{"invalid pattern", `{"pattern":"("}`, "$: invalid pattern"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateEvalAssertions([]models.EvalAssertion{
+				{Type: models.EvalAssertionJSONSchema, Schema: json.RawMessage(tt.schema)},
+			})
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExtractJSON(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{`{"a":1}`, `{"a":1}`},
+		{"  {\"a\":1}\n", `{"a":1}`},
+		{"```json\n{\"a\":1}\n```", `{"a":1}`},
+		{"```\n[1,2]\n```", `[1,2]`},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, extractJSON(tt.output))
+	}
+}
@@ -0,0 +1,1001 @@
+package ai
+
+// Test cases of tenant AI templates and their evaluation runs. A run sends every test case
+// of the template through the same request building as a chat prompt (template config,
+// template variables, read-only tools, redaction) and checks the assertions of the case on
+// the answer. Runs of different configurations can be compared case by case. Runs are
+// evaluated in the background, they are stored as running and completed with their results.
+// A tenant has at most evalMaxRunningRuns running runs, runs interrupted by a restart are
+// failed when the runs of the tenant are read or started again.
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	aiFunctions "sententiawebapi/handlers/apis/ai/functions"
+	aiRedaction "sententiawebapi/handlers/apis/ai/redaction"
+	aiUsage "sententiawebapi/handlers/apis/ai/usage"
+	aiVersions "sententiawebapi/handlers/apis/ai/versions"
+	"sententiawebapi/handlers/apis/tenantManagement"
+	"sententiawebapi/handlers/models"
+	"sententiawebapi/utilities"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/responses"
+)
+
+const (
+	evalMaxRounds      = 5 // Model rounds of one test case, including the function call rounds
+	evalConcurrency    = 4 // Test cases of a run evaluated at once
+	evalMaxTestCases   = 50
+	evalMaxRunVariants = 4
+	evalMaxRunningRuns = 4 // Runs of a tenant evaluated at once
+
+	// Runs still running this long after they were started were interrupted by a restart,
+	// runEvaluations gives up after evalRunTimeout
+	staleEvalRunAfter = evalRunTimeout + 5*time.Minute
+)
+
+var (
+	errTemplateNotFound = errors.New("template not found")
+	errTooManyEvalRuns  = errors.New("too many running evaluation runs")
+)
+
+// getTemplateConfiguration returns the configuration of a template of the tenant
+func getTemplateConfiguration(tenantID string, templateID string) (*models.AiConfiguration, error) {
+	var rawConfig []byte
+	err := tenantManagement.DB.QueryRow(`
+		SELECT configuration
+		FROM st_schema.prompt_config_template
+		WHERE id = $1 AND tenant_id = $2
+	`, templateID, tenantID).Scan(&rawConfig)
+	if err == sql.ErrNoRows {
+		return nil, errTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var config models.AiConfiguration
+	if err := json.Unmarshal(rawConfig, &config); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+func getTemplateTestCases(tenantID string, templateID string) ([]models.AiTemplateTestCase, error) {
+	rows, err := tenantManagement.DB.Query(`
+		SELECT id, template_id, tenant_id, name, prompt, project_id, assertions, created_at, updated_at
+		FROM st_schema.ai_template_test_cases
+		WHERE template_id = $1 AND tenant_id = $2
+		ORDER BY created_at
+	`, templateID, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	testCases := []models.AiTemplateTestCase{}
+	for rows.Next() {
+		var testCase models.AiTemplateTestCase
+		var assertionsJSON []byte
+		if err := rows.Scan(
+			&testCase.ID, &testCase.TemplateID, &testCase.TenantID, &testCase.Name, &testCase.Prompt,
+			&testCase.ProjectID, &assertionsJSON, &testCase.CreatedAt, &testCase.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(assertionsJSON, &testCase.Assertions); err != nil {
+			return nil, err
+		}
+		testCases = append(testCases, testCase)
+	}
+
+	return testCases, rows.Err()
+}
+
+// bindTestCase binds and validates the test case body, the response is written on failure
+func bindTestCase(c *gin.Context) (*models.AiTemplateTestCaseRequest, []byte, bool) {
+	var body models.AiTemplateTestCaseRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return nil, nil, false
+	}
+
+	if err := validateEvalAssertions(body.Assertions); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, nil, false
+	}
+
+	assertionsJSON, err := json.Marshal(body.Assertions)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return nil, nil, false
+	}
+
+	return &body, assertionsJSON, true
+}
+
+func GetTemplateTestCasesHandler(c *gin.Context) {
+	_, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	templateID := c.Query("template_id")
+	if templateID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Template ID is required"})
+		return
+	}
+
+	testCases, err := getTemplateTestCases(tenantID, templateID)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    testCases,
+		"message": "Test cases retrieved successfully!",
+	})
+}
+
+func NewTemplateTestCaseHandler(c *gin.Context) {
+	_, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	templateID := c.Query("template_id")
+	if templateID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Template ID is required"})
+		return
+	}
+
+	body, assertionsJSON, ok := bindTestCase(c)
+	if !ok {
+		return
+	}
+
+	if _, err := getTemplateConfiguration(tenantID, templateID); errors.Is(err, errTemplateNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	} else if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	var count int
+	err := tenantManagement.DB.QueryRow(`
+		SELECT COUNT(*) FROM st_schema.ai_template_test_cases WHERE template_id = $1 AND tenant_id = $2
+	`, templateID, tenantID).Scan(&count)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+	if count >= evalMaxTestCases {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A template can have at most %d test cases", evalMaxTestCases)})
+		return
+	}
+
+	testCase := models.AiTemplateTestCase{
+		TemplateID: templateID,
+		TenantID:   tenantID,
+		Name:       body.Name,
+		Prompt:     body.Prompt,
+		ProjectID:  body.ProjectID,
+		Assertions: body.Assertions,
+	}
+
+	err = tenantManagement.DB.QueryRow(`
+		INSERT INTO st_schema.ai_template_test_cases (template_id, tenant_id, name, prompt, project_id, assertions)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at
+	`, templateID, tenantID, body.Name, body.Prompt, body.ProjectID, assertionsJSON).Scan(
+		&testCase.ID, &testCase.CreatedAt, &testCase.UpdatedAt,
+	)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data":    testCase,
+		"message": "Test case created successfully!",
+	})
+}
+
+func UpdateTemplateTestCaseHandler(c *gin.Context) {
+	_, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	testCaseID := c.Query("id")
+	if testCaseID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Test case ID is required"})
+		return
+	}
+
+	body, assertionsJSON, ok := bindTestCase(c)
+	if !ok {
+		return
+	}
+
+	testCase := models.AiTemplateTestCase{
+		ID:         testCaseID,
+		TenantID:   tenantID,
+		Name:       body.Name,
+		Prompt:     body.Prompt,
+		ProjectID:  body.ProjectID,
+		Assertions: body.Assertions,
+	}
+
+	err := tenantManagement.DB.QueryRow(`
+		UPDATE st_schema.ai_template_test_cases
+		SET name = $1, prompt = $2, project_id = $3, assertions = $4, updated_at = NOW()
+		WHERE id = $5 AND tenant_id = $6
+		RETURNING template_id, created_at, updated_at
+	`, body.Name, body.Prompt, body.ProjectID, assertionsJSON, testCaseID, tenantID).Scan(
+		&testCase.TemplateID, &testCase.CreatedAt, &testCase.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Test case not found"})
+		return
+	}
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    testCase,
+		"message": "Test case updated successfully!",
+	})
+}
+
+func DeleteTemplateTestCaseHandler(c *gin.Context) {
+	_, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	testCaseID := c.Query("id")
+	if testCaseID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Test case ID is required"})
+		return
+	}
+
+	result, err := tenantManagement.DB.Exec(`
+		DELETE FROM st_schema.ai_template_test_cases WHERE id = $1 AND tenant_id = $2
+	`, testCaseID, tenantID)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Test case not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Test case deleted successfully!"})
+}
+
+// evalRunner evaluates the test cases of a template with one configuration
+type evalRunner struct {
+	client       *openai.Client
+	openAiConfig *models.OpenAiConfig
+	userID       string
+	tenantID     string
+	config       *models.AiConfiguration
+}
+
+// runCase answers the prompt of the test case and checks its assertions
+func (r *evalRunner) runCase(ctx context.Context, testCase models.AiTemplateTestCase) models.AiEvalResult {
+	result := models.AiEvalResult{
+		CaseID:     testCase.ID,
+		CaseName:   testCase.Name,
+		Assertions: []models.EvalAssertionResult{},
+	}
+
+	chatCtx := &models.ChatContext{
+		UserID:   r.userID,
+		TenantID: r.tenantID,
+		Feature:  models.AiTaskEvaluation,
+		// Tool switches of the evaluated config
+		ToolSettings: r.config.Tools,
+	}
+	if testCase.ProjectID != nil {
+		chatCtx.ResourceIdentifier = models.ResourceIdentifier{
+			ResourceGroupType: models.ResourceGroupProject,
+			ResourceGroupID:   testCase.ProjectID,
+		}
+	}
+
+	modelRoute := utilities.ResolveModelRoute(r.openAiConfig, models.AiTaskChat)
+	params := getDefaultResponseParams(r.userID, testCase.Prompt, modelRoute.Primary)
+	useTemplateConfig(ctx, chatCtx, r.config, params)
+	chatCtx.ModelRoute = modelRoute
+
+	// Function call rounds continue the stored response, mutating tools could not be approved
+	params.Store = openai.Bool(true)
+	params.Tools = aiFunctions.GetReadOnlyFunctionDefinitions(chatCtx)
+
+	useRedaction(chatCtx, r.openAiConfig, params)
+	defer aiRedaction.RecordAudit(chatCtx)
+
+	started := time.Now()
+	var response *responses.Response
+	for round := 0; ; round++ {
+		if round == evalMaxRounds {
+			message := "too many function call rounds"
+			result.Error = &message
+			break
+		}
+
+		var err error
+		response, err = newResponse(ctx, r.client, chatCtx, params, completionTimeout)
+		if err != nil {
+			message := fmt.Sprintf("prompt failed: %v", err)
+			result.Error = &message
+			break
+		}
+
+		usage := responseUsage(chatCtx, response)
+		aiUsage.Record(usage)
+		result.Model = response.Model
+		result.PromptTokens += response.Usage.InputTokens
+		result.CompletionTokens += response.Usage.OutputTokens
+		result.Cost += aiUsage.CalculateCost(usage.AiModel, usage.PromptTokens, usage.CachedTokens, usage.CompletionTokens)
+
+		functionOutputs, _, err := aiFunctions.ExecuteFunctionCallsParallel(ctx, r.client, chatCtx, response.ID, response.Output)
+		if err != nil {
+			message := fmt.Sprintf("function call failed: %v", err)
+			result.Error = &message
+			break
+		}
+		if len(functionOutputs) == 0 {
+			break
+		}
+
+		params.PreviousResponseID = openai.String(response.ID)
+		params.Input = responses.ResponseNewParamsInputUnion{OfInputItemList: functionOutputs}
+	}
+	result.LatencyMs = time.Since(started).Milliseconds()
+
+	if result.Error != nil {
+		return result
+	}
+
+	result.Output = restoreText(chatCtx, response.OutputText())
+
+	// Grading is part of the cost of the case
+	grade := func(rubric string) models.EvalAssertionResult {
+		assertionResult, usage := gradeRubric(ctx, r.client, r.openAiConfig, chatCtx, testCase.Prompt, result.Output, rubric)
+		if usage != nil {
+			result.PromptTokens += int64(usage.PromptTokens)
+			result.CompletionTokens += int64(usage.CompletionTokens)
+			result.Cost += aiUsage.CalculateCost(usage.AiModel, usage.PromptTokens, usage.CachedTokens, usage.CompletionTokens)
+		}
+		return assertionResult
+	}
+
+	result.Passed = true
+	for _, assertion := range testCase.Assertions {
+		assertionResult := checkEvalAssertion(assertion, result.Output, grade)
+		result.Assertions = append(result.Assertions, assertionResult)
+		result.Passed = result.Passed && assertionResult.Passed
+	}
+
+	return result
+}
+
+// run evaluates all test cases and fills in the results and totals of the run, results keep
+// the order of the cases
+func (r *evalRunner) run(ctx context.Context, run *models.AiEvalRun, testCases []models.AiTemplateTestCase) {
+	run.Status = models.EvalRunCompleted
+	run.Results = make([]models.AiEvalResult, len(testCases))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, evalConcurrency)
+	for i, testCase := range testCases {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// A panicking case fails on its own, the other cases and the run go on
+			defer func() {
+				if p := recover(); p != nil {
+					log.Printf("Template evaluation case %s panicked: %v", testCase.ID, p)
+					message := fmt.Sprintf("evaluation panicked: %v", p)
+					run.Results[i] = models.AiEvalResult{
+						CaseID:     testCase.ID,
+						CaseName:   testCase.Name,
+						Assertions: []models.EvalAssertionResult{},
+						Error:      &message,
+					}
+				}
+			}()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			run.Results[i] = r.runCase(ctx, testCase)
+		}()
+	}
+	wg.Wait()
+
+	for _, result := range run.Results {
+		if result.Passed {
+			run.Passed++
+		} else {
+			run.Failed++
+		}
+		if result.Model != "" {
+			run.Model = result.Model
+		}
+		run.PromptTokens += result.PromptTokens
+		run.CompletionTokens += result.CompletionTokens
+		run.Cost += result.Cost
+	}
+
+	if ctx.Err() != nil {
+		run.Status = models.EvalRunFailed
+	}
+}
+
+// createEvalRuns stores the runs as running, before any test case is evaluated.
+// Returns errTooManyEvalRuns when the runs would exceed the running runs of the tenant.
+func createEvalRuns(tenantID string, runs []*models.AiEvalRun) error {
+	tx, err := tenantManagement.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // Will be no-op if transaction is committed
+
+	// Concurrent requests of the tenant are counted one after another
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, "ai_template_eval_runs:"+tenantID); err != nil {
+		return err
+	}
+
+	var running int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM st_schema.ai_template_eval_runs WHERE tenant_id = $1 AND status = $2
+	`, tenantID, models.EvalRunRunning).Scan(&running)
+	if err != nil {
+		return err
+	}
+	if running+len(runs) > evalMaxRunningRuns {
+		return errTooManyEvalRuns
+	}
+
+	for _, run := range runs {
+		if err := createEvalRun(tx, run); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func createEvalRun(tx *sql.Tx, run *models.AiEvalRun) error {
+	configJSON, err := json.Marshal(run.Configuration)
+	if err != nil {
+		return err
+	}
+
+	run.Status = models.EvalRunRunning
+	return tx.QueryRow(`
+		INSERT INTO st_schema.ai_template_eval_runs (
+			template_id, tenant_id, user_id, label, source_template_id, source_version, configuration, model, status,
+			passed, failed, prompt_tokens, completion_tokens, cost
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, 0, 0, 0, 0, 0)
+		RETURNING id, created_at
+	`,
+		run.TemplateID, run.TenantID, run.UserID, run.Label, run.SourceTemplateID, run.SourceVersion, configJSON, run.Model, run.Status,
+	).Scan(&run.ID, &run.CreatedAt)
+}
+
+// failStaleEvalRuns marks the runs of the tenant interrupted by a restart as failed
+func failStaleEvalRuns(tenantID string) error {
+	result, err := tenantManagement.DB.Exec(`
+		UPDATE st_schema.ai_template_eval_runs
+		SET status = $1
+		WHERE tenant_id = $2 AND status = $3 AND created_at < $4
+	`, models.EvalRunFailed, tenantID, models.EvalRunRunning, time.Now().Add(-staleEvalRunAfter))
+	if err != nil {
+		return err
+	}
+
+	if failed, err := result.RowsAffected(); err == nil && failed > 0 {
+		log.Printf("Failed %d interrupted evaluation runs of tenant %s", failed, tenantID)
+	}
+
+	return nil
+}
+
+// failEvalRun marks a run that could not be evaluated or saved
+func failEvalRun(run *models.AiEvalRun) {
+	_, err := tenantManagement.DB.Exec(`
+		UPDATE st_schema.ai_template_eval_runs SET status = $1 WHERE id = $2 AND tenant_id = $3
+	`, models.EvalRunFailed, run.ID, run.TenantID)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+	}
+}
+
+// finishEvalRun stores the results and the totals of the run
+func finishEvalRun(run *models.AiEvalRun) error {
+	tx, err := tenantManagement.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE st_schema.ai_template_eval_runs
+		SET model = $1, status = $2, passed = $3, failed = $4, prompt_tokens = $5, completion_tokens = $6, cost = $7
+		WHERE id = $8 AND tenant_id = $9
+	`,
+		run.Model, run.Status, run.Passed, run.Failed, run.PromptTokens, run.CompletionTokens, run.Cost,
+		run.ID, run.TenantID,
+	)
+	if err != nil {
+		return err
+	}
+
+	for i := range run.Results {
+		result := &run.Results[i]
+		result.RunID = run.ID
+
+		assertionsJSON, err := json.Marshal(result.Assertions)
+		if err != nil {
+			return err
+		}
+
+		err = tx.QueryRow(`
+			INSERT INTO st_schema.ai_template_eval_results (
+				run_id, tenant_id, case_id, case_name, output, model, prompt_tokens, completion_tokens,
+				cost, latency_ms, passed, assertions, error
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			RETURNING id
+		`,
+			run.ID, run.TenantID, result.CaseID, result.CaseName, result.Output, result.Model, result.PromptTokens,
+			result.CompletionTokens, result.Cost, result.LatencyMs, result.Passed, assertionsJSON, result.Error,
+		).Scan(&result.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// RunTemplateEvaluationHandler starts a run of the test cases of the template for each variant
+// of the body. Without variants the current configuration is evaluated. The runs are returned
+// as running, GetTemplateEvalRunHandler returns their results once they are completed.
+func RunTemplateEvaluationHandler(c *gin.Context) {
+	userID, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	templateID := c.Query("template_id")
+	if templateID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Template ID is required"})
+		return
+	}
+
+	var body models.AiEvalRunRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+	}
+	if len(body.Variants) == 0 {
+		body.Variants = []models.EvalVariant{{Label: "current"}}
+	}
+	if len(body.Variants) > evalMaxRunVariants {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d variants can be run at once", evalMaxRunVariants)})
+		return
+	}
+
+	currentConfig, err := getTemplateConfiguration(tenantID, templateID)
+	if errors.Is(err, errTemplateNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	// Resolve the configuration of every variant before any model is called
+	configs := make([]*models.AiConfiguration, len(body.Variants))
+	for i, variant := range body.Variants {
+		if variant.Label == "" {
+			body.Variants[i].Label = fmt.Sprintf("variant %d", i+1)
+		}
+
+		switch {
+		case variant.Configuration != nil:
+			if err := aiFunctions.ValidateToolSettings(variant.Configuration.Tools); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if err := aiFunctions.ValidateTemplateVariables(variant.Configuration.SystemConfig); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			configs[i] = variant.Configuration
+		case variant.Version != nil:
+			if variant.TemplateID == nil {
+				body.Variants[i].TemplateID = &templateID
+			}
+			version, err := aiVersions.GetTemplateVersion(tenantID, *body.Variants[i].TemplateID, *variant.Version)
+			if errors.Is(err, aiVersions.ErrVersionNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Template version of variant %q not found", body.Variants[i].Label)})
+				return
+			}
+			if err != nil {
+				log.Printf(models.DatabaseError, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+				return
+			}
+			if version.Configuration == nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Template version of variant %q has no configuration", body.Variants[i].Label)})
+				return
+			}
+			configs[i] = version.Configuration
+		case variant.TemplateID != nil:
+			config, err := getTemplateConfiguration(tenantID, *variant.TemplateID)
+			if errors.Is(err, errTemplateNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Template of variant %q not found", body.Variants[i].Label)})
+				return
+			}
+			if err != nil {
+				log.Printf(models.DatabaseError, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+				return
+			}
+			configs[i] = config
+		default:
+			body.Variants[i].TemplateID = &templateID
+			configs[i] = currentConfig
+		}
+	}
+
+	testCases, err := getTemplateTestCases(tenantID, templateID)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+	if len(testCases) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Template has no test cases"})
+		return
+	}
+
+	if !checkAiBudget(c, &models.ChatContext{UserID: userID, TenantID: tenantID}) {
+		return
+	}
+
+	client, openAiConfig, err := GetOpenAiClient(tenantID)
+	if err != nil {
+		log.Printf("Failed to get OpenAI client: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	if err := failStaleEvalRuns(tenantID); err != nil {
+		log.Printf(models.DatabaseError, err)
+	}
+
+	runs := make([]*models.AiEvalRun, len(body.Variants))
+	for i, variant := range body.Variants {
+		runs[i] = &models.AiEvalRun{
+			TemplateID:       templateID,
+			TenantID:         tenantID,
+			UserID:           userID,
+			Label:            variant.Label,
+			SourceTemplateID: variant.TemplateID,
+			SourceVersion:    variant.Version,
+			Configuration:    configs[i],
+			Model:            utilities.ResolveModelRoute(openAiConfig, models.AiTaskChat).Primary,
+		}
+	}
+
+	err = createEvalRuns(tenantID, runs)
+	if errors.Is(err, errTooManyEvalRuns) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": fmt.Sprintf("At most %d evaluation runs can be running at once, wait for the running ones to complete", evalMaxRunningRuns)})
+		return
+	}
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	go runEvaluations(client, openAiConfig, runs, testCases)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"data":    runs,
+		"message": "Template evaluation started successfully!",
+	})
+}
+
+// runEvaluations evaluates the runs one after another, a run that can't be completed is marked as failed
+func runEvaluations(client *openai.Client, openAiConfig *models.OpenAiConfig, runs []*models.AiEvalRun, testCases []models.AiTemplateTestCase) {
+	ctx, cancel := context.WithTimeout(context.Background(), evalRunTimeout)
+	defer cancel()
+
+	next := 0
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Template evaluation panicked: %v", r)
+			for _, run := range runs[next:] {
+				failEvalRun(run)
+			}
+		}
+	}()
+
+	for ; next < len(runs); next++ {
+		run := runs[next]
+		runner := &evalRunner{
+			client:       client,
+			openAiConfig: openAiConfig,
+			userID:       run.UserID,
+			tenantID:     run.TenantID,
+			config:       run.Configuration,
+		}
+
+		runner.run(ctx, run, testCases)
+		if err := finishEvalRun(run); err != nil {
+			log.Printf(models.DatabaseError, err)
+			failEvalRun(run)
+		}
+	}
+}
+
+const evalRunSelect = `
+	SELECT
+		id, template_id, tenant_id, user_id, label, source_template_id, source_version, configuration, model, status,
+		passed, failed, prompt_tokens, completion_tokens, cost, created_at
+	FROM st_schema.ai_template_eval_runs
+`
+
+func scanEvalRun(scanner interface{ Scan(...any) error }) (*models.AiEvalRun, error) {
+	var run models.AiEvalRun
+	var configJSON []byte
+	if err := scanner.Scan(
+		&run.ID, &run.TemplateID, &run.TenantID, &run.UserID, &run.Label, &run.SourceTemplateID, &run.SourceVersion, &configJSON,
+		&run.Model, &run.Status, &run.Passed, &run.Failed, &run.PromptTokens, &run.CompletionTokens, &run.Cost,
+		&run.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if len(configJSON) > 0 {
+		if err := json.Unmarshal(configJSON, &run.Configuration); err != nil {
+			return nil, err
+		}
+	}
+	return &run, nil
+}
+
+// getEvalRun returns the run of the tenant with its results
+func getEvalRun(tenantID string, runID string) (*models.AiEvalRun, error) {
+	run, err := scanEvalRun(tenantManagement.DB.QueryRow(evalRunSelect+` WHERE id = $1 AND tenant_id = $2`, runID, tenantID))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tenantManagement.DB.Query(`
+		SELECT
+			id, run_id, case_id, case_name, output, model, prompt_tokens, completion_tokens,
+			cost, latency_ms, passed, assertions, error
+		FROM st_schema.ai_template_eval_results
+		WHERE run_id = $1 AND tenant_id = $2
+		ORDER BY case_name, id
+	`, runID, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	run.Results = []models.AiEvalResult{}
+	for rows.Next() {
+		var result models.AiEvalResult
+		var assertionsJSON []byte
+		if err := rows.Scan(
+			&result.ID, &result.RunID, &result.CaseID, &result.CaseName, &result.Output, &result.Model,
+			&result.PromptTokens, &result.CompletionTokens, &result.Cost, &result.LatencyMs, &result.Passed,
+			&assertionsJSON, &result.Error,
+		); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(assertionsJSON, &result.Assertions); err != nil {
+			return nil, err
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	return run, rows.Err()
+}
+
+// GetTemplateEvalRunsHandler lists the runs of a template without their results, newest first
+func GetTemplateEvalRunsHandler(c *gin.Context) {
+	_, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	templateID := c.Query("template_id")
+	if templateID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Template ID is required"})
+		return
+	}
+
+	if err := failStaleEvalRuns(tenantID); err != nil {
+		log.Printf(models.DatabaseError, err)
+	}
+
+	rows, err := tenantManagement.DB.Query(evalRunSelect+`
+		WHERE template_id = $1 AND tenant_id = $2
+		ORDER BY created_at DESC
+	`, templateID, tenantID)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+	defer rows.Close()
+
+	runs := []*models.AiEvalRun{}
+	for rows.Next() {
+		run, err := scanEvalRun(rows)
+		if err != nil {
+			log.Printf(models.DatabaseError, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+			return
+		}
+		runs = append(runs, run)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    runs,
+		"message": "Evaluation runs retrieved successfully!",
+	})
+}
+
+func GetTemplateEvalRunHandler(c *gin.Context) {
+	_, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	runID := c.Query("id")
+	if runID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Run ID is required"})
+		return
+	}
+
+	if err := failStaleEvalRuns(tenantID); err != nil {
+		log.Printf(models.DatabaseError, err)
+	}
+
+	run, err := getEvalRun(tenantID, runID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Evaluation run not found"})
+		return
+	}
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    run,
+		"message": "Evaluation run retrieved successfully!",
+	})
+}
+
+// CompareTemplateEvalRunsHandler puts the results of two runs side by side, matched by test case
+func CompareTemplateEvalRunsHandler(c *gin.Context) {
+	_, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	baseID, candidateID := c.Query("base"), c.Query("candidate")
+	if baseID == "" || candidateID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Base and candidate run IDs are required"})
+		return
+	}
+
+	runs := make([]*models.AiEvalRun, 2)
+	for i, runID := range []string{baseID, candidateID} {
+		run, err := getEvalRun(tenantID, runID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Evaluation run not found"})
+			return
+		}
+		if err != nil {
+			log.Printf(models.DatabaseError, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+			return
+		}
+		runs[i] = run
+	}
+	base, candidate := runs[0], runs[1]
+
+	var rows []models.AiEvalComparisonRow
+	index := make(map[string]int)
+	for i := range base.Results {
+		result := &base.Results[i]
+		index[result.CaseID] = len(rows)
+		rows = append(rows, models.AiEvalComparisonRow{CaseID: result.CaseID, CaseName: result.CaseName, Base: result})
+	}
+	for i := range candidate.Results {
+		result := &candidate.Results[i]
+		if position, ok := index[result.CaseID]; ok {
+			rows[position].Candidate = result
+			rows[position].CaseName = result.CaseName
+			continue
+		}
+		rows = append(rows, models.AiEvalComparisonRow{CaseID: result.CaseID, CaseName: result.CaseName, Candidate: result})
+	}
+
+	summary := map[models.EvalCaseChange]int{}
+	for i := range rows {
+		row := &rows[i]
+		switch {
+		case row.Base == nil:
+			row.Change = models.EvalCaseAdded
+		case row.Candidate == nil:
+			row.Change = models.EvalCaseRemoved
+		case !row.Base.Passed && row.Candidate.Passed:
+			row.Change = models.EvalCaseFixed
+		case row.Base.Passed && !row.Candidate.Passed:
+			row.Change = models.EvalCaseRegressed
+		default:
+			row.Change = models.EvalCaseUnchanged
+		}
+		summary[row.Change]++
+	}
+
+	// The results are part of the rows, the runs only carry their totals
+	base.Results, candidate.Results = nil, nil
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"base":      base,
+			"candidate": candidate,
+			"rows":      rows,
+			"summary":   summary,
+			"delta": gin.H{
+				"passed":            candidate.Passed - base.Passed,
+				"prompt_tokens":     candidate.PromptTokens - base.PromptTokens,
+				"completion_tokens": candidate.CompletionTokens - base.CompletionTokens,
+				"cost":              candidate.Cost - base.Cost,
+			},
+		},
+		"message": "Evaluation runs compared successfully!",
+	})
+}
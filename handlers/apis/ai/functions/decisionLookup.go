@@ -0,0 +1,411 @@
+package aiFunctions
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"sententiawebapi/handlers/apis/tenantManagement"
+	"sententiawebapi/handlers/models"
+
+	openai "github.com/openai/openai-go"
+)
+
+const (
+	defaultDecisionLookupLimit = 5
+	maxDecisionLookupLimit     = 20
+)
+
+type DecisionLookupRequest struct {
+	Type       string `json:"type"` // tchart, pnc, swot or matrix
+	Query      string `json:"query"`
+	DecisionID string `json:"decision_id"`
+	Limit      int    `json:"limit"`
+}
+
+type DecisionSummary struct {
+	ID            string
+	Title         string
+	Status        *string
+	Description   *string
+	BetterOption  *string
+	Assumptions   *string
+	FinalDecision *string
+	Implications  *string
+	Details       string // Options, arguments or scores of the analysis
+}
+
+// decisionSource describes where a decision type is stored
+type decisionSource struct {
+	ResourceType      models.ResourceType
+	Label             string
+	Table             string
+	TitleColumn       string
+	DescriptionColumn string
+	StatusColumn      string
+	BetterOption      string // Column expression, NULL when the type has none
+	Details           func(ctx context.Context, decisionID, tenantID string) (string, error)
+}
+
+var decisionSources = map[string]decisionSource{
+	"tchart": {
+		ResourceType:      models.ResourceTypeTChart,
+		Label:             "T-bar",
+		Table:             "st_schema.tbar_analysis",
+		TitleColumn:       "tbar_title",
+		DescriptionColumn: "tbar_description",
+		StatusColumn:      "tbar_status",
+		BetterOption:      "tbar_better_option",
+		Details:           getTBarDetails,
+	},
+	"pnc": {
+		ResourceType:      models.ResourceTypePnC,
+		Label:             "Pros and Cons",
+		Table:             "st_schema.pnc_analysis",
+		TitleColumn:       "title",
+		DescriptionColumn: "pnc_description",
+		StatusColumn:      "pnc_status",
+		BetterOption:      "better_option",
+		Details:           getPncDetails,
+	},
+	"swot": {
+		ResourceType:      models.ResourceTypeSwot,
+		Label:             "SWOT",
+		Table:             "st_schema.swot_analysis",
+		TitleColumn:       "title",
+		DescriptionColumn: "swot_description",
+		StatusColumn:      "swot_status",
+		BetterOption:      "NULL::text",
+		Details:           getSwotDetails,
+	},
+	"matrix": {
+		ResourceType:      models.ResourceTypeMatrix,
+		Label:             "Decision Matrix",
+		Table:             "st_schema.matrix_analysis",
+		TitleColumn:       "title",
+		DescriptionColumn: "matrix_description",
+		StatusColumn:      "matrix_status",
+		BetterOption:      "NULL::text",
+		Details:           getMatrixDetails,
+	},
+}
+
+func init() {
+	RegisterTool(Tool{
+		Name: "decision_lookup",
+		Description: `
+			Looks up decision analyses of the current project: T-bar (tchart), Pros and Cons (pnc), SWOT (swot) and Decision Matrix (matrix).
+			Returns a summary of each decision with its status, final decision, implications and the weighted options, arguments or scores.
+		`,
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"type": map[string]any{
+					"type": "string",
+					"enum": []string{"tchart", "pnc", "swot", "matrix"},
+				},
+				"query": map[string]string{
+					"type":        "string",
+					"description": "Optional keywords matched against the decision title and description.",
+				},
+				"decision_id": map[string]string{
+					"type":        "string",
+					"description": `Optional decision UUID, use "current" for the currently opened decision.`,
+				},
+				"limit": map[string]string{
+					"type":        "number",
+					"description": "Max decisions to return (default 5).",
+				},
+			},
+			"required": []string{"type"},
+		},
+		ResourceGroupTypes:      []models.ResourceGroupType{models.ResourceGroupProject},
+		RequiresResourceGroupID: true,
+		Execute:                 executeDecisionLookup,
+	})
+}
+
+func DecisionLookup(ctx context.Context, req *DecisionLookupRequest, chatCtx *models.ChatContext) ([]DecisionSummary, error) {
+	source, ok := decisionSources[req.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown decision type: %s", req.Type)
+	}
+	if chatCtx.ResourceGroupID == nil {
+		return nil, fmt.Errorf("project ID is missing")
+	}
+
+	if req.Limit <= 0 {
+		req.Limit = defaultDecisionLookupLimit
+	}
+	req.Limit = min(req.Limit, maxDecisionLookupLimit)
+
+	if req.DecisionID == "current" {
+		req.DecisionID = ""
+		if chatCtx.ResourceType != nil && *chatCtx.ResourceType == source.ResourceType && chatCtx.ResourceID != nil {
+			req.DecisionID = *chatCtx.ResourceID
+		}
+	}
+
+	args := []any{*chatCtx.ResourceGroupID, chatCtx.TenantID}
+	conditions := []string{"project_id = $1", "tenant_id = $2"}
+
+	if req.DecisionID != "" {
+		args = append(args, req.DecisionID)
+		conditions = append(conditions, fmt.Sprintf("id = $%d", len(args)))
+	}
+	if query := strings.TrimSpace(req.Query); query != "" {
+		args = append(args, query)
+		conditions = append(conditions, fmt.Sprintf(
+			"(%[1]s ILIKE '%%' || $%[3]d || '%%' OR %[2]s ILIKE '%%' || $%[3]d || '%%')",
+			source.TitleColumn, source.DescriptionColumn, len(args),
+		))
+	}
+
+	args = append(args, req.Limit)
+	query := fmt.Sprintf(`
+		SELECT id, COALESCE(%s, ''), %s, %s, %s, assumptions, final_decision, implications
+		FROM %s
+		WHERE %s
+		ORDER BY updated_at DESC NULLS LAST
+		LIMIT $%d
+	`, source.TitleColumn, source.StatusColumn, source.DescriptionColumn, source.BetterOption,
+		source.Table, strings.Join(conditions, " AND "), len(args))
+
+	rows, err := tenantManagement.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching decisions: %w", err)
+	}
+	defer rows.Close()
+
+	var summaries []DecisionSummary
+	for rows.Next() {
+		var s DecisionSummary
+		if err := rows.Scan(
+			&s.ID,
+			&s.Title,
+			&s.Status,
+			&s.Description,
+			&s.BetterOption,
+			&s.Assumptions,
+			&s.FinalDecision,
+			&s.Implications,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning decision: %w", err)
+		}
+		summaries = append(summaries, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range summaries {
+		details, err := source.Details(ctx, summaries[i].ID, chatCtx.TenantID)
+		if err != nil {
+			return nil, err
+		}
+		summaries[i].Details = details
+	}
+
+	return summaries, nil
+}
+
+func getTBarDetails(ctx context.Context, decisionID, tenantID string) (string, error) {
+	rows, err := tenantManagement.DB.QueryContext(ctx, `
+		SELECT o.option_title, a.argument_name, a.argument_weight
+		FROM st_schema.tbar_options o
+		LEFT JOIN st_schema.tbar_arguments a ON a.option_id = o.id AND a.tenant_id = o.tenant_id
+		WHERE o.tbar_analysis_id = $1 AND o.tenant_id = $2
+		ORDER BY o.option_title, a.argument_weight DESC NULLS LAST
+	`, decisionID, tenantID)
+	if err != nil {
+		return "", fmt.Errorf("error fetching T-bar arguments: %w", err)
+	}
+	defer rows.Close()
+
+	groups := newWeightedGroups()
+	for rows.Next() {
+		var option string
+		var argument sql.NullString
+		var weight sql.NullFloat64
+		if err := rows.Scan(&option, &argument, &weight); err != nil {
+			return "", fmt.Errorf("error scanning T-bar argument: %w", err)
+		}
+		groups.add(option, argument, weight)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	return groups.format("Option"), nil
+}
+
+func getPncDetails(ctx context.Context, decisionID, tenantID string) (string, error) {
+	return getSidedArguments(ctx, "st_schema.pnc_arguments", "pnc_id", decisionID, tenantID)
+}
+
+func getSwotDetails(ctx context.Context, decisionID, tenantID string) (string, error) {
+	return getSidedArguments(ctx, "st_schema.swot_arguments", "swot_id", decisionID, tenantID)
+}
+
+// getSidedArguments formats arguments grouped by side (pro/con, strength/weakness/...)
+func getSidedArguments(ctx context.Context, table, parentColumn, decisionID, tenantID string) (string, error) {
+	rows, err := tenantManagement.DB.QueryContext(ctx, fmt.Sprintf(`
+		SELECT side, argument, argument_weight
+		FROM %s
+		WHERE %s = $1 AND tenant_id = $2
+		ORDER BY side, argument_weight DESC NULLS LAST
+	`, table, parentColumn), decisionID, tenantID)
+	if err != nil {
+		return "", fmt.Errorf("error fetching arguments: %w", err)
+	}
+	defer rows.Close()
+
+	groups := newWeightedGroups()
+	for rows.Next() {
+		var side string
+		var argument sql.NullString
+		var weight sql.NullFloat64
+		if err := rows.Scan(&side, &argument, &weight); err != nil {
+			return "", fmt.Errorf("error scanning argument: %w", err)
+		}
+		groups.add(side, argument, weight)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	return groups.format("Side"), nil
+}
+
+func getMatrixDetails(ctx context.Context, decisionID, tenantID string) (string, error) {
+	// Ratings of all users are averaged per cell, the concept score is the weighted sum over criteria
+	rows, err := tenantManagement.DB.QueryContext(ctx, `
+		SELECT c.title, cr.title, cr.criteria_multiplier, AVG(r.user_rating)
+		FROM st_schema.matrix_concepts c
+		CROSS JOIN st_schema.matrix_criteria cr
+		LEFT JOIN st_schema.matrix_user_ratings r
+			ON r.concept_id = c.id AND r.criteria_id = cr.id AND r.tenant_id = c.tenant_id
+		WHERE c.matrix_id = $1 AND c.tenant_id = $2
+			AND cr.matrix_id = c.matrix_id AND cr.tenant_id = c.tenant_id
+		GROUP BY c.id, c.title, cr.id, cr.title, cr.criteria_multiplier
+		ORDER BY c.title, cr.title
+	`, decisionID, tenantID)
+	if err != nil {
+		return "", fmt.Errorf("error fetching matrix ratings: %w", err)
+	}
+	defer rows.Close()
+
+	groups := newWeightedGroups()
+	for rows.Next() {
+		var concept, criteria string
+		var multiplier, rating sql.NullFloat64
+		if err := rows.Scan(&concept, &criteria, &multiplier, &rating); err != nil {
+			return "", fmt.Errorf("error scanning matrix rating: %w", err)
+		}
+
+		label := sql.NullString{String: fmt.Sprintf("%s (weight %g)", criteria, multiplier.Float64), Valid: true}
+		score := sql.NullFloat64{Float64: multiplier.Float64 * rating.Float64, Valid: rating.Valid}
+		groups.add(concept, label, score)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	return groups.format("Concept"), nil
+}
+
+// weightedGroups collects weighted lines per group in insertion order
+type weightedGroups struct {
+	order  []string
+	lines  map[string][]string
+	totals map[string]float64
+}
+
+func newWeightedGroups() *weightedGroups {
+	return &weightedGroups{lines: make(map[string][]string), totals: make(map[string]float64)}
+}
+
+func (g *weightedGroups) add(group string, label sql.NullString, weight sql.NullFloat64) {
+	if _, ok := g.lines[group]; !ok {
+		g.order = append(g.order, group)
+		g.lines[group] = nil
+	}
+	if !label.Valid {
+		return // Group without entries
+	}
+
+	line := label.String
+	if weight.Valid {
+		line = fmt.Sprintf("%s: %g", label.String, weight.Float64)
+		g.totals[group] += weight.Float64
+	}
+	g.lines[group] = append(g.lines[group], line)
+}
+
+func (g *weightedGroups) format(kind string) string {
+	var sb strings.Builder
+	for _, group := range g.order {
+		sb.WriteString(fmt.Sprintf("%s %s (total %g)\n", kind, group, g.totals[group]))
+		for _, line := range g.lines[group] {
+			sb.WriteString("  - " + line + "\n")
+		}
+	}
+	return sb.String()
+}
+
+func executeDecisionLookup(ctx context.Context, _ *openai.Client, chatCtx *models.ChatContext, rawArguments string) (string, []models.Citation, error) {
+	var arguments DecisionLookupRequest
+	if err := json.Unmarshal([]byte(rawArguments), &arguments); err != nil {
+		return "", nil, fmt.Errorf("invalid function arguments: %v", rawArguments)
+	}
+
+	source, ok := decisionSources[arguments.Type]
+	if !ok {
+		return fmt.Sprintf("Unknown decision type %q, use one of: tchart, pnc, swot, matrix.", arguments.Type), nil, nil
+	}
+
+	summaries, err := DecisionLookup(ctx, &arguments, chatCtx)
+	if err != nil {
+		return "", nil, fmt.Errorf("Decision lookup failed: %v", err)
+	}
+
+	if len(summaries) == 0 {
+		return fmt.Sprintf("No matching %s analyses found.", source.Label), nil, nil
+	}
+
+	var sb strings.Builder
+	citations := make([]models.Citation, 0, len(summaries))
+	for i, s := range summaries {
+		sb.WriteString(fmt.Sprintf("#%d %s: %s (%s)\n", i+1, source.Label, s.Title, s.ID))
+		writeOptionalLine(&sb, "Status", s.Status)
+		writeOptionalLine(&sb, "Description", s.Description)
+		writeOptionalLine(&sb, "Better option", s.BetterOption)
+		writeOptionalLine(&sb, "Assumptions", s.Assumptions)
+		writeOptionalLine(&sb, "Final decision", s.FinalDecision)
+		writeOptionalLine(&sb, "Implications", s.Implications)
+		if s.Details != "" {
+			sb.WriteString(s.Details)
+		}
+		sb.WriteString("\n")
+
+		citations = append(citations, models.Citation{
+			ResourceType: source.ResourceType,
+			ResourceID:   s.ID,
+			Title:        s.Title,
+		})
+	}
+
+	log.Print(sb.String())
+
+	return sb.String(), citations, nil
+}
+
+func writeOptionalLine(sb *strings.Builder, label string, value *string) {
+	if value == nil || strings.TrimSpace(*value) == "" {
+		return
+	}
+	sb.WriteString(fmt.Sprintf("%s: %s\n", label, *value))
+}
@@ -1,12 +1,16 @@
 package aiFunctions
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 
+	aiUsage "sententiawebapi/handlers/apis/ai/usage"
 	"sententiawebapi/handlers/apis/tenantManagement"
 	"sententiawebapi/handlers/models"
 	"sententiawebapi/utilities"
@@ -14,7 +18,6 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/lib/pq"
 	openai "github.com/openai/openai-go"
-	"github.com/openai/openai-go/option"
 )
 
 type DiagramSearchRequest struct {
@@ -24,18 +27,105 @@ type DiagramSearchRequest struct {
 }
 
 type DiagramSearchResult struct {
-	Content  string  `json:"content"`
-	Distance float64 `json:"distance"`
+	DiagramID string  `json:"diagram_id"`
+	Title     string  `json:"title"`
+	Content   string  `json:"content"`
+	Distance  float64 `json:"distance"`
 }
 
+func init() {
+	RegisterTool(Tool{
+		Name: "diagram_search",
+		Description: `
+			Semantic search for diagrams within the project.
+		`,
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"query": map[string]string{
+					"type":        "string",
+					"description": "Natural-language search phrase. If you want current diagram, leave empty and use scope.",
+				},
+				"limit": map[string]string{
+					"type": "number",
+					"description": `
+						Max diagrams to return
+					`,
+				},
+				"scope": map[string]any{
+					"type": "array",
+					"items": map[string]string{
+						"type": "string",
+					},
+					"description": `
+						Optional list of diagram UUIDs to restrict the search scope.
+						Use ["current"] to retrieve currently active diagram.
+						If user input includes references (e.g. @ref(diag: <uuid>)), include those IDs only if they are relevant to the query.
+						Leave empty or omit to search the entire project.
+					`,
+				},
+			},
+			"required": []string{"query", "limit"},
+		},
+		Execute: executeDiagramSearch,
+	})
+}
+
+func executeDiagramSearch(ctx context.Context, client *openai.Client, chatCtx *models.ChatContext, rawArguments string) (string, []models.Citation, error) {
+	var arguments DiagramSearchRequest
+	if err := json.Unmarshal([]byte(rawArguments), &arguments); err != nil {
+		return "", nil, fmt.Errorf("invalid function arguments: %v", rawArguments)
+	}
+
+	results, err := DiagramSearch(ctx, client, &arguments, chatCtx)
+	if err != nil {
+		return "", nil, fmt.Errorf("Diagram search failed: %v", err)
+	}
+
+	if len(results) == 0 {
+		return "", nil, nil
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString("How to read diagram:\n")
+	sb.WriteString("[<group>] #<x-position order L->R> <node label> (icon filename) // node definition\n")
+	sb.WriteString("--> #<target order> <target label> // connection to another node\n")
+	sb.WriteString("--<connection icon>--> #<order> <label>\n")
+	sb.WriteString("--> #<order> <label> : <connection label>\n")
+	sb.WriteString("[<group>] followed by connections // connections from the whole group\n\n")
+
+	citations := make([]models.Citation, 0, len(results))
+	for i, result := range results {
+		sb.WriteString(fmt.Sprintf("#%d ", i+1))
+		sb.WriteString(result.Content)
+		sb.WriteString("\n\n")
+
+		citations = append(citations, models.Citation{
+			ResourceType: models.ResourceTypeDiagram,
+			ResourceID:   result.DiagramID,
+			Title:        result.Title,
+			Distance:     result.Distance,
+		})
+	}
+
+	sb.WriteString("Note: If some expected content is missing, it may not have been indexed yet.\n")
+	sb.WriteString("\n\n")
+
+	log.Print(sb.String())
+
+	return sb.String(), citations, nil
+}
+
+// selectDiagramSearchSource ranks the diagrams by the distance of their digest embedding. Without
+// a query vector they are ranked by the full-text relevance of the digest, the distance is then
+// the negated text rank.
 func selectDiagramSearchSource(
+	ctx context.Context,
 	req *DiagramSearchRequest,
 	vectorStr string,
 	chatCtx *models.ChatContext,
 ) (*sql.Rows, error) {
-	var query string
-	var args []any
-
 	if chatCtx.ResourceGroupID == nil {
 		return nil, fmt.Errorf("cannot perform diagram search without knowing project/template id")
 	}
@@ -52,54 +142,44 @@ func selectDiagramSearchSource(
 		}
 	}
 
+	var table, groupColumn string
 	switch chatCtx.ResourceGroupType {
 	case models.ResourceGroupTemplate:
-		query = `
-			SELECT COALESCE(diagram_digest, ''), embedding <#> ` + vectorStr + `::vector AS distance
-			FROM st_schema.diagram_templates
-			WHERE tenant_id = $1 AND project_template_id = $2 AND diagram_digest IS NOT NULL
-		`
-		args = append(args, chatCtx.TenantID, chatCtx.ResourceGroupID)
-
-		if len(scope) > 0 {
-			query += ` AND id = ANY($3)`
-			args = append(args, pq.Array(scope))
-		}
-
+		table, groupColumn = "st_schema.diagram_templates", "project_template_id"
 	case models.ResourceGroupCommunity:
-		query = `
-			SELECT COALESCE(diagram_digest, ''), embedding <#> ` + vectorStr + `::vector AS distance
-			FROM st_schema.cm_diagram_templates
-			WHERE tenant_id = $1 AND community_project_template_id = $2 AND diagram_digest IS NOT NULL
-		`
-		args = append(args, chatCtx.TenantID, chatCtx.ResourceGroupID)
-
-		if len(scope) > 0 {
-			query += ` AND id = ANY($3)`
-			args = append(args, pq.Array(scope))
-		}
-
+		table, groupColumn = "st_schema.cm_diagram_templates", "community_project_template_id"
 	default: // "project"
-		query = `
-			SELECT COALESCE(diagram_digest, ''), embedding <#> ` + vectorStr + `::vector AS distance
-			FROM st_schema.diagrams
-			WHERE tenant_id = $1 AND project_id = $2 AND diagram_digest IS NOT NULL
-		`
-		args = append(args, chatCtx.TenantID, chatCtx.ResourceGroupID)
-
-		if len(scope) > 0 {
-			query += ` AND id = ANY($3)`
-			args = append(args, pq.Array(scope))
-		}
+		table, groupColumn = "st_schema.diagrams", "project_id"
+	}
+
+	args := []any{chatCtx.TenantID, chatCtx.ResourceGroupID}
+	filter := fmt.Sprintf(`tenant_id = $1 AND %s = $2 AND diagram_digest IS NOT NULL`, groupColumn)
+
+	if len(scope) > 0 {
+		args = append(args, pq.Array(scope))
+		filter += fmt.Sprintf(` AND id = ANY($%d)`, len(args))
+	}
+
+	distance := `embedding <#> ` + vectorStr + `::vector`
+	if vectorStr == "" {
+		args = append(args, req.Query)
+		search := keywordTsQuery(len(args))
+		distance = fmt.Sprintf(`-ts_rank_cd(to_tsvector('%s', diagram_digest), %s)::float8`, textSearchConfig, search)
+		filter += fmt.Sprintf(` AND to_tsvector('%s', diagram_digest) @@ %s`, textSearchConfig, search)
 	}
 
-	query += fmt.Sprintf(` ORDER BY distance ASC LIMIT $%d`, len(args)+1)
 	args = append(args, req.Limit)
+	query := fmt.Sprintf(`
+		SELECT id, COALESCE(title, ''), COALESCE(diagram_digest, ''), %s AS distance
+		FROM %s
+		WHERE %s
+		ORDER BY distance ASC LIMIT $%d
+	`, distance, table, filter, len(args))
 
-	return tenantManagement.DB.Query(query, args...)
+	return tenantManagement.DB.QueryContext(ctx, query, args...)
 }
 
-func DiagramSearch(openaiClient *openai.Client, req *DiagramSearchRequest, chatCtx *models.ChatContext) ([]DiagramSearchResult, error) {
+func DiagramSearch(ctx context.Context, openaiClient *openai.Client, req *DiagramSearchRequest, chatCtx *models.ChatContext) ([]DiagramSearchResult, error) {
 	// 🪵 Pretty log input
 	if payload, err := json.MarshalIndent(req, "", "  "); err == nil {
 		log.Printf("[DiagramSearch] Request:\n%s", payload)
@@ -107,12 +187,16 @@ func DiagramSearch(openaiClient *openai.Client, req *DiagramSearchRequest, chatC
 		log.Printf("[DiagramSearch] Failed to marshal request: %v", err)
 	}
 
-	vectorStr, err := embedQuery(openaiClient, req.Query)
-	if err != nil {
+	// Without budget for the query embedding the diagrams are still found by their keywords
+	vectorStr, err := embedQuery(ctx, openaiClient, chatCtx, req.Query)
+	if errors.Is(err, aiUsage.ErrBudgetExhausted) {
+		log.Printf("[DiagramSearch] Falling back to keyword search: %v", err)
+		vectorStr = utilities.Ptr("")
+	} else if err != nil {
 		return nil, fmt.Errorf("failed to generate embedding for a query message: %v", err)
 	}
 
-	rows, err := selectDiagramSearchSource(req, *vectorStr, chatCtx)
+	rows, err := selectDiagramSearchSource(ctx, req, *vectorStr, chatCtx)
 	if err != nil {
 		return nil, fmt.Errorf("failed preparing query: %v", err)
 	}
@@ -121,7 +205,7 @@ func DiagramSearch(openaiClient *openai.Client, req *DiagramSearchRequest, chatC
 	var results []DiagramSearchResult
 	for rows.Next() {
 		var res DiagramSearchResult
-		if err := rows.Scan(&res.Content, &res.Distance); err != nil {
+		if err := rows.Scan(&res.DiagramID, &res.Title, &res.Content, &res.Distance); err != nil {
 			return nil, fmt.Errorf("failed to read db row: %v", err)
 		}
 
@@ -151,16 +235,14 @@ func DiagramSearchHandler(c *gin.Context) {
 		return
 	}
 
-	openaiConfig, err := utilities.GetOpenAiConfig(tenantManagement.DB, tenantID)
+	openaiClient, _, err := utilities.GetOpenAiClient(tenantManagement.DB, tenantID)
 	if err != nil {
+		log.Printf("Failed to get OpenAI client: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
 	}
 
-	openaiClient := openai.NewClient(
-		option.WithAPIKey(openaiConfig.OpenAIApiKey),
-	)
-
-	results, err := DiagramSearch(&openaiClient, &req, &models.ChatContext{
+	results, err := DiagramSearch(c.Request.Context(), openaiClient, &req, &models.ChatContext{
 		UserID:   userID,
 		TenantID: tenantID,
 		ResourceIdentifier: models.ResourceIdentifier{
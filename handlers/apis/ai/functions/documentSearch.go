@@ -1,12 +1,17 @@
 package aiFunctions
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 
+	aiIndexing "sententiawebapi/handlers/apis/ai/indexing"
+	aiUsage "sententiawebapi/handlers/apis/ai/usage"
 	"sententiawebapi/handlers/apis/tenantManagement"
 	"sententiawebapi/handlers/models"
 	"sententiawebapi/utilities"
@@ -14,34 +19,132 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/lib/pq"
 	openai "github.com/openai/openai-go"
-	"github.com/openai/openai-go/option"
+)
+
+const (
+	DocSearchModeHybrid   = "hybrid"   // full-text + vector ranks fused with RRF
+	DocSearchModeSemantic = "semantic" // vector distance only
+)
+
+const (
+	defaultDocSearchLimit = 10
+	defaultKeywordWeight  = 0.5
+	rrfK                  = 60        // Reciprocal rank fusion constant, dampens the lead of top ranks
+	textSearchConfig      = "english" // Postgres text search configuration used for keyword ranking
 )
 
 type DocSearchRequest struct {
-	Query string   `json:"query" binding:"required"`
-	Limit int      `json:"limit"`
-	Scope []string `json:"scope"`
+	Query         string   `json:"query" binding:"required"`
+	Limit         int      `json:"limit"`
+	Scope         []string `json:"scope"`
+	Mode          string   `json:"mode"`           // hybrid (default) or semantic
+	KeywordWeight *float64 `json:"keyword_weight"` // Share of the full-text rank in hybrid mode, 0..1
 }
 
 type DocSearchResult struct {
-	Title    string  `json:"title"`
-	Content  string  `json:"content"`
-	Distance float64 `json:"distance"`
+	DocumentID string  `json:"document_id"`
+	BlockID    *string `json:"block_id"` // Missing for fragments indexed before block ids were stored
+	Title      string  `json:"title"`
+	Content    string  `json:"content"`
+	Distance   float64 `json:"distance"`
+	Score      float64 `json:"score,omitempty"` // Fused RRF score, hybrid mode only
 }
 
-func selectDocSearchSource(
-	req *DocSearchRequest,
-	vectorStr string,
-	chatCtx *models.ChatContext,
-) (*sql.Rows, error) {
-	var query string
-	var args []any
+func init() {
+	RegisterTool(Tool{
+		Name:        "document_search",
+		Description: "Keyword and semantic search for documents within the project. Returns only (small) fragments of document(s). Each fragment starts with index and document title.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"query": map[string]string{
+					"type":        "string",
+					"description": "Natural-language search phrase. If you want current/concrete document, leave empty and use scope.",
+				},
+				"limit": map[string]string{
+					"type": "number",
+					"description": `
+						Maximum number of matching fragments to return.
+						Each fragment corresponds to a content block (e.g., paragraph, table, heading, or list), similar to Notion-style editors.
+					`,
+				},
+				"scope": map[string]any{
+					"type": "array",
+					"items": map[string]string{
+						"type": "string",
+					},
+					"description": `
+						Optional list of document IDs to restrict the search scope.
+						Use ["current"] to search only the current document.
+						If user input includes references (e.g. @ref(doc: <uuid>)), include those IDs only if they are relevant to the query.
+						Leave empty or omit to search the entire project.
+					`,
+				},
+				"mode": map[string]any{
+					"type": "string",
+					"enum": []string{DocSearchModeHybrid, DocSearchModeSemantic},
+					"description": `
+						Retrieval mode, defaults to "hybrid".
+						"hybrid" combines keyword and semantic ranking, use it when the query contains exact identifiers (service names, table names, ticket numbers).
+						"semantic" ranks by meaning only.
+					`,
+				},
+				"keyword_weight": map[string]string{
+					"type": "number",
+					"description": `
+						Optional weight of keyword ranking in hybrid mode, between 0 and 1 (default 0.5).
+						Increase it when exact terms matter more than meaning.
+					`,
+				},
+			},
+			"required": []string{"query", "limit"},
+		},
+		Execute: executeDocumentSearch,
+	})
+}
 
-	if chatCtx.ResourceGroupID == nil {
-		return nil, fmt.Errorf("cannot perform semantic search without knowing project/template id")
+func executeDocumentSearch(ctx context.Context, client *openai.Client, chatCtx *models.ChatContext, rawArguments string) (string, []models.Citation, error) {
+	var arguments DocSearchRequest
+	if err := json.Unmarshal([]byte(rawArguments), &arguments); err != nil {
+		return "", nil, fmt.Errorf("invalid function arguments: %v", rawArguments)
+	}
+
+	results, err := DocumentSearch(ctx, client, &arguments, chatCtx)
+	if err != nil {
+		return "", nil, fmt.Errorf("Document search failed: %v", err)
 	}
 
-	// Replace "current" with actual document ID, or remove if not applicable
+	if len(results) == 0 {
+		return "", nil, nil
+	}
+
+	var sb strings.Builder
+	citations := make([]models.Citation, 0, len(results))
+	for i, result := range results {
+		sb.WriteString(fmt.Sprintf("#%d Document: %s\n", i+1, result.Title))
+		sb.WriteString(result.Content)
+		sb.WriteString("\n\n")
+
+		citations = append(citations, models.Citation{
+			ResourceType: models.ResourceTypeDocument,
+			ResourceID:   result.DocumentID,
+			Title:        result.Title,
+			BlockID:      result.BlockID,
+			Distance:     result.Distance,
+		})
+	}
+
+	sb.WriteString("Note: If some expected content is missing, it may not have been indexed yet.\n")
+	sb.WriteString("Feel free to ask the user for clarification or to paste the relevant content here.")
+	sb.WriteString("\n\n")
+
+	log.Print(sb.String())
+
+	return sb.String(), citations, nil
+}
+
+// resolveDocSearchScope replaces "current" with the active document ID, or drops it if not applicable
+func resolveDocSearchScope(req *DocSearchRequest, chatCtx *models.ChatContext) []string {
 	scope := make([]string, 0, len(req.Scope))
 	for _, id := range req.Scope {
 		if id == "current" {
@@ -52,11 +155,28 @@ func selectDocSearchSource(
 			scope = append(scope, id)
 		}
 	}
+	return scope
+}
+
+func selectDocSearchSource(
+	ctx context.Context,
+	req *DocSearchRequest,
+	vectorStr string,
+	chatCtx *models.ChatContext,
+) (*sql.Rows, error) {
+	var query string
+	var args []any
+
+	if chatCtx.ResourceGroupID == nil {
+		return nil, fmt.Errorf("cannot perform semantic search without knowing project/template id")
+	}
+
+	scope := resolveDocSearchScope(req, chatCtx)
 
 	switch chatCtx.ResourceGroupType {
 	case models.ResourceGroupTemplate:
 		query = `
-			SELECT vector.content, vector.embedding <#> ` + vectorStr + `::vector AS distance, doc.title
+			SELECT vector.content, vector.embedding <#> ` + vectorStr + `::vector AS distance, doc.title, doc.id, vector.block_id
 			FROM st_schema.document_template_vectors vector
 			JOIN st_schema.document_templates doc ON doc.id = vector.document_template_id
 			WHERE vector.tenant_id = $1 AND vector.project_template_id = $2
@@ -70,7 +190,7 @@ func selectDocSearchSource(
 
 	case models.ResourceGroupCommunity:
 		query = `
-			SELECT vector.content, vector.embedding <#> ` + vectorStr + `::vector AS distance, doc.title
+			SELECT vector.content, vector.embedding <#> ` + vectorStr + `::vector AS distance, doc.title, doc.id, vector.block_id
 			FROM st_schema.cm_document_template_vectors vector
 			JOIN st_schema.cm_document_templates doc ON doc.id = vector.cm_document_template_id
 			WHERE vector.tenant_id = $1 AND vector.cm_project_template_id = $2
@@ -84,7 +204,7 @@ func selectDocSearchSource(
 
 	default: // "project"
 		query = `
-			SELECT vector.content, vector.embedding <#> ` + vectorStr + `::vector AS distance, doc.title
+			SELECT vector.content, vector.embedding <#> ` + vectorStr + `::vector AS distance, doc.title, doc.id, vector.block_id
 			FROM st_schema.project_document_vectors vector
 			JOIN st_schema.project_documents doc ON doc.id = vector.document_id
 			WHERE vector.tenant_id = $1 AND vector.project_id = $2
@@ -100,10 +220,150 @@ func selectDocSearchSource(
 	query += fmt.Sprintf(` ORDER BY distance ASC LIMIT $%d`, len(args)+1)
 	args = append(args, req.Limit)
 
-	return tenantManagement.DB.Query(query, args...)
+	return tenantManagement.DB.QueryContext(ctx, query, args...)
 }
 
-func DocumentSearch(openaiClient *openai.Client, req *DocSearchRequest, chatCtx *models.ChatContext) ([]DocSearchResult, error) {
+// selectHybridDocSearchSource ranks fragments twice, by vector distance and by full-text
+// relevance, and fuses both rankings: score = (1-w)/(k+semantic rank) + w/(k+keyword rank).
+// Fragments found by only one of the rankings still get their share of the score.
+func selectHybridDocSearchSource(
+	ctx context.Context,
+	req *DocSearchRequest,
+	vectorStr string,
+	chatCtx *models.ChatContext,
+) (*sql.Rows, error) {
+	if chatCtx.ResourceGroupID == nil {
+		return nil, fmt.Errorf("cannot perform hybrid search without knowing project/template id")
+	}
+
+	source, ok := aiIndexing.DocumentVectorTables[chatCtx.ResourceGroupType]
+	if !ok {
+		source = aiIndexing.DocumentVectorTables[models.ResourceGroupProject]
+	}
+
+	args := []any{chatCtx.TenantID, chatCtx.ResourceGroupID}
+	filter := fmt.Sprintf(`vector.tenant_id = $1 AND vector.%s = $2`, source.GroupColumn)
+
+	if scope := resolveDocSearchScope(req, chatCtx); len(scope) > 0 {
+		args = append(args, pq.Array(scope))
+		filter += fmt.Sprintf(` AND vector.%s = ANY($%d)`, source.DocumentColumn, len(args))
+	}
+
+	keywordWeight := defaultKeywordWeight
+	if req.KeywordWeight != nil {
+		keywordWeight = min(max(*req.KeywordWeight, 0), 1)
+	}
+
+	// Each ranking contributes a few times more candidates than requested
+	candidates := max(req.Limit*4, 40)
+
+	args = append(args, req.Query, candidates, 1-keywordWeight, keywordWeight, req.Limit)
+	n := len(args)
+	queryArg, candidatesArg, semanticWeightArg, keywordWeightArg, limitArg := n-4, n-3, n-2, n-1, n
+
+	// Vector candidates are taken with ORDER BY distance LIMIT first so the ANN index is used,
+	// only those are ranked. Terms are OR-ed so a question matches fragments containing any of
+	// its keywords, ts_rank_cd still prefers fragments covering more of them
+	query := fmt.Sprintf(`
+		WITH semantic AS (
+			SELECT candidate.id, ROW_NUMBER() OVER (ORDER BY candidate.distance) AS rank
+			FROM (
+				SELECT vector.id, vector.embedding <#> `+vectorStr+`::vector AS distance
+				FROM %[1]s vector
+				WHERE %[2]s
+				ORDER BY distance
+				LIMIT $%[5]d
+			) candidate
+		),
+		keyword AS (
+			SELECT vector.id, ROW_NUMBER() OVER (
+				ORDER BY ts_rank_cd(to_tsvector('%[9]s', vector.content), search.query) DESC
+			) AS rank
+			FROM %[1]s vector,
+				(SELECT replace(plainto_tsquery('%[9]s', $%[4]d)::text, ' & ', ' | ')::tsquery AS query) search
+			WHERE %[2]s AND to_tsvector('%[9]s', vector.content) @@ search.query
+			ORDER BY rank
+			LIMIT $%[5]d
+		)
+		SELECT
+			vector.content,
+			vector.embedding <#> `+vectorStr+`::vector AS distance,
+			doc.title,
+			doc.id,
+			vector.block_id,
+			COALESCE($%[6]d::float8 / (%[10]d + semantic.rank), 0) +
+			COALESCE($%[7]d::float8 / (%[10]d + keyword.rank), 0) AS score
+		FROM semantic
+		FULL OUTER JOIN keyword ON keyword.id = semantic.id
+		JOIN %[1]s vector ON vector.id = COALESCE(semantic.id, keyword.id)
+		JOIN %[3]s doc ON doc.id = vector.%[11]s
+		ORDER BY score DESC
+		LIMIT $%[8]d
+	`,
+		source.Table, filter, source.SourceTable,
+		queryArg, candidatesArg, semanticWeightArg, keywordWeightArg, limitArg,
+		textSearchConfig, rrfK, source.DocumentColumn,
+	)
+
+	return tenantManagement.DB.QueryContext(ctx, query, args...)
+}
+
+// keywordTsQuery ORs the terms of the search phrase in the given param, so a question matches fragments
+// containing any of its keywords. ts_rank_cd still prefers fragments covering more of them.
+func keywordTsQuery(param int) string {
+	return fmt.Sprintf(`replace(plainto_tsquery('%s', $%d)::text, ' & ', ' | ')::tsquery`, textSearchConfig, param)
+}
+
+// selectKeywordDocSearchSource ranks fragments by full-text relevance only, used when the query
+// can't be embedded. The rows have the columns of the hybrid search, the score is the text rank.
+func selectKeywordDocSearchSource(
+	ctx context.Context,
+	req *DocSearchRequest,
+	chatCtx *models.ChatContext,
+) (*sql.Rows, error) {
+	if chatCtx.ResourceGroupID == nil {
+		return nil, fmt.Errorf("cannot perform keyword search without knowing project/template id")
+	}
+
+	source, ok := aiIndexing.DocumentVectorTables[chatCtx.ResourceGroupType]
+	if !ok {
+		source = aiIndexing.DocumentVectorTables[models.ResourceGroupProject]
+	}
+
+	args := []any{chatCtx.TenantID, chatCtx.ResourceGroupID}
+	filter := fmt.Sprintf(`vector.tenant_id = $1 AND vector.%s = $2`, source.GroupColumn)
+
+	if scope := resolveDocSearchScope(req, chatCtx); len(scope) > 0 {
+		args = append(args, pq.Array(scope))
+		filter += fmt.Sprintf(` AND vector.%s = ANY($%d)`, source.DocumentColumn, len(args))
+	}
+
+	args = append(args, req.Query, req.Limit)
+	queryArg, limitArg := len(args)-1, len(args)
+
+	query := fmt.Sprintf(`
+		SELECT
+			vector.content,
+			0::float8 AS distance,
+			doc.title,
+			doc.id,
+			vector.block_id,
+			ts_rank_cd(to_tsvector('%[5]s', vector.content), search.query)::float8 AS score
+		FROM %[1]s vector
+		JOIN %[3]s doc ON doc.id = vector.%[4]s,
+			(SELECT %[6]s AS query) search
+		WHERE %[2]s AND to_tsvector('%[5]s', vector.content) @@ search.query
+		ORDER BY score DESC
+		LIMIT $%[7]d
+	`,
+		source.Table, filter, source.SourceTable, source.DocumentColumn,
+		textSearchConfig, keywordTsQuery(queryArg), limitArg,
+	)
+
+	return tenantManagement.DB.QueryContext(ctx, query, args...)
+}
+
+func DocumentSearch(ctx context.Context, openaiClient *openai.Client, req *DocSearchRequest, chatCtx *models.ChatContext) ([]DocSearchResult, error) {
 	// 🪵 Pretty log input
 	if payload, err := json.MarshalIndent(req, "", "  "); err == nil {
 		log.Printf("[DocumentSearch] Request:\n%s", payload)
@@ -111,12 +371,30 @@ func DocumentSearch(openaiClient *openai.Client, req *DocSearchRequest, chatCtx
 		log.Printf("[DocumentSearch] Failed to marshal request: %v", err)
 	}
 
-	vectorStr, err := embedQuery(openaiClient, req.Query)
-	if err != nil {
+	// Without budget for the query embedding the fragments are still found by their keywords
+	vectorStr, err := embedQuery(ctx, openaiClient, chatCtx, req.Query)
+	keywordOnly := errors.Is(err, aiUsage.ErrBudgetExhausted)
+	if keywordOnly {
+		log.Printf("[DocumentSearch] Falling back to keyword search: %v", err)
+	} else if err != nil {
 		return nil, fmt.Errorf("failed to generate embedding for a query message: %v", err)
 	}
 
-	rows, err := selectDocSearchSource(req, *vectorStr, chatCtx)
+	if req.Limit <= 0 {
+		req.Limit = defaultDocSearchLimit
+	}
+
+	hybrid := keywordOnly || req.Mode != DocSearchModeSemantic
+
+	var rows *sql.Rows
+	switch {
+	case keywordOnly:
+		rows, err = selectKeywordDocSearchSource(ctx, req, chatCtx)
+	case hybrid:
+		rows, err = selectHybridDocSearchSource(ctx, req, *vectorStr, chatCtx)
+	default:
+		rows, err = selectDocSearchSource(ctx, req, *vectorStr, chatCtx)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed preparing query: %v", err)
 	}
@@ -125,7 +403,11 @@ func DocumentSearch(openaiClient *openai.Client, req *DocSearchRequest, chatCtx
 	var results []DocSearchResult
 	for rows.Next() {
 		var res DocSearchResult
-		if err := rows.Scan(&res.Content, &res.Distance, &res.Title); err != nil {
+		dest := []any{&res.Content, &res.Distance, &res.Title, &res.DocumentID, &res.BlockID}
+		if hybrid {
+			dest = append(dest, &res.Score)
+		}
+		if err := rows.Scan(dest...); err != nil {
 			return nil, fmt.Errorf("failed to read db row: %v", err)
 		}
 		results = append(results, res)
@@ -140,11 +422,27 @@ func DocumentSearchHandler(c *gin.Context) {
 		return
 	}
 
-	projectID := c.Query("project_id")
-	if projectID == "" {
+	// Templates are searched by passing the resource identifier (rgt, rgi),
+	// project_id is kept for existing clients
+	resourceIdentifier := &models.ResourceIdentifier{
+		ResourceGroupType: models.ResourceGroupProject,
+	}
+	if c.Query("rgt") != "" {
+		var err error
+		resourceIdentifier, err = utilities.ResolveResourceIdentifier(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown or missing resource identifier"})
+			return
+		}
+	} else if projectID := c.Query("project_id"); projectID != "" {
+		resourceIdentifier.ResourceGroupID = &projectID
+	}
+
+	if resourceIdentifier.ResourceGroupID == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Project ID is required"})
 		return
 	}
+	resourceIdentifier.ResourceType = utilities.Ptr(models.ResourceTypeDocument)
 
 	var req DocSearchRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -152,23 +450,22 @@ func DocumentSearchHandler(c *gin.Context) {
 		return
 	}
 
-	openaiConfig, err := utilities.GetOpenAiConfig(tenantManagement.DB, tenantID)
+	if req.Mode != "" && req.Mode != DocSearchModeHybrid && req.Mode != DocSearchModeSemantic {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Mode must be either hybrid or semantic"})
+		return
+	}
+
+	openaiClient, _, err := utilities.GetOpenAiClient(tenantManagement.DB, tenantID)
 	if err != nil {
+		log.Printf("Failed to get OpenAI client: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
 	}
 
-	openaiClient := openai.NewClient(
-		option.WithAPIKey(openaiConfig.OpenAIApiKey),
-	)
-
-	results, err := DocumentSearch(&openaiClient, &req, &models.ChatContext{
-		UserID:   userID,
-		TenantID: tenantID,
-		ResourceIdentifier: models.ResourceIdentifier{
-			ResourceGroupType: models.ResourceGroupProject,
-			ResourceGroupID:   &projectID,
-			ResourceType:      utilities.Ptr(models.ResourceTypeDocument),
-		},
+	results, err := DocumentSearch(c.Request.Context(), openaiClient, &req, &models.ChatContext{
+		UserID:             userID,
+		TenantID:           tenantID,
+		ResourceIdentifier: *resourceIdentifier,
 	})
 	if err != nil {
 		log.Printf("Semantic search failed: %v", err)
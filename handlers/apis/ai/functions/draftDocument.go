@@ -0,0 +1,309 @@
+package aiFunctions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	aiIndexing "sententiawebapi/handlers/apis/ai/indexing"
+	"sententiawebapi/handlers/apis/tenantManagement"
+	"sententiawebapi/handlers/models"
+
+	"github.com/gin-gonic/gin"
+	openai "github.com/openai/openai-go"
+)
+
+type DraftDocumentRequest struct {
+	Title   string `json:"title"`
+	Content string `json:"content"` // Markdown
+}
+
+// Tiptap JSON node, only the parts produced by the markdown conversion
+type tiptapDraftNode struct {
+	Type    string            `json:"type"`
+	Attrs   map[string]any    `json:"attrs,omitempty"`
+	Content []tiptapDraftNode `json:"content,omitempty"`
+	Text    string            `json:"text,omitempty"`
+	Marks   []tiptapDraftMark `json:"marks,omitempty"`
+}
+
+type tiptapDraftMark struct {
+	Type string `json:"type"`
+}
+
+var (
+	markdownHeading     = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	markdownBulletItem  = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+	markdownOrderedItem = regexp.MustCompile(`^\s*\d+[.)]\s+(.*)$`)
+	markdownInline      = regexp.MustCompile("\\*\\*([^*]+)\\*\\*|`([^`]+)`")
+)
+
+func init() {
+	RegisterTool(Tool{
+		Name: "draft_document",
+		Description: `
+			Creates a new document in the current project from markdown content.
+			Only call it when the user asks for a new document. The user reviews the draft before it is created.
+		`,
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"title": map[string]string{
+					"type":        "string",
+					"description": "Document title.",
+				},
+				"content": map[string]string{
+					"type":        "string",
+					"description": "Document body in markdown. Supported: headings, paragraphs, bullet and numbered lists, code blocks, **bold** and `code`.",
+				},
+			},
+			"required": []string{"title", "content"},
+		},
+		ResourceGroupTypes:      []models.ResourceGroupType{models.ResourceGroupProject},
+		RequiresResourceGroupID: true,
+		Mutating:                true,
+		Execute:                 executeDraftDocument,
+		Preview:                 previewDraftDocument,
+	})
+}
+
+// markdownToTiptap converts the markdown subset written by the model into a Tiptap document
+func markdownToTiptap(markdown string) tiptapDraftNode {
+	doc := tiptapDraftNode{Type: "doc"}
+
+	var paragraph []string
+	var list *tiptapDraftNode
+
+	flushParagraph := func() {
+		if len(paragraph) > 0 {
+			doc.Content = append(doc.Content, tiptapDraftNode{
+				Type:    "paragraph",
+				Content: inlineNodes(strings.Join(paragraph, " ")),
+			})
+			paragraph = nil
+		}
+	}
+	flushList := func() {
+		if list != nil {
+			doc.Content = append(doc.Content, *list)
+			list = nil
+		}
+	}
+	addListItem := func(listType, text string) {
+		if list != nil && list.Type != listType {
+			flushList()
+		}
+		if list == nil {
+			list = &tiptapDraftNode{Type: listType}
+		}
+		list.Content = append(list.Content, tiptapDraftNode{
+			Type:    "listItem",
+			Content: []tiptapDraftNode{{Type: "paragraph", Content: inlineNodes(text)}},
+		})
+	}
+
+	lines := strings.Split(strings.ReplaceAll(markdown, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			flushParagraph()
+			flushList()
+
+			language := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "```"))
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				code = append(code, lines[i])
+			}
+
+			block := tiptapDraftNode{Type: "codeBlock"}
+			if language != "" {
+				block.Attrs = map[string]any{"language": language}
+			}
+			if text := strings.Join(code, "\n"); text != "" {
+				block.Content = []tiptapDraftNode{{Type: "text", Text: text}}
+			}
+			doc.Content = append(doc.Content, block)
+			continue
+		}
+
+		if strings.TrimSpace(line) == "" {
+			flushParagraph()
+			flushList()
+			continue
+		}
+
+		if match := markdownHeading.FindStringSubmatch(line); match != nil {
+			flushParagraph()
+			flushList()
+			doc.Content = append(doc.Content, tiptapDraftNode{
+				Type:    "heading",
+				Attrs:   map[string]any{"level": len(match[1])},
+				Content: inlineNodes(match[2]),
+			})
+			continue
+		}
+
+		if match := markdownBulletItem.FindStringSubmatch(line); match != nil {
+			flushParagraph()
+			addListItem("bulletList", match[1])
+			continue
+		}
+
+		if match := markdownOrderedItem.FindStringSubmatch(line); match != nil {
+			flushParagraph()
+			addListItem("orderedList", match[1])
+			continue
+		}
+
+		flushList()
+		paragraph = append(paragraph, strings.TrimSpace(line))
+	}
+
+	flushParagraph()
+	flushList()
+
+	if len(doc.Content) == 0 {
+		doc.Content = []tiptapDraftNode{{Type: "paragraph"}}
+	}
+
+	return doc
+}
+
+// inlineNodes splits text into text nodes with bold and code marks
+func inlineNodes(text string) []tiptapDraftNode {
+	var nodes []tiptapDraftNode
+	appendText := func(value string, mark string) {
+		if value == "" {
+			return
+		}
+		node := tiptapDraftNode{Type: "text", Text: value}
+		if mark != "" {
+			node.Marks = []tiptapDraftMark{{Type: mark}}
+		}
+		nodes = append(nodes, node)
+	}
+
+	position := 0
+	for _, match := range markdownInline.FindAllStringSubmatchIndex(text, -1) {
+		appendText(text[position:match[0]], "")
+		if match[2] >= 0 {
+			appendText(text[match[2]:match[3]], "bold")
+		} else {
+			appendText(text[match[4]:match[5]], "code")
+		}
+		position = match[1]
+	}
+	appendText(text[position:], "")
+
+	return nodes
+}
+
+func DraftDocument(req *DraftDocumentRequest, chatCtx *models.ChatContext) (string, error) {
+	if chatCtx.ResourceGroupID == nil {
+		return "", fmt.Errorf("project ID is missing")
+	}
+	projectID := *chatCtx.ResourceGroupID
+
+	content, err := json.Marshal(markdownToTiptap(req.Content))
+	if err != nil {
+		return "", fmt.Errorf("failed to build document content: %w", err)
+	}
+
+	tx, err := tenantManagement.DB.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback() // Will be no-op if transaction is committed
+
+	var documentID string
+	err = tx.QueryRow(`
+		INSERT INTO st_schema.project_documents (
+			user_id, tenant_id, project_id, title, content_json
+		) VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`, chatCtx.UserID, chatCtx.TenantID, projectID, req.Title, content).Scan(&documentID)
+	if err != nil {
+		return "", fmt.Errorf("failed to create document: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE st_schema.projects
+		SET updated_at = NOW()
+		WHERE id = $1 AND tenant_id = $2
+	`, projectID, chatCtx.TenantID)
+	if err != nil {
+		return "", fmt.Errorf("failed to update project timestamp: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	raw := json.RawMessage(content)
+	aiIndexing.IndexDocument(aiIndexing.DocumentRef{
+		Type:       models.ResourceGroupProject,
+		TenantID:   chatCtx.TenantID,
+		UserID:     chatCtx.UserID,
+		GroupID:    projectID,
+		DocumentID: documentID,
+	}, &raw)
+
+	return documentID, nil
+}
+
+func parseDraftDocument(rawArguments string) (*DraftDocumentRequest, error) {
+	var arguments DraftDocumentRequest
+	if err := json.Unmarshal([]byte(rawArguments), &arguments); err != nil {
+		return nil, fmt.Errorf("invalid function arguments: %v", rawArguments)
+	}
+
+	arguments.Title = strings.TrimSpace(arguments.Title)
+	if arguments.Title == "" {
+		return nil, &ToolArgumentError{Message: "Document was not created: title is required."}
+	}
+
+	return &arguments, nil
+}
+
+func previewDraftDocument(_ *models.ChatContext, rawArguments string) (*models.ActionPreview, error) {
+	arguments, err := parseDraftDocument(rawArguments)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ActionPreview{
+		Summary:      fmt.Sprintf("Create document %q", arguments.Title),
+		ResourceType: string(models.ResourceTypeDocument),
+		After: gin.H{
+			"title":        arguments.Title,
+			"content":      arguments.Content,
+			"content_json": markdownToTiptap(arguments.Content),
+		},
+	}, nil
+}
+
+func executeDraftDocument(_ context.Context, _ *openai.Client, chatCtx *models.ChatContext, rawArguments string) (string, []models.Citation, error) {
+	arguments, err := parseDraftDocument(rawArguments)
+	if err != nil {
+		return "", nil, err
+	}
+
+	documentID, err := DraftDocument(arguments, chatCtx)
+	if err != nil {
+		return "", nil, fmt.Errorf("Draft document failed: %v", err)
+	}
+
+	log.Printf("Document %s drafted by AI for user %s", documentID, chatCtx.UserID)
+
+	citation := models.Citation{
+		ResourceType: models.ResourceTypeDocument,
+		ResourceID:   documentID,
+		Title:        arguments.Title,
+	}
+
+	return fmt.Sprintf("Document created: %s (@ref(doc: %s))", arguments.Title, documentID), []models.Citation{citation}, nil
+}
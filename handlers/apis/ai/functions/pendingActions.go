@@ -0,0 +1,476 @@
+package aiFunctions
+
+// Mutating tool calls wait for the user approval. The paused response is stored in
+// st_schema.ai_pending_actions, one row per function call. Once every call is decided
+// the outputs are sent back to the model as function_call_output items.
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	aiVersions "sententiawebapi/handlers/apis/ai/versions"
+	"sententiawebapi/handlers/apis/tenantManagement"
+	"sententiawebapi/handlers/models"
+	"sententiawebapi/utilities"
+
+	"github.com/gin-gonic/gin"
+	openai "github.com/openai/openai-go"
+	"github.com/openai/openai-go/responses"
+)
+
+var (
+	ErrPendingActionNotFound = errors.New("pending action not found")
+	ErrPendingActionDecided  = errors.New("pending action is already decided")
+)
+
+// staleExecutingActionAfter is how long an approved action may stay executing. Tools finish within
+// functionCallTimeout, an older executing action was interrupted (e.g. by a restart) and is failed.
+const staleExecutingActionAfter = 10 * time.Minute
+
+// PendingActionsResume holds everything needed to continue the paused response
+type PendingActionsResume struct {
+	ChatContext *models.ChatContext
+	ResponseID  string
+	Inputs      []responses.ResponseInputItemUnionParam
+}
+
+const pendingActionColumns = `
+	id, conversation_id, response_id, call_id, tool_name, arguments, preview,
+	status, output, decision_comment, created_at, decided_at
+`
+
+// createPendingActions stores all calls of the response and returns the ones waiting for approval
+func createPendingActions(chatCtx *models.ChatContext, responseID string, results []functionCallResult) ([]models.PendingAction, error) {
+	if chatCtx.ConversationID == nil {
+		return nil, fmt.Errorf("pending actions require a conversation")
+	}
+
+	tx, err := tenantManagement.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() // Will be no-op if transaction is committed
+
+	pending := make([]models.PendingAction, 0, len(results))
+	for i, result := range results {
+		status := models.PendingActionCompleted
+		var output *string
+		if result.Preview != nil {
+			status = models.PendingActionPending
+		} else {
+			output = &result.Output
+		}
+
+		var previewJSON []byte
+		if result.Preview != nil {
+			if previewJSON, err = json.Marshal(result.Preview); err != nil {
+				return nil, err
+			}
+		}
+
+		// Sources of the earlier rounds are kept with the first call of the batch
+		citations := result.Citations
+		if i == 0 {
+			citations = MergeCitations(append([]models.Citation(nil), chatCtx.Citations...), citations)
+		}
+		var citationsJSON []byte
+		if len(citations) > 0 {
+			if citationsJSON, err = json.Marshal(citations); err != nil {
+				return nil, err
+			}
+		}
+
+		row := tx.QueryRow(fmt.Sprintf(`
+			INSERT INTO st_schema.ai_pending_actions (
+				tenant_id, user_id, conversation_id, response_id, call_id, position,
+				tool_name, arguments, preview, status, output, citations, assistant_name,
+				resource_group_type, resource_group_id, resource_type, resource_id
+			) VALUES (
+				$1, $2, $3, $4, $5, $6,
+				$7, $8, $9, $10, $11, $12, $13,
+				$14, $15, $16, $17
+			) RETURNING %s
+		`, pendingActionColumns),
+			chatCtx.TenantID, chatCtx.UserID, *chatCtx.ConversationID, responseID, result.CallID, i,
+			result.Name, result.Arguments, nullableJSON(previewJSON), status, output, nullableJSON(citationsJSON), chatCtx.AssistantName,
+			chatCtx.ResourceGroupType, chatCtx.ResourceGroupID, chatCtx.ResourceType, chatCtx.ResourceID,
+		)
+
+		action, err := scanPendingAction(row)
+		if err != nil {
+			return nil, err
+		}
+		if action.Status == models.PendingActionPending {
+			pending = append(pending, *action)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	chatCtx.Citations = nil // Stored with the actions until the response is resumed
+
+	return pending, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanPendingAction(row rowScanner) (*models.PendingAction, error) {
+	var action models.PendingAction
+	var previewJSON []byte
+	var decidedAt sql.NullTime
+
+	err := row.Scan(
+		&action.ID,
+		&action.ConversationID,
+		&action.ResponseID,
+		&action.CallID,
+		&action.ToolName,
+		&action.Arguments,
+		&previewJSON,
+		&action.Status,
+		&action.Output,
+		&action.Comment,
+		&action.CreatedAt,
+		&decidedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(previewJSON) > 0 {
+		if err := json.Unmarshal(previewJSON, &action.Preview); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal action preview: %w", err)
+		}
+	}
+	if decidedAt.Valid {
+		action.DecidedAt = &decidedAt.Time
+	}
+
+	return &action, nil
+}
+
+func nullableJSON(value []byte) any {
+	if len(value) == 0 {
+		return nil
+	}
+	return value
+}
+
+// HasPendingActions reports whether the conversation waits for a user decision or an approved action to finish.
+// Interrupted actions are failed first, they never block the conversation.
+func HasPendingActions(tenantID string, conversationID string) (bool, error) {
+	if err := failStaleExecutingActions(tenantID, conversationID); err != nil {
+		return false, err
+	}
+
+	var exists bool
+	err := tenantManagement.DB.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM st_schema.ai_pending_actions
+			WHERE tenant_id = $1 AND conversation_id = $2
+			AND (status = $3 OR (status = $4 AND decided_at >= $5))
+		)
+	`, tenantID, conversationID, models.PendingActionPending, models.PendingActionExecuting,
+		time.Now().Add(-staleExecutingActionAfter)).Scan(&exists)
+	return exists, err
+}
+
+// failStaleExecutingActions fails the approved actions of the conversation whose execution was interrupted.
+// The tool may have written before the interruption, the output tells the model to check.
+func failStaleExecutingActions(tenantID string, conversationID string) error {
+	result, err := tenantManagement.DB.Exec(`
+		UPDATE st_schema.ai_pending_actions
+		SET status = $1, output = $2
+		WHERE tenant_id = $3 AND conversation_id = $4 AND status = $5 AND decided_at < $6
+	`, models.PendingActionFailed,
+		"The user approved the action, but its execution was interrupted. It may or may not have been applied.",
+		tenantID, conversationID, models.PendingActionExecuting, time.Now().Add(-staleExecutingActionAfter))
+	if err != nil {
+		return err
+	}
+
+	if failed, err := result.RowsAffected(); err == nil && failed > 0 {
+		log.Printf("Failed %d interrupted AI actions of conversation %s", failed, conversationID)
+	}
+
+	return nil
+}
+
+// GetPendingActions returns the actions of the user in the conversation waiting for a decision
+func GetPendingActions(tenantID string, userID string, conversationID string) ([]models.PendingAction, error) {
+	rows, err := tenantManagement.DB.Query(fmt.Sprintf(`
+		SELECT %s
+		FROM st_schema.ai_pending_actions
+		WHERE tenant_id = $1 AND user_id = $2 AND conversation_id = $3 AND status = $4
+		ORDER BY created_at, position
+	`, pendingActionColumns), tenantID, userID, conversationID, models.PendingActionPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	actions := []models.PendingAction{}
+	for rows.Next() {
+		action, err := scanPendingAction(rows)
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, *action)
+	}
+
+	return actions, rows.Err()
+}
+
+// DecidePendingAction approves (executes) or rejects the action. When it was the last undecided
+// call of the response, the returned resume holds the function outputs for the model.
+// An approved action is claimed and committed as executing before the tool runs, the tools write
+// outside of this transaction and a failure after the write must not leave the action pending.
+// Actions left executing by a crash are failed by HasPendingActions after staleExecutingActionAfter.
+func DecidePendingAction(
+	ctx context.Context,
+	client *openai.Client,
+	tenantID string,
+	userID string,
+	actionID string,
+	approve bool,
+	comment string,
+) (*models.PendingAction, *PendingActionsResume, error) {
+	tx, err := tenantManagement.DB.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback() // Will be no-op if transaction is committed
+
+	var responseID string
+	chatCtx := &models.ChatContext{UserID: userID, TenantID: tenantID}
+	var conversationID string
+	err = tx.QueryRow(`
+		SELECT response_id, conversation_id, assistant_name,
+			resource_group_type, resource_group_id, resource_type, resource_id
+		FROM st_schema.ai_pending_actions
+		WHERE id = $1 AND tenant_id = $2 AND user_id = $3
+	`, actionID, tenantID, userID).Scan(
+		&responseID,
+		&conversationID,
+		&chatCtx.AssistantName,
+		&chatCtx.ResourceGroupType,
+		&chatCtx.ResourceGroupID,
+		&chatCtx.ResourceType,
+		&chatCtx.ResourceID,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil, ErrPendingActionNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	chatCtx.ConversationID = &conversationID
+
+	// The tool switches of the template apply to the approval as well, a tool disabled since the call was proposed fails
+	templateConfig, err := aiVersions.GetConversationTemplateConfig(tenantID, conversationID)
+	if err == nil {
+		chatCtx.ToolSettings = templateConfig.Tools
+	} else if !errors.Is(err, aiVersions.ErrTemplateNotFound) {
+		return nil, nil, err
+	}
+
+	if err := lockPendingActionsResponse(tx, tenantID, responseID); err != nil {
+		return nil, nil, err
+	}
+
+	var status models.PendingActionStatus
+	var toolName, arguments string
+	err = tx.QueryRow(`
+		SELECT status, tool_name, arguments
+		FROM st_schema.ai_pending_actions
+		WHERE id = $1
+	`, actionID).Scan(&status, &toolName, &arguments)
+	if err != nil {
+		return nil, nil, err
+	}
+	if status != models.PendingActionPending {
+		return nil, nil, ErrPendingActionDecided
+	}
+
+	var nullableComment *string
+	if comment != "" {
+		nullableComment = &comment
+	}
+
+	if !approve {
+		output := "The user rejected the action. Nothing was changed."
+		if comment != "" {
+			output += "\nUser comment: " + comment
+		}
+
+		action, err := scanPendingAction(tx.QueryRow(fmt.Sprintf(`
+			UPDATE st_schema.ai_pending_actions
+			SET status = $1, output = $2, decision_comment = $3, decided_at = $4
+			WHERE id = $5
+			RETURNING %s
+		`, pendingActionColumns), models.PendingActionRejected, output, nullableComment, time.Now(), actionID))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return finishPendingActionDecision(tx, chatCtx, responseID, action)
+	}
+
+	// Claim the action, a second approval now finds it decided and never repeats the write
+	_, err = tx.Exec(`
+		UPDATE st_schema.ai_pending_actions
+		SET status = $1, decision_comment = $2, decided_at = $3
+		WHERE id = $4
+	`, models.PendingActionExecuting, nullableComment, time.Now(), actionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+
+	status = models.PendingActionApproved
+	output, citations, err := ExecuteFunctionCall(ctx, client, chatCtx, toolName, arguments)
+	if err != nil {
+		// The model is told about the failure instead of breaking the conversation
+		log.Printf("Approved action %s failed: %v", actionID, err)
+		status = models.PendingActionFailed
+		output = "The user approved the action, but it failed. Nothing was changed."
+		citations = nil
+	}
+
+	var citationsJSON []byte
+	if len(citations) > 0 {
+		if citationsJSON, err = json.Marshal(citations); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	tx, err = tenantManagement.DB.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback() // Will be no-op if transaction is committed
+
+	if err := lockPendingActionsResponse(tx, tenantID, responseID); err != nil {
+		return nil, nil, err
+	}
+
+	action, err := scanPendingAction(tx.QueryRow(fmt.Sprintf(`
+		UPDATE st_schema.ai_pending_actions
+		SET status = $1, output = $2, citations = $3
+		WHERE id = $4 AND status = $5
+		RETURNING %s
+	`, pendingActionColumns), status, output, nullableJSON(citationsJSON), actionID, models.PendingActionExecuting))
+	if err == sql.ErrNoRows {
+		// Considered interrupted and failed in the meantime
+		log.Printf("Approved action %s finished after it was failed as interrupted", actionID)
+		return nil, nil, ErrPendingActionDecided
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return finishPendingActionDecision(tx, chatCtx, responseID, action)
+}
+
+// lockPendingActionsResponse locks all calls of the response so concurrent decisions resume it only once
+func lockPendingActionsResponse(tx *sql.Tx, tenantID string, responseID string) error {
+	_, err := tx.Exec(`
+		SELECT id FROM st_schema.ai_pending_actions
+		WHERE tenant_id = $1 AND response_id = $2
+		FOR UPDATE
+	`, tenantID, responseID)
+	return err
+}
+
+// finishPendingActionDecision commits the decided action and returns the resume once every call is decided
+func finishPendingActionDecision(tx *sql.Tx, chatCtx *models.ChatContext, responseID string, action *models.PendingAction) (*models.PendingAction, *PendingActionsResume, error) {
+	resume, err := getPendingActionsResume(tx, chatCtx, responseID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+
+	return action, resume, nil
+}
+
+// getPendingActionsResume returns nil while some calls of the response are still pending
+func getPendingActionsResume(tx *sql.Tx, chatCtx *models.ChatContext, responseID string) (*PendingActionsResume, error) {
+	rows, err := tx.Query(`
+		SELECT call_id, status, output, citations
+		FROM st_schema.ai_pending_actions
+		WHERE tenant_id = $1 AND response_id = $2
+		ORDER BY position
+	`, chatCtx.TenantID, responseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resume := &PendingActionsResume{ChatContext: chatCtx, ResponseID: responseID}
+	for rows.Next() {
+		var callID string
+		var status models.PendingActionStatus
+		var output sql.NullString
+		var citationsJSON []byte
+		if err := rows.Scan(&callID, &status, &output, &citationsJSON); err != nil {
+			return nil, err
+		}
+
+		if status == models.PendingActionPending || status == models.PendingActionExecuting {
+			return nil, nil
+		}
+
+		if len(citationsJSON) > 0 {
+			var citations []models.Citation
+			if err := json.Unmarshal(citationsJSON, &citations); err != nil {
+				return nil, err
+			}
+			chatCtx.Citations = MergeCitations(chatCtx.Citations, citations)
+		}
+
+		resume.Inputs = append(resume.Inputs, responses.ResponseInputItemParamOfFunctionCallOutput(callID, output.String))
+	}
+
+	return resume, rows.Err()
+}
+
+func GetPendingActionsHandler(c *gin.Context) {
+	userID, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	conversationID := c.Query("conversation_id")
+	if conversationID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Conversation ID is required"})
+		return
+	}
+
+	// Only the user who prompted can decide the actions, the same as DecidePendingAction
+	actions, err := GetPendingActions(tenantID, userID, conversationID)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    actions,
+		"message": "Pending actions retrieved successfully!",
+	})
+}
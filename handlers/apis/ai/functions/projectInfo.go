@@ -1,6 +1,7 @@
 package aiFunctions
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
@@ -11,8 +12,30 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	openai "github.com/openai/openai-go"
 )
 
+func init() {
+	RegisterTool(Tool{
+		Name: "project_info",
+		Description: `
+			Retrieves high-level information about the current project, including its title, status, category, complexity, description, and all associated requirements.
+			This information can help you better understand the project context before generating ideas, giving suggestions, or answering questions.
+		`,
+		RequiresResourceGroupID: true,
+		Execute: func(ctx context.Context, _ *openai.Client, chatCtx *models.ChatContext, _ string) (string, []models.Citation, error) {
+			projectInfo, err := GetProjectInfo(ctx, chatCtx)
+			if err != nil {
+				return "", nil, fmt.Errorf("Project Info function call failed: %v", err)
+			}
+
+			log.Printf("Project info: %s", projectInfo)
+
+			return projectInfo, nil, nil
+		},
+	})
+}
+
 type ProjectData struct {
 	Title        string
 	Status       string
@@ -29,9 +52,9 @@ type RequirementData struct {
 	Status   sql.NullString
 }
 
-func getProjectData(projectID, tenantID string) (*ProjectData, error) {
+func getProjectData(ctx context.Context, projectID, tenantID string) (*ProjectData, error) {
 	var project ProjectData
-	err := tenantManagement.DB.QueryRow(`
+	err := tenantManagement.DB.QueryRowContext(ctx, `
 		SELECT title, status, category, description, complexity
 		FROM st_schema.projects
 		WHERE id = $1 AND tenant_id = $2
@@ -46,7 +69,7 @@ func getProjectData(projectID, tenantID string) (*ProjectData, error) {
 		return nil, fmt.Errorf("error fetching project: %w", err)
 	}
 
-	rows, err := tenantManagement.DB.Query(`
+	rows, err := tenantManagement.DB.QueryContext(ctx, `
 		SELECT title, details, category, status
 		FROM st_schema.project_requirements
 		WHERE project_id = $1 AND tenant_id = $2
@@ -68,9 +91,9 @@ func getProjectData(projectID, tenantID string) (*ProjectData, error) {
 	return &project, nil
 }
 
-func getTemplateData(templateID, tenantID string) (*ProjectData, error) {
+func getTemplateData(ctx context.Context, templateID, tenantID string) (*ProjectData, error) {
 	var data ProjectData
-	err := tenantManagement.DB.QueryRow(`
+	err := tenantManagement.DB.QueryRowContext(ctx, `
 		SELECT title, 'Template' AS status, category, description, complexity
 		FROM st_schema.project_templates
 		WHERE id = $1 AND tenant_id = $2
@@ -89,9 +112,9 @@ func getTemplateData(templateID, tenantID string) (*ProjectData, error) {
 	return &data, nil
 }
 
-func getCommunityTemplateData(templateID, tenantID string) (*ProjectData, error) {
+func getCommunityTemplateData(ctx context.Context, templateID, tenantID string) (*ProjectData, error) {
 	var data ProjectData
-	err := tenantManagement.DB.QueryRow(`
+	err := tenantManagement.DB.QueryRowContext(ctx, `
 		SELECT title, 'Community Template' AS status, category, description, complexity
 		FROM st_schema.cm_project_templates
 		WHERE id = $1 AND tenant_id = $2
@@ -125,44 +148,52 @@ func formatProjectInfo(data *ProjectData) string {
 
 	if len(data.Requirements) > 0 {
 		sb.WriteString("\nRequirements:\n")
-		for _, r := range data.Requirements {
-			status := "-"
-			if r.Status.Valid {
-				status = r.Status.String
-			}
-			category := "-"
-			if r.Category.Valid {
-				category = r.Category.String
-			}
-
-			sb.WriteString(fmt.Sprintf("- [%s] %s (%s)\n", status, r.Title, category))
-			if strings.TrimSpace(r.Details) != "" {
-				sb.WriteString(fmt.Sprintf("%s\n", r.Details))
-			}
-		}
+		sb.WriteString(formatRequirements(data.Requirements))
 	}
 
 	return sb.String()
 }
 
-func GetProjectInfo(chatCtx *models.ChatContext) (string, error) {
-	var projectData *ProjectData
-	var err error
+func formatRequirements(requirements []RequirementData) string {
+	var sb strings.Builder
+	for _, r := range requirements {
+		status := "-"
+		if r.Status.Valid {
+			status = r.Status.String
+		}
+		category := "-"
+		if r.Category.Valid {
+			category = r.Category.String
+		}
+
+		sb.WriteString(fmt.Sprintf("- [%s] %s (%s)\n", status, r.Title, category))
+		if strings.TrimSpace(r.Details) != "" {
+			sb.WriteString(fmt.Sprintf("%s\n", r.Details))
+		}
+	}
+	return sb.String()
+}
 
+// getResourceGroupData returns the project or template of the chat context
+func getResourceGroupData(ctx context.Context, chatCtx *models.ChatContext) (*ProjectData, error) {
 	if chatCtx.ResourceGroupID == nil {
-		return "", fmt.Errorf("project/template does not exists or it's ID is missing")
+		return nil, fmt.Errorf("project/template does not exists or it's ID is missing")
 	}
 
-	if chatCtx.ResourceGroupType == models.ResourceGroupProject {
-		projectData, err = getProjectData(*chatCtx.ResourceGroupID, chatCtx.TenantID)
-	} else if chatCtx.ResourceGroupType == models.ResourceGroupTemplate {
-		projectData, err = getTemplateData(*chatCtx.ResourceGroupID, chatCtx.TenantID)
-	} else if chatCtx.ResourceGroupType == models.ResourceGroupCommunity {
-		projectData, err = getCommunityTemplateData(*chatCtx.ResourceGroupID, chatCtx.TenantID)
-	} else {
-		return "", fmt.Errorf("invalid resource type: %s", chatCtx.ResourceGroupType)
+	switch chatCtx.ResourceGroupType {
+	case models.ResourceGroupProject:
+		return getProjectData(ctx, *chatCtx.ResourceGroupID, chatCtx.TenantID)
+	case models.ResourceGroupTemplate:
+		return getTemplateData(ctx, *chatCtx.ResourceGroupID, chatCtx.TenantID)
+	case models.ResourceGroupCommunity:
+		return getCommunityTemplateData(ctx, *chatCtx.ResourceGroupID, chatCtx.TenantID)
+	default:
+		return nil, fmt.Errorf("invalid resource type: %s", chatCtx.ResourceGroupType)
 	}
+}
 
+func GetProjectInfo(ctx context.Context, chatCtx *models.ChatContext) (string, error) {
+	projectData, err := getResourceGroupData(ctx, chatCtx)
 	if err != nil {
 		return "", err
 	}
@@ -191,7 +222,7 @@ func GetProjectInfoHandler(c *gin.Context) {
 		},
 	}
 
-	projectInfo, err := GetProjectInfo(&chatCtx)
+	projectInfo, err := GetProjectInfo(c.Request.Context(), &chatCtx)
 	if err != nil {
 		log.Printf("Failed to retrieve project info: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
@@ -2,214 +2,268 @@ package aiFunctions
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"net/http"
 	"sententiawebapi/handlers/models"
+	"sententiawebapi/utilities"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/gin-gonic/gin"
 	openai "github.com/openai/openai-go"
 	"github.com/openai/openai-go/responses"
 	"golang.org/x/sync/errgroup"
 )
 
-func GetFunctionDefinitions(chatCtx *models.ChatContext) []responses.ToolUnionParam {
-	functionTools := make([]responses.ToolUnionParam, 0)
-
-	functionTools = append(functionTools, responses.ToolUnionParam{
-		OfFunction: &responses.FunctionToolParam{
-			Name:        "document_search",
-			Description: openai.String("Semantic search for documents within the project. Returns only (small) fragments of document(s). Each fragment starts with index and document title."),
-			Parameters: map[string]any{
-				"type": "object",
-				"properties": map[string]any{
-					"query": map[string]string{
-						"type":        "string",
-						"description": "Natural-language search phrase. If you want current/concrete document, leave empty and use scope.",
-					},
-					"limit": map[string]string{
-						"type": "number",
-						"description": `
-							Maximum number of matching fragments to return.
-							Each fragment corresponds to a content block (e.g., paragraph, table, heading, or list), similar to Notion-style editors.
-						`,
-					},
-					"scope": map[string]any{
-						"type": "array",
-						"items": map[string]string{
-							"type": "string",
-						},
-						"description": `
-							Optional list of document IDs to restrict the search scope.
-							Use ["current"] to search only the current document.
-							If user input includes references (e.g. @ref(doc: <uuid>)), include those IDs only if they are relevant to the query.
-							Leave empty or omit to search the entire project.
-						`,
-					},
-				},
-				"required": []string{"query", "limit"},
-			},
-		},
-	})
+// ToolExecutor runs a tool call with the raw JSON arguments produced by the model.
+// Search tools also return the sources they used. ctx is cancelled with the request or after functionCallTimeout.
+type ToolExecutor func(ctx context.Context, client *openai.Client, chatCtx *models.ChatContext, rawArguments string) (string, []models.Citation, error)
 
-	functionTools = append(functionTools, responses.ToolUnionParam{
-		OfFunction: &responses.FunctionToolParam{
-			Name: "diagram_search",
-			Description: openai.String(`
-				Semantic search for diagrams within the project.
-			`),
-			Parameters: map[string]any{
-				"type": "object",
-				"properties": map[string]any{
-					"query": map[string]string{
-						"type":        "string",
-						"description": "Natural-language search phrase. If you want current diagram, leave empty and use scope.",
-					},
-					"limit": map[string]string{
-						"type": "number",
-						"description": `
-							Max diagrams to return
-						`,
-					},
-					"scope": map[string]any{
-						"type": "array",
-						"items": map[string]string{
-							"type": "string",
-						},
-						"description": `
-							Optional list of diagram UUIDs to restrict the search scope.
-							Use ["current"] to retrieve currently active diagram.
-							If user input includes references (e.g. @ref(diag: <uuid>)), include those IDs only if they are relevant to the query.
-							Leave empty or omit to search the entire project.
-						`,
-					},
-				},
-				"required": []string{"query", "limit"},
-			},
-		},
-	})
+// ToolPreviewer describes what a mutating tool call would change, without changing anything
+type ToolPreviewer func(chatCtx *models.ChatContext, rawArguments string) (*models.ActionPreview, error)
 
-	if chatCtx.ResourceGroupID != nil {
-		functionTools = append(functionTools, responses.ToolUnionParam{
-			OfFunction: &responses.FunctionToolParam{
-				Name: "project_info",
-				Description: openai.String(`
-					Retrieves high-level information about the current project, including its title, status, category, complexity, description, and all associated requirements.
-					This information can help you better understand the project context before generating ideas, giving suggestions, or answering questions.
-				`),
-			},
-		})
-	}
+// ToolArgumentError is returned for invalid arguments and calls of tools the model may not use,
+// the message goes back to the model as the function output so it can correct the call
+type ToolArgumentError struct {
+	Message string
+}
 
-	return functionTools
+func (e *ToolArgumentError) Error() string {
+	return e.Message
 }
 
-func ExecuteFunctionCall(openai *openai.Client, chatCtx *models.ChatContext, functionName string, rawArguments string) (string, error) {
-	if functionName == "document_search" {
-		var arguments DocSearchRequest
-		if err := json.Unmarshal([]byte(rawArguments), &arguments); err != nil {
-			return "", fmt.Errorf("invalid function arguments: %v", rawArguments)
-		}
+// Tool is a function the model can call. Tools register themselves from init() of their own file.
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  map[string]any // JSON schema of the arguments, nil for tools without arguments
+
+	// Resource group types the tool is offered in, empty means all of them
+	ResourceGroupTypes []models.ResourceGroupType
+	// Tool needs the ID of the current project/template
+	RequiresResourceGroupID bool
+	// Tool creates or changes data on behalf of the user, calls wait for the user approval
+	Mutating bool
+
+	Execute ToolExecutor
+	Preview ToolPreviewer // Required for mutating tools
+}
 
-		results, err := DocumentSearch(openai, &arguments, chatCtx)
-		if err != nil {
-			return "", fmt.Errorf("Document search failed: %v", err)
-		}
+// functionCallTimeout limits a single tool call, including its embedding request
+const functionCallTimeout = 45 * time.Second
 
-		if len(results) == 0 {
-			return "", nil
-		}
+var (
+	toolRegistry = make(map[string]*Tool)
+	toolOrder    []string // Registration order, keeps the tool list stable between requests
+)
 
-		var sb strings.Builder
-		for i, result := range results {
-			sb.WriteString(fmt.Sprintf("#%d Document: %s\n", i+1, result.Title))
-			sb.WriteString(result.Content)
-			sb.WriteString("\n\n")
-		}
+// RegisterTool adds a tool to the registry, registering the same name twice is a programming error
+func RegisterTool(tool Tool) {
+	if tool.Name == "" || tool.Execute == nil {
+		panic("aiFunctions: tool name and executor are required")
+	}
+	if tool.Mutating && tool.Preview == nil {
+		panic(fmt.Sprintf("aiFunctions: mutating tool %s needs a preview", tool.Name))
+	}
+	if _, exists := toolRegistry[tool.Name]; exists {
+		panic(fmt.Sprintf("aiFunctions: tool %s registered twice", tool.Name))
+	}
 
-		sb.WriteString("Note: If some expected content is missing, it may not have been indexed yet.\n")
-		sb.WriteString("Feel free to ask the user for clarification or to paste the relevant content here.")
-		sb.WriteString("\n\n")
+	toolRegistry[tool.Name] = &tool
+	toolOrder = append(toolOrder, tool.Name)
+}
 
-		log.Print(sb.String())
+func GetTool(name string) (*Tool, bool) {
+	tool, ok := toolRegistry[name]
+	return tool, ok
+}
 
-		return sb.String(), nil
-	}
+// ToolNames returns the names of all registered tools, sorted
+func ToolNames() []string {
+	names := append([]string(nil), toolOrder...)
+	sort.Strings(names)
+	return names
+}
 
-	if functionName == "diagram_search" {
-		var arguments DiagramSearchRequest
-		if err := json.Unmarshal([]byte(rawArguments), &arguments); err != nil {
-			return "", fmt.Errorf("invalid function arguments: %v", rawArguments)
+// AppliesTo reports whether the tool can be used in the chat context
+func (t *Tool) AppliesTo(chatCtx *models.ChatContext) bool {
+	if t.RequiresResourceGroupID && chatCtx.ResourceGroupID == nil {
+		return false
+	}
+	// Approval pauses the conversation, there is nothing to resume without one
+	if t.Mutating && chatCtx.ConversationID == nil {
+		return false
+	}
+	if len(t.ResourceGroupTypes) == 0 {
+		return true
+	}
+	for _, groupType := range t.ResourceGroupTypes {
+		if groupType == chatCtx.ResourceGroupType {
+			return true
 		}
+	}
+	return false
+}
+
+// ToolEnabled checks the per-template tool settings, tools missing from the settings are enabled
+func ToolEnabled(settings map[string]bool, name string) bool {
+	enabled, ok := settings[name]
+	return !ok || enabled
+}
 
-		results, err := DiagramSearch(openai, &arguments, chatCtx)
-		if err != nil {
-			return "", fmt.Errorf("Diagram search failed: %v", err)
+// ValidateToolSettings rejects settings of tools that don't exist
+func ValidateToolSettings(settings map[string]bool) error {
+	for name := range settings {
+		if _, ok := toolRegistry[name]; !ok {
+			return fmt.Errorf("unknown AI tool: %s", name)
 		}
+	}
+	return nil
+}
 
-		if len(results) == 0 {
-			return "", nil
+// GetFunctionDefinitions returns the tools available in the chat context and enabled by its tool settings
+func GetFunctionDefinitions(chatCtx *models.ChatContext) []responses.ToolUnionParam {
+	functionTools := make([]responses.ToolUnionParam, 0, len(toolOrder))
+
+	for _, name := range toolOrder {
+		tool := toolRegistry[name]
+		if !tool.AppliesTo(chatCtx) || !ToolEnabled(chatCtx.ToolSettings, name) {
+			continue
 		}
 
-		var sb strings.Builder
+		functionTools = append(functionTools, responses.ToolUnionParam{
+			OfFunction: &responses.FunctionToolParam{
+				Name:        tool.Name,
+				Description: openai.String(tool.Description),
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
 
-		sb.WriteString("How to read diagram:\n")
-		sb.WriteString("[<group>] #<x-position order L->R> <node label> (icon filename) // node definition\n")
-		sb.WriteString("--> #<target order> <target label> // connection to another node\n")
-		sb.WriteString("--<connection icon>--> #<order> <label>\n\n")
+	return functionTools
+}
 
-		for i, result := range results {
-			sb.WriteString(fmt.Sprintf("#%d ", i+1))
-			sb.WriteString(result.Content)
-			sb.WriteString("\n\n")
+// GetReadOnlyFunctionDefinitions is GetFunctionDefinitions without the mutating tools,
+// used where nobody can approve the calls (template evaluations)
+func GetReadOnlyFunctionDefinitions(chatCtx *models.ChatContext) []responses.ToolUnionParam {
+	var readOnly []responses.ToolUnionParam
+	for _, definition := range GetFunctionDefinitions(chatCtx) {
+		if tool, ok := GetTool(definition.OfFunction.Name); ok && !tool.Mutating {
+			readOnly = append(readOnly, definition)
 		}
+	}
+	return readOnly
+}
 
-		sb.WriteString("Note: If some expected content is missing, it may not have been indexed yet.\n")
-		sb.WriteString("\n\n")
+// ToolsHandler lists the registered tools, used by the clients to build the template tool switches
+func ToolsHandler(c *gin.Context) {
+	if _, _, ok := utilities.ProcessIdentity(c); !ok {
+		return
+	}
+
+	tools := make([]gin.H, 0, len(toolOrder))
+	for _, name := range ToolNames() {
+		tool := toolRegistry[name]
+		tools = append(tools, gin.H{
+			"name":                       tool.Name,
+			"description":                strings.Join(strings.Fields(tool.Description), " "),
+			"resource_group_types":       tool.ResourceGroupTypes,
+			"requires_resource_group_id": tool.RequiresResourceGroupID,
+			"requires_approval":          tool.Mutating,
+			"mutating":                   tool.Mutating,
+		})
+	}
 
-		log.Print(sb.String())
+	c.JSON(http.StatusOK, gin.H{
+		"data":    tools,
+		"message": "AI tools retrieved successfully!",
+	})
+}
 
-		return sb.String(), nil
+// getCallableTool returns the tool if the model may call it in the chat context,
+// otherwise a ToolArgumentError the model can recover from
+func getCallableTool(chatCtx *models.ChatContext, functionName string) (*Tool, error) {
+	tool, ok := GetTool(functionName)
+	if !ok {
+		return nil, &ToolArgumentError{Message: fmt.Sprintf("Unknown function: %s", functionName)}
 	}
 
-	if functionName == "project_info" {
-		projectInfo, err := GetProjectInfo(chatCtx)
-		if err != nil {
-			return "", fmt.Errorf("Project Info function call failed: %v", err)
-		}
+	// The model may only call what it was offered
+	if !tool.AppliesTo(chatCtx) {
+		return nil, &ToolArgumentError{Message: fmt.Sprintf("Function %s is not available for %s", functionName, chatCtx.ResourceGroupType)}
+	}
+	if !ToolEnabled(chatCtx.ToolSettings, functionName) {
+		return nil, &ToolArgumentError{Message: fmt.Sprintf("Function %s is disabled by the template", functionName)}
+	}
+
+	return tool, nil
+}
 
-		log.Printf("Project info: %s", projectInfo)
+// ExecuteFunctionCall runs a single function call, search functions also return the sources they used
+func ExecuteFunctionCall(ctx context.Context, openai *openai.Client, chatCtx *models.ChatContext, functionName string, rawArguments string) (string, []models.Citation, error) {
+	var argumentErr *ToolArgumentError
 
-		return projectInfo, nil
+	tool, err := getCallableTool(chatCtx, functionName)
+	if errors.As(err, &argumentErr) {
+		return argumentErr.Message, nil, nil
 	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, functionCallTimeout)
+	defer cancel()
+
+	output, citations, err := tool.Execute(ctx, openai, chatCtx, rawArguments)
+	if errors.As(err, &argumentErr) {
+		return argumentErr.Message, nil, nil
+	}
+
+	return output, citations, err
+}
 
-	return "", fmt.Errorf("unknown function call: %s", functionName)
+// functionCallResult is the outcome of one function call of a response
+type functionCallResult struct {
+	CallID    string
+	Name      string
+	Arguments string
+	Output    string
+	Citations []models.Citation
+	Preview   *models.ActionPreview // Set for mutating calls waiting for approval
 }
 
+// ExecuteFunctionCallsParallel runs all function calls of a response, citations of the
+// search functions are added to chatCtx.Citations.
+// Mutating calls are not executed: when the response contains any, every call of it is stored
+// as a pending action and the returned actions wait for the user decision (no outputs are returned).
+// Arguments are restored and outputs redacted with chatCtx.Redactor, the model only sees placeholders.
 func ExecuteFunctionCallsParallel(
 	ctx context.Context,
 	client *openai.Client,
 	chatCtx *models.ChatContext,
+	responseID string,
 	outputs []responses.ResponseOutputItemUnion,
-) ([]responses.ResponseInputItemUnionParam, error) {
+) ([]responses.ResponseInputItemUnionParam, []models.PendingAction, error) {
 	type task struct {
 		index int
 		out   responses.ResponseOutputItemUnion
 	}
 	var tasks []task
-	for i, o := range outputs {
+	for _, o := range outputs {
 		if o.Type == "function_call" {
-			tasks = append(tasks, task{i, o})
+			tasks = append(tasks, task{len(tasks), o})
 		}
 	}
 	if len(tasks) == 0 {
-		return nil, nil // nothing to do
+		return nil, nil, nil // nothing to do
 	}
 
-	results := make([]responses.ResponseInputItemUnionParam, len(tasks))
+	results := make([]functionCallResult, len(tasks))
 
 	// errgroup with context (cancels all task when one fails)
-	g, _ := errgroup.WithContext(ctx)
+	g, gctx := errgroup.WithContext(ctx)
 
 	sem := make(chan struct{}, 8)
 
@@ -219,23 +273,103 @@ func ExecuteFunctionCallsParallel(
 			sem <- struct{}{}        // acquire
 			defer func() { <-sem }() // release
 
-			out, err := ExecuteFunctionCall(
-				client, chatCtx, t.out.Name, t.out.Arguments,
+			// The model only knows the placeholders of redacted values, the functions work with the originals
+			arguments := t.out.Arguments
+			if chatCtx.Redactor != nil {
+				arguments = chatCtx.Redactor.RestoreJSON(arguments)
+			}
+
+			result := functionCallResult{CallID: t.out.CallID, Name: t.out.Name, Arguments: arguments}
+
+			// Calls of unknown or disabled tools are answered with the error, the model can recover
+			var argumentErr *ToolArgumentError
+			tool, err := getCallableTool(chatCtx, t.out.Name)
+			if errors.As(err, &argumentErr) {
+				result.Output = argumentErr.Message
+				results[t.index] = result
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("%s failed: %w", t.out.Name, err)
+			}
+
+			if tool.Mutating {
+				preview, err := tool.Preview(chatCtx, arguments)
+				if errors.As(err, &argumentErr) {
+					result.Output = argumentErr.Message // Nothing to approve
+				} else if err != nil {
+					return fmt.Errorf("%s preview failed: %w", t.out.Name, err)
+				} else {
+					result.Preview = preview
+				}
+
+				results[t.index] = result
+				return nil
+			}
+
+			result.Output, result.Citations, err = ExecuteFunctionCall(
+				gctx, client, chatCtx, t.out.Name, arguments,
 			)
 			if err != nil {
 				return fmt.Errorf("%s failed: %w", t.out.Name, err)
 			}
 
-			results[t.index] = responses.
-				ResponseInputItemParamOfFunctionCallOutput(t.out.CallID, out)
+			results[t.index] = result
 			return nil
 		})
 	}
 
 	// waiting for all or first error
 	if err := g.Wait(); err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	for _, result := range results {
+		if result.Preview != nil {
+			pending, err := createPendingActions(chatCtx, responseID, results)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to store pending actions: %w", err)
+			}
+			return nil, pending, nil
+		}
+	}
+
+	functionOutputs := make([]responses.ResponseInputItemUnionParam, len(results))
+	for i, result := range results {
+		chatCtx.Citations = MergeCitations(chatCtx.Citations, result.Citations)
+		output := result.Output
+		if chatCtx.Redactor != nil {
+			output = chatCtx.Redactor.Redact(output)
+		}
+		functionOutputs[i] = responses.ResponseInputItemParamOfFunctionCallOutput(result.CallID, output)
 	}
 
-	return results, nil
+	return functionOutputs, nil, nil
+}
+
+// MergeCitations appends new sources, a fragment cited more than once keeps its closest distance
+func MergeCitations(citations []models.Citation, sources []models.Citation) []models.Citation {
+	for _, source := range sources {
+		duplicate := false
+		for i, existing := range citations {
+			if existing.ResourceType == source.ResourceType &&
+				existing.ResourceID == source.ResourceID &&
+				ptrEqual(existing.BlockID, source.BlockID) {
+				citations[i].Distance = min(existing.Distance, source.Distance)
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			citations = append(citations, source)
+		}
+	}
+	return citations
+}
+
+func ptrEqual(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
 }
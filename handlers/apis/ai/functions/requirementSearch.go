@@ -0,0 +1,274 @@
+package aiFunctions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"sententiawebapi/handlers/apis/projects"
+	"sententiawebapi/handlers/apis/tenantManagement"
+	"sententiawebapi/handlers/models"
+
+	"github.com/lib/pq"
+	openai "github.com/openai/openai-go"
+)
+
+const (
+	defaultRequirementSearchLimit = 20
+	maxRequirementSearchLimit     = 100
+)
+
+type RequirementSearchRequest struct {
+	Query    string   `json:"query"`
+	Status   []string `json:"status"`
+	Category []string `json:"category"`
+	Limit    int      `json:"limit"`
+}
+
+type RequirementSearchResult struct {
+	ID       string
+	Title    string
+	Details  string
+	Category *string
+	Status   *string
+}
+
+type CreateRequirementRequest struct {
+	Title    string  `json:"title"`
+	Details  *string `json:"details"`
+	Category *string `json:"category"`
+	Status   *string `json:"status"`
+}
+
+func init() {
+	RegisterTool(Tool{
+		Name: "requirement_search",
+		Description: `
+			Searches the requirements of the current project by keywords, status and category.
+			Each result starts with the requirement ID, status and category.
+		`,
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"query": map[string]string{
+					"type":        "string",
+					"description": "Keywords matched against requirement title and details. Leave empty to list requirements by the filters only.",
+				},
+				"status": map[string]any{
+					"type":        "array",
+					"items":       map[string]any{"type": "string", "enum": projects.RequirementStatuses},
+					"description": "Optional list of statuses to include.",
+				},
+				"category": map[string]any{
+					"type":        "array",
+					"items":       map[string]any{"type": "string", "enum": projects.RequirementCategories},
+					"description": "Optional list of categories to include.",
+				},
+				"limit": map[string]string{
+					"type":        "number",
+					"description": "Max requirements to return (default 20).",
+				},
+			},
+		},
+		ResourceGroupTypes:      []models.ResourceGroupType{models.ResourceGroupProject},
+		RequiresResourceGroupID: true,
+		Execute:                 executeRequirementSearch,
+	})
+
+	RegisterTool(Tool{
+		Name: "create_requirement",
+		Description: `
+			Creates a new requirement in the current project. Only call it when the user asks for it,
+			search the existing requirements first to avoid duplicates.
+		`,
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"title": map[string]string{
+					"type":        "string",
+					"description": "Short requirement title.",
+				},
+				"details": map[string]string{
+					"type":        "string",
+					"description": "Requirement details, acceptance criteria.",
+				},
+				"category": map[string]any{
+					"type": "string",
+					"enum": projects.RequirementCategories,
+				},
+				"status": map[string]any{
+					"type":        "string",
+					"enum":        projects.RequirementStatuses,
+					"description": `Defaults to "Not Started".`,
+				},
+			},
+			"required": []string{"title"},
+		},
+		ResourceGroupTypes:      []models.ResourceGroupType{models.ResourceGroupProject},
+		RequiresResourceGroupID: true,
+		Mutating:                true,
+		Execute:                 executeCreateRequirement,
+		Preview:                 previewCreateRequirement,
+	})
+}
+
+func RequirementSearch(ctx context.Context, req *RequirementSearchRequest, chatCtx *models.ChatContext) ([]RequirementSearchResult, error) {
+	if chatCtx.ResourceGroupID == nil {
+		return nil, fmt.Errorf("project ID is missing")
+	}
+
+	if req.Limit <= 0 {
+		req.Limit = defaultRequirementSearchLimit
+	}
+	req.Limit = min(req.Limit, maxRequirementSearchLimit)
+
+	args := []any{*chatCtx.ResourceGroupID, chatCtx.TenantID}
+	conditions := []string{"project_id = $1", "tenant_id = $2"}
+	orderBy := "title"
+
+	if query := strings.TrimSpace(req.Query); query != "" {
+		// Full-text match for phrases, ILIKE catches identifiers the text parser splits
+		args = append(args, query)
+		conditions = append(conditions, fmt.Sprintf(`(
+			to_tsvector('%[1]s', title || ' ' || COALESCE(details, '')) @@ websearch_to_tsquery('%[1]s', $%[2]d)
+			OR title ILIKE '%%' || $%[2]d || '%%'
+		)`, textSearchConfig, len(args)))
+		orderBy = fmt.Sprintf(
+			"ts_rank_cd(to_tsvector('%[1]s', title || ' ' || COALESCE(details, '')), websearch_to_tsquery('%[1]s', $%[2]d)) DESC, title",
+			textSearchConfig, len(args),
+		)
+	}
+	if len(req.Status) > 0 {
+		args = append(args, pq.Array(req.Status))
+		conditions = append(conditions, fmt.Sprintf("status = ANY($%d)", len(args)))
+	}
+	if len(req.Category) > 0 {
+		args = append(args, pq.Array(req.Category))
+		conditions = append(conditions, fmt.Sprintf("category = ANY($%d)", len(args)))
+	}
+
+	args = append(args, req.Limit)
+	query := fmt.Sprintf(`
+		SELECT id, title, COALESCE(details, ''), category, status
+		FROM st_schema.project_requirements
+		WHERE %s
+		ORDER BY %s
+		LIMIT $%d
+	`, strings.Join(conditions, " AND "), orderBy, len(args))
+
+	rows, err := tenantManagement.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching requirements: %w", err)
+	}
+	defer rows.Close()
+
+	var results []RequirementSearchResult
+	for rows.Next() {
+		var r RequirementSearchResult
+		if err := rows.Scan(&r.ID, &r.Title, &r.Details, &r.Category, &r.Status); err != nil {
+			return nil, fmt.Errorf("error scanning requirement: %w", err)
+		}
+		results = append(results, r)
+	}
+
+	return results, rows.Err()
+}
+
+func executeRequirementSearch(ctx context.Context, _ *openai.Client, chatCtx *models.ChatContext, rawArguments string) (string, []models.Citation, error) {
+	var arguments RequirementSearchRequest
+	if err := json.Unmarshal([]byte(rawArguments), &arguments); err != nil {
+		return "", nil, fmt.Errorf("invalid function arguments: %v", rawArguments)
+	}
+
+	results, err := RequirementSearch(ctx, &arguments, chatCtx)
+	if err != nil {
+		return "", nil, fmt.Errorf("Requirement search failed: %v", err)
+	}
+
+	if len(results) == 0 {
+		return "No matching requirements found.", nil, nil
+	}
+
+	var sb strings.Builder
+	for _, r := range results {
+		sb.WriteString(fmt.Sprintf("- (%s) [%s] %s (%s)\n", r.ID, valueOrDash(r.Status), r.Title, valueOrDash(r.Category)))
+		if strings.TrimSpace(r.Details) != "" {
+			sb.WriteString(r.Details)
+			sb.WriteString("\n")
+		}
+	}
+
+	log.Print(sb.String())
+
+	return sb.String(), nil, nil
+}
+
+// parseCreateRequirement validates the arguments, invalid values are reported back so the model can correct them
+func parseCreateRequirement(rawArguments string) (*CreateRequirementRequest, error) {
+	var arguments CreateRequirementRequest
+	if err := json.Unmarshal([]byte(rawArguments), &arguments); err != nil {
+		return nil, fmt.Errorf("invalid function arguments: %v", rawArguments)
+	}
+
+	arguments.Title = strings.TrimSpace(arguments.Title)
+	if arguments.Title == "" {
+		return nil, &ToolArgumentError{Message: "Requirement was not created: title is required."}
+	}
+	if arguments.Category != nil && !slices.Contains(projects.RequirementCategories, *arguments.Category) {
+		return nil, &ToolArgumentError{Message: fmt.Sprintf("Requirement was not created: invalid category %q.", *arguments.Category)}
+	}
+	if arguments.Status == nil {
+		status := "Not Started"
+		arguments.Status = &status
+	} else if !slices.Contains(projects.RequirementStatuses, *arguments.Status) {
+		return nil, &ToolArgumentError{Message: fmt.Sprintf("Requirement was not created: invalid status %q.", *arguments.Status)}
+	}
+
+	return &arguments, nil
+}
+
+func previewCreateRequirement(_ *models.ChatContext, rawArguments string) (*models.ActionPreview, error) {
+	arguments, err := parseCreateRequirement(rawArguments)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ActionPreview{
+		Summary:      fmt.Sprintf("Create requirement %q", arguments.Title),
+		ResourceType: "requirement",
+		After:        arguments,
+	}, nil
+}
+
+func executeCreateRequirement(_ context.Context, _ *openai.Client, chatCtx *models.ChatContext, rawArguments string) (string, []models.Citation, error) {
+	arguments, err := parseCreateRequirement(rawArguments)
+	if err != nil {
+		return "", nil, err
+	}
+
+	requirementsApi := projects.NewRequirementsApi(chatCtx.TenantID, chatCtx.UserID, *chatCtx.ResourceGroupID)
+	requirement, err := requirementsApi.AddOne(tenantManagement.DB, models.Requirement{
+		Title:    arguments.Title,
+		Details:  arguments.Details,
+		Category: arguments.Category,
+		Status:   arguments.Status,
+		Owner:    &chatCtx.UserID,
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("Create requirement failed: %v", err)
+	}
+
+	log.Printf("Requirement %s created by AI for user %s", requirement.ID, chatCtx.UserID)
+
+	return fmt.Sprintf("Requirement created: (%s) [%s] %s", requirement.ID, *requirement.Status, requirement.Title), nil, nil
+}
+
+func valueOrDash(value *string) string {
+	if value == nil || strings.TrimSpace(*value) == "" {
+		return "-"
+	}
+	return *value
+}
@@ -0,0 +1,218 @@
+package aiFunctions
+
+// The system config of AI templates may contain variables like {{project.title}}, they are
+// resolved from the chat context when the prompt is sent. Variables without a value in the
+// context (e.g. project variables in a chat without a project) render as empty text.
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	aiIndexing "sententiawebapi/handlers/apis/ai/indexing"
+	"sententiawebapi/handlers/apis/tenantManagement"
+	"sententiawebapi/handlers/models"
+	"sententiawebapi/utilities"
+
+	"github.com/gin-gonic/gin"
+)
+
+var templateVariablePattern = regexp.MustCompile(`\{\{\s*([^{}]*?)\s*\}\}`)
+
+// templateValues loads the data of the variables once per render
+type templateValues struct {
+	ctx     context.Context
+	chatCtx *models.ChatContext
+
+	project *ProjectData
+	user    templateUser
+	loaded  map[string]bool
+}
+
+type templateUser struct {
+	FirstName sql.NullString
+	LastName  sql.NullString
+}
+
+type templateVariable struct {
+	Description string
+	Resolve     func(v *templateValues) string
+}
+
+var templateVariables = map[string]templateVariable{
+	"project.title": {
+		Description: "Title of the current project or template",
+		Resolve:     func(v *templateValues) string { return v.getProject().Title },
+	},
+	"project.description": {
+		Description: "Description of the current project or template",
+		Resolve:     func(v *templateValues) string { return v.getProject().Description.String },
+	},
+	"project.status": {
+		Description: "Status of the current project",
+		Resolve:     func(v *templateValues) string { return v.getProject().Status },
+	},
+	"project.category": {
+		Description: "Category of the current project",
+		Resolve:     func(v *templateValues) string { return v.getProject().Category },
+	},
+	"project.requirements": {
+		Description: "Requirements of the current project, one per line",
+		Resolve: func(v *templateValues) string {
+			return strings.TrimSpace(formatRequirements(v.getProject().Requirements))
+		},
+	},
+	"document.current.title": {
+		Description: "Title of the document the chat is opened from",
+		Resolve:     func(v *templateValues) string { return v.getDocumentTitle() },
+	},
+	"user.first_name": {
+		Description: "First name of the user",
+		Resolve:     func(v *templateValues) string { return v.getUser().FirstName.String },
+	},
+	"user.last_name": {
+		Description: "Last name of the user",
+		Resolve:     func(v *templateValues) string { return v.getUser().LastName.String },
+	},
+	"today": {
+		Description: "Current date, YYYY-MM-DD",
+		Resolve:     func(v *templateValues) string { return time.Now().Format(time.DateOnly) },
+	},
+}
+
+// TemplateVariableNames returns the names of the supported variables, sorted
+func TemplateVariableNames() []string {
+	names := make([]string, 0, len(templateVariables))
+	for name := range templateVariables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidateTemplateVariables rejects variables of the text that don't exist
+func ValidateTemplateVariables(text string) error {
+	var unknown []string
+	for _, match := range templateVariablePattern.FindAllStringSubmatch(text, -1) {
+		if _, ok := templateVariables[match[1]]; !ok {
+			unknown = append(unknown, "{{"+match[1]+"}}")
+		}
+	}
+
+	if len(unknown) > 0 {
+		return fmt.Errorf("unknown template variables: %s (available: %s)",
+			strings.Join(unknown, ", "), strings.Join(TemplateVariableNames(), ", "))
+	}
+	return nil
+}
+
+// RenderTemplateVariables replaces the variables of the text with their values in the chat context
+func RenderTemplateVariables(ctx context.Context, chatCtx *models.ChatContext, text string) string {
+	if !strings.Contains(text, "{{") {
+		return text
+	}
+
+	values := &templateValues{ctx: ctx, chatCtx: chatCtx, loaded: make(map[string]bool)}
+
+	return templateVariablePattern.ReplaceAllStringFunc(text, func(match string) string {
+		name := templateVariablePattern.FindStringSubmatch(match)[1]
+		variable, ok := templateVariables[name]
+		if !ok {
+			return match // Saved before the validation existed
+		}
+		return variable.Resolve(values)
+	})
+}
+
+func (v *templateValues) getProject() *ProjectData {
+	if !v.loaded["project"] {
+		v.loaded["project"] = true
+
+		if v.chatCtx.ResourceGroupID != nil {
+			project, err := getResourceGroupData(v.ctx, v.chatCtx)
+			if err != nil {
+				log.Printf("Failed to load project of template variables: %v", err)
+			} else {
+				v.project = project
+			}
+		}
+	}
+
+	if v.project == nil {
+		return &ProjectData{}
+	}
+	return v.project
+}
+
+func (v *templateValues) getUser() templateUser {
+	if !v.loaded["user"] {
+		v.loaded["user"] = true
+
+		err := tenantManagement.DB.QueryRowContext(v.ctx, `
+			SELECT first_name, last_name
+			FROM st_schema.users
+			WHERE id = $1
+		`, v.chatCtx.UserID).Scan(&v.user.FirstName, &v.user.LastName)
+		if err != nil {
+			log.Printf("Failed to load user of template variables: %v", err)
+		}
+	}
+
+	return v.user
+}
+
+func (v *templateValues) getDocumentTitle() string {
+	chatCtx := v.chatCtx
+	if chatCtx.ResourceType == nil || *chatCtx.ResourceType != models.ResourceTypeDocument || chatCtx.ResourceID == nil {
+		return ""
+	}
+
+	table, ok := aiIndexing.DocumentVectorTables[chatCtx.ResourceGroupType]
+	if !ok {
+		return ""
+	}
+
+	// Community documents are shared by all tenants
+	query := `SELECT title FROM ` + table.SourceTable + ` WHERE id = $1 AND tenant_id = $2`
+	args := []any{*chatCtx.ResourceID, chatCtx.TenantID}
+	if chatCtx.ResourceGroupType == models.ResourceGroupCommunity {
+		query = `SELECT title FROM ` + table.SourceTable + ` WHERE id = $1`
+		args = args[:1]
+	}
+
+	var title string
+	err := tenantManagement.DB.QueryRowContext(v.ctx, query, args...).Scan(&title)
+	if err != nil {
+		log.Printf("Failed to load document of template variables: %v", err)
+		return ""
+	}
+
+	return title
+}
+
+// TemplateVariablesHandler lists the variables template authors can use in the system config
+func TemplateVariablesHandler(c *gin.Context) {
+	if _, _, ok := utilities.ProcessIdentity(c); !ok {
+		return
+	}
+
+	variables := make([]gin.H, 0, len(templateVariables))
+	for _, name := range TemplateVariableNames() {
+		variables = append(variables, gin.H{
+			"name":        name,
+			"placeholder": "{{" + name + "}}",
+			"description": templateVariables[name].Description,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    variables,
+		"message": "AI template variables retrieved successfully!",
+	})
+}
@@ -0,0 +1,76 @@
+package aiFunctions
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"sententiawebapi/handlers/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateTemplateVariables(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		unknown []string
+	}{
+		{name: "no variables", text: "You are a helpful assistant."},
+		{name: "known variable", text: "Project: {{project.title}}"},
+		{name: "spaces inside the braces", text: "Project: {{ project.title }} of {{  user.first_name}}"},
+		{name: "several variables on one line", text: "{{project.title}}{{project.status}}{{today}}"},
+		{name: "unknown variable", text: "Hello {{ user.email }}", unknown: []string{"{{user.email}}"}},
+		{name: "misspelled variable", text: "{{project.tittle}} and {{project.title}}", unknown: []string{"{{project.tittle}}"}},
+		{name: "names are case sensitive", text: "{{Project.Title}}", unknown: []string{"{{Project.Title}}"}},
+		{name: "empty braces", text: "{{ }}", unknown: []string{"{{}}"}},
+		{name: "nested braces match the inner variable", text: "{{ {{project.title}} }}"},
+		{name: "nested unknown variable", text: "{{{{project.owner}}}}", unknown: []string{"{{project.owner}}"}},
+		{name: "unclosed braces are plain text", text: "{{project.title and {{project.owner"},
+		{name: "single closing brace is plain text", text: "{{project.owner}"},
+		{name: "single braces are plain text", text: "{project.owner}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTemplateVariables(tt.text)
+			if len(tt.unknown) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.Error(t, err) {
+				for _, name := range tt.unknown {
+					assert.Contains(t, err.Error(), name)
+				}
+				assert.Contains(t, err.Error(), "available: ")
+			}
+		})
+	}
+}
+
+func TestRenderTemplateVariables(t *testing.T) {
+	today := time.Now().Format(time.DateOnly)
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "no variables", text: "You are a helpful assistant.", want: "You are a helpful assistant."},
+		{name: "today", text: "Today is {{ today }}.", want: "Today is " + today + "."},
+		// Without a ResourceGroupID there is no project, the variables render as empty text
+		{name: "project without a project", text: "Project: {{project.title}} ({{ project.status }})", want: "Project:  ()"},
+		{name: "requirements without a project", text: "[{{project.requirements}}]", want: "[]"},
+		{name: "document without a document", text: "[{{document.current.title}}]", want: "[]"},
+		{name: "unknown variable is kept", text: "{{ user.email }} {{today}}", want: "{{ user.email }} " + today},
+		{name: "nested braces keep the outer braces", text: "{{{{today}}}}", want: "{{" + today + "}}"},
+		{name: "unbalanced braces are kept", text: "{{today and {{today}", want: "{{today and {{today}"},
+	}
+
+	chatCtx := &models.ChatContext{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, RenderTemplateVariables(context.Background(), chatCtx, tt.text))
+		})
+	}
+}
@@ -3,22 +3,54 @@ package aiFunctions
 import (
 	"context"
 	"fmt"
+	"log"
 	"strings"
+	"time"
+
+	aiUsage "sententiawebapi/handlers/apis/ai/usage"
+	"sententiawebapi/handlers/apis/tenantManagement"
+	"sententiawebapi/handlers/models"
+	"sententiawebapi/utilities"
 
 	openai "github.com/openai/openai-go"
 )
 
-func embedQuery(openaiClient *openai.Client, query string) (*string, error) {
-	embedResp, err := openaiClient.Embeddings.New(context.Background(), openai.EmbeddingNewParams{
-		Model: openai.EmbeddingModelTextEmbedding3Small,
-		Input: openai.EmbeddingNewParamsInputUnion{
-			OfString: openai.String(query),
-		},
+const embeddingTimeout = 15 * time.Second
+
+// embedQuery embeds the search query of a tool call, the usage is recorded for the chat user.
+// Returns aiUsage.ErrBudgetExhausted without calling OpenAI when a budget of the chat is exhausted.
+func embedQuery(ctx context.Context, openaiClient *openai.Client, chatCtx *models.ChatContext, query string) (*string, error) {
+	var projectID *string
+	if chatCtx.ResourceGroupType == models.ResourceGroupProject {
+		projectID = chatCtx.ResourceGroupID
+	}
+	if budget := aiUsage.ExhaustedBudget(chatCtx.TenantID, chatCtx.UserID, projectID); budget != nil {
+		return nil, fmt.Errorf("%s budget: %w", budget.Scope, aiUsage.ErrBudgetExhausted)
+	}
+
+	openAiConfig, err := utilities.GetOpenAiConfig(tenantManagement.DB, chatCtx.TenantID)
+	if err != nil {
+		log.Printf("Using the default embedding model: %v", err)
+	}
+	route := utilities.ResolveModelRoute(openAiConfig, models.AiTaskEmbeddings)
+
+	embedResp, servedModel, err := utilities.CallWithModelFallback(ctx, route, func(model string) (*openai.CreateEmbeddingResponse, error) {
+		attemptCtx, cancel := context.WithTimeout(ctx, embeddingTimeout)
+		defer cancel()
+
+		return openaiClient.Embeddings.New(attemptCtx, openai.EmbeddingNewParams{
+			Model: model,
+			Input: openai.EmbeddingNewParamsInputUnion{
+				OfString: openai.String(query),
+			},
+		})
 	})
 	if err != nil || len(embedResp.Data) == 0 {
 		return nil, fmt.Errorf("failed to generate embedding for a query message: %v", err)
 	}
 
+	aiUsage.RecordEmbeddings(chatCtx.TenantID, chatCtx.UserID, projectID, route, servedModel, embedResp)
+
 	embedding := embedResp.Data[0].Embedding
 	vectorStr := "'[" + strings.Trim(strings.ReplaceAll(fmt.Sprint(embedding), " ", ","), "[]") + "]'"
 
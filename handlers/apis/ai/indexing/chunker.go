@@ -0,0 +1,225 @@
+package aiIndexing
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Upper bound for a single chunk, long tables and lists are split on line boundaries
+const maxChunkChars = 6000
+
+// Chunk is a single block-level fragment of a Tiptap document
+type Chunk struct {
+	BlockID   string
+	BlockType string
+	Index     int
+	Heading   string // Closest heading above the block, used as embedding context
+	Content   string
+	Hash      string
+}
+
+// EmbeddingInput is the text sent to the embedding model, the section heading
+// is prepended so short paragraphs still carry their context
+func (c Chunk) EmbeddingInput() string {
+	if c.Heading == "" || c.BlockType == "heading" {
+		return c.Content
+	}
+	return c.Heading + "\n" + c.Content
+}
+
+type tiptapNode struct {
+	Type    string         `json:"type"`
+	Attrs   map[string]any `json:"attrs"`
+	Text    string         `json:"text"`
+	Content []tiptapNode   `json:"content"`
+}
+
+// ChunkTiptapDocument splits Tiptap JSON into block-level chunks (paragraphs, headings, tables, lists...)
+func ChunkTiptapDocument(content []byte) ([]Chunk, error) {
+	doc, err := parseTiptap(content)
+	if err != nil {
+		return nil, err
+	}
+
+	var chunks []Chunk
+	var heading string
+	seen := make(map[string]int)
+	used := make(map[string]bool)
+
+	for i, node := range doc.Content {
+		text := strings.TrimSpace(blockText(node, 0))
+		if text == "" {
+			continue
+		}
+
+		if node.Type == "heading" {
+			heading = text
+		}
+
+		blockID := fmt.Sprintf("block-%d", i)
+		if id, ok := node.Attrs["id"].(string); ok && id != "" {
+			blockID = id
+		}
+
+		// Pasted blocks may share the same id, keep them apart. The suffix is counted on the
+		// original id and skips ids already taken by other blocks.
+		originalID := blockID
+		for used[blockID] {
+			seen[originalID]++
+			blockID = fmt.Sprintf("%s-%d", originalID, seen[originalID])
+		}
+		used[blockID] = true
+
+		for part, partText := range splitText(text, maxChunkChars) {
+			chunk := Chunk{
+				BlockID:   blockID,
+				BlockType: node.Type,
+				Index:     len(chunks),
+				Heading:   heading,
+				Content:   partText,
+			}
+			if part > 0 {
+				chunk.BlockID = fmt.Sprintf("%s:%d", blockID, part)
+			}
+
+			sum := sha256.Sum256([]byte(chunk.EmbeddingInput()))
+			chunk.Hash = hex.EncodeToString(sum[:])
+
+			chunks = append(chunks, chunk)
+		}
+	}
+
+	return chunks, nil
+}
+
+func parseTiptap(content []byte) (*tiptapNode, error) {
+	raw := strings.TrimSpace(string(content))
+	if raw == "" || raw == "null" {
+		return &tiptapNode{Type: "doc"}, nil
+	}
+
+	// Some columns keep the document as a JSON encoded string
+	if strings.HasPrefix(raw, `"`) {
+		var inner string
+		if err := json.Unmarshal([]byte(raw), &inner); err != nil {
+			return nil, fmt.Errorf("failed to decode document content: %w", err)
+		}
+		raw = inner
+	}
+
+	var doc tiptapNode
+	if err := json.Unmarshal([]byte(raw), &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse tiptap document: %w", err)
+	}
+
+	return &doc, nil
+}
+
+func blockText(node tiptapNode, depth int) string {
+	switch node.Type {
+	case "text":
+		return node.Text
+	case "hardBreak":
+		return "\n"
+	case "table":
+		rows := make([]string, 0, len(node.Content))
+		for _, row := range node.Content {
+			cells := make([]string, 0, len(row.Content))
+			for _, cell := range row.Content {
+				cells = append(cells, strings.TrimSpace(inlineText(cell)))
+			}
+			rows = append(rows, "| "+strings.Join(cells, " | ")+" |")
+		}
+		return strings.Join(rows, "\n")
+	case "bulletList", "orderedList", "taskList":
+		items := make([]string, 0, len(node.Content))
+		for i, item := range node.Content {
+			marker := "-"
+			if node.Type == "orderedList" {
+				marker = fmt.Sprintf("%d.", i+1)
+			}
+			if checked, ok := item.Attrs["checked"].(bool); ok {
+				if checked {
+					marker = "- [x]"
+				} else {
+					marker = "- [ ]"
+				}
+			}
+
+			var parts []string
+			for _, child := range item.Content {
+				// Only the end is trimmed, the start of a nested list is its indentation
+				if text := strings.TrimRightFunc(blockText(child, depth+1), unicode.IsSpace); strings.TrimSpace(text) != "" {
+					parts = append(parts, text)
+				}
+			}
+			indent := strings.Repeat("  ", depth)
+			items = append(items, indent+marker+" "+strings.Join(parts, "\n"))
+		}
+		return strings.Join(items, "\n")
+	default:
+		return inlineText(node)
+	}
+}
+
+func inlineText(node tiptapNode) string {
+	if node.Type == "text" {
+		return node.Text
+	}
+
+	var sb strings.Builder
+	for i, child := range node.Content {
+		if i > 0 && isBlock(child) {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(blockText(child, 0))
+	}
+	return sb.String()
+}
+
+func isBlock(node tiptapNode) bool {
+	return node.Type != "text" && node.Type != "hardBreak" && node.Type != "mention"
+}
+
+// splitText keeps whole lines together where possible
+func splitText(text string, limit int) []string {
+	if len(text) <= limit {
+		return []string{text}
+	}
+
+	var parts []string
+	var current strings.Builder
+
+	flush := func() {
+		if current.Len() > 0 {
+			parts = append(parts, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		for len(line) > limit {
+			flush()
+			cut := limit
+			// Do not cut in the middle of a multi-byte character
+			for cut > 0 && (line[cut]&0xC0) == 0x80 {
+				cut--
+			}
+			parts = append(parts, line[:cut])
+			line = line[cut:]
+		}
+
+		if current.Len()+len(line)+1 > limit {
+			flush()
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+	}
+	flush()
+
+	return parts
+}
@@ -0,0 +1,200 @@
+package aiIndexing
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func paragraph(id string, text string) string {
+	return `{"type":"paragraph","attrs":{"id":"` + id + `"},"content":[{"type":"text","text":"` + text + `"}]}`
+}
+
+func doc(blocks ...string) []byte {
+	return []byte(`{"type":"doc","content":[` + strings.Join(blocks, ",") + `]}`)
+}
+
+func TestChunkTiptapDocument(t *testing.T) {
+	type wantChunk struct {
+		blockID   string
+		blockType string
+		heading   string
+		content   string
+	}
+
+	tests := []struct {
+		name    string
+		content []byte
+		want    []wantChunk
+	}{
+		{
+			name:    "empty document",
+			content: []byte(""),
+		},
+		{
+			name:    "null document",
+			content: []byte("null"),
+		},
+		{
+			name:    "empty blocks are skipped",
+			content: doc(`{"type":"paragraph","attrs":{"id":"a"}}`, paragraph("b", "Text")),
+			want:    []wantChunk{{"b", "paragraph", "", "Text"}},
+		},
+		{
+			name:    "blocks without id use their position",
+			content: doc(`{"type":"paragraph"}`, `{"type":"paragraph","content":[{"type":"text","text":"Text"}]}`),
+			want:    []wantChunk{{"block-1", "paragraph", "", "Text"}},
+		},
+		{
+			name: "closest heading is kept",
+			content: doc(
+				paragraph("a", "Intro"),
+				`{"type":"heading","attrs":{"id":"h1","level":1},"content":[{"type":"text","text":"Scope"}]}`,
+				paragraph("b", "In scope"),
+				`{"type":"heading","attrs":{"id":"h2","level":2},"content":[{"type":"text","text":"Risks"}]}`,
+				paragraph("c", "None"),
+			),
+			want: []wantChunk{
+				{"a", "paragraph", "", "Intro"},
+				{"h1", "heading", "Scope", "Scope"},
+				{"b", "paragraph", "Scope", "In scope"},
+				{"h2", "heading", "Risks", "Risks"},
+				{"c", "paragraph", "Risks", "None"},
+			},
+		},
+		{
+			name:    "duplicate ids get a suffix",
+			content: doc(paragraph("p", "One"), paragraph("p", "Two"), paragraph("p", "Three")),
+			want: []wantChunk{
+				{"p", "paragraph", "", "One"},
+				{"p-1", "paragraph", "", "Two"},
+				{"p-2", "paragraph", "", "Three"},
+			},
+		},
+		{
+			name:    "suffix skips ids taken by other blocks",
+			content: doc(paragraph("p", "One"), paragraph("p-1", "Two"), paragraph("p", "Three")),
+			want: []wantChunk{
+				{"p", "paragraph", "", "One"},
+				{"p-1", "paragraph", "", "Two"},
+				{"p-2", "paragraph", "", "Three"},
+			},
+		},
+		{
+			name: "hard break",
+			content: doc(`{"type":"paragraph","attrs":{"id":"a"},"content":[
+				{"type":"text","text":"One"},{"type":"hardBreak"},{"type":"text","text":"Two"}]}`),
+			want: []wantChunk{{"a", "paragraph", "", "One\nTwo"}},
+		},
+		{
+			name: "table",
+			content: doc(`{"type":"table","attrs":{"id":"t"},"content":[
+				{"type":"tableRow","content":[{"type":"tableHeader","content":[` + paragraph("x", "Name") + `]},{"type":"tableHeader","content":[` + paragraph("y", "Owner") + `]}]},
+				{"type":"tableRow","content":[{"type":"tableCell","content":[` + paragraph("z", "API") + `]},{"type":"tableCell","content":[` + paragraph("w", "Ops") + `]}]}
+			]}`),
+			want: []wantChunk{{"t", "table", "", "| Name | Owner |\n| API | Ops |"}},
+		},
+		{
+			name: "nested ordered list",
+			content: doc(`{"type":"orderedList","attrs":{"id":"l"},"content":[
+				{"type":"listItem","content":[` + paragraph("a", "First") + `,
+					{"type":"bulletList","content":[{"type":"listItem","content":[` + paragraph("b", "Nested") + `]}]}]},
+				{"type":"listItem","content":[` + paragraph("c", "Second") + `]}
+			]}`),
+			want: []wantChunk{{"l", "orderedList", "", "1. First\n  - Nested\n2. Second"}},
+		},
+		{
+			name: "task list",
+			content: doc(`{"type":"taskList","attrs":{"id":"l"},"content":[
+				{"type":"taskItem","attrs":{"checked":true},"content":[` + paragraph("a", "Done") + `]},
+				{"type":"taskItem","attrs":{"checked":false},"content":[` + paragraph("b", "Open") + `]}
+			]}`),
+			want: []wantChunk{{"l", "taskList", "", "- [x] Done\n- [ ] Open"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks, err := ChunkTiptapDocument(tt.content)
+			assert.NoError(t, err)
+			assert.Len(t, chunks, len(tt.want))
+
+			for i, chunk := range chunks {
+				if i >= len(tt.want) {
+					break
+				}
+				assert.Equal(t, tt.want[i].blockID, chunk.BlockID)
+				assert.Equal(t, tt.want[i].blockType, chunk.BlockType)
+				assert.Equal(t, tt.want[i].heading, chunk.Heading)
+				assert.Equal(t, tt.want[i].content, chunk.Content)
+				assert.Equal(t, i, chunk.Index)
+				assert.Len(t, chunk.Hash, 64)
+			}
+		})
+	}
+}
+
+func TestChunkTiptapDocumentEncodedAsString(t *testing.T) {
+	encoded, err := json.Marshal(string(doc(paragraph("a", "Text"))))
+	assert.NoError(t, err)
+
+	chunks, err := ChunkTiptapDocument(encoded)
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 1)
+	assert.Equal(t, "Text", chunks[0].Content)
+}
+
+func TestChunkTiptapDocumentInvalid(t *testing.T) {
+	for _, content := range []string{`{"type":`, `"{\"type\":"`, `"unterminated`} {
+		_, err := ChunkTiptapDocument([]byte(content))
+		assert.Error(t, err, content)
+	}
+}
+
+func TestChunkTiptapDocumentLongBlock(t *testing.T) {
+	line := strings.Repeat("a", maxChunkChars/2-1)
+	text := strings.Join([]string{line, line, line}, `\n`)
+	content := doc(`{"type":"codeBlock","attrs":{"id":"c"},"content":[{"type":"text","text":"` + text + `"}]}`)
+
+	chunks, err := ChunkTiptapDocument(content)
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 2)
+	assert.Equal(t, "c", chunks[0].BlockID)
+	assert.Equal(t, "c:1", chunks[1].BlockID)
+	for _, chunk := range chunks {
+		assert.LessOrEqual(t, len(chunk.Content), maxChunkChars)
+	}
+}
+
+func TestChunkHashFollowsHeading(t *testing.T) {
+	first, err := ChunkTiptapDocument(doc(`{"type":"heading","content":[{"type":"text","text":"One"}]}`, paragraph("p", "Text")))
+	assert.NoError(t, err)
+	second, err := ChunkTiptapDocument(doc(`{"type":"heading","content":[{"type":"text","text":"Two"}]}`, paragraph("p", "Text")))
+	assert.NoError(t, err)
+
+	// The heading is part of the embedding input, a renamed section is embedded again
+	assert.Equal(t, "One\nText", first[1].EmbeddingInput())
+	assert.NotEqual(t, first[1].Hash, second[1].Hash)
+}
+
+func TestSplitText(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  []string
+	}{
+		{"fits", "short", 10, []string{"short"}},
+		{"lines are kept together", "aaaa\nbbbb\ncccc", 10, []string{"aaaa\nbbbb", "cccc"}},
+		{"long line is cut", "aaaaaaaaaaaaaaa", 10, []string{"aaaaaaaaaa", "aaaaa"}},
+		{"multi-byte characters are not cut", "aaaaaaaaaé", 10, []string{"aaaaaaaaa", "é"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, splitText(tt.text, tt.limit))
+		})
+	}
+}
@@ -0,0 +1,320 @@
+package aiIndexing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"sententiawebapi/handlers/apis/cloud"
+)
+
+// The digest is the text format explained to the model by diagram_search:
+//
+//	[<group>] #<x-position order L->R> <node label> (icon filename)
+//	--> #<target order> <target label>
+//	--<connection icon>--> #<target order> <target label>
+
+type flowPosition struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+type flowNode struct {
+	ID         string         `json:"id"`
+	Type       string         `json:"type"`
+	Position   flowPosition   `json:"position"`
+	ParentID   string         `json:"parentId"`
+	ParentNode string         `json:"parentNode"` // React Flow < v11.11
+	Data       map[string]any `json:"data"`
+}
+
+type flowEdge struct {
+	ID     string         `json:"id"`
+	Source string         `json:"source"`
+	Target string         `json:"target"`
+	Label  any            `json:"label"`
+	Data   map[string]any `json:"data"`
+}
+
+type flowDesign struct {
+	Nodes []flowNode `json:"nodes"`
+	Edges []flowEdge `json:"edges"`
+}
+
+// Icon lookups built once from cloud.AzureNodes
+var (
+	azureIconsByType map[string]string
+	azureIconsByName map[string]string
+)
+
+func init() {
+	azureIconsByType = make(map[string]string)
+	azureIconsByName = make(map[string]string)
+
+	for _, category := range cloud.AzureNodes {
+		for _, item := range category.Items {
+			file := iconFilename(item.URL)
+			if item.Type != "" {
+				if _, exists := azureIconsByType[strings.ToLower(item.Type)]; !exists {
+					azureIconsByType[strings.ToLower(item.Type)] = file
+				}
+			}
+			azureIconsByName[strings.ToLower(item.Name)] = file
+		}
+	}
+}
+
+// SerializeDiagram turns a React Flow design into the canonical diagram_search text.
+// Returns an empty string when the design has no nodes.
+func SerializeDiagram(title string, design []byte) (string, error) {
+	flow, err := parseFlowDesign(design)
+	if err != nil {
+		return "", err
+	}
+
+	nodesByID := make(map[string]*flowNode, len(flow.Nodes))
+	isParent := make(map[string]bool)
+	for i := range flow.Nodes {
+		node := &flow.Nodes[i]
+		nodesByID[node.ID] = node
+		if parent := node.parent(); parent != "" {
+			isParent[parent] = true
+		}
+	}
+
+	// Positions of child nodes are relative to their parent
+	absolute := make(map[string]flowPosition, len(flow.Nodes))
+	var resolve func(node *flowNode, depth int) flowPosition
+	resolve = func(node *flowNode, depth int) flowPosition {
+		if pos, ok := absolute[node.ID]; ok {
+			return pos
+		}
+		pos := node.Position
+		if parent, ok := nodesByID[node.parent()]; ok && depth < len(flow.Nodes) {
+			parentPos := resolve(parent, depth+1)
+			pos.X += parentPos.X
+			pos.Y += parentPos.Y
+		}
+		absolute[node.ID] = pos
+		return pos
+	}
+
+	var elements []*flowNode
+	for i := range flow.Nodes {
+		node := &flow.Nodes[i]
+		resolve(node, 0)
+		if !isGroupNode(node, isParent) {
+			elements = append(elements, node)
+		}
+	}
+
+	if len(elements) == 0 {
+		return "", nil
+	}
+
+	// Number nodes left to right, top to bottom for equal x
+	sort.SliceStable(elements, func(i, j int) bool {
+		a, b := absolute[elements[i].ID], absolute[elements[j].ID]
+		if a.X != b.X {
+			return a.X < b.X
+		}
+		if a.Y != b.Y {
+			return a.Y < b.Y
+		}
+		return elements[i].ID < elements[j].ID
+	})
+
+	order := make(map[string]int, len(elements))
+	for i, node := range elements {
+		order[node.ID] = i + 1
+	}
+
+	groupPath := func(node *flowNode) string {
+		var names []string
+		seen := make(map[string]bool)
+		for parent, ok := nodesByID[node.parent()]; ok && !seen[parent.ID]; parent, ok = nodesByID[parent.parent()] {
+			seen[parent.ID] = true
+			names = append([]string{nodeLabel(parent)}, names...)
+		}
+		return strings.Join(names, " / ")
+	}
+
+	target := func(id string) string {
+		node, ok := nodesByID[id]
+		if !ok {
+			return ""
+		}
+		if n, ok := order[id]; ok {
+			return fmt.Sprintf("#%d %s", n, nodeLabel(node))
+		}
+		return fmt.Sprintf("[%s]", nodeLabel(node))
+	}
+
+	outgoing := make(map[string][]flowEdge)
+	for _, edge := range flow.Edges {
+		outgoing[edge.Source] = append(outgoing[edge.Source], edge)
+	}
+
+	var sb strings.Builder
+	if title = strings.TrimSpace(title); title != "" {
+		sb.WriteString("Diagram: " + title + "\n")
+	}
+
+	for _, node := range elements {
+		if group := groupPath(node); group != "" {
+			sb.WriteString("[" + group + "] ")
+		}
+		sb.WriteString(fmt.Sprintf("#%d %s", order[node.ID], nodeLabel(node)))
+		if icon := nodeIcon(node); icon != "" {
+			sb.WriteString(" (" + icon + ")")
+		}
+		sb.WriteString("\n")
+
+		writeConnections(&sb, outgoing[node.ID], target)
+	}
+
+	// Connections drawn from a whole group
+	for i := range flow.Nodes {
+		node := &flow.Nodes[i]
+		if _, ok := order[node.ID]; ok || len(outgoing[node.ID]) == 0 {
+			continue
+		}
+		sb.WriteString("[" + nodeLabel(node) + "]\n")
+		writeConnections(&sb, outgoing[node.ID], target)
+	}
+
+	return strings.TrimSpace(sb.String()), nil
+}
+
+func writeConnections(sb *strings.Builder, edges []flowEdge, target func(id string) string) {
+	for _, edge := range edges {
+		to := target(edge.Target)
+		if to == "" {
+			continue
+		}
+
+		arrow := "-->"
+		if icon := edgeIcon(edge); icon != "" {
+			arrow = "--" + icon + "-->"
+		}
+
+		sb.WriteString(arrow + " " + to)
+		if label := edgeLabel(edge); label != "" {
+			sb.WriteString(" : " + label)
+		}
+		sb.WriteString("\n")
+	}
+}
+
+func parseFlowDesign(design []byte) (*flowDesign, error) {
+	raw := strings.TrimSpace(string(design))
+	if raw == "" || raw == "null" {
+		return &flowDesign{}, nil
+	}
+
+	// Some designs are stored as a JSON encoded string
+	if strings.HasPrefix(raw, `"`) {
+		var inner string
+		if err := json.Unmarshal([]byte(raw), &inner); err != nil {
+			return nil, fmt.Errorf("failed to decode diagram design: %w", err)
+		}
+		raw = inner
+	}
+
+	var flow flowDesign
+	if err := json.Unmarshal([]byte(raw), &flow); err != nil {
+		return nil, fmt.Errorf("failed to parse diagram design: %w", err)
+	}
+
+	return &flow, nil
+}
+
+func (n *flowNode) parent() string {
+	if n.ParentID != "" {
+		return n.ParentID
+	}
+	return n.ParentNode
+}
+
+func isGroupNode(node *flowNode, isParent map[string]bool) bool {
+	if isParent[node.ID] {
+		return true
+	}
+	switch strings.ToLower(node.Type) {
+	case "group", "groupnode":
+		return true
+	}
+	return false
+}
+
+func nodeLabel(node *flowNode) string {
+	for _, key := range []string{"label", "name", "title", "text"} {
+		if value := dataString(node.Data, key); value != "" {
+			return value
+		}
+	}
+	if icon := nodeIcon(node); icon != "" {
+		return strings.TrimSuffix(icon, path.Ext(icon))
+	}
+	return "Unnamed"
+}
+
+// nodeIcon prefers the icon set on the node, falling back to the Azure catalogue
+func nodeIcon(node *flowNode) string {
+	for _, key := range []string{"icon", "iconUrl", "iconURL", "url", "image", "src"} {
+		// Inline images carry no usable name
+		if value := dataString(node.Data, key); value != "" && !strings.HasPrefix(value, "data:") {
+			return iconFilename(value)
+		}
+	}
+	for _, key := range []string{"resourceType", "type"} {
+		if file, ok := azureIconsByType[strings.ToLower(dataString(node.Data, key))]; ok {
+			return file
+		}
+	}
+	for _, key := range []string{"iconName", "nodeName", "name"} {
+		if file, ok := azureIconsByName[strings.ToLower(dataString(node.Data, key))]; ok {
+			return file
+		}
+	}
+	return ""
+}
+
+func edgeIcon(edge flowEdge) string {
+	for _, key := range []string{"icon", "iconUrl", "iconURL"} {
+		if value := dataString(edge.Data, key); value != "" {
+			return iconFilename(value)
+		}
+	}
+	return ""
+}
+
+func edgeLabel(edge flowEdge) string {
+	if label, ok := edge.Label.(string); ok && strings.TrimSpace(label) != "" {
+		return singleLine(label)
+	}
+	return dataString(edge.Data, "label")
+}
+
+func dataString(data map[string]any, key string) string {
+	value, ok := data[key].(string)
+	if !ok {
+		return ""
+	}
+	return singleLine(value)
+}
+
+func singleLine(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
+
+// iconFilename reduces an icon URL or path to its file name, e.g. app-services.svg
+func iconFilename(icon string) string {
+	if parsed, err := url.Parse(icon); err == nil && parsed.Path != "" {
+		icon = parsed.Path
+	}
+	return path.Base(icon)
+}
@@ -0,0 +1,119 @@
+package aiIndexing
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func flowDoc(nodes []string, edges []string) []byte {
+	return []byte(`{"nodes":[` + strings.Join(nodes, ",") + `],"edges":[` + strings.Join(edges, ",") + `]}`)
+}
+
+func TestSerializeDiagram(t *testing.T) {
+	tests := []struct {
+		name   string
+		title  string
+		design []byte
+		want   string
+	}{
+		{
+			name:   "empty design",
+			title:  "Empty",
+			design: []byte(""),
+			want:   "",
+		},
+		{
+			name:   "only groups",
+			design: flowDoc([]string{`{"id":"g","type":"group","position":{"x":0,"y":0},"data":{"label":"VNet"}}`}, nil),
+			want:   "",
+		},
+		{
+			name:  "ordered left to right, top to bottom for equal x",
+			title: "  Web  ",
+			design: flowDoc([]string{
+				`{"id":"c","position":{"x":300,"y":0},"data":{"label":"Database"}}`,
+				`{"id":"b","position":{"x":100,"y":200},"data":{"label":"Worker"}}`,
+				`{"id":"a","position":{"x":100,"y":0},"data":{"label":"Frontend"}}`,
+			}, nil),
+			want: "Diagram: Web\n" +
+				"#1 Frontend\n" +
+				"#2 Worker\n" +
+				"#3 Database",
+		},
+		{
+			name: "grouped nodes use absolute positions and the group path",
+			design: flowDoc([]string{
+				`{"id":"vnet","type":"group","position":{"x":500,"y":0},"data":{"label":"VNet"}}`,
+				`{"id":"subnet","position":{"x":10,"y":10},"parentId":"vnet","data":{"label":"Subnet"}}`,
+				`{"id":"vm","position":{"x":10,"y":10},"parentNode":"subnet","data":{"label":"VM"}}`,
+				`{"id":"lb","position":{"x":400,"y":0},"data":{"label":"Load Balancer"}}`,
+			}, nil),
+			want: "#1 Load Balancer\n" +
+				"[VNet / Subnet] #2 VM",
+		},
+		{
+			name: "icons from the node data, inline images are ignored",
+			design: flowDoc([]string{
+				`{"id":"a","position":{"x":0,"y":0},"data":{"label":"App","icon":"https://cdn.example.com/icons/app-services.svg?v=2"}}`,
+				`{"id":"b","position":{"x":100,"y":0},"data":{"label":"Inline","icon":"data:image/png;base64,AAAA"}}`,
+				`{"id":"c","position":{"x":200,"y":0},"data":{"icon":"/icons/storage-accounts.svg"}}`,
+				`{"id":"d","position":{"x":300,"y":0},"data":{}}`,
+			}, nil),
+			want: "#1 App (app-services.svg)\n" +
+				"#2 Inline\n" +
+				"#3 storage-accounts (storage-accounts.svg)\n" +
+				"#4 Unnamed",
+		},
+		{
+			name: "edges with icons and labels, edges to unknown nodes are dropped",
+			design: flowDoc([]string{
+				`{"id":"a","position":{"x":0,"y":0},"data":{"label":"API"}}`,
+				`{"id":"b","position":{"x":100,"y":0},"data":{"label":"Queue"}}`,
+			}, []string{
+				`{"id":"e1","source":"a","target":"b","label":"  publishes\n events "}`,
+				`{"id":"e2","source":"b","target":"a","data":{"icon":"/icons/https.svg","label":"acks"}}`,
+				`{"id":"e3","source":"a","target":"missing"}`,
+			}),
+			want: "#1 API\n" +
+				"--> #2 Queue : publishes events\n" +
+				"#2 Queue\n" +
+				"--https.svg--> #1 API : acks",
+		},
+		{
+			name: "connections to and from groups",
+			design: flowDoc([]string{
+				`{"id":"g","type":"group","position":{"x":0,"y":0},"data":{"label":"Backend"}}`,
+				`{"id":"a","position":{"x":0,"y":0},"parentId":"g","data":{"label":"API"}}`,
+				`{"id":"u","position":{"x":-100,"y":0},"data":{"label":"User"}}`,
+			}, []string{
+				`{"id":"e1","source":"u","target":"g"}`,
+				`{"id":"e2","source":"g","target":"u"}`,
+			}),
+			want: "#1 User\n" +
+				"--> [Backend]\n" +
+				"[Backend] #2 API\n" +
+				"[Backend]\n" +
+				"--> #1 User",
+		},
+		{
+			name:   "design stored as a JSON encoded string",
+			design: []byte(`"{\"nodes\":[{\"id\":\"a\",\"position\":{\"x\":0,\"y\":0},\"data\":{\"label\":\"API\"}}]}"`),
+			want:   "#1 API",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SerializeDiagram(tt.title, tt.design)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSerializeDiagramInvalid(t *testing.T) {
+	_, err := SerializeDiagram("Broken", []byte(`{"nodes":`))
+	assert.Error(t, err)
+}
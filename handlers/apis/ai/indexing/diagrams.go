@@ -0,0 +1,132 @@
+package aiIndexing
+
+// Diagrams are indexed as a whole: the design is serialized into the diagram_search text
+// format, stored in diagram_digest and embedded into the embedding column of the same row.
+
+// Tables:
+// 1. st_schema.diagrams - project diagrams
+// 2. st_schema.diagram_templates - private diagram templates
+// 3. st_schema.cm_diagram_templates - community diagram templates
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"sententiawebapi/handlers/apis/tenantManagement"
+	"sententiawebapi/handlers/models"
+)
+
+var DiagramTables = map[models.ResourceGroupType]string{
+	models.ResourceGroupProject:   "st_schema.diagrams",
+	models.ResourceGroupTemplate:  "st_schema.diagram_templates",
+	models.ResourceGroupCommunity: "st_schema.cm_diagram_templates",
+}
+
+// DiagramRef identifies an indexed diagram
+type DiagramRef struct {
+	Type      models.ResourceGroupType
+	TenantID  string
+	UserID    string // User whose save is indexed, the embedding usage is recorded for them
+	ProjectID string // Set for project diagrams
+	DiagramID string
+}
+
+func (ref DiagramRef) owner() embeddingOwner {
+	owner := embeddingOwner{TenantID: ref.TenantID, UserID: ref.UserID}
+	if ref.ProjectID != "" {
+		owner.ProjectID = &ref.ProjectID
+	}
+	return owner
+}
+
+func (ref DiagramRef) key() string {
+	return fmt.Sprintf("diagram:%s:%s", ref.Type, ref.DiagramID)
+}
+
+// IndexDiagram schedules (re)indexing of the diagram design, it never blocks the request
+func IndexDiagram(ref DiagramRef, title *string, design *json.RawMessage) {
+	if ref.DiagramID == "" {
+		return
+	}
+
+	var diagramTitle string
+	if title != nil {
+		diagramTitle = *title
+	}
+
+	var raw []byte
+	if design != nil {
+		raw = append([]byte(nil), *design...)
+	}
+
+	defaultPipeline.enqueue(ref.key(), func(ctx context.Context) error {
+		return indexDiagram(ctx, ref, diagramTitle, raw)
+	})
+}
+
+func indexDiagram(ctx context.Context, ref DiagramRef, title string, design []byte) error {
+	table, ok := DiagramTables[ref.Type]
+	if !ok {
+		return fmt.Errorf("unknown resource group type: %s", ref.Type)
+	}
+
+	digest, err := SerializeDiagram(title, design)
+	if err != nil {
+		return err
+	}
+
+	// Empty diagrams are excluded from diagram_search
+	if digest == "" {
+		_, err = tenantManagement.DB.ExecContext(ctx, fmt.Sprintf(`
+			UPDATE %s SET diagram_digest = NULL, embedding = NULL
+			WHERE id = $1 AND tenant_id = $2
+		`, table), ref.DiagramID, ref.TenantID)
+		if err != nil {
+			return fmt.Errorf("failed to clear diagram digest: %w", err)
+		}
+		return nil
+	}
+
+	// Saves that don't change the title or the design leave the digest untouched, skip the embedding call
+	var current sql.NullString
+	var hasEmbedding bool
+	err = tenantManagement.DB.QueryRowContext(ctx, fmt.Sprintf(`
+		SELECT diagram_digest, embedding IS NOT NULL
+		FROM %s
+		WHERE id = $1 AND tenant_id = $2
+	`, table), ref.DiagramID, ref.TenantID).Scan(&current, &hasEmbedding)
+	if err == sql.ErrNoRows {
+		return nil // Deleted in the meantime
+	}
+	if err != nil {
+		return fmt.Errorf("failed to fetch diagram digest: %w", err)
+	}
+	if current.Valid && current.String == digest && hasEmbedding {
+		return nil
+	}
+
+	client, route, err := getTenantClient(ref.TenantID)
+	if err != nil {
+		return err
+	}
+
+	vectors, err := embedTexts(ctx, client, route, ref.owner(), []string{digest})
+	if err != nil {
+		return err
+	}
+
+	_, err = tenantManagement.DB.ExecContext(ctx, fmt.Sprintf(`
+		UPDATE %s SET diagram_digest = $1, embedding = $2::vector
+		WHERE id = $3 AND tenant_id = $4
+	`, table), digest, vectors[0], ref.DiagramID, ref.TenantID)
+	if err != nil {
+		return fmt.Errorf("failed to store diagram digest: %w", err)
+	}
+
+	log.Printf("[aiIndexing] Diagram %s indexed: %d chars", ref.DiagramID, len(digest))
+
+	return nil
+}
@@ -0,0 +1,260 @@
+package aiIndexing
+
+// This package keeps the vector tables used by the AI search functions in sync with the editors.
+// Documents are split into block-level chunks, only new or changed blocks are embedded and
+// rows of removed blocks are deleted.
+
+// Tables:
+// 1. st_schema.project_document_vectors - project documents
+// 2. st_schema.document_template_vectors - private document templates
+// 3. st_schema.cm_document_template_vectors - community document templates
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"sententiawebapi/handlers/apis/tenantManagement"
+	"sententiawebapi/handlers/models"
+
+	"github.com/lib/pq"
+)
+
+type DocumentVectorTable struct {
+	Table          string
+	GroupColumn    string // project / template the document belongs to
+	DocumentColumn string
+	SourceTable    string // table holding the documents themselves
+}
+
+var DocumentVectorTables = map[models.ResourceGroupType]DocumentVectorTable{
+	models.ResourceGroupProject: {
+		Table:          "st_schema.project_document_vectors",
+		GroupColumn:    "project_id",
+		DocumentColumn: "document_id",
+		SourceTable:    "st_schema.project_documents",
+	},
+	models.ResourceGroupTemplate: {
+		Table:          "st_schema.document_template_vectors",
+		GroupColumn:    "project_template_id",
+		DocumentColumn: "document_template_id",
+		SourceTable:    "st_schema.document_templates",
+	},
+	models.ResourceGroupCommunity: {
+		Table:          "st_schema.cm_document_template_vectors",
+		GroupColumn:    "cm_project_template_id",
+		DocumentColumn: "cm_document_template_id",
+		SourceTable:    "st_schema.cm_document_templates",
+	},
+}
+
+// DocumentRef identifies an indexed document
+type DocumentRef struct {
+	Type       models.ResourceGroupType
+	TenantID   string
+	UserID     string // User whose save is indexed, the embedding usage is recorded for them
+	GroupID    string
+	DocumentID string
+}
+
+func (ref DocumentRef) owner() embeddingOwner {
+	owner := embeddingOwner{TenantID: ref.TenantID, UserID: ref.UserID}
+	if ref.Type == models.ResourceGroupProject {
+		owner.ProjectID = &ref.GroupID
+	}
+	return owner
+}
+
+func (ref DocumentRef) key() string {
+	return fmt.Sprintf("document:%s:%s", ref.Type, ref.DocumentID)
+}
+
+type storedChunk struct {
+	Hash  string
+	Index int
+}
+
+// IndexDocument schedules (re)indexing of the document content, it never blocks the request
+func IndexDocument(ref DocumentRef, content *json.RawMessage) {
+	if content == nil || ref.DocumentID == "" || ref.GroupID == "" {
+		return
+	}
+
+	raw := append([]byte(nil), *content...)
+	defaultPipeline.enqueue(ref.key(), func(ctx context.Context) error {
+		return indexDocument(ctx, ref, raw)
+	})
+}
+
+// RemoveDocument schedules removal of all indexed chunks of the document
+func RemoveDocument(ref DocumentRef) {
+	if ref.DocumentID == "" {
+		return
+	}
+
+	defaultPipeline.enqueue(ref.key(), func(ctx context.Context) error {
+		return removeDocument(ctx, ref)
+	})
+}
+
+// diffChunks splits the chunks into the new or edited ones that need a new embedding and the block ids
+// of the unchanged ones. unchanged is never nil: pq.Array sends a nil slice as NULL, and the stale chunk
+// delete would then keep every row.
+func diffChunks(chunks []Chunk, stored map[string]storedChunk) ([]Chunk, []string) {
+	changed := []Chunk{}
+	unchanged := []string{}
+	for _, chunk := range chunks {
+		if existing, ok := stored[chunk.BlockID]; ok && existing.Hash == chunk.Hash {
+			unchanged = append(unchanged, chunk.BlockID)
+			continue
+		}
+		changed = append(changed, chunk)
+	}
+	return changed, unchanged
+}
+
+func indexDocument(ctx context.Context, ref DocumentRef, content []byte) error {
+	table, ok := DocumentVectorTables[ref.Type]
+	if !ok {
+		return fmt.Errorf("unknown resource group type: %s", ref.Type)
+	}
+
+	chunks, err := ChunkTiptapDocument(content)
+	if err != nil {
+		return err
+	}
+
+	stored, err := getStoredChunks(ctx, table, ref)
+	if err != nil {
+		return err
+	}
+
+	changed, unchanged := diffChunks(chunks, stored)
+	inputs := make([]string, len(changed))
+	for i, chunk := range changed {
+		inputs[i] = chunk.EmbeddingInput()
+	}
+
+	var vectors []string
+	if len(changed) > 0 {
+		client, route, err := getTenantClient(ref.TenantID)
+		if err != nil {
+			return err
+		}
+
+		vectors, err = embedTexts(ctx, client, route, ref.owner(), inputs)
+		if err != nil {
+			return err
+		}
+	}
+
+	tx, err := tenantManagement.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Will be no-op if transaction is committed
+
+	// Drop removed and edited blocks, rows indexed before block ids existed are dropped as well
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(`
+		DELETE FROM %s
+		WHERE tenant_id = $1 AND %s = $2
+		AND (block_id IS NULL OR NOT (block_id = ANY($3)))
+	`, table.Table, table.DocumentColumn), ref.TenantID, ref.DocumentID, pq.Array(unchanged))
+	if err != nil {
+		return fmt.Errorf("failed to delete stale chunks: %w", err)
+	}
+
+	// Blocks may have been moved around without being edited
+	for _, chunk := range chunks {
+		existing, ok := stored[chunk.BlockID]
+		if !ok || existing.Hash != chunk.Hash || existing.Index == chunk.Index {
+			continue
+		}
+
+		_, err = tx.ExecContext(ctx, fmt.Sprintf(`
+			UPDATE %s SET block_index = $1
+			WHERE tenant_id = $2 AND %s = $3 AND block_id = $4
+		`, table.Table, table.DocumentColumn), chunk.Index, ref.TenantID, ref.DocumentID, chunk.BlockID)
+		if err != nil {
+			return fmt.Errorf("failed to update chunk position: %w", err)
+		}
+	}
+
+	if len(changed) > 0 {
+		stmt, err := tx.PrepareContext(ctx, fmt.Sprintf(`
+			INSERT INTO %s (
+				tenant_id, %s, %s,
+				block_id, block_type, block_index,
+				content, content_hash, embedding
+			) VALUES (
+				$1, $2, $3,
+				$4, $5, $6,
+				$7, $8, $9::vector
+			)
+		`, table.Table, table.GroupColumn, table.DocumentColumn))
+		if err != nil {
+			return fmt.Errorf("failed to prepare chunk insert: %w", err)
+		}
+		defer stmt.Close()
+
+		for i, chunk := range changed {
+			_, err = stmt.ExecContext(ctx,
+				ref.TenantID, ref.GroupID, ref.DocumentID,
+				chunk.BlockID, chunk.BlockType, chunk.Index,
+				chunk.Content, chunk.Hash, vectors[i],
+			)
+			if err != nil {
+				return fmt.Errorf("failed to insert chunk: %w", err)
+			}
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Printf("[aiIndexing] Document %s indexed: %d blocks, %d embedded", ref.DocumentID, len(chunks), len(changed))
+
+	return nil
+}
+
+func getStoredChunks(ctx context.Context, table DocumentVectorTable, ref DocumentRef) (map[string]storedChunk, error) {
+	rows, err := tenantManagement.DB.QueryContext(ctx, fmt.Sprintf(`
+		SELECT block_id, COALESCE(content_hash, ''), COALESCE(block_index, 0)
+		FROM %s
+		WHERE tenant_id = $1 AND %s = $2 AND block_id IS NOT NULL
+	`, table.Table, table.DocumentColumn), ref.TenantID, ref.DocumentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch indexed chunks: %w", err)
+	}
+	defer rows.Close()
+
+	stored := make(map[string]storedChunk)
+	for rows.Next() {
+		var blockID string
+		var chunk storedChunk
+		if err := rows.Scan(&blockID, &chunk.Hash, &chunk.Index); err != nil {
+			return nil, fmt.Errorf("failed to read indexed chunk: %w", err)
+		}
+		stored[blockID] = chunk
+	}
+
+	return stored, rows.Err()
+}
+
+func removeDocument(ctx context.Context, ref DocumentRef) error {
+	table, ok := DocumentVectorTables[ref.Type]
+	if !ok {
+		return fmt.Errorf("unknown resource group type: %s", ref.Type)
+	}
+
+	_, err := tenantManagement.DB.ExecContext(ctx, fmt.Sprintf(`
+		DELETE FROM %s WHERE tenant_id = $1 AND %s = $2
+	`, table.Table, table.DocumentColumn), ref.TenantID, ref.DocumentID)
+	if err != nil {
+		return fmt.Errorf("failed to delete indexed chunks: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package aiIndexing
+
+import (
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+// storedFrom returns the chunks of the document as they are stored after indexing it
+func storedFrom(t *testing.T, content []byte) map[string]storedChunk {
+	chunks, err := ChunkTiptapDocument(content)
+	assert.NoError(t, err)
+
+	stored := map[string]storedChunk{}
+	for _, chunk := range chunks {
+		stored[chunk.BlockID] = storedChunk{Hash: chunk.Hash, Index: chunk.Index}
+	}
+	return stored
+}
+
+func TestDiffChunks(t *testing.T) {
+	indexed := doc(paragraph("a", "First"), paragraph("b", "Second"))
+
+	tests := []struct {
+		name          string
+		stored        []byte
+		content       []byte
+		wantChanged   []string
+		wantUnchanged []string
+	}{
+		{
+			name:          "first indexing",
+			content:       indexed,
+			wantChanged:   []string{"a", "b"},
+			wantUnchanged: []string{},
+		},
+		{
+			name:          "nothing changed",
+			stored:        indexed,
+			content:       indexed,
+			wantChanged:   []string{},
+			wantUnchanged: []string{"a", "b"},
+		},
+		{
+			name:          "one block edited",
+			stored:        indexed,
+			content:       doc(paragraph("a", "First"), paragraph("b", "Edited")),
+			wantChanged:   []string{"b"},
+			wantUnchanged: []string{"a"},
+		},
+		{
+			name:          "every block edited",
+			stored:        indexed,
+			content:       doc(paragraph("a", "Edited first"), paragraph("b", "Edited second")),
+			wantChanged:   []string{"a", "b"},
+			wantUnchanged: []string{},
+		},
+		{
+			name:          "one paragraph document edited",
+			stored:        doc(paragraph("a", "Only")),
+			content:       doc(paragraph("a", "Only, edited")),
+			wantChanged:   []string{"a"},
+			wantUnchanged: []string{},
+		},
+		{
+			name:          "document emptied",
+			stored:        indexed,
+			content:       doc(),
+			wantChanged:   []string{},
+			wantUnchanged: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stored := map[string]storedChunk{}
+			if tt.stored != nil {
+				stored = storedFrom(t, tt.stored)
+			}
+			chunks, err := ChunkTiptapDocument(tt.content)
+			assert.NoError(t, err)
+
+			changed, unchanged := diffChunks(chunks, stored)
+
+			changedIDs := []string{}
+			for _, chunk := range changed {
+				changedIDs = append(changedIDs, chunk.BlockID)
+			}
+			assert.Equal(t, tt.wantChanged, changedIDs)
+			assert.Equal(t, tt.wantUnchanged, unchanged)
+
+			// A NULL array would make the stale chunk delete keep every row
+			value, err := pq.Array(unchanged).Value()
+			assert.NoError(t, err)
+			assert.NotNil(t, value)
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package aiIndexing
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	aiUsage "sententiawebapi/handlers/apis/ai/usage"
+	"sententiawebapi/handlers/apis/tenantManagement"
+	"sententiawebapi/handlers/models"
+	"sententiawebapi/utilities"
+
+	openai "github.com/openai/openai-go"
+)
+
+// Number of inputs sent in a single embeddings request
+const embeddingBatchSize = 96
+
+// getTenantClient returns the client of the tenant and its embeddings route, the same route
+// is used by document_search / diagram_search for query embeddings
+func getTenantClient(tenantID string) (*openai.Client, models.ModelRoute, error) {
+	client, openAiConfig, err := utilities.GetOpenAiClient(tenantManagement.DB, tenantID)
+	if err != nil {
+		return nil, models.ModelRoute{}, err
+	}
+	return client, utilities.ResolveModelRoute(openAiConfig, models.AiTaskEmbeddings), nil
+}
+
+// embeddingOwner is who the embedding usage is recorded for
+type embeddingOwner struct {
+	TenantID  string
+	UserID    string
+	ProjectID *string // Usage of project resources counts against the project budget
+}
+
+// embedTexts returns one pgvector literal per input, in the same order. Nothing is sent to OpenAI
+// once a budget of the owner is exhausted, aiUsage.ErrBudgetExhausted is returned instead.
+func embedTexts(ctx context.Context, client *openai.Client, route models.ModelRoute, owner embeddingOwner, inputs []string) ([]string, error) {
+	if budget := aiUsage.ExhaustedBudget(owner.TenantID, owner.UserID, owner.ProjectID); budget != nil {
+		return nil, fmt.Errorf("%s budget: %w", budget.Scope, aiUsage.ErrBudgetExhausted)
+	}
+
+	vectors := make([]string, len(inputs))
+
+	for start := 0; start < len(inputs); start += embeddingBatchSize {
+		end := min(start+embeddingBatchSize, len(inputs))
+
+		embedResp, servedModel, err := utilities.CallWithModelFallback(ctx, route, func(model string) (*openai.CreateEmbeddingResponse, error) {
+			return client.Embeddings.New(ctx, openai.EmbeddingNewParams{
+				Model: model,
+				Input: openai.EmbeddingNewParamsInputUnion{
+					OfArrayOfStrings: inputs[start:end],
+				},
+			})
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate embeddings: %w", err)
+		}
+		aiUsage.RecordEmbeddings(owner.TenantID, owner.UserID, owner.ProjectID, route, servedModel, embedResp)
+
+		if len(embedResp.Data) != end-start {
+			return nil, fmt.Errorf("expected %d embeddings, got %d", end-start, len(embedResp.Data))
+		}
+
+		for _, data := range embedResp.Data {
+			vectors[start+int(data.Index)] = FormatVector(data.Embedding)
+		}
+	}
+
+	return vectors, nil
+}
+
+// FormatVector converts an embedding into pgvector text format, e.g. [0.1,0.2]
+func FormatVector(embedding []float64) string {
+	parts := make([]string, len(embedding))
+	for i, value := range embedding {
+		parts[i] = strconv.FormatFloat(value, 'f', -1, 32)
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}
+
+// EmbedQuery embeds a single search phrase with the tenant's OpenAI key, returns a pgvector literal.
+// The usage is recorded for the user, and for the project when the search is limited to one.
+func EmbedQuery(ctx context.Context, tenantID, userID string, projectID *string, query string) (string, error) {
+	client, route, err := getTenantClient(tenantID)
+	if err != nil {
+		return "", err
+	}
+
+	vectors, err := embedTexts(ctx, client, route, embeddingOwner{tenantID, userID, projectID}, []string{query})
+	if err != nil {
+		return "", err
+	}
+
+	return vectors[0], nil
+}
@@ -0,0 +1,137 @@
+package aiIndexing
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"log"
+	"sync"
+	"time"
+
+	aiUsage "sententiawebapi/handlers/apis/ai/usage"
+)
+
+const (
+	indexWorkers    = 4
+	indexQueueSize  = 256
+	indexJobTimeout = 2 * time.Minute
+	// How often tasks that didn't fit into a full queue are offered to it again
+	indexSweepInterval = 10 * time.Second
+)
+
+type indexTask func(ctx context.Context) error
+
+// pipeline runs indexing tasks in the background. Tasks are keyed by resource, a key
+// always lands on the same worker so updates of one document never race each other,
+// and a task queued while an older one for the same key is still waiting replaces it.
+// Keys that don't fit into a full queue are kept in overflow and queued by the sweep.
+type pipeline struct {
+	once     sync.Once
+	mu       sync.Mutex
+	pending  map[string]indexTask
+	overflow map[string]struct{}
+	queues   []chan string
+}
+
+var defaultPipeline = &pipeline{}
+
+func (p *pipeline) start() {
+	p.pending = make(map[string]indexTask)
+	p.overflow = make(map[string]struct{})
+	p.queues = make([]chan string, indexWorkers)
+
+	for i := range p.queues {
+		p.queues[i] = make(chan string, indexQueueSize)
+		go p.work(p.queues[i])
+	}
+
+	go p.sweep()
+}
+
+func (p *pipeline) queueFor(key string) chan string {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return p.queues[h.Sum32()%uint32(len(p.queues))]
+}
+
+// enqueue never blocks, when the queue of the key is full the task stays pending and the
+// key is marked for the sweep
+func (p *pipeline) enqueue(key string, task indexTask) {
+	p.once.Do(p.start)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	_, queued := p.pending[key]
+	p.pending[key] = task
+
+	// Already waiting in a queue or in overflow, the latest task is picked up
+	if queued {
+		return
+	}
+
+	select {
+	case p.queueFor(key) <- key:
+	default:
+		p.overflow[key] = struct{}{}
+		log.Printf("[aiIndexing] Queue is full, %s is left for the sweep", key)
+	}
+}
+
+// sweep moves overflowed keys into their queues once there is room again
+func (p *pipeline) sweep() {
+	ticker := time.NewTicker(indexSweepInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		p.mu.Lock()
+		for key := range p.overflow {
+			select {
+			case p.queueFor(key) <- key:
+				delete(p.overflow, key)
+			default:
+			}
+		}
+		p.mu.Unlock()
+	}
+}
+
+func (p *pipeline) work(queue chan string) {
+	for key := range queue {
+		p.mu.Lock()
+		task, ok := p.pending[key]
+		delete(p.pending, key)
+		p.mu.Unlock()
+
+		if !ok {
+			continue
+		}
+
+		p.run(key, task)
+	}
+}
+
+func (p *pipeline) run(key string, task indexTask) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[aiIndexing] Task %s panicked: %v", key, r)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), indexJobTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := task(ctx)
+	if errors.Is(err, aiUsage.ErrBudgetExhausted) {
+		// Indexed again with the next change of the resource
+		log.Printf("[aiIndexing] Task %s skipped: %v", key, err)
+		return
+	}
+	if err != nil {
+		log.Printf("[aiIndexing] Task %s failed: %v", key, err)
+		return
+	}
+
+	log.Printf("[aiIndexing] Task %s finished in %v", key, time.Since(start))
+}
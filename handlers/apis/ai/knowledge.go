@@ -0,0 +1,46 @@
+package ai
+
+import (
+	"log"
+	"slices"
+
+	aiKnowledge "sententiawebapi/handlers/apis/ai/knowledge"
+	"sententiawebapi/handlers/models"
+
+	"github.com/openai/openai-go/responses"
+)
+
+// useProjectKnowledge lets the model search the knowledge bases of the project, e.g. company standards
+// and policies. The stores are added to the file search of the assistant when it has one.
+func useProjectKnowledge(chatCtx *models.ChatContext, openAiConfig *models.OpenAiConfig, responseParams *responses.ResponseNewParams) {
+	projectID := chatProjectID(chatCtx)
+	if projectID == nil {
+		return
+	}
+
+	vectorStoreIDs, err := aiKnowledge.ProjectVectorStoreIDs(chatCtx.TenantID, *projectID, openAiConfig)
+	if err != nil {
+		log.Printf("Failed to get project knowledge bases: %v", err)
+		return
+	}
+	if len(vectorStoreIDs) == 0 {
+		return
+	}
+
+	for _, tool := range responseParams.Tools {
+		if tool.OfFileSearch != nil {
+			for _, id := range vectorStoreIDs {
+				if !slices.Contains(tool.OfFileSearch.VectorStoreIDs, id) {
+					tool.OfFileSearch.VectorStoreIDs = append(tool.OfFileSearch.VectorStoreIDs, id)
+				}
+			}
+			return
+		}
+	}
+
+	responseParams.Tools = append(responseParams.Tools, responses.ToolUnionParam{
+		OfFileSearch: &responses.FileSearchToolParam{
+			VectorStoreIDs: vectorStoreIDs,
+		},
+	})
+}
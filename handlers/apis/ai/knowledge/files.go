@@ -0,0 +1,393 @@
+package aiKnowledge
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"sententiawebapi/handlers/apis/tenantManagement"
+	"sententiawebapi/handlers/models"
+	"sententiawebapi/utilities"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openai/openai-go"
+)
+
+const maxKnowledgeFileSize = 50 * 1024 * 1024 // 50 MB
+
+// Supported file types by extension
+var knowledgeContentTypes = map[string]string{
+	".pdf":      "application/pdf",
+	".docx":     "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".md":       "text/markdown",
+	".markdown": "text/markdown",
+	".txt":      "text/plain",
+}
+
+// detectKnowledgeContentType checks the content against the extension of the file
+func detectKnowledgeContentType(filename string, content []byte) (string, bool) {
+	contentType, ok := knowledgeContentTypes[strings.ToLower(filepath.Ext(filename))]
+	if !ok {
+		return "", false
+	}
+
+	switch contentType {
+	case "application/pdf":
+		return contentType, bytes.HasPrefix(content, []byte("%PDF-"))
+	case "text/markdown", "text/plain":
+		return contentType, utf8.Valid(content)
+	default:
+		// DOCX files are ZIP archives
+		return contentType, bytes.HasPrefix(content, []byte("PK\x03\x04"))
+	}
+}
+
+const knowledgeFileSelect = `
+	SELECT
+		id, tenant_id, store_name, vector_store_id, file_id, filename, content_type, size_bytes,
+		status, last_error, uploaded_by, created_at, updated_at
+	FROM st_schema.ai_knowledge_files
+`
+
+func scanKnowledgeFile(scanner interface{ Scan(...any) error }) (*models.KnowledgeFile, error) {
+	var file models.KnowledgeFile
+	err := scanner.Scan(
+		&file.ID, &file.TenantID, &file.StoreName, &file.VectorStoreID, &file.FileID, &file.Filename,
+		&file.ContentType, &file.SizeBytes, &file.Status, &file.LastError, &file.UploadedBy,
+		&file.CreatedAt, &file.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// refreshFileStatus reads the indexing status of a file still being processed
+func refreshFileStatus(ctx context.Context, client *openai.Client, file *models.KnowledgeFile) {
+	if file.Status != models.KnowledgeFileInProgress {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, storeRequestTimeout)
+	defer cancel()
+
+	storeFile, err := client.VectorStores.Files.Get(ctx, file.VectorStoreID, file.FileID)
+	if err != nil {
+		log.Printf("Failed to get status of knowledge file %s: %v", file.ID, err)
+		return
+	}
+	if models.KnowledgeFileStatus(storeFile.Status) == file.Status {
+		return
+	}
+
+	file.Status = models.KnowledgeFileStatus(storeFile.Status)
+	if storeFile.LastError.Message != "" {
+		file.LastError = &storeFile.LastError.Message
+	}
+
+	err = tenantManagement.DB.QueryRow(`
+		UPDATE st_schema.ai_knowledge_files
+		SET status = $1, last_error = $2, updated_at = NOW()
+		WHERE id = $3
+		RETURNING updated_at
+	`, file.Status, file.LastError, file.ID).Scan(&file.UpdatedAt)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+	}
+}
+
+// UploadKnowledgeFileHandler uploads a PDF, DOCX, Markdown or text file into a knowledge base.
+// A knowledge base that doesn't exist yet is created. Indexing runs in the background,
+// the file is searchable when its status is completed.
+func UploadKnowledgeFileHandler(c *gin.Context) {
+	userID, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	storeName := c.Query("store")
+	if !storeNamePattern.MatchString(storeName) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Knowledge base name must be 1-64 letters, digits, dashes or underscores"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxKnowledgeFileSize)
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		if strings.Contains(err.Error(), "http: request body too large") {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File size is too big. Please make it at most 50MB"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing or invalid file"})
+		return
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing or invalid file"})
+		return
+	}
+	filename := filepath.Base(header.Filename)
+	contentType, ok := detectKnowledgeContentType(filename, content)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file type, supported are PDF, DOCX, Markdown and text files"})
+		return
+	}
+
+	client, openAiConfig, err := utilities.GetOpenAiClient(tenantManagement.DB, tenantID)
+	if err != nil {
+		log.Printf("Failed to get OpenAI client: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 4*storeRequestTimeout)
+	defer cancel()
+
+	vectorStoreID, err := ensureVectorStore(ctx, client, tenantID, openAiConfig, storeName)
+	if err != nil {
+		log.Printf("Failed to get knowledge base %s: %v", storeName, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	uploaded, err := client.Files.New(ctx, openai.FileNewParams{
+		File:    openai.File(bytes.NewReader(content), filename, contentType),
+		Purpose: openai.FilePurposeAssistants,
+	})
+	if err != nil {
+		log.Printf("Failed to upload knowledge file: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to upload the file to the AI provider"})
+		return
+	}
+
+	storeFile, err := client.VectorStores.Files.New(ctx, vectorStoreID, openai.VectorStoreFileNewParams{
+		FileID: uploaded.ID,
+		Attributes: map[string]openai.VectorStoreFileNewParamsAttributeUnion{
+			"filename": {OfString: openai.String(filename)},
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to add file to vector store %s: %v", vectorStoreID, err)
+		deleteOpenAiFile(client, uploaded.ID)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to add the file to the knowledge base"})
+		return
+	}
+
+	var lastError *string
+	if storeFile.LastError.Message != "" {
+		lastError = &storeFile.LastError.Message
+	}
+
+	knowledgeFile, err := scanKnowledgeFile(tenantManagement.DB.QueryRow(`
+		INSERT INTO st_schema.ai_knowledge_files (
+			tenant_id, store_name, vector_store_id, file_id, filename, content_type, size_bytes,
+			status, last_error, uploaded_by, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW(), NOW())
+		RETURNING
+			id, tenant_id, store_name, vector_store_id, file_id, filename, content_type, size_bytes,
+			status, last_error, uploaded_by, created_at, updated_at
+	`, tenantID, storeName, vectorStoreID, uploaded.ID, filename, contentType, int64(len(content)),
+		storeFile.Status, lastError, userID,
+	))
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		// Without the row the file could never be listed or deleted
+		detachOpenAiFile(client, vectorStoreID, uploaded.ID)
+		deleteOpenAiFile(client, uploaded.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data":    knowledgeFile,
+		"message": "File uploaded successfully!",
+	})
+}
+
+func detachOpenAiFile(client *openai.Client, vectorStoreID string, fileID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeRequestTimeout)
+	defer cancel()
+
+	if _, err := client.VectorStores.Files.Delete(ctx, vectorStoreID, fileID); err != nil {
+		log.Printf("Failed to remove file %s from vector store %s: %v", fileID, vectorStoreID, err)
+	}
+}
+
+func deleteOpenAiFile(client *openai.Client, fileID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeRequestTimeout)
+	defer cancel()
+
+	if _, err := client.Files.Delete(ctx, fileID); err != nil {
+		log.Printf("Failed to delete file %s: %v", fileID, err)
+	}
+}
+
+// GetKnowledgeFilesHandler lists the files of a knowledge base with their indexing status
+func GetKnowledgeFilesHandler(c *gin.Context) {
+	_, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	storeName := c.Query("store")
+	if storeName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Knowledge base name is required"})
+		return
+	}
+
+	rows, err := tenantManagement.DB.Query(knowledgeFileSelect+`
+		WHERE tenant_id = $1 AND store_name = $2
+		ORDER BY created_at DESC
+	`, tenantID, storeName)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+	defer rows.Close()
+
+	files := []*models.KnowledgeFile{}
+	for rows.Next() {
+		file, err := scanKnowledgeFile(rows)
+		if err != nil {
+			log.Printf(models.DatabaseError, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+			return
+		}
+		files = append(files, file)
+	}
+	rows.Close()
+
+	// Only files still being indexed are looked up at the provider
+	var client *openai.Client
+	for _, file := range files {
+		if file.Status != models.KnowledgeFileInProgress {
+			continue
+		}
+		if client == nil {
+			if client, _, err = utilities.GetOpenAiClient(tenantManagement.DB, tenantID); err != nil {
+				log.Printf("Failed to get OpenAI client: %v", err)
+				break
+			}
+		}
+		refreshFileStatus(c.Request.Context(), client, file)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    files,
+		"message": "Knowledge files retrieved successfully!",
+	})
+}
+
+// GetKnowledgeFileStatusHandler returns the indexing status of one file
+func GetKnowledgeFileStatusHandler(c *gin.Context) {
+	_, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	fileID := c.Query("id")
+	if fileID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File ID is required"})
+		return
+	}
+
+	file, err := scanKnowledgeFile(tenantManagement.DB.QueryRow(
+		knowledgeFileSelect+` WHERE id = $1 AND tenant_id = $2`, fileID, tenantID,
+	))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	if file.Status == models.KnowledgeFileInProgress {
+		client, _, err := utilities.GetOpenAiClient(tenantManagement.DB, tenantID)
+		if err != nil {
+			log.Printf("Failed to get OpenAI client: %v", err)
+		} else {
+			refreshFileStatus(c.Request.Context(), client, file)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    file,
+		"message": "Knowledge file retrieved successfully!",
+	})
+}
+
+// DeleteKnowledgeFileHandler removes the file from its knowledge base and from the provider
+func DeleteKnowledgeFileHandler(c *gin.Context) {
+	_, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	fileID := c.Query("id")
+	if fileID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File ID is required"})
+		return
+	}
+
+	file, err := scanKnowledgeFile(tenantManagement.DB.QueryRow(
+		knowledgeFileSelect+` WHERE id = $1 AND tenant_id = $2`, fileID, tenantID,
+	))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	client, _, err := utilities.GetOpenAiClient(tenantManagement.DB, tenantID)
+	if err != nil {
+		log.Printf("Failed to get OpenAI client: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), storeRequestTimeout)
+	defer cancel()
+
+	// A file already gone at the provider is still removed from the list
+	if _, err := client.VectorStores.Files.Delete(ctx, file.VectorStoreID, file.FileID); err != nil && !isNotFound(err) {
+		log.Printf("Failed to remove file %s from vector store %s: %v", file.FileID, file.VectorStoreID, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to remove the file from the knowledge base"})
+		return
+	}
+	if _, err := client.Files.Delete(ctx, file.FileID); err != nil && !isNotFound(err) {
+		log.Printf("Failed to delete file %s: %v", file.FileID, err)
+	}
+
+	if _, err := tenantManagement.DB.Exec(`DELETE FROM st_schema.ai_knowledge_files WHERE id = $1`, file.ID); err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    file,
+		"message": "File deleted successfully!",
+	})
+}
+
+func isNotFound(err error) bool {
+	var apiErr *openai.Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
@@ -0,0 +1,151 @@
+package aiKnowledge
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+
+	"sententiawebapi/handlers/apis/tenantManagement"
+	"sententiawebapi/handlers/models"
+	"sententiawebapi/utilities"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+func getProjectStores(tenantID string, projectID string) ([]string, error) {
+	var stores pq.StringArray
+	err := tenantManagement.DB.QueryRow(`
+		SELECT COALESCE(array_agg(store_name ORDER BY store_name), '{}')
+		FROM st_schema.project_knowledge_bases
+		WHERE tenant_id = $1 AND project_id = $2
+	`, tenantID, projectID).Scan(&stores)
+	return stores, err
+}
+
+// GetProjectKnowledgeBasesHandler returns the knowledge bases prompts of the project are grounded in
+func GetProjectKnowledgeBasesHandler(c *gin.Context) {
+	_, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	projectID := c.Query("project_id")
+	if projectID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Project ID is required"})
+		return
+	}
+
+	stores, err := getProjectStores(tenantID, projectID)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    stores,
+		"message": "Project knowledge bases retrieved successfully!",
+	})
+}
+
+// UpdateProjectKnowledgeBasesHandler replaces the knowledge bases of the project
+func UpdateProjectKnowledgeBasesHandler(c *gin.Context) {
+	userID, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	projectID := c.Query("project_id")
+	if projectID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Project ID is required"})
+		return
+	}
+
+	var req models.ProjectKnowledgeBasesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	openAiConfig, err := utilities.GetOpenAiConfig(tenantManagement.DB, tenantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+	vectorStores := VectorStores(openAiConfig)
+	for _, name := range req.Stores {
+		if _, ok := vectorStores[name]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Knowledge base %s not found", name)})
+			return
+		}
+	}
+
+	var ownerID string
+	err = tenantManagement.DB.QueryRow(`
+		SELECT user_id FROM st_schema.projects WHERE id = $1 AND tenant_id = $2
+	`, projectID, tenantID).Scan(&ownerID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+	// The knowledge bases ground every prompt of the project, only its owner or a tenant admin changes them
+	if ownerID != userID && !utilities.IsTenantAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the owner of the project or an admin can change its knowledge bases"})
+		return
+	}
+
+	tx, err := tenantManagement.DB.Begin()
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		DELETE FROM st_schema.project_knowledge_bases WHERE tenant_id = $1 AND project_id = $2
+	`, tenantID, projectID)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO st_schema.project_knowledge_bases (tenant_id, project_id, store_name)
+		SELECT p.tenant_id, p.id, stores.name
+		FROM st_schema.projects p
+		CROSS JOIN (SELECT DISTINCT unnest($3::text[]) AS name) stores
+		WHERE p.tenant_id = $1 AND p.id = $2
+	`, tenantID, projectID, pq.Array(req.Stores))
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	stores, err := getProjectStores(tenantID, projectID)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    stores,
+		"message": "Project knowledge bases updated successfully!",
+	})
+}
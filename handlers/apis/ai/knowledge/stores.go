@@ -0,0 +1,200 @@
+package aiKnowledge
+
+// Knowledge bases are the named vector stores of a tenant. OpenAiConfig.VectorStores maps the
+// names to the store IDs of the tenant AI provider, assistant configs and projects use the names.
+// The files uploaded into a store are tracked in st_schema.ai_knowledge_files.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"time"
+
+	"sententiawebapi/handlers/apis/tenantManagement"
+	"sententiawebapi/handlers/models"
+	"sententiawebapi/utilities"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/openai/openai-go"
+)
+
+const storeRequestTimeout = 30 * time.Second
+
+var storeNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// VectorStores returns the knowledge base names of the tenant with their vector store IDs
+func VectorStores(openAiConfig *models.OpenAiConfig) map[string]string {
+	vectorStores := map[string]string{}
+	if openAiConfig == nil || len(openAiConfig.VectorStores) == 0 {
+		return vectorStores
+	}
+	if err := json.Unmarshal(openAiConfig.VectorStores, &vectorStores); err != nil {
+		log.Printf("Failed to unmarshal vector stores: %v", err)
+	}
+	return vectorStores
+}
+
+// ensureVectorStore returns the store ID of the knowledge base, a missing one is created.
+// The config keeps the first store saved under the name when uploads race.
+func ensureVectorStore(ctx context.Context, client *openai.Client, tenantID string, openAiConfig *models.OpenAiConfig, name string) (string, error) {
+	if id, ok := VectorStores(openAiConfig)[name]; ok && id != "" {
+		return id, nil
+	}
+
+	store, err := client.VectorStores.New(ctx, openai.VectorStoreNewParams{
+		Name:     openai.String(name),
+		Metadata: map[string]string{"tenant_id": tenantID},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create vector store: %w", err)
+	}
+
+	var savedID string
+	err = tenantManagement.DB.QueryRow(`
+		UPDATE st_schema.ai_providers
+		SET config_schema = jsonb_set(
+			config_schema::jsonb,
+			'{vector_stores}',
+			jsonb_build_object($2::text, $3::text) || COALESCE(config_schema::jsonb -> 'vector_stores', '{}'::jsonb)
+		)
+		WHERE tenant_id = $1 AND name = 'openai' AND is_active = true
+		RETURNING config_schema::jsonb -> 'vector_stores' ->> $2::text
+	`, tenantID, name, store.ID).Scan(&savedID)
+	if err != nil {
+		deleteVectorStore(client, store.ID)
+		return "", err
+	}
+	if savedID != store.ID {
+		deleteVectorStore(client, store.ID)
+	}
+
+	return savedID, nil
+}
+
+func deleteVectorStore(client *openai.Client, vectorStoreID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeRequestTimeout)
+	defer cancel()
+
+	if _, err := client.VectorStores.Delete(ctx, vectorStoreID); err != nil {
+		log.Printf("Failed to delete vector store %s: %v", vectorStoreID, err)
+	}
+}
+
+// ProjectVectorStoreIDs returns the vector store IDs of the knowledge bases associated with the project
+func ProjectVectorStoreIDs(tenantID string, projectID string, openAiConfig *models.OpenAiConfig) ([]string, error) {
+	rows, err := tenantManagement.DB.Query(`
+		SELECT store_name FROM st_schema.project_knowledge_bases
+		WHERE tenant_id = $1 AND project_id = $2
+		ORDER BY store_name
+	`, tenantID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	vectorStores := VectorStores(openAiConfig)
+	var ids []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if id := vectorStores[name]; id != "" {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, rows.Err()
+}
+
+// storeProjects returns the associated projects by knowledge base name
+func storeProjects(tenantID string) (map[string][]string, error) {
+	rows, err := tenantManagement.DB.Query(`
+		SELECT store_name, array_agg(project_id::text ORDER BY project_id)
+		FROM st_schema.project_knowledge_bases
+		WHERE tenant_id = $1
+		GROUP BY store_name
+	`, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	projects := map[string][]string{}
+	for rows.Next() {
+		var name string
+		var projectIDs pq.StringArray
+		if err := rows.Scan(&name, &projectIDs); err != nil {
+			return nil, err
+		}
+		projects[name] = projectIDs
+	}
+
+	return projects, rows.Err()
+}
+
+// GetKnowledgeBasesHandler lists the knowledge bases of the tenant with their indexing status
+func GetKnowledgeBasesHandler(c *gin.Context) {
+	_, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	client, openAiConfig, err := utilities.GetOpenAiClient(tenantManagement.DB, tenantID)
+	if err != nil {
+		log.Printf("Failed to get OpenAI client: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	projects, err := storeProjects(tenantID)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	knowledgeBases := []models.KnowledgeBase{}
+	for name, id := range VectorStores(openAiConfig) {
+		knowledgeBase := models.KnowledgeBase{
+			Name:          name,
+			VectorStoreID: id,
+			ProjectIDs:    projects[name],
+		}
+		if knowledgeBase.ProjectIDs == nil {
+			knowledgeBase.ProjectIDs = []string{}
+		}
+
+		// A store that can't be read is listed without status, the others are still useful
+		ctx, cancel := context.WithTimeout(c.Request.Context(), storeRequestTimeout)
+		store, err := client.VectorStores.Get(ctx, id)
+		cancel()
+		if err != nil {
+			log.Printf("Failed to get vector store %s: %v", id, err)
+		} else {
+			knowledgeBase.Status = string(store.Status)
+			knowledgeBase.UsageBytes = store.UsageBytes
+			knowledgeBase.FileCounts = &models.KnowledgeFileCounts{
+				InProgress: store.FileCounts.InProgress,
+				Completed:  store.FileCounts.Completed,
+				Failed:     store.FileCounts.Failed,
+				Cancelled:  store.FileCounts.Cancelled,
+				Total:      store.FileCounts.Total,
+			}
+		}
+
+		knowledgeBases = append(knowledgeBases, knowledgeBase)
+	}
+
+	sort.Slice(knowledgeBases, func(i, j int) bool { return knowledgeBases[i].Name < knowledgeBases[j].Name })
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    knowledgeBases,
+		"message": "Knowledge bases retrieved successfully!",
+	})
+}
@@ -0,0 +1,119 @@
+package ai
+
+// Model calls go through the route of the task (chatCtx.ModelRoute), a rate limited, timed out
+// or filtered model is replaced by the next model of the route. Token usage stores the model
+// that served the response next to the requested one.
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	aiUsage "sententiawebapi/handlers/apis/ai/usage"
+	"sententiawebapi/handlers/models"
+	"sententiawebapi/utilities"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/packages/param"
+	"github.com/openai/openai-go/responses"
+)
+
+// attemptRoute prefers the model of the params, following rounds stay with the model that served the previous one
+func attemptRoute(chatCtx *models.ChatContext, params *responses.ResponseNewParams) models.ModelRoute {
+	return chatCtx.ModelRoute.WithPrimary(string(params.Model))
+}
+
+func isContentFiltered(response *responses.Response) bool {
+	return response.Status == responses.ResponseStatusIncomplete && response.IncompleteDetails.Reason == "content_filter"
+}
+
+// recordFilteredUsage stores the usage of a response stopped by the content filter before the fallback
+func recordFilteredUsage(chatCtx *models.ChatContext, response *responses.Response) {
+	log.Printf("Response of model %s was stopped by the content filter", response.Model)
+
+	usage := responseUsage(chatCtx, response)
+	usage.Status = models.TokenUsageFiltered
+	aiUsage.Record(usage)
+}
+
+// attemptParams returns the params of an attempt with params.Model, the temperature is
+// left out for the models that reject it (a fallback may be a reasoning model)
+func attemptParams(params *responses.ResponseNewParams) responses.ResponseNewParams {
+	attempt := *params
+	if !utilities.SupportsTemperature(string(attempt.Model)) {
+		attempt.Temperature = param.Opt[float64]{}
+	}
+	return attempt
+}
+
+// newResponse creates a response with the models of the route, each attempt has its own timeout
+func newResponse(ctx context.Context, client *openai.Client, chatCtx *models.ChatContext, params *responses.ResponseNewParams, timeout time.Duration) (*responses.Response, error) {
+	response, _, err := utilities.CallWithModelFallback(ctx, attemptRoute(chatCtx, params), func(model string) (*responses.Response, error) {
+		params.Model = model
+
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		response, err := client.Responses.New(attemptCtx, attemptParams(params))
+		if isCancellation(attemptCtx, err) {
+			recordCancelledUsage(chatCtx, params, "", err)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if isContentFiltered(response) {
+			recordFilteredUsage(chatCtx, response)
+			return nil, utilities.ErrContentFiltered
+		}
+
+		return response, nil
+	})
+
+	return response, err
+}
+
+// newStreamingResponse streams a response, onDelta receives the output text as it arrives.
+// Another model is tried only before the first delta, the client can't take back streamed text.
+func newStreamingResponse(ctx context.Context, client *openai.Client, chatCtx *models.ChatContext, params *responses.ResponseNewParams, timeout time.Duration, onDelta func(string)) (*responses.Response, error) {
+	response, _, err := utilities.CallWithModelFallback(ctx, attemptRoute(chatCtx, params), func(model string) (*responses.Response, error) {
+		params.Model = model
+
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		stream := client.Responses.NewStreaming(attemptCtx, attemptParams(params))
+
+		var streamed strings.Builder
+		for stream.Next() {
+			event := stream.Current()
+
+			if event.Type == "response.output_text.delta" {
+				streamed.WriteString(event.Delta)
+				onDelta(event.Delta)
+			}
+		}
+
+		err := stream.Err()
+		if isCancellation(attemptCtx, err) {
+			recordCancelledUsage(chatCtx, params, streamed.String(), err)
+		}
+		if err != nil {
+			if streamed.Len() > 0 {
+				return nil, utilities.StopModelFallback(err)
+			}
+			return nil, err
+		}
+
+		response := stream.Current().Response
+		if isContentFiltered(&response) && streamed.Len() == 0 {
+			recordFilteredUsage(chatCtx, &response)
+			return nil, utilities.ErrContentFiltered
+		}
+
+		return &response, nil
+	})
+
+	return response, err
+}
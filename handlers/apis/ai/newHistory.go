@@ -9,6 +9,7 @@ import (
 	"sententiawebapi/handlers/models"
 	"sententiawebapi/utilities"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -116,9 +117,11 @@ func newCompletionResource(completionRequest *models.CompletionRequest) (*models
             prompt_tokens,
             completion_tokens,
 			total_tokens,
-			created_at
+			created_at,
+			citations,
+			response_id
         ) VALUES (
-            $1, $2, $3, $4, $5, $6, $7, $8, $9
+            $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
         ) RETURNING
             id,
             user_id,
@@ -147,6 +150,16 @@ func newCompletionResource(completionRequest *models.CompletionRequest) (*models
 		return nil, err
 	}
 
+	// Answers without any sources keep citations NULL
+	var citationsJSON interface{} = nil
+	if len(completionRequest.Citations) > 0 {
+		citationsJSON, err = json.Marshal(completionRequest.Citations)
+		if err != nil {
+			log.Printf("Failed to marshal citations: %v", err)
+			return nil, err
+		}
+	}
+
 	// Execute the statement and return the result
 	var completionRequestResource models.CompletionRequestResource
 	err = stmt.QueryRow(
@@ -159,6 +172,8 @@ func newCompletionResource(completionRequest *models.CompletionRequest) (*models
 		completionRequest.CompletionTokens,
 		completionRequest.TotalTokens,
 		completionRequest.CreatedAt,
+		citationsJSON,
+		completionRequest.ResponseID,
 	).Scan(
 		&completionRequestResource.ID,
 		&completionRequestResource.UserID,
@@ -182,28 +197,161 @@ func newCompletionResource(completionRequest *models.CompletionRequest) (*models
 	return &completionRequestResource, nil
 }
 
-func getCombinedMessages(tenantID string, conversationID string) ([]models.MessageResource, error) {
+// historySegment is a part of the conversation history. Branches show the messages
+// of their parent conversations up to the message they were branched from.
+type historySegment struct {
+	ConversationID string
+	Until          *time.Time // Last message of the parent shown in the branch, nil for the conversation itself
+}
+
+// getHistorySegments returns the conversation and its ancestors, the root conversation first
+func getHistorySegments(tenantID string, conversationID string) ([]historySegment, error) {
+	query := `
+		WITH RECURSIVE chain AS (
+			SELECT id, parent_conversation_id, parent_message_id, 0 AS depth
+			FROM st_schema.conversation
+			WHERE tenant_id = $1 AND id = $2
+			UNION ALL
+			SELECT parent.id, parent.parent_conversation_id, parent.parent_message_id, chain.depth + 1
+			FROM st_schema.conversation parent
+			JOIN chain ON parent.id = chain.parent_conversation_id
+			WHERE parent.tenant_id = $1
+		)
+		SELECT
+			chain.id, fork.created_at
+		FROM
+			chain
+		LEFT JOIN
+			st_schema.completion_prompt fork ON fork.id = chain.parent_message_id
+		ORDER BY
+			chain.depth DESC
+	`
+
+	rows, err := tenantManagement.DB.Query(query, tenantID, conversationID)
+	if err != nil {
+		log.Printf("Error fetching conversation chain: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var segments []historySegment
+	var forks []sql.NullTime
+	for rows.Next() {
+		var segment historySegment
+		var forkedAt sql.NullTime
+		if err := rows.Scan(&segment.ConversationID, &forkedAt); err != nil {
+			log.Printf("Error scanning conversation chain row: %v", err)
+			return nil, err
+		}
+		segments = append(segments, segment)
+		forks = append(forks, forkedAt)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// The fork point of a branch limits the history of its parent, the previous segment
+	for i := 1; i < len(segments); i++ {
+		if forks[i].Valid {
+			until := forks[i].Time
+			segments[i-1].Until = &until
+		}
+	}
+
+	return segments, nil
+}
+
+// getBranchTree returns the whole tree the conversation belongs to, starting at its root conversation
+func getBranchTree(tenantID string, conversationID string) (*models.ConversationBranch, error) {
+	query := `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_conversation_id
+			FROM st_schema.conversation
+			WHERE tenant_id = $1 AND id = $2
+			UNION ALL
+			SELECT parent.id, parent.parent_conversation_id
+			FROM st_schema.conversation parent
+			JOIN ancestors ON parent.id = ancestors.parent_conversation_id
+			WHERE parent.tenant_id = $1
+		), tree AS (
+			SELECT c.id, c.title, c.parent_conversation_id, c.parent_message_id, c.created_at
+			FROM st_schema.conversation c
+			JOIN ancestors ON ancestors.id = c.id
+			WHERE ancestors.parent_conversation_id IS NULL
+			UNION ALL
+			SELECT c.id, c.title, c.parent_conversation_id, c.parent_message_id, c.created_at
+			FROM st_schema.conversation c
+			JOIN tree ON c.parent_conversation_id = tree.id
+			WHERE c.tenant_id = $1
+		)
+		SELECT
+			id, title, parent_conversation_id, parent_message_id, created_at
+		FROM
+			tree
+		ORDER BY
+			created_at ASC
+	`
+
+	rows, err := tenantManagement.DB.Query(query, tenantID, conversationID)
+	if err != nil {
+		log.Printf("Error fetching branch tree: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var root *models.ConversationBranch
+	nodes := make(map[string]*models.ConversationBranch)
+	for rows.Next() {
+		node := &models.ConversationBranch{Branches: []*models.ConversationBranch{}}
+		if err := rows.Scan(&node.ID, &node.Title, &node.ParentConversationID, &node.ParentMessageID, &node.CreatedAt); err != nil {
+			log.Printf("Error scanning branch tree row: %v", err)
+			return nil, err
+		}
+		nodes[node.ID] = node
+
+		// Parents are created before their branches
+		if parent, ok := nodes[valueOrEmpty(node.ParentConversationID)]; ok {
+			parent.Branches = append(parent.Branches, node)
+		} else if root == nil {
+			root = node
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return root, nil
+}
+
+func valueOrEmpty(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func getCombinedMessages(tenantID string, conversationID string, until *time.Time) ([]models.MessageResource, error) {
 	var messages []models.MessageResource
 
 	query := `
 		SELECT
-			id, prompt, created_at, 'user' as role, selections
+			id, prompt, created_at, 'user' as role, selections, NULL as citations, false AS branchable
 		FROM
 			st_schema.user_prompt
 		WHERE
-			tenant_id = $1 AND conversation_id = $2
+			tenant_id = $1 AND conversation_id = $2 AND ($3::timestamptz IS NULL OR created_at <= $3)
 		UNION ALL
 		SELECT
-			id, prompt, created_at, 'assistant' as role, NULL as selections
+			id, prompt, created_at, 'assistant' as role, NULL as selections, citations, response_id IS NOT NULL AS branchable
 		FROM
 			st_schema.completion_prompt
 		WHERE
-			tenant_id = $1 AND conversation_id = $2
+			tenant_id = $1 AND conversation_id = $2 AND ($3::timestamptz IS NULL OR created_at <= $3)
 		ORDER BY
 			created_at ASC
 	`
 
-	rows, err := tenantManagement.DB.Query(query, tenantID, conversationID)
+	rows, err := tenantManagement.DB.Query(query, tenantID, conversationID, until)
 	if err != nil {
 		log.Printf("Error fetching combined messages: %v", err)
 		return nil, err
@@ -213,8 +361,9 @@ func getCombinedMessages(tenantID string, conversationID string) ([]models.Messa
 	for rows.Next() {
 		var message models.MessageResource
 		var selectionsJSON *json.RawMessage
+		var citationsJSON *json.RawMessage
 
-		err := rows.Scan(&message.ID, &message.Content, &message.CreatedAt, &message.Role, &selectionsJSON)
+		err := rows.Scan(&message.ID, &message.Content, &message.CreatedAt, &message.Role, &selectionsJSON, &citationsJSON, &message.Branchable)
 		if err != nil {
 			if err == sql.ErrNoRows {
 				return messages, nil
@@ -223,6 +372,7 @@ func getCombinedMessages(tenantID string, conversationID string) ([]models.Messa
 			log.Printf("Error scanning combined message row: %v", err)
 			return nil, err
 		}
+		message.ConversationID = conversationID
 
 		// Parse selections if they exist
 		if selectionsJSON != nil {
@@ -238,6 +388,16 @@ func getCombinedMessages(tenantID string, conversationID string) ([]models.Messa
 			message.Selections = []models.DocumentSelection{}
 		}
 
+		if citationsJSON != nil {
+			err = json.Unmarshal(*citationsJSON, &message.Citations)
+
+			if err != nil {
+				log.Printf("Error unmarshaling citations: %v", err)
+
+				return nil, err
+			}
+		}
+
 		messages = append(messages, message)
 	}
 
@@ -249,6 +409,8 @@ func getCombinedMessages(tenantID string, conversationID string) ([]models.Messa
 	return messages, nil
 }
 
+// NewChatHistoryHandler returns the messages of the conversation, branches start with the
+// inherited messages of their parents. With tree=true the branch tree is returned as well.
 func NewChatHistoryHandler(c *gin.Context) {
 	_, tenantID, ok := utilities.ProcessIdentity(c)
 	if !ok {
@@ -262,18 +424,39 @@ func NewChatHistoryHandler(c *gin.Context) {
 		return
 	}
 
-	messages, err := getCombinedMessages(tenantID, conversationID)
+	segments, err := getHistorySegments(tenantID, conversationID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
 		return
 	}
 
+	var messages []models.MessageResource
+	for _, segment := range segments {
+		segmentMessages, err := getCombinedMessages(tenantID, segment.ConversationID, segment.Until)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+			return
+		}
+		messages = append(messages, segmentMessages...)
+	}
+
 	// Ensure messages is an empty array if nil
 	if messages == nil {
 		messages = []models.MessageResource{}
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	result := gin.H{
 		"messages": messages,
-	})
+	}
+
+	if c.Query("tree") == "true" {
+		tree, err := getBranchTree(tenantID, conversationID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+			return
+		}
+		result["tree"] = tree
+	}
+
+	c.JSON(http.StatusOK, result)
 }
@@ -8,6 +8,9 @@ import (
 	"log"
 	"net/http"
 	aiFunctions "sententiawebapi/handlers/apis/ai/functions"
+	aiRedaction "sententiawebapi/handlers/apis/ai/redaction"
+	aiUsage "sententiawebapi/handlers/apis/ai/usage"
+	aiVersions "sententiawebapi/handlers/apis/ai/versions"
 	"sententiawebapi/handlers/apis/tenantManagement"
 	"sententiawebapi/handlers/models"
 	"sententiawebapi/utilities"
@@ -39,24 +42,16 @@ type ConversationData struct {
 }
 
 type ChatCompletionData struct {
-	Message        string                   `json:"message"`
-	Status         responses.ResponseStatus `json:"status"`
-	TotalTokens    int64                    `json:"total_tokens"`
-	Params         *responses.ResponseNewParams
-	ShouldContinue bool
+	Message         string                   `json:"message"`
+	Status          responses.ResponseStatus `json:"status"`
+	TotalTokens     int64                    `json:"total_tokens"`
+	Citations       []models.Citation        `json:"citations"`
+	PendingActions  []models.PendingAction   `json:"pending_actions"` // Mutating tool calls waiting for approval
+	Params          *responses.ResponseNewParams
+	FunctionOutputs []responses.ResponseInputItemUnionParam // Outputs sent back to the model in the next round
+	ShouldContinue  bool
 }
 
-// type GenerationState struct {
-// 	Mutex    sync.Mutex
-// 	TenantID string
-// 	StreamID string
-// 	Messages string // Collects the chunks in a stream
-// 	Error    error
-// 	Ended    bool
-// }
-
-// var newGenerationSessions = make(map[string]*GenerationState)
-
 func getConversationData(tenantID string, conversationID string) (*ConversationData, error) {
 	var conversationData ConversationData
 	var lastChatCompletionID sql.NullString
@@ -104,42 +99,10 @@ func getConversationData(tenantID string, conversationID string) (*ConversationD
 	return &conversationData, nil
 }
 
-func getTemplateConfig(tenantID string, conversationID string) (*models.AiConfiguration, error) {
-	query := `
-		SELECT
-			pt.configuration
-		FROM
-			st_schema.prompt_config_template pt
-		INNER JOIN
-			st_schema.conversation c ON pt.id = c.conversation_config_template_id
-		WHERE
-			c.id = $1 AND pt.tenant_id = $2;
-	`
-
-	var rawConfig []byte
-	err := tenantManagement.DB.QueryRow(query, conversationID, tenantID).Scan(&rawConfig)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			log.Printf("Prompt configuration does not exist: %v", err)
-			return nil, err
-		}
-		log.Printf("Failed to get prompt configuration: %v", err)
-		return nil, err
-	}
-
-	var config models.AiConfiguration
-	if err := json.Unmarshal(rawConfig, &config); err != nil {
-		log.Printf("Failed to unmarshal prompt configuration: %v", err)
-		return nil, err
-	}
-
-	return &config, nil
-}
-
 // Return default parameters, used mostly for quick chat
-func getDefaultResponseParams(userID string, userPrompt string) *responses.ResponseNewParams {
+func getDefaultResponseParams(userID string, userPrompt string, model string) *responses.ResponseNewParams {
 	return &responses.ResponseNewParams{
-		Model:           "gpt-4o-mini",
+		Model:           model,
 		User:            openai.String(userID),
 		Instructions:    openai.String("You are a helpful assistant that can answer questions and help with tasks."),
 		Temperature:     openai.Float(0.5),
@@ -151,7 +114,7 @@ func getDefaultResponseParams(userID string, userPrompt string) *responses.Respo
 	}
 }
 
-func useConversationConfig(chatCtx *models.ChatContext, responseParams *responses.ResponseNewParams) (err error) {
+func useConversationConfig(ctx context.Context, chatCtx *models.ChatContext, responseParams *responses.ResponseNewParams) (err error) {
 	conversationData, err := getConversationData(chatCtx.TenantID, *chatCtx.ConversationID)
 	if err != nil {
 		return err
@@ -162,16 +125,15 @@ func useConversationConfig(chatCtx *models.ChatContext, responseParams *response
 
 	// Set the configuration
 	if conversationData.ConversationConfigTemplateId != "" {
-		// Use user-defined configuration
-		templateConfig, err := getTemplateConfig(chatCtx.TenantID, *chatCtx.ConversationID)
+		// Use the template version the conversation is pinned to
+		templateConfig, err := aiVersions.GetConversationTemplateConfig(chatCtx.TenantID, *chatCtx.ConversationID)
 		if err != nil {
+			log.Printf("Failed to get prompt configuration: %v", err)
 			return err
 		}
 
-		responseParams.Temperature = openai.Float(templateConfig.AiTemperature)
-		responseParams.TopP = openai.Float(templateConfig.TopP)
-		responseParams.MaxOutputTokens = openai.Int(int64(templateConfig.MaxTokens))
-		responseParams.Instructions = openai.String(templateConfig.SystemConfig)
+		useTemplateConfig(ctx, chatCtx, templateConfig, responseParams)
+		chatCtx.ToolSettings = templateConfig.Tools
 	}
 
 	// Attach last response id for history
@@ -185,20 +147,17 @@ func useConversationConfig(chatCtx *models.ChatContext, responseParams *response
 	return nil
 }
 
-func useAssistantConfig(assistantName string, openAiConfig *models.OpenAiConfig, responseParams *responses.ResponseNewParams) (err error) {
-	var rawConfig []byte
-
-	query := `
-		SELECT
-			config
-		FROM
-			st_schema.assistants
-		WHERE
-			name = $1
-		AND is_active = true
-	`
+// useTemplateConfig applies the settings of an AI template, the variables of the system config are rendered
+func useTemplateConfig(ctx context.Context, chatCtx *models.ChatContext, templateConfig *models.AiConfiguration, responseParams *responses.ResponseNewParams) {
+	responseParams.Temperature = openai.Float(templateConfig.AiTemperature)
+	responseParams.TopP = openai.Float(templateConfig.TopP)
+	responseParams.MaxOutputTokens = openai.Int(int64(templateConfig.MaxTokens))
+	responseParams.Instructions = openai.String(aiFunctions.RenderTemplateVariables(ctx, chatCtx, templateConfig.SystemConfig))
+}
 
-	err = tenantManagement.DB.QueryRow(query, assistantName).Scan(&rawConfig)
+// useAssistantConfig merges the assistant config, within a conversation the pinned version is used
+func useAssistantConfig(chatCtx *models.ChatContext, assistantName string, openAiConfig *models.OpenAiConfig, responseParams *responses.ResponseNewParams) (err error) {
+	rawConfig, err := aiVersions.GetAssistantConfig(chatCtx.TenantID, chatCtx.ConversationID, assistantName)
 	if err != nil {
 		log.Printf("Failed to get assistant config: %v", err)
 		return err
@@ -288,84 +247,19 @@ func updateLastChatCompletionID(tenantID string, conversationID string, lastChat
 	return nil
 }
 
-func newTokenUsageResource(tokenUsageRequest *models.TenantTokenUsageRequest) error {
-	// Prepare the database statement to insert the AI response
-	stmt, err := tenantManagement.DB.Prepare(`
-        INSERT INTO st_schema.tenant_token_usage (
-            tenant_id,
-            user_id,
-            conversation_id,
-			ai_vendor,
-            ai_model,
-			configuration,
-            prompt_tokens,
-            completion_tokens
-        ) VALUES (
-            $1, $2, $3, $4, $5, $6, $7, $8
-        ) RETURNING
-            id,
-            tenant_id,
-            user_id,
-            conversation_id,
-            ai_vendor,
-            ai_model,
-            prompt_tokens,
-            completion_tokens,
-            created_at
-    `)
-
-	// If the service failed to prepare the statement, return an error
-	if err != nil {
-		log.Printf("Failed to prepare SQL statement: %v", err)
-		return err
-	}
-
-	defer stmt.Close()
-
-	toolsJSON, err := json.Marshal(tokenUsageRequest.Tools)
-	if err != nil {
-		log.Printf("Failed to marshal tools: %v", err)
-		return err
-	}
-
-	// Execute the statement and return the result
-	var tokenUsageResource models.TenantTokenUsageResource
-	err = stmt.QueryRow(
-		tokenUsageRequest.TenantID,
-		tokenUsageRequest.UserID,
-		tokenUsageRequest.ConversationID,
-		tokenUsageRequest.AiVendor,
-		tokenUsageRequest.AiModel,
-		toolsJSON,
-		tokenUsageRequest.PromptTokens,
-		tokenUsageRequest.CompletionTokens,
-	).Scan(
-		&tokenUsageResource.ID,
-		&tokenUsageResource.TenantID,
-		&tokenUsageResource.UserID,
-		&tokenUsageResource.ConversationID,
-		&tokenUsageResource.AiVendor,
-		&tokenUsageResource.AiModel,
-		&tokenUsageResource.PromptTokens,
-		&tokenUsageResource.CompletionTokens,
-		&tokenUsageResource.CreatedAt,
-	)
-
-	if err != nil {
-		log.Printf("Failed to execute SQL insertion: %v", err)
-		return err
-	}
-
-	return nil
-}
-
-func handleCompletion(chatCtx *models.ChatContext, response *responses.Response, params *responses.ResponseNewParams, client *openai.Client) (data *ChatCompletionData, err error) {
+func handleCompletion(ctx context.Context, chatCtx *models.ChatContext, response *responses.Response, params *responses.ResponseNewParams, client *openai.Client) (data *ChatCompletionData, err error) {
 	// clear the previous input entirely
 	params.Input = responses.ResponseNewParamsInputUnion{
 		OfInputItemList: []responses.ResponseInputItemUnionParam{},
 	}
 
-	functionOutputs, err := aiFunctions.ExecuteFunctionCallsParallel(context.Background(), client, chatCtx, response.Output)
+	functionOutputs, pendingActions, err := aiFunctions.ExecuteFunctionCallsParallel(ctx, client, chatCtx, response.ID, response.Output)
+	if isCancellation(ctx, err) {
+		// The round itself finished, its usage is complete but the generation stops here
+		usage := responseUsage(chatCtx, response)
+		usage.Status = models.TokenUsageCancelled
+		aiUsage.Record(usage)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("function call failed: %v", err)
 	}
@@ -377,27 +271,31 @@ func handleCompletion(chatCtx *models.ChatContext, response *responses.Response,
 
 	hasFunctionCall := len(functionOutputs) > 0
 
+	// The conversation pauses, the response is continued once the user decides the actions
+	isPaused := len(pendingActions) > 0
+
 	if chatCtx.ConversationID != nil {
 		params.PreviousResponseID = openai.String(response.ID)
-		if !hasFunctionCall {
+		if !hasFunctionCall && !isPaused {
 			updateLastChatCompletionID(chatCtx.TenantID, *chatCtx.ConversationID, response.ID)
 		}
 	}
 
-	var outputText = response.OutputText()
+	var outputText = restoreText(chatCtx, response.OutputText())
+
+	// Sources collected during the function call rounds belong to the final answer
+	var citations []models.Citation
+	// Only final answers can be continued by a branch, other responses wait for function outputs
+	var branchResponseID *string
+	if !hasFunctionCall && !isPaused {
+		citations = chatCtx.Citations
+		chatCtx.Citations = nil
+		branchResponseID = &response.ID
+	}
 
 	now := time.Now()
 	defer func() {
-		err := newTokenUsageResource(&models.TenantTokenUsageRequest{
-			TenantID:         chatCtx.TenantID,
-			UserID:           chatCtx.UserID,
-			ConversationID:   chatCtx.ConversationID,
-			AiVendor:         "openai",
-			AiModel:          response.Model,
-			Tools:            map[string]interface{}{},
-			PromptTokens:     int32(response.Usage.InputTokens),
-			CompletionTokens: int32(response.Usage.OutputTokens),
-		})
+		err := aiUsage.Record(responseUsage(chatCtx, response))
 		if err == nil {
 			log.Printf("Token usage stored successfully")
 		}
@@ -414,6 +312,8 @@ func handleCompletion(chatCtx *models.ChatContext, response *responses.Response,
 				PromptTokens:     int32(response.Usage.InputTokens),
 				CompletionTokens: int32(response.Usage.OutputTokens),
 				TotalTokens:      int32(response.Usage.TotalTokens),
+				Citations:        citations,
+				ResponseID:       branchResponseID,
 			})
 			if err == nil {
 				log.Printf("Completion resource stored successfully")
@@ -429,106 +329,24 @@ func handleCompletion(chatCtx *models.ChatContext, response *responses.Response,
 
 	log.Printf("Token usage: %v", tokenUsage)
 
-	if outputText == "" && !hasFunctionCall {
+	if outputText == "" && !hasFunctionCall && !isPaused {
 		log.Printf("No output text available in response")
 		return nil, fmt.Errorf("no output text available in response")
 	}
 
 	return &ChatCompletionData{
-		Message:        outputText,
-		Status:         response.Status,
-		TotalTokens:    response.Usage.TotalTokens,
-		Params:         params,
-		ShouldContinue: hasFunctionCall,
+		Message:         outputText,
+		Status:          response.Status,
+		TotalTokens:     response.Usage.TotalTokens,
+		Citations:       citations,
+		PendingActions:  pendingActions,
+		Params:          params,
+		FunctionOutputs: functionOutputs,
+		ShouldContinue:  hasFunctionCall,
 	}, nil
 }
 
-// func generateToken(userID string, tenantID string) (string, error) {
-// 	claims := models.QueryParamToken{
-// 		UserID:   userID,
-// 		TenantID: tenantID,
-// 		RegisteredClaims: jwt.RegisteredClaims{
-// 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(2 * time.Minute)),
-// 			Subject:   userID,
-// 		},
-// 	}
-
-// 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-// 	tokenString, err := token.SignedString([]byte(os.Getenv("SENDGRID_KEY")))
-
-// 	if err != nil {
-// 		log.Println("Could not generate token", err)
-
-// 		return "", err
-// 	}
-
-// 	return tokenString, nil
-// }
-
-// func streamResponse(c *gin.Context, client *openai.Client, idMap *IdMap, responseParams *responses.ResponseNewParams) {
-// 	stream := client.Responses.NewStreaming(context.Background(), *responseParams)
-
-// 	streamID := uuid.New().String()
-// 	session := &GenerationState{
-// 		TenantID: idMap.TenantID,
-// 		StreamID: streamID,
-// 		Messages: "",
-// 		Ended:    false,
-// 	}
-// 	newGenerationSessions[streamID] = session
-
-// 	// Start streaming in a goroutine
-// 	go func() {
-// 		var completeText string
-
-// 		for stream.Next() {
-// 			event := stream.Current()
-
-// 			if event.JSON.Text.IsPresent() {
-// 				log.Printf("Content stream finished: %s", event.Text)
-// 				completeText = event.Text
-
-// 				session.Mutex.Lock()
-// 				session.Ended = true
-// 				session.Mutex.Unlock()
-// 			}
-
-// 			if !session.Ended {
-// 				session.Mutex.Lock()
-// 				session.Messages += strings.ReplaceAll(event.Delta, " ", "&nbsp;")
-// 				session.Mutex.Unlock()
-// 			}
-// 		}
-
-// 		if err := stream.Err(); err != nil {
-// 			log.Printf("Error during streaming: %v", err)
-
-// 			session.Mutex.Lock()
-// 			session.Error = err
-// 			session.Mutex.Unlock()
-// 		}
-
-// 		log.Printf("Complete text: %s", completeText)
-
-// 		response := stream.Current().Response
-// 		handleCompletion(idMap, &response, responseParams, client)
-// 	}()
-
-// 	token, err := generateToken(idMap.UserID, idMap.TenantID)
-// 	if err != nil {
-// 		log.Printf("Failed to generate token: %v", err)
-// 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
-// 		return
-// 	}
-
-// 	c.JSON(http.StatusAccepted, gin.H{
-// 		"status":     "Generation started",
-// 		"stream_url": fmt.Sprintf("newAiStream?stream_id=%s&token=%s", streamID, token),
-// 	})
-// }
-
-func streamChunks(c *gin.Context, client *openai.Client, chatCtx *models.ChatContext, responseParams *responses.ResponseNewParams) error {
+func streamChunks(ctx context.Context, c *gin.Context, client *openai.Client, chatCtx *models.ChatContext, responseParams *responses.ResponseNewParams) error {
 	// Set headers for chunked streaming
 	c.Header("Content-Type", "text/plain; charset=UTF-8")
 	c.Header("Transfer-Encoding", "chunked")
@@ -544,21 +362,18 @@ func streamChunks(c *gin.Context, client *openai.Client, chatCtx *models.ChatCon
 	}
 
 	for {
-		stream := client.Responses.NewStreaming(context.Background(), *responseParams)
-
-		for stream.Next() {
-			event := stream.Current()
-
-			if event.Type == "response.output_text.delta" {
-				writer.Write([]byte(event.Delta))
-				flusher.Flush()
-			}
-		}
-		if err := stream.Err(); err != nil {
+		restorer := aiRedaction.NewStreamRestorer(chatCtx.Redactor)
+		response, err := newStreamingResponse(ctx, client, chatCtx, responseParams, completionTimeout, func(delta string) {
+			writer.Write([]byte(restorer.Write(delta)))
+			flusher.Flush()
+		})
+		writer.Write([]byte(restorer.Flush()))
+		flusher.Flush()
+		if err != nil {
 			return fmt.Errorf("error during streaming: %v", err)
 		}
 
-		if b, err := json.MarshalIndent(stream.Current().Response, "", "  "); err == nil {
+		if b, err := json.MarshalIndent(response, "", "  "); err == nil {
 			log.Println("==== Response ====")
 			log.Println(string(b))
 			log.Println("==================")
@@ -567,8 +382,7 @@ func streamChunks(c *gin.Context, client *openai.Client, chatCtx *models.ChatCon
 			log.Println("Failed to marshal response:", err)
 		}
 
-		response := stream.Current().Response
-		completion, err := handleCompletion(chatCtx, &response, responseParams, client)
+		completion, err := handleCompletion(ctx, chatCtx, response, responseParams, client)
 		if err != nil {
 			return fmt.Errorf("failed to process the openai response: %v", err)
 		}
@@ -577,22 +391,20 @@ func streamChunks(c *gin.Context, client *openai.Client, chatCtx *models.ChatCon
 			continue
 		}
 
+		// Paused responses end the stream, the client lists the actions with /api/aiPendingActions
 		break
 	}
 
 	return nil
 }
 
-func regularResponse(client *openai.Client, chatCtx *models.ChatContext, responseParams *responses.ResponseNewParams) (*ChatCompletionData, error) {
-	response, err := client.Responses.New(
-		context.Background(),
-		*responseParams,
-	)
+func regularResponse(ctx context.Context, client *openai.Client, chatCtx *models.ChatContext, responseParams *responses.ResponseNewParams) (*ChatCompletionData, error) {
+	response, err := newResponse(ctx, client, chatCtx, responseParams, completionTimeout)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create chat response: %v", err)
 	}
 
-	completion, err := handleCompletion(chatCtx, response, responseParams, client)
+	completion, err := handleCompletion(ctx, chatCtx, response, responseParams, client)
 	if err != nil {
 		return nil, fmt.Errorf("failed to process openai response: %v", err)
 	}
@@ -620,11 +432,13 @@ func NewPromptHandler(c *gin.Context) {
 		UserID:             userID,
 		TenantID:           tenantID,
 		ResourceIdentifier: *resourceIdentifier,
+		Feature:            models.AiTaskChat,
 	}
 
 	if conversationID != "" {
 		chatCtx.ConversationID = &conversationID
 	}
+	chatCtx.AssistantName = assistantName
 
 	var promptBody PromptBody
 	if err := c.ShouldBindJSON(&promptBody); err != nil {
@@ -632,7 +446,22 @@ func NewPromptHandler(c *gin.Context) {
 		return
 	}
 
+	if !checkAiBudget(c, chatCtx) {
+		return
+	}
+
 	if conversationID != "" {
+		hasPendingActions, err := aiFunctions.HasPendingActions(tenantID, conversationID)
+		if err != nil {
+			log.Printf(models.DatabaseError, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+			return
+		}
+		if hasPendingActions {
+			c.JSON(http.StatusConflict, gin.H{"error": "Conversation is waiting for approval of pending AI actions"})
+			return
+		}
+
 		now := time.Now()
 
 		defer func() {
@@ -657,17 +486,20 @@ func NewPromptHandler(c *gin.Context) {
 	if err != nil {
 		log.Printf("Failed to get OpenAI client: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
 	}
 
-	var responseParams = getDefaultResponseParams(userID, promptBody.Prompt)
+	modelRoute := utilities.ResolveModelRoute(openAiConfig, models.AiTaskChat)
+
+	var responseParams = getDefaultResponseParams(userID, promptBody.Prompt, modelRoute.Primary)
 
 	var configError error
 	if conversationID != "" {
-		configError = useConversationConfig(chatCtx, responseParams)
+		configError = useConversationConfig(c.Request.Context(), chatCtx, responseParams)
 	}
 
 	if assistantName != "" {
-		configError = useAssistantConfig(assistantName, openAiConfig, responseParams)
+		configError = useAssistantConfig(chatCtx, assistantName, openAiConfig, responseParams)
 	}
 
 	if promptBody.Params != nil {
@@ -679,97 +511,77 @@ func NewPromptHandler(c *gin.Context) {
 		return
 	}
 
+	useProjectKnowledge(chatCtx, openAiConfig, responseParams)
+
+	// A model set by the assistant is requested first, the tenant route stays as fallback
+	chatCtx.ModelRoute = modelRoute.WithPrimary(string(responseParams.Model))
+
+	// The history keeps the prompt as written, only the model gets the redacted text
+	useRedaction(chatCtx, openAiConfig, responseParams)
+	if !promptBody.Stream {
+		// Background generations record the audit and the placeholders when they end
+		defer aiRedaction.RecordAudit(chatCtx)
+		defer aiRedaction.SavePlaceholders(chatCtx)
+	}
+
 	if promptBody.ChunkedStream {
-		err := streamChunks(c, client, chatCtx, responseParams)
+		err := streamChunks(c.Request.Context(), c, client, chatCtx, responseParams)
 		if err != nil {
 			log.Printf("Streaming error: %v", err)
 		}
+	} else if promptBody.Stream {
+		streamResponse(c, client, chatCtx, responseParams)
 	} else {
-		var running = true
-		var totalTokens int64 = 0
-		var lastCompletion *ChatCompletionData
-
-		for running {
-			completion, err := regularResponse(client, chatCtx, responseParams)
-			if err != nil {
-				log.Print(err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
-				return
-			}
+		completion, totalTokens, err := runCompletions(c.Request.Context(), client, chatCtx, responseParams)
+		if err != nil {
+			log.Print(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+			return
+		}
+
+		c.JSON(http.StatusOK, completionResponse(completion, totalTokens))
+	}
+}
 
-			totalTokens += completion.TotalTokens
-			running = completion.ShouldContinue
-			lastCompletion = completion
+// runCompletions calls the model until it stops requesting functions or waits for an approval
+func runCompletions(ctx context.Context, client *openai.Client, chatCtx *models.ChatContext, responseParams *responses.ResponseNewParams) (*ChatCompletionData, int64, error) {
+	var running = true
+	var totalTokens int64 = 0
+	var lastCompletion *ChatCompletionData
+
+	for running {
+		completion, err := regularResponse(ctx, client, chatCtx, responseParams)
+		if err != nil {
+			return nil, totalTokens, err
 		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"message":      lastCompletion.Message,
-			"status":       lastCompletion.Status,
-			"total_tokens": totalTokens,
-		})
+		totalTokens += completion.TotalTokens
+		running = completion.ShouldContinue
+		lastCompletion = completion
 	}
+
+	return lastCompletion, totalTokens, nil
 }
 
-// func NewStreamHandler(c *gin.Context) {
-// 	streamID := c.Query("stream_id")
-
-// 	if streamID == "" {
-// 		log.Printf("Stream ID is not provided")
-// 		c.JSON(http.StatusBadRequest, gin.H{"error": "Stream ID is required"})
-// 		return
-// 	}
-
-// 	// Retrieve session for the stream ID
-// 	session, exists := newGenerationSessions[streamID]
-// 	if !exists {
-// 		c.JSON(http.StatusNotFound, gin.H{"error": "Stream not found in active sessions"})
-// 		return
-// 	}
-
-// 	c.Header("Content-Type", "text/event-stream")
-// 	c.Header("Cache-Control", "no-cache")
-// 	c.Header("Connection", "keep-alive")
-// 	c.Header("Transfer-Encoding", "chunked")
-
-// 	flusher, ok := c.Writer.(http.Flusher)
-// 	if !ok {
-// 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Streaming unsupported"})
-// 		return
-// 	}
-
-// 	// Stream the response in real-time
-// 	c.Stream(func(w io.Writer) bool {
-// 		session.Mutex.Lock()
-// 		defer session.Mutex.Unlock()
-
-// 		// If an error occurred during streaming
-// 		if session.Error != nil {
-// 			c.SSEvent("error", session.Error.Error())
-// 			return false
-// 		}
-
-// 		// Stream the current buffer
-// 		if len(session.Messages) > 0 {
-// 			c.SSEvent("message", session.Messages)
-// 			session.Messages = "" // Clear the buffer after streaming
-// 			flusher.Flush()
-// 		}
-
-// 		// Check if the end-of-stream marker is present
-// 		if session.Ended {
-// 			c.SSEvent("end-of-stream", "Stream completed")
-// 			return false
-// 		}
-
-// 		// If no new data, keep the connection alive
-// 		select {
-// 		case <-c.Request.Context().Done():
-// 			return false
-// 		default:
-// 			return true
-// 		}
-// 	})
-// }
+func completionResponse(completion *ChatCompletionData, totalTokens int64) gin.H {
+	citations := completion.Citations
+	if citations == nil {
+		citations = []models.Citation{}
+	}
+
+	pendingActions := completion.PendingActions
+	if pendingActions == nil {
+		pendingActions = []models.PendingAction{}
+	}
+
+	return gin.H{
+		"message":         completion.Message,
+		"status":          completion.Status,
+		"total_tokens":    totalTokens,
+		"citations":       citations,
+		"pending_actions": pendingActions,
+	}
+}
 
 func lines(parts ...string) string {
 	return strings.Join(parts, "\n")
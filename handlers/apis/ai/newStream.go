@@ -0,0 +1,446 @@
+package ai
+
+// Resumable SSE streaming of AI responses.
+// The generation runs in the background and keeps every event in memory (per API instance).
+// The client reads them from /api/newAiStream and reconnects with Last-Event-ID to continue
+// where it left off, the model is never called again for a reconnect.
+// The generation is not bound to the prompt request. It stops on DELETE /api/newAiStream,
+// after streamGenerationTimeout, or when no client has been reading it for streamAbandonTimeout.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	aiRedaction "sententiawebapi/handlers/apis/ai/redaction"
+	"sententiawebapi/handlers/models"
+	"sententiawebapi/utilities"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/responses"
+)
+
+type StreamEventType string
+
+const (
+	StreamEventDelta      StreamEventType = "delta"       // Chunk of the answer text
+	StreamEventToolCall   StreamEventType = "tool_call"   // Model called a function
+	StreamEventToolResult StreamEventType = "tool_result" // Function finished or waits for approval
+	StreamEventCitation   StreamEventType = "citation"    // Source of the final answer
+	StreamEventUsage      StreamEventType = "usage"       // Token usage of one model round
+	StreamEventDone       StreamEventType = "done"        // Last event of a successful generation
+	StreamEventError      StreamEventType = "error"       // Last event of a failed generation
+)
+
+const (
+	generationSessionTTL      = 10 * time.Minute // Finished generations stay available for reconnects
+	generationJanitorInterval = 15 * time.Second
+	streamAbandonTimeout      = time.Minute // Running generation without any reader is cancelled
+	streamTokenTTL            = 15 * time.Minute
+	streamKeepAlive           = 15 * time.Second
+	streamRetryMillis         = 2000
+	toolResultPreviewSize     = 500
+)
+
+type StreamEvent struct {
+	ID   int64
+	Type StreamEventType
+	Data json.RawMessage
+}
+
+type GenerationState struct {
+	mutex      sync.Mutex
+	ID         string
+	TenantID   string
+	UserID     string
+	events     []StreamEvent
+	ended      bool
+	endedAt    time.Time
+	updated    chan struct{} // Closed and replaced on every change to wake up the readers
+	cancel     context.CancelFunc
+	readers    int       // Connected SSE clients
+	lastReadAt time.Time // When the last reader disconnected
+}
+
+var (
+	generationSessions      = make(map[string]*GenerationState)
+	generationSessionsMutex sync.Mutex
+	generationJanitorOnce   sync.Once
+)
+
+// newGenerationSession returns the session and the context the generation runs with
+func newGenerationSession(tenantID string, userID string) (*GenerationState, context.Context) {
+	generationJanitorOnce.Do(func() {
+		go cleanupGenerationSessions()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), streamGenerationTimeout)
+
+	session := &GenerationState{
+		ID:         uuid.New().String(),
+		TenantID:   tenantID,
+		UserID:     userID,
+		updated:    make(chan struct{}),
+		cancel:     cancel,
+		lastReadAt: time.Now(),
+	}
+
+	generationSessionsMutex.Lock()
+	generationSessions[session.ID] = session
+	generationSessionsMutex.Unlock()
+
+	return session, ctx
+}
+
+func getGenerationSession(streamID string) (*GenerationState, bool) {
+	generationSessionsMutex.Lock()
+	defer generationSessionsMutex.Unlock()
+
+	session, ok := generationSessions[streamID]
+	return session, ok
+}
+
+func cleanupGenerationSessions() {
+	ticker := time.NewTicker(generationJanitorInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		generationSessionsMutex.Lock()
+		for id, session := range generationSessions {
+			session.mutex.Lock()
+			expired := session.ended && time.Since(session.endedAt) > generationSessionTTL
+			abandoned := !session.ended && session.readers == 0 && time.Since(session.lastReadAt) > streamAbandonTimeout
+			session.mutex.Unlock()
+
+			if expired {
+				delete(generationSessions, id)
+			}
+			if abandoned {
+				log.Printf("Cancelling generation %s, no client is reading it", id)
+				session.cancel()
+			}
+		}
+		generationSessionsMutex.Unlock()
+	}
+}
+
+func (s *GenerationState) emit(eventType StreamEventType, data any) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Failed to marshal %s stream event: %v", eventType, err)
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.ended {
+		return
+	}
+
+	s.events = append(s.events, StreamEvent{
+		ID:   int64(len(s.events) + 1),
+		Type: eventType,
+		Data: payload,
+	})
+
+	if eventType == StreamEventDone || eventType == StreamEventError {
+		s.ended = true
+		s.endedAt = time.Now()
+	}
+
+	close(s.updated)
+	s.updated = make(chan struct{})
+}
+
+// attach registers a connected reader, generations without readers are cancelled
+func (s *GenerationState) attach() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.readers++
+}
+
+func (s *GenerationState) detach() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.readers--
+	s.lastReadAt = time.Now()
+}
+
+// eventsAfter returns the events the client has not seen yet and a channel closed on the next change
+func (s *GenerationState) eventsAfter(lastEventID int64) ([]StreamEvent, bool, <-chan struct{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var events []StreamEvent
+	if lastEventID >= 0 && lastEventID < int64(len(s.events)) {
+		events = append(events, s.events[lastEventID:]...)
+	}
+
+	return events, s.ended, s.updated
+}
+
+// StreamTokenSecretEnv holds the secret of the stream tokens, it is used for nothing else
+const StreamTokenSecretEnv = "AI_STREAM_TOKEN_SECRET"
+
+func generateStreamToken(userID string, tenantID string) (string, error) {
+	secret := os.Getenv(StreamTokenSecretEnv)
+	if secret == "" {
+		return "", fmt.Errorf("missing secret for: %s", StreamTokenSecretEnv)
+	}
+
+	claims := models.QueryParamToken{
+		UserID:   userID,
+		TenantID: tenantID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(streamTokenTTL)),
+			Subject:   userID,
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+
+	return token.SignedString([]byte(secret))
+}
+
+// streamResponse starts the generation in the background and returns the stream URL
+func streamResponse(c *gin.Context, client *openai.Client, chatCtx *models.ChatContext, responseParams *responses.ResponseNewParams) {
+	token, err := generateStreamToken(chatCtx.UserID, chatCtx.TenantID)
+	if err != nil {
+		log.Printf("Could not generate stream token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	session, ctx := newGenerationSession(chatCtx.TenantID, chatCtx.UserID)
+
+	go runStreamingGeneration(ctx, session, client, chatCtx, responseParams)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":     "Generation started",
+		"stream_id":  session.ID,
+		"stream_url": fmt.Sprintf("newAiStream?stream_id=%s&token=%s", session.ID, token),
+	})
+}
+
+func runStreamingGeneration(ctx context.Context, session *GenerationState, client *openai.Client, chatCtx *models.ChatContext, responseParams *responses.ResponseNewParams) {
+	defer session.cancel()
+	defer aiRedaction.RecordAudit(chatCtx)
+	defer aiRedaction.SavePlaceholders(chatCtx)
+
+	var totalTokens int64
+
+	for {
+		restorer := aiRedaction.NewStreamRestorer(chatCtx.Redactor)
+		response, err := newStreamingResponse(ctx, client, chatCtx, responseParams, completionTimeout, func(delta string) {
+			if text := restorer.Write(delta); text != "" {
+				session.emit(StreamEventDelta, gin.H{"text": text})
+			}
+		})
+		if text := restorer.Flush(); text != "" {
+			session.emit(StreamEventDelta, gin.H{"text": text})
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				session.emit(StreamEventError, gin.H{"error": "Generation was cancelled", "cancelled": true})
+				return
+			}
+			log.Printf("Error during streaming: %v", err)
+			session.emit(StreamEventError, gin.H{"error": "Failed to generate the response"})
+			return
+		}
+
+		callNames := make(map[string]string)
+		for _, output := range response.Output {
+			if output.Type == "function_call" {
+				callNames[output.CallID] = output.Name
+				session.emit(StreamEventToolCall, gin.H{
+					"call_id":   output.CallID,
+					"name":      output.Name,
+					"arguments": restoreText(chatCtx, output.Arguments),
+				})
+			}
+		}
+
+		completion, err := handleCompletion(ctx, chatCtx, response, responseParams, client)
+		if err != nil {
+			log.Printf("Failed to process the openai response: %v", err)
+			if ctx.Err() != nil {
+				session.emit(StreamEventError, gin.H{"error": "Generation was cancelled", "cancelled": true})
+			} else {
+				session.emit(StreamEventError, gin.H{"error": "Failed to process the response"})
+			}
+			return
+		}
+
+		totalTokens += completion.TotalTokens
+		session.emit(StreamEventUsage, gin.H{
+			"model":             response.Model,
+			"prompt_tokens":     response.Usage.InputTokens,
+			"completion_tokens": response.Usage.OutputTokens,
+			"total_tokens":      response.Usage.TotalTokens,
+		})
+
+		for _, output := range completion.FunctionOutputs {
+			if output.OfFunctionCallOutput == nil {
+				continue
+			}
+			callID := output.OfFunctionCallOutput.CallID
+			session.emit(StreamEventToolResult, gin.H{
+				"call_id": callID,
+				"name":    callNames[callID],
+				"status":  models.PendingActionCompleted,
+				"output":  truncateText(restoreText(chatCtx, output.OfFunctionCallOutput.Output), toolResultPreviewSize),
+			})
+		}
+
+		for _, action := range completion.PendingActions {
+			session.emit(StreamEventToolResult, gin.H{
+				"call_id": action.CallID,
+				"name":    action.ToolName,
+				"status":  action.Status,
+				"action":  action,
+			})
+		}
+
+		if completion.ShouldContinue {
+			continue
+		}
+
+		for _, citation := range completion.Citations {
+			session.emit(StreamEventCitation, citation)
+		}
+
+		session.emit(StreamEventDone, gin.H{
+			"status":          completion.Status,
+			"total_tokens":    totalTokens,
+			"pending_actions": len(completion.PendingActions),
+		})
+		return
+	}
+}
+
+func truncateText(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit]) + "…"
+}
+
+// NewStreamHandler sends the events of a generation as SSE. Reconnecting clients send
+// Last-Event-ID (or the last_event_id query param) and receive only the missed events.
+func NewStreamHandler(c *gin.Context) {
+	userID, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	streamID := c.Query("stream_id")
+	if streamID == "" {
+		log.Printf("Stream ID is not provided")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Stream ID is required"})
+		return
+	}
+
+	session, exists := getGenerationSession(streamID)
+	if !exists || session.TenantID != tenantID || session.UserID != userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stream not found in active sessions"})
+		return
+	}
+
+	lastEventID := int64(0)
+	rawLastEventID := c.GetHeader("Last-Event-ID")
+	if rawLastEventID == "" {
+		rawLastEventID = c.Query("last_event_id")
+	}
+	if rawLastEventID != "" {
+		parsed, err := strconv.ParseInt(rawLastEventID, 10, 64)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Last-Event-ID"})
+			return
+		}
+		lastEventID = parsed
+	}
+
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Streaming unsupported"})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Writer.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(c.Writer, "retry: %d\n\n", streamRetryMillis)
+	flusher.Flush()
+
+	session.attach()
+	defer session.detach()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		events, ended, updated := session.eventsAfter(lastEventID)
+
+		for _, event := range events {
+			fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+			lastEventID = event.ID
+		}
+		if len(events) > 0 {
+			flusher.Flush()
+		}
+
+		if ended {
+			return
+		}
+
+		select {
+		case <-updated:
+		case <-keepAlive.C:
+			fmt.Fprint(c.Writer, ": keep-alive\n\n")
+			flusher.Flush()
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+// CancelStreamHandler stops a running background generation, the readers receive an error event
+func CancelStreamHandler(c *gin.Context) {
+	userID, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	streamID := c.Query("stream_id")
+	if streamID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Stream ID is required"})
+		return
+	}
+
+	session, exists := getGenerationSession(streamID)
+	if !exists || session.TenantID != tenantID || session.UserID != userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stream not found in active sessions"})
+		return
+	}
+
+	session.cancel()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Generation cancelled successfully!"})
+}
@@ -0,0 +1,138 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	aiFunctions "sententiawebapi/handlers/apis/ai/functions"
+	aiRedaction "sententiawebapi/handlers/apis/ai/redaction"
+	"sententiawebapi/handlers/models"
+	"sententiawebapi/utilities"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/responses"
+)
+
+type PendingActionDecisionBody struct {
+	Decision string `json:"decision" binding:"required,oneof=approve reject"`
+	Comment  string `json:"comment,omitempty"` // Passed to the model together with a rejection
+}
+
+// PendingActionDecisionHandler approves or rejects a mutating AI tool call.
+// When the last pending call of the response is decided the generation continues
+// and the response has the same shape as NewPromptHandler.
+func PendingActionDecisionHandler(c *gin.Context) {
+	userID, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	actionID := c.Query("id")
+	if actionID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Action ID is required"})
+		return
+	}
+
+	var body PendingActionDecisionBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	// The project of the paused prompt is known only after the decision, its budget is checked by the next prompt
+	if !checkAiBudget(c, &models.ChatContext{UserID: userID, TenantID: tenantID}) {
+		return
+	}
+
+	client, openAiConfig, err := GetOpenAiClient(tenantID)
+	if err != nil {
+		log.Printf("Failed to get OpenAI client: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	action, resume, err := aiFunctions.DecidePendingAction(c.Request.Context(), client, tenantID, userID, actionID, body.Decision == "approve", body.Comment)
+	if errors.Is(err, aiFunctions.ErrPendingActionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pending action not found"})
+		return
+	}
+	if errors.Is(err, aiFunctions.ErrPendingActionDecided) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Pending action is already decided"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to decide pending action: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	// Other calls of the response still wait for a decision
+	if resume == nil {
+		c.JSON(http.StatusOK, gin.H{
+			"action":  action,
+			"message": "Decision saved, waiting for the remaining actions",
+		})
+		return
+	}
+
+	responseParams, err := getResumeResponseParams(c.Request.Context(), resume, openAiConfig)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	defer aiRedaction.RecordAudit(resume.ChatContext)
+	defer aiRedaction.SavePlaceholders(resume.ChatContext)
+
+	completion, totalTokens, err := runCompletions(c.Request.Context(), client, resume.ChatContext, responseParams)
+	if err != nil {
+		log.Print(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	result := completionResponse(completion, totalTokens)
+	result["action"] = action
+
+	c.JSON(http.StatusOK, result)
+}
+
+// getResumeResponseParams rebuilds the params of the paused prompt and continues its response
+func getResumeResponseParams(ctx context.Context, resume *aiFunctions.PendingActionsResume, openAiConfig *models.OpenAiConfig) (*responses.ResponseNewParams, error) {
+	chatCtx := resume.ChatContext
+	modelRoute := utilities.ResolveModelRoute(openAiConfig, models.AiTaskChat)
+	responseParams := getDefaultResponseParams(chatCtx.UserID, "", modelRoute.Primary)
+
+	if err := useConversationConfig(ctx, chatCtx, responseParams); err != nil {
+		return nil, err
+	}
+
+	if chatCtx.AssistantName != "" {
+		if err := useAssistantConfig(chatCtx, chatCtx.AssistantName, openAiConfig, responseParams); err != nil {
+			return nil, err
+		}
+	}
+
+	useProjectKnowledge(chatCtx, openAiConfig, responseParams)
+
+	chatCtx.ModelRoute = modelRoute.WithPrimary(string(responseParams.Model))
+	chatCtx.Feature = models.AiTaskChat
+
+	useRedaction(chatCtx, openAiConfig, responseParams)
+
+	// Pending actions store the outputs as the functions returned them
+	for _, input := range resume.Inputs {
+		if input.OfFunctionCallOutput != nil {
+			input.OfFunctionCallOutput.Output = chatCtx.Redactor.Redact(input.OfFunctionCallOutput.Output)
+		}
+	}
+
+	responseParams.PreviousResponseID = openai.String(resume.ResponseID)
+	responseParams.Input = responses.ResponseNewParamsInputUnion{
+		OfInputItemList: resume.Inputs,
+	}
+
+	return responseParams, nil
+}
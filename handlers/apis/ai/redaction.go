@@ -0,0 +1,53 @@
+package ai
+
+import (
+	"log"
+
+	aiRedaction "sententiawebapi/handlers/apis/ai/redaction"
+	"sententiawebapi/handlers/models"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/responses"
+)
+
+// useRedaction sets the redactor of the tenant on the chat and redacts the text input and
+// the instructions of the params. Function outputs are redacted when the functions run.
+// Placeholders of earlier turns, including the turns a branch inherits, can be restored as well.
+func useRedaction(chatCtx *models.ChatContext, openAiConfig *models.OpenAiConfig, params *responses.ResponseNewParams) {
+	chatCtx.Redactor = aiRedaction.NewRedactor(chatCtx.TenantID, openAiConfig.Redaction)
+
+	if chatCtx.ConversationID != nil {
+		if err := loadConversationPlaceholders(chatCtx); err != nil {
+			log.Printf("Failed to load redaction placeholders, earlier turns are not restored: %v", err)
+		}
+	}
+
+	if params.Input.OfString.IsPresent() {
+		params.Input.OfString = openai.String(chatCtx.Redactor.Redact(params.Input.OfString.Value))
+	}
+	if params.Instructions.IsPresent() {
+		params.Instructions = openai.String(chatCtx.Redactor.Redact(params.Instructions.Value))
+	}
+}
+
+func loadConversationPlaceholders(chatCtx *models.ChatContext) error {
+	segments, err := getHistorySegments(chatCtx.TenantID, *chatCtx.ConversationID)
+	if err != nil {
+		return err
+	}
+
+	conversationIDs := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		conversationIDs = append(conversationIDs, segment.ConversationID)
+	}
+
+	return aiRedaction.LoadPlaceholders(chatCtx.Redactor, chatCtx.TenantID, conversationIDs)
+}
+
+// restoreText puts the redacted values back into model output
+func restoreText(chatCtx *models.ChatContext, text string) string {
+	if chatCtx.Redactor == nil {
+		return text
+	}
+	return chatCtx.Redactor.Restore(text)
+}
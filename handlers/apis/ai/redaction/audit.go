@@ -0,0 +1,127 @@
+package aiRedaction
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"sententiawebapi/handlers/apis/tenantManagement"
+	"sententiawebapi/handlers/models"
+	"sententiawebapi/utilities"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RecordAudit stores the redactions of the request made since the last record,
+// requests without redactions are not stored
+func RecordAudit(chatCtx *models.ChatContext) {
+	redactor, ok := chatCtx.Redactor.(*Redactor)
+	if !ok {
+		return
+	}
+
+	counts := redactor.takeCounts()
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	if total == 0 {
+		return
+	}
+
+	countsJSON, err := json.Marshal(counts)
+	if err != nil {
+		log.Printf("Failed to marshal redaction counts: %v", err)
+		return
+	}
+
+	var projectID *string
+	if chatCtx.ResourceGroupType == models.ResourceGroupProject {
+		projectID = chatCtx.ResourceGroupID
+	}
+
+	_, err = tenantManagement.DB.Exec(`
+		INSERT INTO st_schema.ai_redaction_audit (tenant_id, user_id, conversation_id, project_id, feature, counts, total)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7)
+	`, chatCtx.TenantID, chatCtx.UserID, chatCtx.ConversationID, projectID, chatCtx.Feature, countsJSON, total)
+	if err != nil {
+		log.Printf("Failed to store redaction audit: %v", err)
+		return
+	}
+
+	log.Printf("Redacted %d sensitive spans before sending them to the model", total)
+}
+
+// GetRedactionAuditHandler lists the redaction counts of the tenant requests, newest first.
+// The period is from/to (YYYY-MM-DD), the current month by default.
+func GetRedactionAuditHandler(c *gin.Context) {
+	_, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now().UTC()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := now.AddDate(0, 0, 1).Truncate(24 * time.Hour)
+
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date, expected YYYY-MM-DD"})
+			return
+		}
+		from = parsed
+	}
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date, expected YYYY-MM-DD"})
+			return
+		}
+		to = parsed.AddDate(0, 0, 1) // Inclusive
+	}
+
+	rows, err := tenantManagement.DB.Query(`
+		SELECT id, user_id, conversation_id, project_id, COALESCE(feature, ''), counts, total, created_at
+		FROM st_schema.ai_redaction_audit
+		WHERE tenant_id = $1
+		AND created_at >= $2 AND created_at < $3
+		ORDER BY created_at DESC
+	`, tenantID, from, to)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+	defer rows.Close()
+
+	entries := []models.RedactionAudit{}
+	total := 0
+	for rows.Next() {
+		var entry models.RedactionAudit
+		var countsJSON []byte
+		if err := rows.Scan(
+			&entry.ID, &entry.UserID, &entry.ConversationID, &entry.ProjectID, &entry.Feature,
+			&countsJSON, &entry.Total, &entry.CreatedAt,
+		); err != nil {
+			log.Printf(models.DatabaseError, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+			return
+		}
+		if err := json.Unmarshal(countsJSON, &entry.Counts); err != nil {
+			log.Printf("Failed to unmarshal redaction counts: %v", err)
+		}
+		total += entry.Total
+		entries = append(entries, entry)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    entries,
+		"total":   total,
+		"rules":   BuiltinRuleNames(),
+		"from":    from.Format(time.DateOnly),
+		"to":      to.AddDate(0, 0, -1).Format(time.DateOnly),
+		"message": "Redaction audit retrieved successfully!",
+	})
+}
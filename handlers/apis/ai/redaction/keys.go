@@ -0,0 +1,84 @@
+package aiRedaction
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+)
+
+// SecretEnv holds the secret the placeholder and value keys are derived from, it is used for nothing else.
+// Without it the keys are random per process: redaction keeps working, but the values stored with the
+// conversations before a restart can't be restored anymore.
+const SecretEnv = "AI_REDACTION_SECRET"
+
+var redactionKeys struct {
+	sync.Once
+	placeholder []byte // HMAC key of the placeholders
+	value       cipher.AEAD
+}
+
+func loadKeys() {
+	redactionKeys.Do(func() {
+		secret := []byte(os.Getenv(SecretEnv))
+		if len(secret) == 0 {
+			log.Printf("Missing %s, redaction placeholders of earlier turns won't survive a restart", SecretEnv)
+			secret = make([]byte, 32)
+			if _, err := rand.Read(secret); err != nil {
+				panic(fmt.Sprintf("aiRedaction: failed to generate a key: %v", err))
+			}
+		}
+
+		redactionKeys.placeholder = deriveKey(secret, "placeholders")
+
+		block, err := aes.NewCipher(deriveKey(secret, "values"))
+		if err != nil {
+			panic(fmt.Sprintf("aiRedaction: %v", err))
+		}
+		redactionKeys.value, err = cipher.NewGCM(block)
+		if err != nil {
+			panic(fmt.Sprintf("aiRedaction: %v", err))
+		}
+	})
+}
+
+func deriveKey(secret []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// encryptValue seals an original value, the conversation and the placeholder are authenticated
+// with it so a value can't be moved to another placeholder or conversation
+func encryptValue(conversationID string, placeholder string, value string) ([]byte, error) {
+	loadKeys()
+
+	nonce := make([]byte, redactionKeys.value.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return redactionKeys.value.Seal(nonce, nonce, []byte(value), valueData(conversationID, placeholder)), nil
+}
+
+func decryptValue(conversationID string, placeholder string, sealed []byte) (string, error) {
+	loadKeys()
+
+	size := redactionKeys.value.NonceSize()
+	if len(sealed) < size {
+		return "", fmt.Errorf("sealed value too short")
+	}
+	value, err := redactionKeys.value.Open(nil, sealed[:size], sealed[size:], valueData(conversationID, placeholder))
+	if err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
+
+func valueData(conversationID string, placeholder string) []byte {
+	return []byte(conversationID + "\x00" + placeholder)
+}
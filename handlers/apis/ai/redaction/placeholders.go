@@ -0,0 +1,86 @@
+package aiRedaction
+
+import (
+	"log"
+
+	"sententiawebapi/handlers/apis/tenantManagement"
+	"sententiawebapi/handlers/models"
+
+	"github.com/lib/pq"
+)
+
+// LoadPlaceholders adds the placeholders stored with the conversations to the redactor, earlier
+// turns stay in the model context and the model may repeat their placeholders
+func LoadPlaceholders(redactor models.TextRedactor, tenantID string, conversationIDs []string) error {
+	r, ok := redactor.(*Redactor)
+	if !ok || len(conversationIDs) == 0 {
+		return nil
+	}
+
+	rows, err := tenantManagement.DB.Query(`
+		SELECT conversation_id, placeholder, encrypted_value
+		FROM st_schema.ai_redaction_placeholders
+		WHERE tenant_id = $1 AND conversation_id = ANY($2)
+	`, tenantID, pq.Array(conversationIDs))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for rows.Next() {
+		var conversationID, placeholder string
+		var sealed []byte
+		if err := rows.Scan(&conversationID, &placeholder, &sealed); err != nil {
+			return err
+		}
+
+		// Values sealed with another secret stay placeholders in the output
+		value, err := decryptValue(conversationID, placeholder, sealed)
+		if err != nil {
+			log.Printf("Failed to decrypt redaction placeholder: %v", err)
+			continue
+		}
+		r.addOriginal(placeholder, value)
+	}
+
+	return rows.Err()
+}
+
+// SavePlaceholders stores the placeholders first used by the request with its conversation
+func SavePlaceholders(chatCtx *models.ChatContext) {
+	redactor, ok := chatCtx.Redactor.(*Redactor)
+	if !ok || chatCtx.ConversationID == nil {
+		return
+	}
+
+	added := redactor.takeAdded()
+	if len(added) == 0 {
+		return
+	}
+
+	// Only the sealed values are stored, the originals never reach the database in plain text
+	placeholders := make([]string, 0, len(added))
+	values := make([][]byte, 0, len(added))
+	for placeholder, value := range added {
+		sealed, err := encryptValue(*chatCtx.ConversationID, placeholder, value)
+		if err != nil {
+			log.Printf("Failed to encrypt redaction placeholder: %v", err)
+			return
+		}
+		placeholders = append(placeholders, placeholder)
+		values = append(values, sealed)
+	}
+
+	_, err := tenantManagement.DB.Exec(`
+		INSERT INTO st_schema.ai_redaction_placeholders (conversation_id, placeholder, tenant_id, encrypted_value)
+		SELECT $1, placeholder, $2, encrypted_value
+		FROM unnest($3::text[], $4::bytea[]) AS added(placeholder, encrypted_value)
+		ON CONFLICT (conversation_id, placeholder) DO NOTHING
+	`, *chatCtx.ConversationID, chatCtx.TenantID, pq.Array(placeholders), pq.Array(values))
+	if err != nil {
+		log.Printf("Failed to store redaction placeholders: %v", err)
+	}
+}
@@ -0,0 +1,334 @@
+package aiRedaction
+
+// Text sent to the model (prompts, document selections, magician text, function outputs) is
+// redacted first: emails, IP addresses, connection strings, keys and the spans of the tenant
+// rules are replaced with placeholders like [EMAIL_3f9a2c1d]. The placeholder is an HMAC of the
+// value keyed with a server secret (keys.go), the same value gets the same placeholder within the
+// tenant, so the model can still tell values apart and earlier turns of a conversation stay
+// consistent. Placeholders in the model output are replaced with the original values again, the
+// values of earlier turns are stored encrypted with the conversation (placeholders.go).
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"sententiawebapi/handlers/models"
+)
+
+const maxPlaceholderLength = 64 // Longer bracketed text in a stream can't be a placeholder
+
+var (
+	placeholderPattern = regexp.MustCompile(`\[[A-Z][A-Z0-9_]*_[0-9a-f]{8}\]`)
+	ruleNamePattern    = regexp.MustCompile(`^[A-Z][A-Z0-9_]{0,39}$`)
+)
+
+type rule struct {
+	name    string
+	pattern *regexp.Regexp
+	value   int // Submatch index of the "value" group, 0 redacts the whole match
+}
+
+func newRule(name string, pattern *regexp.Regexp) rule {
+	value := pattern.SubexpIndex("value")
+	if value < 0 {
+		value = 0
+	}
+	return rule{name: name, pattern: pattern, value: value}
+}
+
+// Specific rules go first, a connection string is not redacted as an email and an IP address
+var builtinRules = []rule{
+	newRule("PRIVATE_KEY", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)),
+	newRule("CONNECTION_STRING", regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.-]*://[^\s:/@"'<>]+:[^\s@"'<>]+@[^\s"'<>]+`)),
+	newRule("CONNECTION_STRING", regexp.MustCompile(`(?i)\b(?:server|data source|host|endpoint|defaultendpointsprotocol|accountname)=[^;"'<>\r\n]+;[^"'<>\r\n]*?(?:password|pwd|accountkey|sharedaccesskey)=[^;"'<>\r\n]+;?`)),
+	newRule("JWT", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)),
+	newRule("API_KEY", regexp.MustCompile(`\b(?:sk-[A-Za-z0-9_-]{20,}|(?:AKIA|ASIA)[0-9A-Z]{16}|gh[pousr]_[A-Za-z0-9]{36,}|xox[abprs]-[A-Za-z0-9-]{10,}|AIza[0-9A-Za-z_-]{35})`)),
+	newRule("SECRET", regexp.MustCompile(`(?i)\b(?:password|passwd|pwd|secret|client[_-]?secret|api[_-]?key|access[_-]?key|access[_-]?token|auth[_-]?token)["']?\s*[:=]\s*["']?(?P<value>[^\s"'<>;,]{6,})`)),
+	newRule("EMAIL", regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)),
+	newRule("IP_ADDRESS", regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`)),
+	newRule("IP_ADDRESS", regexp.MustCompile(`(?i)\b(?:[0-9a-f]{1,4}:){7}[0-9a-f]{1,4}\b`)),
+	newRule("IP_ADDRESS", regexp.MustCompile(`(?i)\b(?:[0-9a-f]{1,4}:){1,6}(?::[0-9a-f]{1,4}){1,6}\b`)), // Compressed, e.g. fe80::1
+}
+
+// BuiltinRuleNames lists the rules a tenant can switch off with disabled_rules
+func BuiltinRuleNames() []string {
+	var names []string
+	for _, r := range builtinRules {
+		if len(names) == 0 || names[len(names)-1] != r.name {
+			names = append(names, r.name)
+		}
+	}
+	return names
+}
+
+// Tenant patterns are compiled once, the config is loaded for every request
+var tenantPatterns sync.Map // pattern -> *regexp.Regexp
+
+func compileTenantRule(tenantRule models.RedactionRule) (rule, bool) {
+	name := strings.ToUpper(tenantRule.Name)
+	if !ruleNamePattern.MatchString(name) {
+		log.Printf("Skipping redaction rule with invalid name %q", tenantRule.Name)
+		return rule{}, false
+	}
+
+	if cached, ok := tenantPatterns.Load(tenantRule.Pattern); ok {
+		return newRule(name, cached.(*regexp.Regexp)), true
+	}
+
+	pattern, err := regexp.Compile(tenantRule.Pattern)
+	if err != nil {
+		log.Printf("Skipping redaction rule %s: %v", name, err)
+		return rule{}, false
+	}
+	tenantPatterns.Store(tenantRule.Pattern, pattern)
+
+	return newRule(name, pattern), true
+}
+
+// Redactor redacts the text of one request and restores its placeholders, it is safe for
+// concurrent use by the function calls of a response
+type Redactor struct {
+	tenantID string
+	rules    []rule
+
+	mu           sync.Mutex
+	originals    map[string]string // Placeholder -> original value
+	placeholders map[string]string // Rule and original value -> placeholder
+	added        map[string]string // Placeholders first used by this request, not stored yet
+	counts       map[string]int    // Redacted spans by rule since the last audit record
+}
+
+// NewRedactor returns the redactor of the tenant config, a disabled config redacts nothing
+func NewRedactor(tenantID string, config models.RedactionConfig) *Redactor {
+	r := &Redactor{
+		tenantID:     tenantID,
+		originals:    make(map[string]string),
+		placeholders: make(map[string]string),
+		added:        make(map[string]string),
+		counts:       make(map[string]int),
+	}
+
+	if config.Disabled {
+		return r
+	}
+
+	disabled := make(map[string]bool)
+	for _, name := range config.DisabledRules {
+		disabled[strings.ToUpper(name)] = true
+	}
+
+	for _, builtin := range builtinRules {
+		if !disabled[builtin.name] {
+			r.rules = append(r.rules, builtin)
+		}
+	}
+
+	for _, tenantRule := range config.Rules {
+		if compiled, ok := compileTenantRule(tenantRule); ok {
+			r.rules = append(r.rules, compiled)
+		}
+	}
+
+	return r
+}
+
+// placeholder returns the placeholder of the value, new values are added to the originals.
+// The caller holds r.mu.
+func (r *Redactor) placeholder(name string, value string) string {
+	key := name + "\x00" + value
+	if placeholder, ok := r.placeholders[key]; ok {
+		return placeholder
+	}
+
+	// Placeholders are short, a placeholder taken by another value is derived again with a counter
+	var placeholder string
+	for attempt := 0; ; attempt++ {
+		placeholder = r.derivePlaceholder(name, value, attempt)
+		if _, taken := r.originals[placeholder]; !taken {
+			break
+		}
+	}
+
+	r.originals[placeholder] = value
+	r.placeholders[key] = placeholder
+	r.added[placeholder] = value
+	return placeholder
+}
+
+func (r *Redactor) derivePlaceholder(name string, value string, attempt int) string {
+	loadKeys()
+
+	mac := hmac.New(sha256.New, redactionKeys.placeholder)
+	mac.Write([]byte(r.tenantID + "\x00" + name + "\x00" + value))
+	if attempt > 0 {
+		mac.Write([]byte("\x00" + strconv.Itoa(attempt)))
+	}
+	return "[" + name + "_" + hex.EncodeToString(mac.Sum(nil)[:4]) + "]"
+}
+
+// addOriginal adds a placeholder of an earlier turn. The caller holds r.mu.
+func (r *Redactor) addOriginal(placeholder string, value string) {
+	r.originals[placeholder] = value
+	if name, ok := placeholderRule(placeholder); ok {
+		r.placeholders[name+"\x00"+value] = placeholder
+	}
+}
+
+// placeholderRule returns the rule name of a placeholder, [EMAIL_3f9a2c1d] is EMAIL
+func placeholderRule(placeholder string) (string, bool) {
+	if !placeholderPattern.MatchString(placeholder) {
+		return "", false
+	}
+	return placeholder[1 : len(placeholder)-len("_00000000]")], true
+}
+
+// Redact replaces the sensitive spans of the text with their placeholders
+func (r *Redactor) Redact(text string) string {
+	if text == "" || len(r.rules) == 0 {
+		return text
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, current := range r.rules {
+		matches := current.pattern.FindAllStringSubmatchIndex(text, -1)
+		if len(matches) == 0 {
+			continue
+		}
+
+		// Spans already replaced by an earlier rule stay as they are
+		existing := placeholderPattern.FindAllStringIndex(text, -1)
+
+		var out strings.Builder
+		last := 0
+		for _, match := range matches {
+			start, end := match[2*current.value], match[2*current.value+1]
+			if start < 0 || start == end || overlaps(existing, start, end) {
+				continue
+			}
+
+			value := text[start:end]
+			placeholder := r.placeholder(current.name, value)
+			r.counts[current.name]++
+
+			out.WriteString(text[last:start])
+			out.WriteString(placeholder)
+			last = end
+		}
+		out.WriteString(text[last:])
+		text = out.String()
+	}
+
+	return text
+}
+
+func overlaps(spans [][]int, start int, end int) bool {
+	for _, span := range spans {
+		if start < span[1] && span[0] < end {
+			return true
+		}
+	}
+	return false
+}
+
+// Restore replaces the known placeholders with their original values, unknown ones are kept
+func (r *Redactor) Restore(text string) string {
+	return r.restore(text, func(value string) string { return value })
+}
+
+// RestoreJSON is Restore for JSON documents, the values are escaped as JSON string content
+func (r *Redactor) RestoreJSON(text string) string {
+	return r.restore(text, func(value string) string {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return value
+		}
+		return string(encoded[1 : len(encoded)-1])
+	})
+}
+
+func (r *Redactor) restore(text string, encode func(string) string) string {
+	if !strings.Contains(text, "[") {
+		return text
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.originals) == 0 {
+		return text
+	}
+
+	return placeholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		if value, ok := r.originals[placeholder]; ok {
+			return encode(value)
+		}
+		return placeholder
+	})
+}
+
+// takeCounts returns the redactions since the last call
+func (r *Redactor) takeCounts() map[string]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	counts := r.counts
+	r.counts = make(map[string]int)
+	return counts
+}
+
+// takeAdded returns the placeholders first used since the last call
+func (r *Redactor) takeAdded() map[string]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	added := r.added
+	r.added = make(map[string]string)
+	return added
+}
+
+// StreamRestorer restores the placeholders of streamed output. A delta ending inside a
+// possible placeholder is held back until the rest of it arrives.
+type StreamRestorer struct {
+	redactor models.TextRedactor
+	pending  string
+}
+
+// NewStreamRestorer passes the deltas through unchanged when the chat has no redactor
+func NewStreamRestorer(redactor models.TextRedactor) *StreamRestorer {
+	return &StreamRestorer{redactor: redactor}
+}
+
+// Write returns the restored text that can be sent to the client
+func (s *StreamRestorer) Write(delta string) string {
+	if s.redactor == nil {
+		return delta
+	}
+
+	text := s.pending + delta
+	cut := len(text)
+	if i := strings.LastIndexByte(text, '['); i >= 0 && !strings.Contains(text[i:], "]") && len(text)-i < maxPlaceholderLength {
+		cut = i
+	}
+
+	s.pending = text[cut:]
+	return s.redactor.Restore(text[:cut])
+}
+
+// Flush returns the text held back at the end of the stream
+func (s *StreamRestorer) Flush() string {
+	text := s.pending
+	s.pending = ""
+	if s.redactor == nil || text == "" {
+		return text
+	}
+	return s.redactor.Restore(text)
+}
@@ -0,0 +1,103 @@
+package aiUsage
+
+// Monthly budgets (tenant, user, project) sum the costs of the usage rows of the current month.
+// The soft threshold only warns, the hard threshold stops every OpenAI call of the scope,
+// prompts as well as the embeddings of indexing and search.
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+
+	"sententiawebapi/handlers/apis/tenantManagement"
+	"sententiawebapi/handlers/models"
+)
+
+// ErrBudgetExhausted is returned instead of calling OpenAI when a hard threshold is reached
+var ErrBudgetExhausted = errors.New("monthly AI budget is exhausted")
+
+// BudgetSelect selects budgets with their spending, summed from the usage rows of the current calendar month (UTC)
+const BudgetSelect = `
+	SELECT
+		b.id, b.tenant_id, b.scope, b.scope_id, b.monthly_limit, b.soft_threshold, b.hard_threshold,
+		b.created_at, b.updated_at,
+		COALESCE((
+			SELECT SUM(u.cost)
+			FROM st_schema.tenant_token_usage u
+			WHERE u.tenant_id = b.tenant_id
+			AND u.created_at >= date_trunc('month', NOW() AT TIME ZONE 'UTC') AT TIME ZONE 'UTC'
+			AND (
+				b.scope = 'tenant'
+				OR (b.scope = 'user' AND u.user_id = b.scope_id)
+				OR (b.scope = 'project' AND u.project_id = b.scope_id)
+			)
+		), 0)
+	FROM st_schema.ai_budgets b
+`
+
+// ScanBudgets reads the rows of BudgetSelect and sets the state of each budget
+func ScanBudgets(rows *sql.Rows) ([]models.AiBudget, error) {
+	budgets := []models.AiBudget{}
+	for rows.Next() {
+		var budget models.AiBudget
+		if err := rows.Scan(
+			&budget.ID, &budget.TenantID, &budget.Scope, &budget.ScopeID, &budget.MonthlyLimit,
+			&budget.SoftThreshold, &budget.HardThreshold, &budget.CreatedAt, &budget.UpdatedAt,
+			&budget.Spent,
+		); err != nil {
+			return nil, err
+		}
+		setBudgetState(&budget)
+		budgets = append(budgets, budget)
+	}
+	return budgets, rows.Err()
+}
+
+func setBudgetState(budget *models.AiBudget) {
+	budget.Percent = budget.Spent / budget.MonthlyLimit * 100
+	budget.State = models.BudgetStateOk
+
+	if budget.HardThreshold != nil && budget.Percent >= *budget.HardThreshold {
+		budget.State = models.BudgetStateExceeded
+	} else if budget.Percent >= budget.SoftThreshold {
+		budget.State = models.BudgetStateWarning
+	}
+}
+
+// ApplicableBudgets returns the budgets of the tenant, user and project with their spending this month.
+// Calls without a user or project only fall under the budgets they have.
+func ApplicableBudgets(tenantID string, userID string, projectID *string) ([]models.AiBudget, error) {
+	query := BudgetSelect + `
+		WHERE b.tenant_id = $1
+		AND (
+			b.scope = 'tenant'
+			OR (b.scope = 'user' AND b.scope_id = NULLIF($2::text, '')::uuid)
+			OR (b.scope = 'project' AND b.scope_id = NULLIF($3::text, '')::uuid)
+		)
+	`
+
+	rows, err := tenantManagement.DB.Query(query, tenantID, userID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return ScanBudgets(rows)
+}
+
+// ExhaustedBudget returns the first budget whose hard threshold is reached, nil when the call may go ahead.
+// Budgets protect from overspend, a failed check must not take the AI down.
+func ExhaustedBudget(tenantID string, userID string, projectID *string) *models.AiBudget {
+	budgets, err := ApplicableBudgets(tenantID, userID, projectID)
+	if err != nil {
+		log.Printf("Failed to check AI budgets: %v", err)
+		return nil
+	}
+
+	for i := range budgets {
+		if budgets[i].State == models.BudgetStateExceeded {
+			return &budgets[i]
+		}
+	}
+	return nil
+}
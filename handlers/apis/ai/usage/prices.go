@@ -0,0 +1,109 @@
+package aiUsage
+
+// Costs come from the model price catalogue. The catalogue is st_schema.ai_model_prices,
+// models missing there use the list prices below.
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"sententiawebapi/handlers/apis/tenantManagement"
+	"sententiawebapi/handlers/models"
+)
+
+const priceCatalogueTTL = 5 * time.Minute
+
+var defaultModelPrices = []models.ModelPrice{
+	{Model: "gpt-4o-mini", InputPer1K: 0.00015, CachedInputPer1K: 0.000075, OutputPer1K: 0.0006, Currency: "USD"},
+	{Model: "gpt-4o", InputPer1K: 0.0025, CachedInputPer1K: 0.00125, OutputPer1K: 0.01, Currency: "USD"},
+	{Model: "gpt-4.1-mini", InputPer1K: 0.0004, CachedInputPer1K: 0.0001, OutputPer1K: 0.0016, Currency: "USD"},
+	{Model: "gpt-4.1", InputPer1K: 0.002, CachedInputPer1K: 0.0005, OutputPer1K: 0.008, Currency: "USD"},
+	{Model: "o3", InputPer1K: 0.002, CachedInputPer1K: 0.0005, OutputPer1K: 0.008, Currency: "USD"},
+	{Model: "o4-mini", InputPer1K: 0.0011, CachedInputPer1K: 0.000275, OutputPer1K: 0.0044, Currency: "USD"},
+	{Model: "text-embedding-3-small", InputPer1K: 0.00002, Currency: "USD"},
+}
+
+var priceCatalogue struct {
+	sync.Mutex
+	prices   map[string]models.ModelPrice
+	loadedAt time.Time
+}
+
+// ModelPrices returns the catalogue by model name, the database rows override the defaults
+func ModelPrices() map[string]models.ModelPrice {
+	priceCatalogue.Lock()
+	defer priceCatalogue.Unlock()
+
+	if priceCatalogue.prices != nil && time.Since(priceCatalogue.loadedAt) < priceCatalogueTTL {
+		return priceCatalogue.prices
+	}
+
+	prices := make(map[string]models.ModelPrice)
+	for _, price := range defaultModelPrices {
+		prices[price.Model] = price
+	}
+
+	rows, err := tenantManagement.DB.Query(`
+		SELECT model, input_per_1k, cached_input_per_1k, output_per_1k, currency
+		FROM st_schema.ai_model_prices
+	`)
+	if err != nil {
+		// Keep the last catalogue, costs are still better than none
+		log.Printf("Failed to load model prices: %v", err)
+		if priceCatalogue.prices != nil {
+			return priceCatalogue.prices
+		}
+	} else {
+		defer rows.Close()
+		for rows.Next() {
+			var price models.ModelPrice
+			if err := rows.Scan(&price.Model, &price.InputPer1K, &price.CachedInputPer1K, &price.OutputPer1K, &price.Currency); err != nil {
+				log.Printf("Failed to scan model price: %v", err)
+				continue
+			}
+			prices[price.Model] = price
+		}
+	}
+
+	priceCatalogue.prices = prices
+	priceCatalogue.loadedAt = time.Now()
+
+	return prices
+}
+
+// FindModelPrice matches the model exactly or by the longest catalogue prefix,
+// responses report dated versions like gpt-4o-mini-2024-07-18
+func FindModelPrice(model string) (models.ModelPrice, bool) {
+	prices := ModelPrices()
+
+	if price, ok := prices[model]; ok {
+		return price, true
+	}
+
+	var best models.ModelPrice
+	for name, price := range prices {
+		if strings.HasPrefix(model, name+"-") && len(name) > len(best.Model) {
+			best = price
+		}
+	}
+
+	return best, best.Model != ""
+}
+
+// CalculateCost returns the cost of the usage, cached input tokens are part of the prompt tokens
+func CalculateCost(model string, promptTokens int32, cachedTokens int32, completionTokens int32) float64 {
+	price, ok := FindModelPrice(model)
+	if !ok {
+		log.Printf("No price for model %s, usage is stored without cost", model)
+		return 0
+	}
+
+	cached := min(cachedTokens, promptTokens)
+	uncached := promptTokens - cached
+
+	return (float64(uncached)*price.InputPer1K +
+		float64(cached)*price.CachedInputPer1K +
+		float64(completionTokens)*price.OutputPer1K) / 1000
+}
@@ -0,0 +1,145 @@
+package aiUsage
+
+// Every AI call is stored in st_schema.tenant_token_usage with its cost, the rows feed the
+// usage report and the monthly budgets.
+
+import (
+	"encoding/json"
+	"log"
+
+	"sententiawebapi/handlers/apis/tenantManagement"
+	"sententiawebapi/handlers/models"
+
+	openai "github.com/openai/openai-go"
+)
+
+// Record stores the usage of an AI call, the cost is calculated from the price catalogue
+func Record(tokenUsageRequest *models.TenantTokenUsageRequest) error {
+	// Prepare the database statement to insert the AI response
+	stmt, err := tenantManagement.DB.Prepare(`
+        INSERT INTO st_schema.tenant_token_usage (
+            tenant_id,
+            user_id,
+            conversation_id,
+			ai_vendor,
+            ai_model,
+            requested_model,
+			configuration,
+            prompt_tokens,
+            completion_tokens,
+            cached_tokens,
+            status,
+            feature,
+            project_id,
+            cost
+        ) VALUES (
+            $1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9, $10, $11, NULLIF($12, ''), $13, $14
+        ) RETURNING
+            id,
+            tenant_id,
+            user_id,
+            conversation_id,
+            ai_vendor,
+            ai_model,
+            COALESCE(requested_model, ai_model),
+            prompt_tokens,
+            completion_tokens,
+            cached_tokens,
+            status,
+            COALESCE(feature, ''),
+            project_id,
+            cost,
+            created_at
+    `)
+
+	// If the service failed to prepare the statement, return an error
+	if err != nil {
+		log.Printf("Failed to prepare SQL statement: %v", err)
+		return err
+	}
+
+	defer stmt.Close()
+
+	toolsJSON, err := json.Marshal(tokenUsageRequest.Tools)
+	if err != nil {
+		log.Printf("Failed to marshal tools: %v", err)
+		return err
+	}
+
+	if tokenUsageRequest.Status == "" {
+		tokenUsageRequest.Status = models.TokenUsageCompleted
+	}
+
+	tokenUsageRequest.Cost = CalculateCost(
+		tokenUsageRequest.AiModel,
+		tokenUsageRequest.PromptTokens,
+		tokenUsageRequest.CachedTokens,
+		tokenUsageRequest.CompletionTokens,
+	)
+
+	// Execute the statement and return the result
+	var tokenUsageResource models.TenantTokenUsageResource
+	err = stmt.QueryRow(
+		tokenUsageRequest.TenantID,
+		tokenUsageRequest.UserID,
+		tokenUsageRequest.ConversationID,
+		tokenUsageRequest.AiVendor,
+		tokenUsageRequest.AiModel,
+		tokenUsageRequest.RequestedModel,
+		toolsJSON,
+		tokenUsageRequest.PromptTokens,
+		tokenUsageRequest.CompletionTokens,
+		tokenUsageRequest.CachedTokens,
+		tokenUsageRequest.Status,
+		tokenUsageRequest.Feature,
+		tokenUsageRequest.ProjectID,
+		tokenUsageRequest.Cost,
+	).Scan(
+		&tokenUsageResource.ID,
+		&tokenUsageResource.TenantID,
+		&tokenUsageResource.UserID,
+		&tokenUsageResource.ConversationID,
+		&tokenUsageResource.AiVendor,
+		&tokenUsageResource.AiModel,
+		&tokenUsageResource.RequestedModel,
+		&tokenUsageResource.PromptTokens,
+		&tokenUsageResource.CompletionTokens,
+		&tokenUsageResource.CachedTokens,
+		&tokenUsageResource.Status,
+		&tokenUsageResource.Feature,
+		&tokenUsageResource.ProjectID,
+		&tokenUsageResource.Cost,
+		&tokenUsageResource.CreatedAt,
+	)
+
+	if err != nil {
+		log.Printf("Failed to execute SQL insertion: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// RecordEmbeddings stores the usage of an embeddings call, embeddings are billed for the input only.
+// servedModel is the model of the route that answered, used when the response doesn't name one.
+func RecordEmbeddings(tenantID string, userID string, projectID *string, route models.ModelRoute, servedModel string, resp *openai.CreateEmbeddingResponse) {
+	model := resp.Model
+	if model == "" {
+		model = servedModel
+	}
+
+	err := Record(&models.TenantTokenUsageRequest{
+		TenantID:       tenantID,
+		UserID:         userID,
+		AiVendor:       "openai",
+		AiModel:        model,
+		RequestedModel: route.Primary,
+		Tools:          map[string]interface{}{},
+		PromptTokens:   int32(resp.Usage.PromptTokens),
+		Feature:        models.AiTaskEmbeddings,
+		ProjectID:      projectID,
+	})
+	if err != nil {
+		log.Printf("Failed to record embedding usage: %v", err)
+	}
+}
@@ -9,21 +9,17 @@ import (
 	"sententiawebapi/utilities"
 
 	"github.com/openai/openai-go"
-	"github.com/openai/openai-go/option"
 	"github.com/openai/openai-go/responses"
 )
 
+// GetOpenAiClient returns the client of the tenant AI provider (OpenAI, Azure OpenAI or compatible)
 func GetOpenAiClient(tenantID string) (*openai.Client, *models.OpenAiConfig, error) {
-	openAiConfig, err := utilities.GetOpenAiConfig(tenantManagement.DB, tenantID)
+	client, openAiConfig, err := utilities.GetOpenAiClient(tenantManagement.DB, tenantID)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to get OpenAI config: %w", err)
+		return nil, nil, fmt.Errorf("failed to get OpenAI client: %w", err)
 	}
 
-	client := openai.NewClient(
-		option.WithAPIKey(openAiConfig.OpenAIApiKey),
-	)
-
-	return &client, openAiConfig, nil
+	return client, openAiConfig, nil
 }
 
 func mergeAssistantParams(responseParams *responses.ResponseNewParams, assistantParams *models.AssistantParams, openAiConfig *models.OpenAiConfig) {
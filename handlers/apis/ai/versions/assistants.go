@@ -0,0 +1,347 @@
+package aiVersions
+
+// Assistants keep their configs as versions in st_schema.assistant_versions,
+// st_schema.assistants holds the config and number of the active version.
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"sententiawebapi/handlers/apis/tenantManagement"
+	"sententiawebapi/handlers/models"
+	"sententiawebapi/utilities"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ErrAssistantNotFound = errors.New("assistant not found")
+
+// CreateAssistantVersion snapshots the config of the assistant as its next version and makes it the active one.
+// Callers change st_schema.assistants.config in the same transaction first.
+func CreateAssistantVersion(tx *sql.Tx, name string, userID *string, changelog *string) (int, error) {
+	// Locked in its own statement, see lockTemplate
+	if _, err := lockAssistant(tx, name); err != nil {
+		return 0, err
+	}
+
+	var version int
+	err := tx.QueryRow(`
+		INSERT INTO st_schema.assistant_versions (name, version, config, changelog, created_by)
+		SELECT
+			a.name,
+			COALESCE((SELECT MAX(v.version) FROM st_schema.assistant_versions v WHERE v.name = a.name), 0) + 1,
+			a.config, $2, $3
+		FROM st_schema.assistants a
+		WHERE a.name = $1
+		RETURNING version
+	`, name, changelog, userID).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, ErrAssistantNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`UPDATE st_schema.assistants SET version = $1 WHERE name = $2`, version, name)
+	return version, err
+}
+
+// EnsureAssistantVersion returns the active version of the assistant, assistants created before
+// versioning get their current config as version 1
+func EnsureAssistantVersion(name string) (int, error) {
+	var version sql.NullInt64
+	err := tenantManagement.DB.QueryRow(`SELECT version FROM st_schema.assistants WHERE name = $1`, name).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, ErrAssistantNotFound
+	}
+	if err != nil || version.Valid {
+		return int(version.Int64), err
+	}
+
+	tx, err := tenantManagement.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Another request may have created it before the row was locked
+	version, err = lockAssistant(tx, name)
+	if err != nil || version.Valid {
+		return int(version.Int64), err
+	}
+
+	created, err := CreateAssistantVersion(tx, name, nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	return created, tx.Commit()
+}
+
+// lockAssistant locks the assistant row until the end of the transaction and returns its active version
+func lockAssistant(tx *sql.Tx, name string) (sql.NullInt64, error) {
+	var version sql.NullInt64
+	err := tx.QueryRow(`SELECT version FROM st_schema.assistants WHERE name = $1 FOR UPDATE`, name).Scan(&version)
+	if err == sql.ErrNoRows {
+		return version, ErrAssistantNotFound
+	}
+	return version, err
+}
+
+const assistantVersionSelect = `
+	SELECT v.id, v.name, v.version, v.config, v.changelog, v.created_by, v.created_at, v.version = a.version
+	FROM st_schema.assistant_versions v
+	INNER JOIN st_schema.assistants a ON a.name = v.name
+`
+
+func scanAssistantVersion(scanner interface{ Scan(...any) error }) (*models.AssistantVersion, error) {
+	var version models.AssistantVersion
+	var active sql.NullBool
+	if err := scanner.Scan(
+		&version.ID, &version.Name, &version.Version, &version.Config,
+		&version.Changelog, &version.CreatedBy, &version.CreatedAt, &active,
+	); err != nil {
+		return nil, err
+	}
+	version.Active = active.Bool
+	return &version, nil
+}
+
+// GetAssistantVersion returns one version of an assistant
+func GetAssistantVersion(name string, version int) (*models.AssistantVersion, error) {
+	assistantVersion, err := scanAssistantVersion(tenantManagement.DB.QueryRow(
+		assistantVersionSelect+` WHERE v.name = $1 AND v.version = $2`, name, version,
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrVersionNotFound
+	}
+	return assistantVersion, err
+}
+
+// GetAssistantConfig returns the config of an active assistant. Within a conversation the version
+// the conversation is pinned to is used, the first use pins the active version.
+func GetAssistantConfig(tenantID string, conversationID *string, name string) (json.RawMessage, error) {
+	var config json.RawMessage
+	err := tenantManagement.DB.QueryRow(`
+		SELECT config FROM st_schema.assistants WHERE name = $1 AND is_active = true
+	`, name).Scan(&config)
+	if err == sql.ErrNoRows {
+		return nil, ErrAssistantNotFound
+	}
+	if err != nil || conversationID == nil {
+		return config, err
+	}
+
+	active, err := EnsureAssistantVersion(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keeps a pin set by a concurrent prompt
+	var pinned int
+	err = tenantManagement.DB.QueryRow(`
+		UPDATE st_schema.conversation
+		SET assistant_versions = jsonb_build_object($1::text, $2::int) || COALESCE(assistant_versions, '{}'::jsonb)
+		WHERE id = $3 AND tenant_id = $4
+		RETURNING (assistant_versions ->> $1::text)::int
+	`, name, active, *conversationID, tenantID).Scan(&pinned)
+	if err == sql.ErrNoRows {
+		// Not a conversation of the tenant, the prompt is answered with the active version
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if pinned == active {
+		return config, nil
+	}
+
+	version, err := GetAssistantVersion(name, pinned)
+	if err != nil {
+		return nil, err
+	}
+	return version.Config, nil
+}
+
+// GetAssistantVersionsHandler lists the versions of an assistant, newest first
+func GetAssistantVersionsHandler(c *gin.Context) {
+	name := c.Query("name")
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Assistant name is required"})
+		return
+	}
+
+	if _, err := EnsureAssistantVersion(name); errors.Is(err, ErrAssistantNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Assistant not found"})
+		return
+	} else if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	rows, err := tenantManagement.DB.Query(assistantVersionSelect+` WHERE v.name = $1 ORDER BY v.version DESC`, name)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+	defer rows.Close()
+
+	versions := []*models.AssistantVersion{}
+	for rows.Next() {
+		version, err := scanAssistantVersion(rows)
+		if err != nil {
+			log.Printf(models.DatabaseError, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+			return
+		}
+		versions = append(versions, version)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    versions,
+		"message": "Assistant versions retrieved successfully!",
+	})
+}
+
+// DiffAssistantVersionsHandler compares two versions of an assistant, "to" defaults to the active one
+func DiffAssistantVersionsHandler(c *gin.Context) {
+	name := c.Query("name")
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Assistant name is required"})
+		return
+	}
+	from, ok := parseVersion(c, "from")
+	if !ok {
+		return
+	}
+
+	to, err := EnsureAssistantVersion(name)
+	if errors.Is(err, ErrAssistantNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Assistant not found"})
+		return
+	}
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+	if c.Query("to") != "" {
+		if to, ok = parseVersion(c, "to"); !ok {
+			return
+		}
+	}
+
+	versions := make([]*models.AssistantVersion, 2)
+	for i, version := range []int{from, to} {
+		versions[i], err = GetAssistantVersion(name, version)
+		if errors.Is(err, ErrVersionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Assistant version %d not found", version)})
+			return
+		}
+		if err != nil {
+			log.Printf(models.DatabaseError, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+			return
+		}
+	}
+
+	changes, err := utilities.DiffConfigs(versions[0].Config, versions[1].Config)
+	if err != nil {
+		log.Printf("Failed to diff assistant versions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"from":    versions[0],
+			"to":      versions[1],
+			"changes": changes,
+		},
+		"message": "Assistant versions compared successfully!",
+	})
+}
+
+// RollbackAssistantHandler activates the config of an earlier version as a new version
+func RollbackAssistantHandler(c *gin.Context) {
+	userID, _, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	name := c.Query("name")
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Assistant name is required"})
+		return
+	}
+	version, ok := parseVersion(c, "version")
+	if !ok {
+		return
+	}
+
+	var body models.VersionRollbackRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+	}
+	if body.Changelog == "" {
+		body.Changelog = fmt.Sprintf("Rollback to version %d", version)
+	}
+
+	target, err := GetAssistantVersion(name, version)
+	if errors.Is(err, ErrVersionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Assistant version not found"})
+		return
+	}
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	tx, err := tenantManagement.DB.Begin()
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE st_schema.assistants SET config = $1 WHERE name = $2`, []byte(target.Config), name); err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	newVersion, err := CreateAssistantVersion(tx, name, &userID, &body.Changelog)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	current, err := GetAssistantVersion(name, newVersion)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    current,
+		"message": "Assistant rolled back successfully!",
+	})
+}
@@ -0,0 +1,276 @@
+package aiVersions
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"sententiawebapi/handlers/apis/tenantManagement"
+	"sententiawebapi/handlers/models"
+	"sententiawebapi/utilities"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ErrConversationNotFound = errors.New("conversation not found")
+
+// isConversationManager reports whether the user owns the conversation or its project
+func isConversationManager(tenantID string, userID string, conversationID string) (bool, error) {
+	var manager bool
+	err := tenantManagement.DB.QueryRow(`
+		SELECT c.user_id = $3 OR EXISTS (
+			SELECT 1 FROM st_schema.projects p
+			WHERE p.id = c.project_id AND p.tenant_id = c.tenant_id AND p.user_id = $3
+		)
+		FROM st_schema.conversation c
+		WHERE c.id = $1 AND c.tenant_id = $2
+	`, conversationID, tenantID, userID).Scan(&manager)
+	if err == sql.ErrNoRows {
+		return false, ErrConversationNotFound
+	}
+	return manager, err
+}
+
+// GetConversationTemplateConfig returns the template configuration of the version the conversation is pinned to.
+// Conversations started before versioning are pinned to version 1 when it is backfilled (BackfillTemplateVersion).
+func GetConversationTemplateConfig(tenantID string, conversationID string) (*models.AiConfiguration, error) {
+	var templateID sql.NullString
+	var pinned sql.NullInt64
+	err := tenantManagement.DB.QueryRow(`
+		SELECT conversation_config_template_id, template_version
+		FROM st_schema.conversation
+		WHERE id = $1 AND tenant_id = $2
+	`, conversationID, tenantID).Scan(&templateID, &pinned)
+	if err == sql.ErrNoRows {
+		return nil, ErrConversationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !templateID.Valid {
+		return nil, ErrTemplateNotFound
+	}
+
+	if !pinned.Valid {
+		latest, err := EnsureTemplateVersion(tenantID, templateID.String)
+		if err != nil {
+			return nil, err
+		}
+
+		// Keeps a pin set by a concurrent prompt
+		err = tenantManagement.DB.QueryRow(`
+			UPDATE st_schema.conversation
+			SET template_version = COALESCE(template_version, $1)
+			WHERE id = $2 AND tenant_id = $3
+			RETURNING template_version
+		`, latest, conversationID, tenantID).Scan(&pinned)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	version, err := GetTemplateVersion(tenantID, templateID.String, int(pinned.Int64))
+	if err != nil {
+		return nil, err
+	}
+	if version.Configuration == nil {
+		return nil, fmt.Errorf("template version %d has no configuration", version.Version)
+	}
+
+	return version.Configuration, nil
+}
+
+func getConversationVersionPins(tenantID string, conversationID string) (*models.ConversationVersionPins, error) {
+	pins := models.ConversationVersionPins{ConversationID: conversationID}
+	var rawAssistantVersions []byte
+	err := tenantManagement.DB.QueryRow(`
+		SELECT
+			c.conversation_config_template_id,
+			c.template_version,
+			(SELECT MAX(v.version) FROM st_schema.prompt_config_template_versions v WHERE v.template_id = c.conversation_config_template_id),
+			c.assistant_versions
+		FROM st_schema.conversation c
+		WHERE c.id = $1 AND c.tenant_id = $2
+	`, conversationID, tenantID).Scan(&pins.TemplateID, &pins.TemplateVersion, &pins.LatestTemplateVersion, &rawAssistantVersions)
+	if err == sql.ErrNoRows {
+		return nil, ErrConversationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pins.AssistantVersions = map[string]int{}
+	if len(rawAssistantVersions) > 0 {
+		if err := json.Unmarshal(rawAssistantVersions, &pins.AssistantVersions); err != nil {
+			return nil, err
+		}
+	}
+
+	return &pins, nil
+}
+
+// GetConversationVersionsHandler returns the versions a conversation is pinned to next to the latest template version
+func GetConversationVersionsHandler(c *gin.Context) {
+	_, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	conversationID := c.Query("conversation_id")
+	if conversationID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Conversation ID is required"})
+		return
+	}
+
+	pins, err := getConversationVersionPins(tenantID, conversationID)
+	if errors.Is(err, ErrConversationNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+		return
+	}
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    pins,
+		"message": "Conversation versions retrieved successfully!",
+	})
+}
+
+// PinConversationVersionsHandler moves a conversation to other template or assistant versions.
+// Upgrade pins the latest template version and the active assistant versions.
+// Only the owner of the conversation or of its project and tenant admins can change the pins.
+func PinConversationVersionsHandler(c *gin.Context) {
+	userID, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	conversationID := c.Query("conversation_id")
+	if conversationID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Conversation ID is required"})
+		return
+	}
+
+	manager, err := isConversationManager(tenantID, userID, conversationID)
+	if errors.Is(err, ErrConversationNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+		return
+	}
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+	if !manager && !utilities.IsTenantAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the owner of the conversation or of its project can change its versions"})
+		return
+	}
+
+	var body models.ConversationVersionPinRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	pins, err := getConversationVersionPins(tenantID, conversationID)
+	if errors.Is(err, ErrConversationNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+		return
+	}
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	templateVersion := body.TemplateVersion
+	assistantVersions := pins.AssistantVersions
+	for name, version := range body.AssistantVersions {
+		assistantVersions[name] = version
+	}
+
+	if body.Upgrade {
+		if pins.TemplateID != nil {
+			latest, err := EnsureTemplateVersion(tenantID, *pins.TemplateID)
+			if err != nil {
+				log.Printf(models.DatabaseError, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+				return
+			}
+			templateVersion = &latest
+		}
+		for name := range assistantVersions {
+			active, err := EnsureAssistantVersion(name)
+			if errors.Is(err, ErrAssistantNotFound) {
+				delete(assistantVersions, name)
+				continue
+			}
+			if err != nil {
+				log.Printf(models.DatabaseError, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+				return
+			}
+			assistantVersions[name] = active
+		}
+	}
+
+	if templateVersion != nil {
+		if pins.TemplateID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Conversation doesn't use a template"})
+			return
+		}
+		if _, err := GetTemplateVersion(tenantID, *pins.TemplateID, *templateVersion); errors.Is(err, ErrVersionNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Template version %d not found", *templateVersion)})
+			return
+		} else if err != nil {
+			log.Printf(models.DatabaseError, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+			return
+		}
+	}
+	for name, version := range body.AssistantVersions {
+		if _, err := GetAssistantVersion(name, version); errors.Is(err, ErrVersionNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Version %d of assistant %s not found", version, name)})
+			return
+		} else if err != nil {
+			log.Printf(models.DatabaseError, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+			return
+		}
+	}
+
+	rawAssistantVersions, err := json.Marshal(assistantVersions)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	_, err = tenantManagement.DB.Exec(`
+		UPDATE st_schema.conversation
+		SET template_version = COALESCE($1, template_version), assistant_versions = $2, updated_at = NOW()
+		WHERE id = $3 AND tenant_id = $4
+	`, templateVersion, rawAssistantVersions, conversationID, tenantID)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	pins, err = getConversationVersionPins(tenantID, conversationID)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    pins,
+		"message": "Conversation versions updated successfully!",
+	})
+}
@@ -0,0 +1,434 @@
+package aiVersions
+
+// Tenant AI templates keep every saved configuration as an immutable version in
+// st_schema.prompt_config_template_versions. prompt_config_template holds the latest one.
+// Conversations are pinned to the version they started with (see conversations.go),
+// editing a template never changes the answers of existing conversations.
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"sententiawebapi/handlers/apis/tenantManagement"
+	"sententiawebapi/handlers/models"
+	"sententiawebapi/utilities"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrTemplateNotFound = errors.New("template not found")
+	ErrVersionNotFound  = errors.New("version not found")
+)
+
+// queryer is implemented by *sql.DB and *sql.Tx
+type queryer interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// lockTemplate locks the template row until the end of the transaction. The versions are read
+// by the following statements, a statement waiting for the lock would still see the versions of
+// its own snapshot.
+func lockTemplate(tx *sql.Tx, tenantID string, templateID string) error {
+	var id string
+	err := tx.QueryRow(`
+		SELECT id FROM st_schema.prompt_config_template WHERE id = $1 AND tenant_id = $2 FOR UPDATE
+	`, templateID, tenantID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return ErrTemplateNotFound
+	}
+	return err
+}
+
+// CreateTemplateVersion snapshots the current configuration of the template as its next version.
+// The template row is locked, concurrent saves get consecutive versions.
+func CreateTemplateVersion(tx *sql.Tx, tenantID string, templateID string, userID *string, changelog *string) (int, error) {
+	if err := lockTemplate(tx, tenantID, templateID); err != nil {
+		return 0, err
+	}
+
+	var version int
+	err := tx.QueryRow(`
+		INSERT INTO st_schema.prompt_config_template_versions (
+			template_id, tenant_id, version, ai_model, configuration, changelog, created_by
+		)
+		SELECT
+			t.id, t.tenant_id,
+			COALESCE((SELECT MAX(v.version) FROM st_schema.prompt_config_template_versions v WHERE v.template_id = t.id), 0) + 1,
+			t.ai_model, t.configuration, $3, $4
+		FROM st_schema.prompt_config_template t
+		WHERE t.id = $1 AND t.tenant_id = $2
+		RETURNING version
+	`, templateID, tenantID, changelog, userID).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, ErrTemplateNotFound
+	}
+
+	return version, err
+}
+
+// BackfillTemplateVersion returns the latest version of the template within the transaction.
+// Templates saved before versioning (or cloned from the community) get their current
+// configuration as version 1, call it before the configuration is changed. The conversations
+// of the template that aren't pinned yet are pinned to version 1, the following change of the
+// configuration doesn't reach them.
+func BackfillTemplateVersion(tx *sql.Tx, tenantID string, templateID string) (int, error) {
+	if err := lockTemplate(tx, tenantID, templateID); err != nil {
+		return 0, err
+	}
+
+	latest, err := latestTemplateVersion(tx, templateID)
+	if err != nil || latest != nil {
+		return derefVersion(latest), err
+	}
+
+	version, err := CreateTemplateVersion(tx, tenantID, templateID, nil, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE st_schema.conversation
+		SET template_version = $1
+		WHERE conversation_config_template_id = $2 AND tenant_id = $3 AND template_version IS NULL
+	`, version, templateID, tenantID)
+	if err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
+
+// EnsureTemplateVersion returns the latest version of the template, see BackfillTemplateVersion
+func EnsureTemplateVersion(tenantID string, templateID string) (int, error) {
+	latest, err := latestTemplateVersion(tenantManagement.DB, templateID)
+	if err != nil || latest != nil {
+		return derefVersion(latest), err
+	}
+
+	tx, err := tenantManagement.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	version, err := BackfillTemplateVersion(tx, tenantID, templateID)
+	if err != nil {
+		return 0, err
+	}
+
+	return version, tx.Commit()
+}
+
+func latestTemplateVersion(db queryer, templateID string) (*int, error) {
+	var latest sql.NullInt64
+	err := db.QueryRow(`
+		SELECT MAX(version) FROM st_schema.prompt_config_template_versions WHERE template_id = $1
+	`, templateID).Scan(&latest)
+	if err != nil || !latest.Valid {
+		return nil, err
+	}
+
+	version := int(latest.Int64)
+	return &version, nil
+}
+
+func derefVersion(version *int) int {
+	if version == nil {
+		return 0
+	}
+	return *version
+}
+
+const templateVersionSelect = `
+	SELECT id, template_id, tenant_id, version, ai_model, configuration, changelog, created_by, created_at
+	FROM st_schema.prompt_config_template_versions
+`
+
+func scanTemplateVersion(scanner interface{ Scan(...any) error }) (*models.AiTemplateVersion, error) {
+	var version models.AiTemplateVersion
+	var rawConfig []byte
+	if err := scanner.Scan(
+		&version.ID, &version.TemplateID, &version.TenantID, &version.Version, &version.AiModel,
+		&rawConfig, &version.Changelog, &version.CreatedBy, &version.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if len(rawConfig) > 0 {
+		if err := json.Unmarshal(rawConfig, &version.Configuration); err != nil {
+			return nil, err
+		}
+	}
+	return &version, nil
+}
+
+// GetTemplateVersion returns one version of a template of the tenant
+func GetTemplateVersion(tenantID string, templateID string, version int) (*models.AiTemplateVersion, error) {
+	templateVersion, err := scanTemplateVersion(tenantManagement.DB.QueryRow(
+		templateVersionSelect+` WHERE template_id = $1 AND tenant_id = $2 AND version = $3`,
+		templateID, tenantID, version,
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrVersionNotFound
+	}
+	return templateVersion, err
+}
+
+// parseVersion reads a version query parameter, the response is written when it is invalid
+func parseVersion(c *gin.Context, name string) (int, bool) {
+	version, err := strconv.Atoi(c.Query(name))
+	if err != nil || version < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid %s, expected a version number", name)})
+		return 0, false
+	}
+	return version, true
+}
+
+// GetTemplateVersionsHandler lists the versions of a template, newest first
+func GetTemplateVersionsHandler(c *gin.Context) {
+	_, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	templateID := c.Query("id")
+	if templateID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Template ID is required"})
+		return
+	}
+
+	if _, err := EnsureTemplateVersion(tenantID, templateID); errors.Is(err, ErrTemplateNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	} else if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	rows, err := tenantManagement.DB.Query(
+		templateVersionSelect+` WHERE template_id = $1 AND tenant_id = $2 ORDER BY version DESC`,
+		templateID, tenantID,
+	)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+	defer rows.Close()
+
+	versions := []*models.AiTemplateVersion{}
+	for rows.Next() {
+		version, err := scanTemplateVersion(rows)
+		if err != nil {
+			log.Printf(models.DatabaseError, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+			return
+		}
+		versions = append(versions, version)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    versions,
+		"message": "Template versions retrieved successfully!",
+	})
+}
+
+func GetTemplateVersionHandler(c *gin.Context) {
+	_, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	templateID := c.Query("id")
+	if templateID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Template ID is required"})
+		return
+	}
+	version, ok := parseVersion(c, "version")
+	if !ok {
+		return
+	}
+
+	templateVersion, err := GetTemplateVersion(tenantID, templateID, version)
+	if errors.Is(err, ErrVersionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template version not found"})
+		return
+	}
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    templateVersion,
+		"message": "Template version retrieved successfully!",
+	})
+}
+
+// DiffTemplateVersionsHandler compares two versions of a template, "to" defaults to the latest one
+func DiffTemplateVersionsHandler(c *gin.Context) {
+	_, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	templateID := c.Query("id")
+	if templateID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Template ID is required"})
+		return
+	}
+	from, ok := parseVersion(c, "from")
+	if !ok {
+		return
+	}
+
+	to, err := EnsureTemplateVersion(tenantID, templateID)
+	if errors.Is(err, ErrTemplateNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+	if c.Query("to") != "" {
+		if to, ok = parseVersion(c, "to"); !ok {
+			return
+		}
+	}
+
+	versions := make([]*models.AiTemplateVersion, 2)
+	for i, version := range []int{from, to} {
+		versions[i], err = GetTemplateVersion(tenantID, templateID, version)
+		if errors.Is(err, ErrVersionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Template version %d not found", version)})
+			return
+		}
+		if err != nil {
+			log.Printf(models.DatabaseError, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+			return
+		}
+	}
+
+	// The model is part of the version next to the configuration
+	snapshot := func(version *models.AiTemplateVersion) []byte {
+		data, _ := json.Marshal(gin.H{"ai_model": version.AiModel, "configuration": version.Configuration})
+		return data
+	}
+
+	changes, err := utilities.DiffConfigs(snapshot(versions[0]), snapshot(versions[1]))
+	if err != nil {
+		log.Printf("Failed to diff template versions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"from":    versions[0],
+			"to":      versions[1],
+			"changes": changes,
+		},
+		"message": "Template versions compared successfully!",
+	})
+}
+
+// RollbackTemplateHandler makes an earlier version the current configuration again.
+// The rollback is a new version, the history is never rewritten.
+func RollbackTemplateHandler(c *gin.Context) {
+	userID, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	templateID := c.Query("id")
+	if templateID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Template ID is required"})
+		return
+	}
+	version, ok := parseVersion(c, "version")
+	if !ok {
+		return
+	}
+
+	var body models.VersionRollbackRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+	}
+	if body.Changelog == "" {
+		body.Changelog = fmt.Sprintf("Rollback to version %d", version)
+	}
+
+	target, err := GetTemplateVersion(tenantID, templateID, version)
+	if errors.Is(err, ErrVersionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template version not found"})
+		return
+	}
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	configJSON, err := json.Marshal(target.Configuration)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	tx, err := tenantManagement.DB.Begin()
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE st_schema.prompt_config_template
+		SET ai_model = $1, configuration = $2, updated_at = NOW()
+		WHERE id = $3 AND tenant_id = $4
+	`, target.AiModel, configJSON, templateID, tenantID)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	newVersion, err := CreateTemplateVersion(tx, tenantID, templateID, &userID, &body.Changelog)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	current, err := GetTemplateVersion(tenantID, templateID, newVersion)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    current,
+		"message": "Template rolled back successfully!",
+	})
+}
@@ -101,7 +101,7 @@ func GetAzureResourcesByTag(c *gin.Context) {
 	}
 
 	// Create Key Vault client
-	kvClient, err := azsecrets.NewClient(os.Getenv("AZURE_KEY_VAULT_URL"), azCred, nil)
+	kvClient, err := azsecrets.NewClient(os.Getenv("AZURE_KEY_VAULT_URL"), azCred, utilities.KeyVaultClientOptions())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to create Key Vault client: %v", err)})
 		return
@@ -135,7 +135,7 @@ func GetAzureResourcesByTag(c *gin.Context) {
 	}
 
 	// Create client with retrieved credentials
-	client, err := armresources.NewClient(creds.SubscriptionID, clientCred, nil)
+	client, err := armresources.NewClient(creds.SubscriptionID, clientCred, utilities.ArmClientOptions())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to create client: %v", err)})
 		return
@@ -80,7 +80,7 @@ func CreateTenantCredential(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Key Vault URL is not configured"})
 		return
 	}
-	client, err := azsecrets.NewClient(keyVaultURL, cred, nil)
+	client, err := azsecrets.NewClient(keyVaultURL, cred, utilities.KeyVaultClientOptions())
 	if err != nil {
 		fmt.Println("Failed to create Key Vault client:", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to create Key Vault client: %v", err)})
@@ -361,7 +361,7 @@ func CreateTenantCredential2(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create credential"})
 		return
 	}
-	client, err := azsecrets.NewClient(keyVaultURL, cred, nil)
+	client, err := azsecrets.NewClient(keyVaultURL, cred, utilities.KeyVaultClientOptions())
 	if err != nil {
 		fmt.Println("Failed to create Key Vault client:", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create credential"})
@@ -593,7 +593,7 @@ func DeleteTenantCredential(c *gin.Context) {
 	}
 
 	// Create Key Vault client
-	client, err := azsecrets.NewClient(os.Getenv("AZURE_KEY_VAULT_URL"), cred, nil)
+	client, err := azsecrets.NewClient(os.Getenv("AZURE_KEY_VAULT_URL"), cred, utilities.KeyVaultClientOptions())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to create Key Vault client: %v", err)})
 		return
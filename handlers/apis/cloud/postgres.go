@@ -8,6 +8,7 @@ import (
 	"os"
 	"sententiawebapi/handlers/apis/tenantManagement"
 	"sententiawebapi/handlers/models"
+	"sententiawebapi/utilities"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
 )
@@ -45,7 +46,7 @@ func GetPostgresCredentials(tenantID, credentialID string) (*models.PostgresCred
 
 	// Create Key Vault client
 	keyVaultURL := os.Getenv("AZURE_KEY_VAULT_URL")
-	kvClient, err := azsecrets.NewClient(keyVaultURL, azCred, nil)
+	kvClient, err := azsecrets.NewClient(keyVaultURL, azCred, utilities.KeyVaultClientOptions())
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Key Vault client: %w", err)
 	}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"strings"
 
+	aiIndexing "sententiawebapi/handlers/apis/ai/indexing"
 	"sententiawebapi/handlers/apis/tenantManagement"
 	"sententiawebapi/handlers/models"
 	"sententiawebapi/utilities"
@@ -79,6 +80,14 @@ func NewPublicDiagramTemplate(c *gin.Context) {
 		return
 	}
 
+	// Keep diagram_search in sync
+	aiIndexing.IndexDiagram(aiIndexing.DiagramRef{
+		Type:      models.ResourceGroupCommunity,
+		TenantID:  tenantID,
+		UserID:    userID,
+		DiagramID: *diagram.ID,
+	}, diagram.Title, diagram.Design)
+
 	// Return the new diagram data
 	c.JSON(200, gin.H{
 		"data":    diagram,
@@ -363,6 +372,13 @@ func UpdatePublicDiagramTemplate(c *gin.Context) {
 		return
 	}
 
+	aiIndexing.IndexDiagram(aiIndexing.DiagramRef{
+		Type:      models.ResourceGroupCommunity,
+		TenantID:  tenantID,
+		UserID:    userID,
+		DiagramID: diagramTemplateID,
+	}, updatedTemplate.Title, updatedTemplate.Design)
+
 	// Return the updated diagram template data
 	c.JSON(http.StatusOK, gin.H{
 		"data":    updatedTemplate,
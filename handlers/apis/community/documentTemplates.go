@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"strings"
 
+	aiIndexing "sententiawebapi/handlers/apis/ai/indexing"
 	"sententiawebapi/handlers/apis/tenantManagement"
 	"sententiawebapi/handlers/models"
 	"sententiawebapi/utilities"
@@ -76,6 +77,15 @@ func NewPublicTemplateDocument(c *gin.Context) {
 		return
 	}
 
+	// Keep document_search in sync
+	aiIndexing.IndexDocument(aiIndexing.DocumentRef{
+		Type:       models.ResourceGroupCommunity,
+		TenantID:   tenantID,
+		UserID:     userID,
+		GroupID:    communityProjectTemplateID,
+		DocumentID: *Document.ID,
+	}, Document.Content)
+
 	// Return the new document data
 	c.JSON(200, gin.H{
 		"data":    Document,
@@ -216,7 +226,7 @@ func GetPublicTemplateDocuments(c *gin.Context) {
 
 // This function updates an existing community document template
 func UpdatePublicTemplateDocument(c *gin.Context) {
-	_, tenantID, ok := utilities.ProcessIdentity(c)
+	userID, tenantID, ok := utilities.ProcessIdentity(c)
 	if !ok {
 		return
 	}
@@ -323,6 +333,14 @@ func UpdatePublicTemplateDocument(c *gin.Context) {
 		return
 	}
 
+	aiIndexing.IndexDocument(aiIndexing.DocumentRef{
+		Type:       models.ResourceGroupCommunity,
+		TenantID:   tenantID,
+		UserID:     userID,
+		GroupID:    communityProjectTemplateID,
+		DocumentID: documentTemplateID,
+	}, updatedTemplate.Content)
+
 	// Return the updated document template data
 	c.JSON(http.StatusOK, gin.H{
 		"data":    updatedTemplate,
@@ -397,6 +415,13 @@ func DeletePublicTemplateDocument(c *gin.Context) {
 		return
 	}
 
+	aiIndexing.RemoveDocument(aiIndexing.DocumentRef{
+		Type:       models.ResourceGroupCommunity,
+		TenantID:   tenantID,
+		GroupID:    communityProjectTemplateID,
+		DocumentID: documentTemplateID,
+	})
+
 	// Return a success response
 	c.JSON(http.StatusOK, gin.H{
 		"data":    document,
@@ -0,0 +1,792 @@
+package decisions
+
+// All decision types share one lifecycle: draft → proposed → in review → accepted/rejected → superseded.
+// The state is kept in st_schema.decision_lifecycles and mirrored to the status of the analysis, a decision
+// without a lifecycle row is a draft. Accepted and rejected are only reached through the reviews of the
+// assigned reviewers, every transition and review is recorded for the decision record. The author and the
+// proposer don't review their own decision. Only drafts that were never proposed can be deleted, a decision
+// that was proposed once stays part of the record, also after it went back to draft.
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+
+	"sententiawebapi/handlers/apis/tenantManagement"
+	models "sententiawebapi/handlers/models"
+	"sententiawebapi/utilities"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+var errDecisionNotFound = errors.New("decision not found")
+
+type decisionTable struct {
+	table        string
+	statusColumn string
+}
+
+var decisionTables = map[models.DecisionType]decisionTable{
+	models.DecisionTypeTChart: {table: "st_schema.tbar_analysis", statusColumn: "tbar_status"},
+	models.DecisionTypePnc:    {table: "st_schema.pnc_analysis", statusColumn: "pnc_status"},
+	models.DecisionTypeSwot:   {table: "st_schema.swot_analysis", statusColumn: "swot_status"},
+	models.DecisionTypeMatrix: {table: "st_schema.matrix_analysis", statusColumn: "matrix_status"},
+}
+
+// decisionTransitions are the transitions users can make, accepted and rejected are reached by the reviews
+var decisionTransitions = map[models.DecisionState][]models.DecisionState{
+	models.DecisionStateDraft:    {models.DecisionStateProposed},
+	models.DecisionStateProposed: {models.DecisionStateDraft, models.DecisionStateInReview},
+	models.DecisionStateInReview: {models.DecisionStateDraft},
+	models.DecisionStateRejected: {models.DecisionStateDraft},
+	models.DecisionStateAccepted: {models.DecisionStateSuperseded},
+}
+
+// Reviewers can only be changed before the review, the approvals needed can't change during one
+var reviewerStates = []models.DecisionState{models.DecisionStateDraft, models.DecisionStateProposed, models.DecisionStateRejected}
+
+type decisionLifecycleRow struct {
+	state             models.DecisionState
+	requiredApprovals int
+	reviewRound       int
+}
+
+// legacyDecisionTypes are the names clients may still use for a decision type
+var legacyDecisionTypes = map[models.DecisionType]models.DecisionType{
+	"tbar": models.DecisionTypeTChart, // The name of the /api/tbar endpoints
+}
+
+// normalizeDecisionType maps the legacy names to the decision type
+func normalizeDecisionType(decisionType models.DecisionType) models.DecisionType {
+	if normalized, ok := legacyDecisionTypes[decisionType]; ok {
+		return normalized
+	}
+	return decisionType
+}
+
+// parseDecision reads the decision_type and decision_id query params
+func parseDecision(c *gin.Context) (models.DecisionType, string, bool) {
+	decisionType := normalizeDecisionType(models.DecisionType(c.Query("decision_type")))
+	if _, ok := decisionTables[decisionType]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "decision_type must be tchart, pnc, swot or matrix"})
+		return "", "", false
+	}
+
+	decisionID, ok := utilities.ValidateQueryParam(c, "decision_id")
+	if !ok {
+		return "", "", false
+	}
+
+	return decisionType, decisionID, true
+}
+
+func decisionExists(db dbExecutor, decisionType models.DecisionType, tenantID string, decisionID string) (bool, error) {
+	var exists bool
+	err := db.QueryRow(fmt.Sprintf(`
+		SELECT EXISTS (SELECT 1 FROM %s WHERE id = $1 AND tenant_id = $2)
+	`, decisionTables[decisionType].table), decisionID, tenantID).Scan(&exists)
+	return exists, err
+}
+
+// lockDecisionLifecycle creates the lifecycle of a decision on first use and locks it for the transaction
+func lockDecisionLifecycle(tx *sql.Tx, decisionType models.DecisionType, tenantID string, decisionID string) (*decisionLifecycleRow, error) {
+	_, err := tx.Exec(fmt.Sprintf(`
+		INSERT INTO st_schema.decision_lifecycles (decision_type, decision_id, tenant_id, state, required_approvals, review_round)
+		SELECT $1, id, tenant_id, $4, 1, 0 FROM %s WHERE id = $2 AND tenant_id = $3
+		ON CONFLICT (decision_type, decision_id) DO NOTHING
+	`, decisionTables[decisionType].table), decisionType, decisionID, tenantID, models.DecisionStateDraft)
+	if err != nil {
+		return nil, err
+	}
+
+	var lifecycle decisionLifecycleRow
+	err = tx.QueryRow(`
+		SELECT state, required_approvals, review_round
+		FROM st_schema.decision_lifecycles
+		WHERE decision_type = $1 AND decision_id = $2 AND tenant_id = $3
+		FOR UPDATE
+	`, decisionType, decisionID, tenantID).Scan(&lifecycle.state, &lifecycle.requiredApprovals, &lifecycle.reviewRound)
+	if err == sql.ErrNoRows {
+		return nil, errDecisionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &lifecycle, nil
+}
+
+// transitionDecision moves a locked decision to the next state and records who did it
+func transitionDecision(tx *sql.Tx, decisionType models.DecisionType, tenantID string, decisionID string, userID string, from models.DecisionState, to models.DecisionState, comment *string) error {
+	_, err := tx.Exec(`
+		UPDATE st_schema.decision_lifecycles
+		SET state = $1,
+			review_round = review_round + CASE WHEN $1 = $5 THEN 1 ELSE 0 END,
+			updated_by = $4,
+			updated_at = NOW()
+		WHERE decision_type = $2 AND decision_id = $3 AND tenant_id = $6
+	`, to, decisionType, decisionID, userID, models.DecisionStateInReview, tenantID)
+	if err != nil {
+		return err
+	}
+
+	table := decisionTables[decisionType]
+	_, err = tx.Exec(fmt.Sprintf(`
+		UPDATE %s SET %s = $1 WHERE id = $2 AND tenant_id = $3
+	`, table.table, table.statusColumn), to, decisionID, tenantID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO st_schema.decision_transitions (decision_type, decision_id, tenant_id, from_state, to_state, user_id, comment)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, decisionType, decisionID, tenantID, from, to, userID, comment)
+	return err
+}
+
+// countReviewers counts the reviewers of a decision that may review it, the excluded users don't count
+func countReviewers(tx *sql.Tx, decisionType models.DecisionType, tenantID string, decisionID string, excluded []string) (int, error) {
+	var reviewers int
+	err := tx.QueryRow(`
+		SELECT COUNT(*) FROM st_schema.decision_reviewers
+		WHERE decision_type = $1 AND decision_id = $2 AND tenant_id = $3
+		AND NOT (reviewer_id::text = ANY($4::text[]))
+	`, decisionType, decisionID, tenantID, pq.Array(excluded)).Scan(&reviewers)
+	return reviewers, err
+}
+
+// decisionAuthors returns the author of the decision and the user who proposed it last,
+// neither may review it
+func decisionAuthors(db dbExecutor, decisionType models.DecisionType, tenantID string, decisionID string) ([]string, error) {
+	var author, proposer sql.NullString
+	err := db.QueryRow(fmt.Sprintf(`
+		SELECT d.user_id::text, (
+			SELECT t.user_id::text FROM st_schema.decision_transitions t
+			WHERE t.decision_type = $1 AND t.decision_id = d.id AND t.tenant_id = d.tenant_id AND t.to_state = $4
+			ORDER BY t.created_at DESC
+			LIMIT 1
+		)
+		FROM %s d
+		WHERE d.id = $2 AND d.tenant_id = $3
+	`, decisionTables[decisionType].table), decisionType, decisionID, tenantID, models.DecisionStateProposed).Scan(&author, &proposer)
+	if err == sql.ErrNoRows {
+		return nil, errDecisionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	authors := []string{}
+	for _, user := range []sql.NullString{author, proposer} {
+		if user.Valid && !slices.Contains(authors, user.String) {
+			authors = append(authors, user.String)
+		}
+	}
+	return authors, nil
+}
+
+// decisionDeletable reports whether a decision can still be deleted, only a draft that was never proposed
+func decisionDeletable(state models.DecisionState, proposed bool) bool {
+	return state == models.DecisionStateDraft && !proposed
+}
+
+// checkDecisionDelete rejects deleting a decision that was proposed and removes the lifecycle of a
+// deletable one in the transaction of the delete. Decisions without a lifecycle are drafts.
+func checkDecisionDelete(c *gin.Context, tx *sql.Tx, decisionType models.DecisionType, tenantID string, decisionID string) bool {
+	// Every decision that left draft has a transition, the first one is the proposal
+	// (or the legacy status of a migrated decision)
+	var state models.DecisionState
+	var proposed bool
+	err := tx.QueryRow(`
+		SELECT l.state, EXISTS (
+			SELECT 1 FROM st_schema.decision_transitions t
+			WHERE t.decision_type = l.decision_type AND t.decision_id = l.decision_id
+		)
+		FROM st_schema.decision_lifecycles l
+		WHERE l.decision_type = $1 AND l.decision_id = $2 AND l.tenant_id = $3
+		FOR UPDATE OF l
+	`, decisionType, decisionID, tenantID).Scan(&state, &proposed)
+	if err == sql.ErrNoRows {
+		return true
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to lock the decision lifecycle: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return false
+	}
+	if !decisionDeletable(state, proposed) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The decision was proposed and is part of the decision record, it can't be deleted"})
+		return false
+	}
+
+	// Reviewers, reviews and transitions go with the lifecycle
+	_, err = tx.Exec(`
+		DELETE FROM st_schema.decision_lifecycles
+		WHERE decision_type = $1 AND decision_id = $2 AND tenant_id = $3
+	`, decisionType, decisionID, tenantID)
+	if err != nil {
+		log.Printf("ERROR: Failed to delete the decision lifecycle: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return false
+	}
+
+	return true
+}
+
+// loadDecisionLifecycle returns the lifecycle of an existing decision with its reviewers, reviews and transitions
+func loadDecisionLifecycle(decisionType models.DecisionType, tenantID string, decisionID string) (*models.DecisionLifecycle, error) {
+	lifecycle := models.DecisionLifecycle{
+		DecisionType:      decisionType,
+		DecisionID:        decisionID,
+		State:             models.DecisionStateDraft,
+		RequiredApprovals: 1,
+		Reviewers:         []models.DecisionReviewer{},
+		Reviews:           []models.DecisionReview{},
+		Transitions:       []models.DecisionTransition{},
+	}
+
+	err := tenantManagement.DB.QueryRow(`
+		SELECT state, required_approvals, review_round, superseded_by_type, superseded_by_id
+		FROM st_schema.decision_lifecycles
+		WHERE decision_type = $1 AND decision_id = $2 AND tenant_id = $3
+	`, decisionType, decisionID, tenantID).Scan(
+		&lifecycle.State,
+		&lifecycle.RequiredApprovals,
+		&lifecycle.ReviewRound,
+		&lifecycle.SupersededByType,
+		&lifecycle.SupersededByID,
+	)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	lifecycle.AllowedTransitions = decisionTransitions[lifecycle.State]
+	if lifecycle.AllowedTransitions == nil {
+		lifecycle.AllowedTransitions = []models.DecisionState{}
+	}
+
+	reviewerRows, err := tenantManagement.DB.Query(`
+		SELECT reviewer_id, assigned_by, created_at
+		FROM st_schema.decision_reviewers
+		WHERE decision_type = $1 AND decision_id = $2 AND tenant_id = $3
+		ORDER BY created_at, reviewer_id
+	`, decisionType, decisionID, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer reviewerRows.Close()
+	for reviewerRows.Next() {
+		var reviewer models.DecisionReviewer
+		if err := reviewerRows.Scan(&reviewer.ReviewerID, &reviewer.AssignedBy, &reviewer.CreatedAt); err != nil {
+			return nil, err
+		}
+		lifecycle.Reviewers = append(lifecycle.Reviewers, reviewer)
+	}
+	if err := reviewerRows.Err(); err != nil {
+		return nil, err
+	}
+
+	reviewRows, err := tenantManagement.DB.Query(`
+		SELECT id, reviewer_id, review_round, verdict, comment, created_at
+		FROM st_schema.decision_reviews
+		WHERE decision_type = $1 AND decision_id = $2 AND tenant_id = $3
+		ORDER BY created_at
+	`, decisionType, decisionID, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer reviewRows.Close()
+	for reviewRows.Next() {
+		var review models.DecisionReview
+		if err := reviewRows.Scan(&review.ID, &review.ReviewerID, &review.ReviewRound, &review.Verdict, &review.Comment, &review.CreatedAt); err != nil {
+			return nil, err
+		}
+		if review.ReviewRound == lifecycle.ReviewRound && review.Verdict == models.DecisionVerdictApprove {
+			lifecycle.Approvals++
+		}
+		lifecycle.Reviews = append(lifecycle.Reviews, review)
+	}
+	if err := reviewRows.Err(); err != nil {
+		return nil, err
+	}
+
+	transitionRows, err := tenantManagement.DB.Query(`
+		SELECT id, from_state, to_state, user_id, comment, created_at
+		FROM st_schema.decision_transitions
+		WHERE decision_type = $1 AND decision_id = $2 AND tenant_id = $3
+		ORDER BY created_at
+	`, decisionType, decisionID, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer transitionRows.Close()
+	for transitionRows.Next() {
+		var transition models.DecisionTransition
+		if err := transitionRows.Scan(&transition.ID, &transition.FromState, &transition.ToState, &transition.UserID, &transition.Comment, &transition.CreatedAt); err != nil {
+			return nil, err
+		}
+		lifecycle.Transitions = append(lifecycle.Transitions, transition)
+	}
+
+	return &lifecycle, transitionRows.Err()
+}
+
+// respondDecisionLifecycle sends the lifecycle after a change was committed
+func respondDecisionLifecycle(c *gin.Context, decisionType models.DecisionType, tenantID string, decisionID string, message string) {
+	lifecycle, err := loadDecisionLifecycle(decisionType, tenantID, decisionID)
+	if err != nil {
+		log.Printf("ERROR: Failed to retrieve the decision lifecycle: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    lifecycle,
+		"message": message,
+	})
+}
+
+// decisionUpdate holds the lifecycle controlled fields of an analysis update. Replace is set for
+// updates that overwrite every field, a nil final decision or implications then clears them.
+type decisionUpdate struct {
+	Status        *string
+	FinalDecision *string
+	Implications  *string
+	Replace       bool
+}
+
+func changesValue(value *string, current *string, replace bool) bool {
+	if value == nil {
+		return replace && current != nil
+	}
+	return current == nil || *value != *current
+}
+
+// checkDecisionUpdate rejects updates that change the status outside of the lifecycle or change
+// the final decision or implications of an accepted decision. The lifecycle stays locked by the
+// transaction of the update, a transition can't slip in before it is committed. Unknown decisions
+// are left to the update.
+func checkDecisionUpdate(c *gin.Context, tx *sql.Tx, decisionType models.DecisionType, tenantID string, decisionID string, update decisionUpdate) bool {
+	lifecycle, err := lockDecisionLifecycle(tx, decisionType, tenantID, decisionID)
+	if errors.Is(err, errDecisionNotFound) {
+		return true
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to lock the decision lifecycle: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return false
+	}
+
+	table := decisionTables[decisionType]
+
+	var status, finalDecision, implications *string
+	err = tx.QueryRow(fmt.Sprintf(`
+		SELECT %s, final_decision, implications FROM %s WHERE id = $1 AND tenant_id = $2
+	`, table.statusColumn, table.table), decisionID, tenantID).Scan(&status, &finalDecision, &implications)
+	if err == sql.ErrNoRows {
+		return true
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to retrieve the decision: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return false
+	}
+	state := lifecycle.state
+
+	if changesValue(update.Status, status, false) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The status is changed through the decision lifecycle transitions"})
+		return false
+	}
+	if state == models.DecisionStateAccepted || state == models.DecisionStateSuperseded {
+		if changesValue(update.FinalDecision, finalDecision, update.Replace) || changesValue(update.Implications, implications, update.Replace) {
+			c.JSON(http.StatusConflict, gin.H{"error": "The final decision and implications are locked once the decision is accepted"})
+			return false
+		}
+	}
+
+	return true
+}
+
+// GetDecisionLifecycle returns the state of a decision with its reviewers, reviews and transition history
+func GetDecisionLifecycle(c *gin.Context) {
+	_, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	decisionType, decisionID, ok := parseDecision(c)
+	if !ok {
+		return
+	}
+
+	exists, err := decisionExists(tenantManagement.DB, decisionType, tenantID, decisionID)
+	if err != nil {
+		log.Printf("ERROR: Failed to retrieve the decision: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Decision not found"})
+		return
+	}
+
+	respondDecisionLifecycle(c, decisionType, tenantID, decisionID, "Decision lifecycle retrieved successfully!")
+}
+
+// TransitionDecision moves a decision to another state, only the transitions of the lifecycle are allowed
+func TransitionDecision(c *gin.Context) {
+	userID, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	decisionType, decisionID, ok := parseDecision(c)
+	if !ok {
+		return
+	}
+
+	var req models.DecisionTransitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	tx, err := tenantManagement.DB.Begin()
+	if err != nil {
+		log.Printf("ERROR: Failed to start a transaction: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return
+	}
+	defer tx.Rollback()
+
+	lifecycle, err := lockDecisionLifecycle(tx, decisionType, tenantID, decisionID)
+	if errors.Is(err, errDecisionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Decision not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to lock the decision lifecycle: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return
+	}
+
+	if !slices.Contains(decisionTransitions[lifecycle.state], req.To) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Transition from %s to %s is not allowed", lifecycle.state, req.To)})
+		return
+	}
+
+	switch req.To {
+	case models.DecisionStateInReview:
+		authors, err := decisionAuthors(tx, decisionType, tenantID, decisionID)
+		if err != nil {
+			log.Printf("ERROR: Failed to retrieve the decision authors: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+			return
+		}
+		reviewers, err := countReviewers(tx, decisionType, tenantID, decisionID, authors)
+		if err != nil {
+			log.Printf("ERROR: Failed to count the decision reviewers: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+			return
+		}
+		if reviewers < lifecycle.requiredApprovals {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Assign at least %d reviewers other than the author and proposer before the review", lifecycle.requiredApprovals)})
+			return
+		}
+
+	case models.DecisionStateSuperseded:
+		if (req.SupersededByType == nil) != (req.SupersededByID == nil) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "superseded_by_type and superseded_by_id must be given together"})
+			return
+		}
+		if req.SupersededByType != nil {
+			*req.SupersededByType = normalizeDecisionType(*req.SupersededByType)
+			if _, ok := decisionTables[*req.SupersededByType]; !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "superseded_by_type must be tchart, pnc, swot or matrix"})
+				return
+			}
+			if *req.SupersededByType == decisionType && *req.SupersededByID == decisionID {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "A decision can't supersede itself"})
+				return
+			}
+			exists, err := decisionExists(tx, *req.SupersededByType, tenantID, *req.SupersededByID)
+			if err != nil {
+				log.Printf("ERROR: Failed to retrieve the decision: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+				return
+			}
+			if !exists {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Superseding decision not found"})
+				return
+			}
+		}
+
+		_, err := tx.Exec(`
+			UPDATE st_schema.decision_lifecycles SET superseded_by_type = $1, superseded_by_id = $2
+			WHERE decision_type = $3 AND decision_id = $4 AND tenant_id = $5
+		`, req.SupersededByType, req.SupersededByID, decisionType, decisionID, tenantID)
+		if err != nil {
+			log.Printf("ERROR: Failed to update the decision lifecycle: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+			return
+		}
+	}
+
+	if err := transitionDecision(tx, decisionType, tenantID, decisionID, userID, lifecycle.state, req.To, req.Comment); err != nil {
+		log.Printf("ERROR: Failed to transition the decision: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("ERROR: Failed to commit transaction: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return
+	}
+
+	respondDecisionLifecycle(c, decisionType, tenantID, decisionID, "Decision transitioned successfully!")
+}
+
+// UpdateDecisionReviewers replaces the reviewers of a decision and the approvals needed to accept it
+func UpdateDecisionReviewers(c *gin.Context) {
+	userID, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	decisionType, decisionID, ok := parseDecision(c)
+	if !ok {
+		return
+	}
+
+	var req models.DecisionReviewersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body, required_approvals must be at least 1"})
+		return
+	}
+
+	reviewerIDs := []string{}
+	for _, reviewerID := range req.ReviewerIDs {
+		if reviewerID != "" && !slices.Contains(reviewerIDs, reviewerID) {
+			reviewerIDs = append(reviewerIDs, reviewerID)
+		}
+	}
+	if req.RequiredApprovals > len(reviewerIDs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "required_approvals can't be more than the number of reviewers"})
+		return
+	}
+
+	tx, err := tenantManagement.DB.Begin()
+	if err != nil {
+		log.Printf("ERROR: Failed to start a transaction: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return
+	}
+	defer tx.Rollback()
+
+	lifecycle, err := lockDecisionLifecycle(tx, decisionType, tenantID, decisionID)
+	if errors.Is(err, errDecisionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Decision not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to lock the decision lifecycle: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return
+	}
+	if !slices.Contains(reviewerStates, lifecycle.state) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Reviewers can't be changed while the decision is %s", lifecycle.state)})
+		return
+	}
+
+	authors, err := decisionAuthors(tx, decisionType, tenantID, decisionID)
+	if err != nil {
+		log.Printf("ERROR: Failed to retrieve the decision authors: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return
+	}
+	for _, reviewerID := range reviewerIDs {
+		if slices.Contains(authors, reviewerID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The author or proposer of the decision can't review it"})
+			return
+		}
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM st_schema.decision_reviewers
+		WHERE decision_type = $1 AND decision_id = $2 AND tenant_id = $3
+	`, decisionType, decisionID, tenantID)
+	if err != nil {
+		log.Printf("ERROR: Failed to remove the decision reviewers: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return
+	}
+
+	// Only members of the tenant can review its decisions
+	result, err := tx.Exec(`
+		INSERT INTO st_schema.decision_reviewers (decision_type, decision_id, tenant_id, reviewer_id, assigned_by)
+		SELECT $1, $2, tm.tenant_id, tm.user_id, $4
+		FROM st_schema.tenant_members tm
+		WHERE tm.tenant_id = $3 AND tm.user_id::text = ANY($5::text[])
+	`, decisionType, decisionID, tenantID, userID, pq.Array(reviewerIDs))
+	if err != nil {
+		log.Printf("ERROR: Failed to assign the decision reviewers: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return
+	}
+	if assigned, _ := result.RowsAffected(); int(assigned) != len(reviewerIDs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Every reviewer must be a member of the tenant"})
+		return
+	}
+
+	_, err = tx.Exec(`
+		UPDATE st_schema.decision_lifecycles SET required_approvals = $1, updated_by = $2, updated_at = NOW()
+		WHERE decision_type = $3 AND decision_id = $4 AND tenant_id = $5
+	`, req.RequiredApprovals, userID, decisionType, decisionID, tenantID)
+	if err != nil {
+		log.Printf("ERROR: Failed to update the decision lifecycle: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("ERROR: Failed to commit transaction: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return
+	}
+
+	respondDecisionLifecycle(c, decisionType, tenantID, decisionID, "Decision reviewers updated successfully!")
+}
+
+// ReviewDecision records the verdict of an assigned reviewer on a decision in review. A rejection rejects
+// the decision, the approval reaching the required approvals accepts it.
+func ReviewDecision(c *gin.Context) {
+	userID, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	decisionType, decisionID, ok := parseDecision(c)
+	if !ok {
+		return
+	}
+
+	var req models.DecisionReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if req.Verdict != models.DecisionVerdictApprove && req.Verdict != models.DecisionVerdictReject {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "verdict must be approve or reject"})
+		return
+	}
+	if req.Verdict == models.DecisionVerdictReject && (req.Comment == nil || *req.Comment == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A rejection needs a comment"})
+		return
+	}
+
+	tx, err := tenantManagement.DB.Begin()
+	if err != nil {
+		log.Printf("ERROR: Failed to start a transaction: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return
+	}
+	defer tx.Rollback()
+
+	lifecycle, err := lockDecisionLifecycle(tx, decisionType, tenantID, decisionID)
+	if errors.Is(err, errDecisionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Decision not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to lock the decision lifecycle: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return
+	}
+	if lifecycle.state != models.DecisionStateInReview {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("The decision is %s, only decisions in review can be reviewed", lifecycle.state)})
+		return
+	}
+
+	var isReviewer, reviewed bool
+	err = tx.QueryRow(`
+		SELECT
+			EXISTS (SELECT 1 FROM st_schema.decision_reviewers
+				WHERE decision_type = $1 AND decision_id = $2 AND tenant_id = $3 AND reviewer_id = $4),
+			EXISTS (SELECT 1 FROM st_schema.decision_reviews
+				WHERE decision_type = $1 AND decision_id = $2 AND tenant_id = $3 AND reviewer_id = $4 AND review_round = $5)
+	`, decisionType, decisionID, tenantID, userID, lifecycle.reviewRound).Scan(&isReviewer, &reviewed)
+	if err != nil {
+		log.Printf("ERROR: Failed to retrieve the decision reviewers: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return
+	}
+	if !isReviewer {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only assigned reviewers can review the decision"})
+		return
+	}
+
+	// Reviewers assigned before the decision was proposed by one of them are caught here
+	authors, err := decisionAuthors(tx, decisionType, tenantID, decisionID)
+	if err != nil {
+		log.Printf("ERROR: Failed to retrieve the decision authors: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return
+	}
+	if slices.Contains(authors, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "The author or proposer of the decision can't review it"})
+		return
+	}
+	if reviewed {
+		c.JSON(http.StatusConflict, gin.H{"error": "You already reviewed this round of the decision"})
+		return
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO st_schema.decision_reviews (decision_type, decision_id, tenant_id, reviewer_id, review_round, verdict, comment)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, decisionType, decisionID, tenantID, userID, lifecycle.reviewRound, req.Verdict, req.Comment)
+	if err != nil {
+		log.Printf("ERROR: Failed to record the decision review: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return
+	}
+
+	var approvals int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM st_schema.decision_reviews
+		WHERE decision_type = $1 AND decision_id = $2 AND tenant_id = $3 AND review_round = $4 AND verdict = $5
+	`, decisionType, decisionID, tenantID, lifecycle.reviewRound, models.DecisionVerdictApprove).Scan(&approvals)
+	if err != nil {
+		log.Printf("ERROR: Failed to count the decision approvals: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return
+	}
+
+	next := lifecycle.state
+	switch {
+	case req.Verdict == models.DecisionVerdictReject:
+		next = models.DecisionStateRejected
+	case approvals >= lifecycle.requiredApprovals:
+		next = models.DecisionStateAccepted
+	}
+	if next != lifecycle.state {
+		if err := transitionDecision(tx, decisionType, tenantID, decisionID, userID, lifecycle.state, next, req.Comment); err != nil {
+			log.Printf("ERROR: Failed to transition the decision: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("ERROR: Failed to commit transaction: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return
+	}
+
+	respondDecisionLifecycle(c, decisionType, tenantID, decisionID, "Decision review recorded successfully!")
+}
@@ -0,0 +1,104 @@
+package decisions
+
+import (
+	"slices"
+	"testing"
+
+	models "sententiawebapi/handlers/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecisionTransitions(t *testing.T) {
+	tests := []struct {
+		from    models.DecisionState
+		to      models.DecisionState
+		allowed bool
+	}{
+		{models.DecisionStateDraft, models.DecisionStateProposed, true},
+		{models.DecisionStateProposed, models.DecisionStateDraft, true},
+		{models.DecisionStateProposed, models.DecisionStateInReview, true},
+		{models.DecisionStateInReview, models.DecisionStateDraft, true},
+		{models.DecisionStateRejected, models.DecisionStateDraft, true},
+		{models.DecisionStateAccepted, models.DecisionStateSuperseded, true},
+
+		// Accepted and rejected are only reached through the reviews
+		{models.DecisionStateInReview, models.DecisionStateAccepted, false},
+		{models.DecisionStateInReview, models.DecisionStateRejected, false},
+		{models.DecisionStateProposed, models.DecisionStateAccepted, false},
+
+		// No skipping ahead
+		{models.DecisionStateDraft, models.DecisionStateInReview, false},
+		{models.DecisionStateDraft, models.DecisionStateAccepted, false},
+
+		// An accepted decision is only replaced, superseded is final
+		{models.DecisionStateAccepted, models.DecisionStateDraft, false},
+		{models.DecisionStateSuperseded, models.DecisionStateDraft, false},
+		{models.DecisionStateSuperseded, models.DecisionStateAccepted, false},
+
+		// The status values the analyses used before the lifecycle
+		{models.DecisionStateDraft, "Completed", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			assert.Equal(t, tt.allowed, slices.Contains(decisionTransitions[tt.from], tt.to))
+		})
+	}
+}
+
+func TestDecisionTransitionTargetsAreStates(t *testing.T) {
+	states := []models.DecisionState{
+		models.DecisionStateDraft, models.DecisionStateProposed, models.DecisionStateInReview,
+		models.DecisionStateAccepted, models.DecisionStateRejected, models.DecisionStateSuperseded,
+	}
+
+	for from, targets := range decisionTransitions {
+		assert.Contains(t, states, from)
+		for _, to := range targets {
+			assert.Contains(t, states, to)
+			assert.NotEqual(t, from, to)
+		}
+	}
+}
+
+func TestDecisionDeletable(t *testing.T) {
+	tests := []struct {
+		name      string
+		state     models.DecisionState
+		proposed  bool
+		deletable bool
+	}{
+		{"draft", models.DecisionStateDraft, false, true},
+		{"back to draft after the proposal", models.DecisionStateDraft, true, false},
+		{"proposed", models.DecisionStateProposed, true, false},
+		{"in review", models.DecisionStateInReview, true, false},
+		{"accepted", models.DecisionStateAccepted, true, false},
+		{"rejected", models.DecisionStateRejected, true, false},
+		{"superseded", models.DecisionStateSuperseded, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.deletable, decisionDeletable(tt.state, tt.proposed))
+		})
+	}
+}
+
+func TestNormalizeDecisionType(t *testing.T) {
+	tests := []struct {
+		decisionType models.DecisionType
+		want         models.DecisionType
+	}{
+		{"tchart", models.DecisionTypeTChart},
+		{"tbar", models.DecisionTypeTChart},
+		{"pnc", models.DecisionTypePnc},
+		{"unknown", "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.decisionType), func(t *testing.T) {
+			assert.Equal(t, tt.want, normalizeDecisionType(tt.decisionType))
+		})
+	}
+}
@@ -37,6 +37,9 @@ func NewMatrix(c *gin.Context) {
 		return
 	}
 
+	// New decisions start as draft, the status is changed through the lifecycle
+	matrix.MatrixStatus = utilities.Ptr(string(models.DecisionStateDraft))
+
 	// Begin a new transaction
 	tx, err := tenantManagement.DB.Begin()
 	if err != nil {
@@ -157,6 +160,23 @@ func GetMatrix(c *gin.Context) {
 		"details": matrix,
 	}
 
+	// Weighted results are computed on request, sensitivity=true includes the sensitivity analysis
+	withSensitivity := c.Query("sensitivity") == "true"
+	if c.Query("scores") == "true" || withSensitivity {
+		aggregation, trim, ok := parseMatrixAggregation(c)
+		if !ok {
+			return
+		}
+
+		scores, err := loadMatrixScores(tenantID, matrixID, aggregation, trim, withSensitivity)
+		if err != nil {
+			log.Printf("ERROR: Failed to score matrix analysis: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+			return
+		}
+		data["scores"] = scores
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"data":    data,
 		"message": "Matrix analysis retrieved successfully!",
@@ -252,11 +272,29 @@ func UpdateMatrix(c *gin.Context) {
 		return
 	}
 
-	_, err := tenantManagement.DB.Exec(
+	tx, err := tenantManagement.DB.Begin()
+	if err != nil {
+		log.Printf("ERROR: Failed to start transaction: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return
+	}
+	defer tx.Rollback()
+
+	// The update replaces every field, a missing status keeps the stored one
+	if !checkDecisionUpdate(c, tx, models.DecisionTypeMatrix, tenantID, matrixID, decisionUpdate{
+		Status:        matrix.MatrixStatus,
+		FinalDecision: matrix.FinalDecision,
+		Implications:  matrix.Implications,
+		Replace:       true,
+	}) {
+		return
+	}
+
+	_, err = tx.Exec(
 		`UPDATE st_schema.matrix_analysis SET
 			title = $1,
 			matrix_description = $2,
-			matrix_status = $3,
+			matrix_status = COALESCE($3, matrix_status),
 			category = $4,
 			assumptions = $5,
 			final_decision = $6,
@@ -287,6 +325,12 @@ func UpdateMatrix(c *gin.Context) {
 		return
 	}
 
+	if err := tx.Commit(); err != nil {
+		log.Printf("ERROR: Failed to commit the transaction: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return
+	}
+
 	row := tenantManagement.DB.QueryRow(`
 		SELECT
 			id, user_id, tenant_id, title, matrix_description, matrix_status, category,
@@ -389,6 +433,11 @@ func DeleteMatrix(c *gin.Context) {
 		return
 	}
 
+	if !checkDecisionDelete(c, tx, models.DecisionTypeMatrix, tenantID, matrixID) {
+		tx.Rollback()
+		return
+	}
+
 	_, err = tx.Exec("DELETE FROM st_schema.matrix_analysis WHERE id = $1 AND tenant_id = $2", matrixID, tenantID)
 	if err != nil {
 		tx.Rollback()
@@ -535,12 +584,14 @@ func GetMatrixCriteria(c *gin.Context) {
 		return
 	}
 
+	// Ratings of the calling user, the ratings of all participants are in /api/matrixUserRatings/aggregate
 	rows, err := tenantManagement.DB.Query(
 		`SELECT concept_id, user_rating
          FROM st_schema.matrix_user_ratings
-         WHERE criteria_id = $1 AND tenant_id = $2`,
+         WHERE criteria_id = $1 AND tenant_id = $2 AND user_id = $3`,
 		criteriaID,
 		tenantID,
+		userID,
 	)
 	if err != nil {
 		log.Printf("ERROR: Failed to retrieve user ratings from the database: %v", err)
@@ -618,13 +669,14 @@ func GetAllMatrixCriteria(c *gin.Context) {
 		criteria.UserID = userID
 		criteria.TenantID = tenantID
 
-		// Fetch associated user ratings for the current criteria
+		// Fetch the ratings of the calling user for the current criteria
 		ratingRows, err := tenantManagement.DB.Query(
 			`SELECT criteria_id, concept_id, user_id, user_rating
 			FROM st_schema.matrix_user_ratings
-			WHERE criteria_id = $1 AND tenant_id = $2`,
+			WHERE criteria_id = $1 AND tenant_id = $2 AND user_id = $3`,
 			criteria.Id,
 			tenantID,
+			userID,
 		)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user ratings from the database"})
@@ -1212,6 +1264,7 @@ func DeleteMatrixConcept(c *gin.Context) {
 	})
 }
 
+// UpdateMatrixUserRating saves the rating of the calling user, every participant keeps their own rating of a cell
 func UpdateMatrixUserRating(c *gin.Context) {
 	userID, tenantID, ok := utilities.ProcessIdentity(c)
 	if !ok {
@@ -1231,41 +1284,24 @@ func UpdateMatrixUserRating(c *gin.Context) {
 		return
 	}
 
+	// One rating per user and cell, see migrations/matrix_user_ratings_unique.sql
 	row := tenantManagement.DB.QueryRow(
-		`UPDATE st_schema.matrix_user_ratings
-        SET user_rating = $1
-        WHERE criteria_id = $2 AND concept_id = $3 AND tenant_id = $4
+		`INSERT INTO st_schema.matrix_user_ratings (user_rating, criteria_id, concept_id, user_id, tenant_id, updated_at)
+        VALUES ($1, $2, $3, $4, $5, NOW())
+        ON CONFLICT (criteria_id, concept_id, user_id, tenant_id)
+        DO UPDATE SET user_rating = EXCLUDED.user_rating, updated_at = NOW()
         RETURNING id`,
 		userRatingUpdate.UserRating,
 		criteriaID,
 		userRatingUpdate.ConceptID,
+		userID,
 		tenantID,
 	)
 
-	err := row.Scan(&userRatingUpdate.Id)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			// If no rows were affected, insert a new record
-			insertRow := tenantManagement.DB.QueryRow(
-				`INSERT INTO st_schema.matrix_user_ratings (user_rating, criteria_id, concept_id, user_id, tenant_id)
-				VALUES ($1, $2, $3, $4, $5) RETURNING id`,
-				userRatingUpdate.UserRating,
-				criteriaID,
-				userRatingUpdate.ConceptID,
-				userID,
-				tenantID,
-			)
-			err = insertRow.Scan(&userRatingUpdate.Id)
-			if err != nil {
-				log.Printf(models.DatabaseError, err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to insert a new user rating."})
-				return
-			}
-		} else {
-			log.Printf(models.DatabaseError, err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update the user rating."})
-			return
-		}
+	if err := row.Scan(&userRatingUpdate.Id); err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save the user rating."})
+		return
 	}
 
 	// Set additional fields for response
@@ -0,0 +1,458 @@
+package decisions
+
+// Analytic Hierarchy Process: instead of typing in the criteria multipliers, participants compare the
+// criteria pairwise. The judgements of all users are combined by their geometric mean, the principal
+// eigenvector of the comparison matrix gives the weights and the consistency ratio tells whether the
+// judgements contradict each other. Applied weights are written to the criteria as multipliers in percent.
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+
+	"sententiawebapi/handlers/apis/tenantManagement"
+	models "sententiawebapi/handlers/models"
+	"sententiawebapi/utilities"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxConsistencyRatio = 0.1
+	maxJudgement        = 9
+	powerIterations     = 1000
+	powerTolerance      = 1e-12
+)
+
+// Saaty's random consistency index by number of criteria, larger matrices use the last one
+var randomIndex = []float64{0, 0, 0, 0.58, 0.90, 1.12, 1.24, 1.32, 1.41, 1.45, 1.49, 1.51, 1.48, 1.56, 1.57, 1.59}
+
+// criteriaPair is stored with the lower criteria ID first, the judgement is inverted to match
+type criteriaPair struct {
+	a string
+	b string
+}
+
+// ahpJudgements holds how much more important pair.a is than pair.b
+type ahpJudgements map[criteriaPair]float64
+
+func canonicalPair(criteriaAID string, criteriaBID string, value float64) (criteriaPair, float64) {
+	if criteriaAID > criteriaBID {
+		return criteriaPair{a: criteriaBID, b: criteriaAID}, 1 / value
+	}
+	return criteriaPair{a: criteriaAID, b: criteriaBID}, value
+}
+
+// deriveAhpWeights computes the priority vector and its consistency, every pair of criteria has to be judged
+func deriveAhpWeights(criteria []matrixCriterion, judgements ahpJudgements) models.MatrixAhpResult {
+	n := len(criteria)
+	result := models.MatrixAhpResult{
+		Weights:      []models.MatrixAhpWeight{},
+		MissingPairs: []models.MatrixPairwiseComparison{},
+	}
+
+	comparison := make([][]float64, n)
+	for i := range comparison {
+		comparison[i] = make([]float64, n)
+		comparison[i][i] = 1
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			pair, _ := canonicalPair(criteria[i].ID, criteria[j].ID, 1)
+			value, ok := judgements[pair]
+			if !ok {
+				result.MissingPairs = append(result.MissingPairs, models.MatrixPairwiseComparison{CriteriaAID: pair.a, CriteriaBID: pair.b})
+				continue
+			}
+			if pair.a != criteria[i].ID {
+				value = 1 / value
+			}
+			comparison[i][j] = value
+			comparison[j][i] = 1 / value
+		}
+	}
+	if len(result.MissingPairs) > 0 {
+		return result
+	}
+	result.Complete = true
+
+	// Power iteration converges to the principal eigenvector of the positive comparison matrix
+	weights := make([]float64, n)
+	for i := range weights {
+		weights[i] = 1 / float64(n)
+	}
+	product := make([]float64, n)
+	for iteration := 0; iteration < powerIterations; iteration++ {
+		var sum float64
+		for i := range comparison {
+			product[i] = 0
+			for j := range comparison[i] {
+				product[i] += comparison[i][j] * weights[j]
+			}
+			sum += product[i]
+		}
+
+		var change float64
+		for i := range weights {
+			next := product[i] / sum
+			change = math.Max(change, math.Abs(next-weights[i]))
+			weights[i] = next
+		}
+		if change < powerTolerance {
+			break
+		}
+	}
+
+	for i := range comparison {
+		var row float64
+		for j := range comparison[i] {
+			row += comparison[i][j] * weights[j]
+		}
+		result.LambdaMax += row / weights[i] / float64(n)
+	}
+
+	if n > 2 {
+		result.ConsistencyIndex = math.Max(0, (result.LambdaMax-float64(n))/float64(n-1))
+		result.ConsistencyRatio = result.ConsistencyIndex / randomIndex[min(n, len(randomIndex)-1)]
+	}
+	result.Consistent = result.ConsistencyRatio <= maxConsistencyRatio
+
+	for i, criterion := range criteria {
+		result.Weights = append(result.Weights, models.MatrixAhpWeight{
+			CriteriaID: criterion.ID,
+			Title:      criterion.Title,
+			Weight:     weights[i],
+			// A criterion is never dropped by rounding, it keeps a multiplier of at least 1
+			Multiplier: max(1, int(math.Round(weights[i]*100))),
+		})
+	}
+
+	if !result.Consistent {
+		// The judgement furthest from the ratio of the derived weights is the first to reconsider
+		var worst float64
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				deviation := math.Abs(math.Log(comparison[i][j] * weights[j] / weights[i]))
+				if deviation > worst {
+					worst = deviation
+					result.MostInconsistent = &models.MatrixAhpJudgement{
+						CriteriaAID:    criteria[i].ID,
+						CriteriaBID:    criteria[j].ID,
+						Value:          comparison[i][j],
+						SuggestedValue: weights[i] / weights[j],
+					}
+				}
+			}
+		}
+	}
+
+	return result
+}
+
+// matrixAhpResult derives the group weights from the judgements of all users, the consistency of every user is included
+func matrixAhpResult(criteria []matrixCriterion, comparisons []models.MatrixPairwiseComparison) models.MatrixAhpResult {
+	userJudgements := map[string]ahpJudgements{}
+	logSums := map[criteriaPair]float64{}
+	counts := map[criteriaPair]int{}
+	for _, comparison := range comparisons {
+		pair, value := canonicalPair(comparison.CriteriaAID, comparison.CriteriaBID, comparison.Value)
+		if userJudgements[comparison.UserID] == nil {
+			userJudgements[comparison.UserID] = ahpJudgements{}
+		}
+		userJudgements[comparison.UserID][pair] = value
+		logSums[pair] += math.Log(value)
+		counts[pair]++
+	}
+
+	// The geometric mean keeps the group judgements reciprocal, the arithmetic mean would not
+	group := ahpJudgements{}
+	for pair, sum := range logSums {
+		group[pair] = math.Exp(sum / float64(counts[pair]))
+	}
+
+	result := deriveAhpWeights(criteria, group)
+	result.Participants = []models.MatrixAhpParticipant{}
+	for userID, judgements := range userJudgements {
+		own := deriveAhpWeights(criteria, judgements)
+		result.Participants = append(result.Participants, models.MatrixAhpParticipant{
+			UserID:           userID,
+			Comparisons:      len(judgements),
+			Complete:         own.Complete,
+			ConsistencyRatio: own.ConsistencyRatio,
+			Consistent:       own.Complete && own.Consistent,
+		})
+	}
+	sort.Slice(result.Participants, func(i, j int) bool { return result.Participants[i].UserID < result.Participants[j].UserID })
+
+	return result
+}
+
+// loadMatrixComparisons returns the pairwise comparisons of the matrix, only those of the user when userID isn't empty.
+// Comparisons of deleted criteria are left out.
+func loadMatrixComparisons(tenantID string, matrixID string, userID string) ([]models.MatrixPairwiseComparison, error) {
+	rows, err := tenantManagement.DB.Query(`
+		SELECT p.criteria_a_id, p.criteria_b_id, p.value, p.user_id
+		FROM st_schema.matrix_pairwise_comparisons p
+		INNER JOIN st_schema.matrix_criteria a ON a.id = p.criteria_a_id AND a.matrix_id = p.matrix_id
+		INNER JOIN st_schema.matrix_criteria b ON b.id = p.criteria_b_id AND b.matrix_id = p.matrix_id
+		WHERE p.matrix_id = $1 AND p.tenant_id = $2
+		AND ($3::text = '' OR p.user_id::text = $3)
+		ORDER BY p.user_id, p.criteria_a_id, p.criteria_b_id
+	`, matrixID, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comparisons := []models.MatrixPairwiseComparison{}
+	for rows.Next() {
+		var comparison models.MatrixPairwiseComparison
+		if err := rows.Scan(&comparison.CriteriaAID, &comparison.CriteriaBID, &comparison.Value, &comparison.UserID); err != nil {
+			return nil, err
+		}
+		comparisons = append(comparisons, comparison)
+	}
+
+	return comparisons, rows.Err()
+}
+
+// GetMatrixAhp returns the weights derived from the pairwise comparisons and their consistency
+func GetMatrixAhp(c *gin.Context) {
+	_, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	matrixID, ok := utilities.ValidateQueryParam(c, "matrix_id")
+	if !ok {
+		return
+	}
+
+	result, err := loadMatrixAhpResult(tenantID, matrixID)
+	if err != nil {
+		log.Printf("ERROR: Failed to retrieve pairwise comparisons from the database: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    result,
+		"message": "Matrix AHP weights retrieved successfully!",
+	})
+}
+
+func loadMatrixAhpResult(tenantID string, matrixID string) (*models.MatrixAhpResult, error) {
+	criteria, err := loadMatrixCriteria(tenantID, matrixID)
+	if err != nil {
+		return nil, err
+	}
+
+	comparisons, err := loadMatrixComparisons(tenantID, matrixID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	result := matrixAhpResult(criteria, comparisons)
+	return &result, nil
+}
+
+// GetMatrixPairwiseComparisons returns the pairwise comparisons of the matrix, user_id limits them to one participant
+func GetMatrixPairwiseComparisons(c *gin.Context) {
+	_, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	matrixID, ok := utilities.ValidateQueryParam(c, "matrix_id")
+	if !ok {
+		return
+	}
+
+	comparisons, err := loadMatrixComparisons(tenantID, matrixID, c.Query("user_id"))
+	if err != nil {
+		log.Printf("ERROR: Failed to retrieve pairwise comparisons from the database: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    comparisons,
+		"message": "Pairwise comparisons retrieved successfully!",
+	})
+}
+
+// UpdateMatrixPairwiseComparisons saves judgements of the calling user. Once the user has compared every pair,
+// judgements above the consistency threshold are rejected unless allow_inconsistent is set.
+func UpdateMatrixPairwiseComparisons(c *gin.Context) {
+	userID, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	matrixID, ok := utilities.ValidateQueryParam(c, "matrix_id")
+	if !ok {
+		return
+	}
+
+	var req models.MatrixPairwiseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	criteria, err := loadMatrixCriteria(tenantID, matrixID)
+	if err != nil {
+		log.Printf("ERROR: Failed to retrieve criteria from the database: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return
+	}
+	criteriaIDs := map[string]bool{}
+	for _, criterion := range criteria {
+		criteriaIDs[criterion.ID] = true
+	}
+
+	existing, err := loadMatrixComparisons(tenantID, matrixID, userID)
+	if err != nil {
+		log.Printf("ERROR: Failed to retrieve pairwise comparisons from the database: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return
+	}
+	judgements := ahpJudgements{}
+	for _, comparison := range existing {
+		pair, value := canonicalPair(comparison.CriteriaAID, comparison.CriteriaBID, comparison.Value)
+		judgements[pair] = value
+	}
+
+	updated := ahpJudgements{}
+	for i, comparison := range req.Comparisons {
+		if !criteriaIDs[comparison.CriteriaAID] || !criteriaIDs[comparison.CriteriaBID] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("comparisons[%d]: criteria not found in the matrix", i)})
+			return
+		}
+		if comparison.CriteriaAID == comparison.CriteriaBID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("comparisons[%d]: a criterion can't be compared with itself", i)})
+			return
+		}
+		if comparison.Value < 1.0/maxJudgement-scoreTolerance || comparison.Value > maxJudgement+scoreTolerance {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("comparisons[%d]: value must be between 1/9 and 9", i)})
+			return
+		}
+
+		pair, value := canonicalPair(comparison.CriteriaAID, comparison.CriteriaBID, comparison.Value)
+		if _, ok := updated[pair]; ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("comparisons[%d]: pair is compared more than once", i)})
+			return
+		}
+		updated[pair] = value
+		judgements[pair] = value
+	}
+
+	result := deriveAhpWeights(criteria, judgements)
+	if result.Complete && !result.Consistent && !req.AllowInconsistent {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": fmt.Sprintf("Judgements are inconsistent, the consistency ratio %.3f is above %.1f", result.ConsistencyRatio, maxConsistencyRatio),
+			"data":  result,
+		})
+		return
+	}
+
+	tx, err := tenantManagement.DB.Begin()
+	if err != nil {
+		log.Printf("ERROR: Failed to start a transaction: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return
+	}
+	defer tx.Rollback()
+
+	for pair, value := range updated {
+		// One judgement per user and pair, see migrations/matrix_pairwise_comparisons.sql
+		_, err = tx.Exec(`
+			INSERT INTO st_schema.matrix_pairwise_comparisons (matrix_id, criteria_a_id, criteria_b_id, user_id, value, tenant_id)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (matrix_id, criteria_a_id, criteria_b_id, user_id)
+			DO UPDATE SET value = EXCLUDED.value, updated_at = NOW()
+		`, matrixID, pair.a, pair.b, userID, value, tenantID)
+		if err != nil {
+			log.Printf("ERROR: Failed to save pairwise comparison: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("ERROR: Failed to commit transaction: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    result,
+		"message": "Pairwise comparisons saved successfully!",
+	})
+}
+
+// ApplyMatrixAhpWeights writes the group weights to the criteria multipliers used by the scoring.
+// Inconsistent group judgements are only applied with force=true.
+func ApplyMatrixAhpWeights(c *gin.Context) {
+	_, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	matrixID, ok := utilities.ValidateQueryParam(c, "matrix_id")
+	if !ok {
+		return
+	}
+
+	result, err := loadMatrixAhpResult(tenantID, matrixID)
+	if err != nil {
+		log.Printf("ERROR: Failed to retrieve pairwise comparisons from the database: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return
+	}
+	if len(result.Weights) == 0 || !result.Complete {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Every pair of criteria must be compared before the weights can be applied", "data": result})
+		return
+	}
+	if !result.Consistent && c.Query("force") != "true" {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": fmt.Sprintf("Judgements are inconsistent, the consistency ratio %.3f is above %.1f", result.ConsistencyRatio, maxConsistencyRatio),
+			"data":  result,
+		})
+		return
+	}
+
+	tx, err := tenantManagement.DB.Begin()
+	if err != nil {
+		log.Printf("ERROR: Failed to start a transaction: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return
+	}
+	defer tx.Rollback()
+
+	for _, weight := range result.Weights {
+		_, err = tx.Exec(`
+			UPDATE st_schema.matrix_criteria
+			SET criteria_multiplier = $1, criteria_multiplier_title = $2
+			WHERE id = $3 AND matrix_id = $4 AND tenant_id = $5
+		`, weight.Multiplier, fmt.Sprintf("AHP %.1f%%", weight.Weight*100), weight.CriteriaID, matrixID, tenantID)
+		if err != nil {
+			log.Printf("ERROR: Failed to update the criteria in the database: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("ERROR: Failed to commit transaction: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    result,
+		"message": "Matrix AHP weights applied successfully!",
+	})
+}
@@ -0,0 +1,115 @@
+package decisions
+
+import (
+	"testing"
+
+	models "sententiawebapi/handlers/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeriveAhpWeights(t *testing.T) {
+	criteria := []matrixCriterion{{ID: "a", Title: "A"}, {ID: "b", Title: "B"}, {ID: "c", Title: "C"}}
+
+	tests := []struct {
+		name            string
+		criteria        []matrixCriterion
+		judgements      ahpJudgements
+		wantComplete    bool
+		wantMissing     []models.MatrixPairwiseComparison
+		wantWeights     []float64
+		wantMultipliers []int
+		wantConsistent  bool
+		wantRatio       float64 // Only checked for consistent judgements
+	}{
+		{
+			name:         "missing pairs",
+			criteria:     criteria,
+			judgements:   ahpJudgements{{a: "a", b: "b"}: 2},
+			wantComplete: false,
+			wantMissing: []models.MatrixPairwiseComparison{
+				{CriteriaAID: "a", CriteriaBID: "c"},
+				{CriteriaAID: "b", CriteriaBID: "c"},
+			},
+		},
+		{
+			name:            "consistent judgements",
+			criteria:        criteria,
+			judgements:      ahpJudgements{{a: "a", b: "b"}: 2, {a: "a", b: "c"}: 4, {a: "b", b: "c"}: 2},
+			wantComplete:    true,
+			wantWeights:     []float64{4.0 / 7, 2.0 / 7, 1.0 / 7},
+			wantMultipliers: []int{57, 29, 14},
+			wantConsistent:  true,
+		},
+		{
+			name:            "equal importance",
+			criteria:        criteria,
+			judgements:      ahpJudgements{{a: "a", b: "b"}: 1, {a: "a", b: "c"}: 1, {a: "b", b: "c"}: 1},
+			wantComplete:    true,
+			wantWeights:     []float64{1.0 / 3, 1.0 / 3, 1.0 / 3},
+			wantMultipliers: []int{33, 33, 33},
+			wantConsistent:  true,
+		},
+		{
+			name:            "judgements are stored with the lower ID first",
+			criteria:        []matrixCriterion{{ID: "b"}, {ID: "a"}},
+			judgements:      ahpJudgements{{a: "a", b: "b"}: 3},
+			wantComplete:    true,
+			wantWeights:     []float64{0.25, 0.75},
+			wantMultipliers: []int{25, 75},
+			wantConsistent:  true,
+		},
+		{
+			name:            "tiny weights keep a multiplier",
+			criteria:        []matrixCriterion{{ID: "a"}, {ID: "b"}},
+			judgements:      ahpJudgements{{a: "a", b: "b"}: 1000},
+			wantComplete:    true,
+			wantWeights:     []float64{1000.0 / 1001, 1.0 / 1001},
+			wantMultipliers: []int{100, 1},
+			wantConsistent:  true,
+		},
+		{
+			name:           "contradicting judgements",
+			criteria:       criteria,
+			judgements:     ahpJudgements{{a: "a", b: "b"}: 9, {a: "b", b: "c"}: 9, {a: "a", b: "c"}: 1.0 / 9},
+			wantComplete:   true,
+			wantConsistent: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := deriveAhpWeights(tt.criteria, tt.judgements)
+
+			assert.Equal(t, tt.wantComplete, result.Complete)
+			if !tt.wantComplete {
+				assert.Equal(t, tt.wantMissing, result.MissingPairs)
+				assert.Empty(t, result.Weights)
+				return
+			}
+			assert.Empty(t, result.MissingPairs)
+			assert.Len(t, result.Weights, len(tt.criteria))
+
+			var sum float64
+			for i, weight := range result.Weights {
+				assert.Equal(t, tt.criteria[i].ID, weight.CriteriaID)
+				sum += weight.Weight
+				if tt.wantWeights != nil {
+					assert.InDelta(t, tt.wantWeights[i], weight.Weight, 1e-9)
+					assert.Equal(t, tt.wantMultipliers[i], weight.Multiplier)
+				}
+			}
+			assert.InDelta(t, 1, sum, 1e-9)
+
+			assert.Equal(t, tt.wantConsistent, result.Consistent)
+			if tt.wantConsistent {
+				assert.InDelta(t, float64(len(tt.criteria)), result.LambdaMax, 1e-6)
+				assert.InDelta(t, tt.wantRatio, result.ConsistencyRatio, 1e-6)
+				assert.Nil(t, result.MostInconsistent)
+			} else {
+				assert.Greater(t, result.ConsistencyRatio, maxConsistencyRatio)
+				assert.NotNil(t, result.MostInconsistent)
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package decisions
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"sententiawebapi/handlers/apis/tenantManagement"
+	models "sententiawebapi/handlers/models"
+	"sententiawebapi/utilities"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Share of the ratings dropped at each end for the trimmed mean
+const defaultTrimFraction = 0.2
+
+// Standard deviation of a cell, relative to the range of all ratings in the matrix, from which on the disagreement is medium or high
+const (
+	mediumDisagreement = 0.15
+	highDisagreement   = 0.3
+)
+
+// matrixCellRatings holds the ratings of all users of a cell: cells[criteriaID][conceptID]
+type matrixCellRatings map[string]map[string][]float64
+
+// parseMatrixAggregation reads the aggregation and trim query params, mean is the default
+func parseMatrixAggregation(c *gin.Context) (models.MatrixAggregation, float64, bool) {
+	aggregation := models.MatrixAggregation(c.DefaultQuery("aggregation", string(models.MatrixAggregationMean)))
+	switch aggregation {
+	case models.MatrixAggregationMean, models.MatrixAggregationMedian, models.MatrixAggregationTrimmedMean:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "aggregation must be mean, median or trimmed_mean"})
+		return "", 0, false
+	}
+
+	trim := defaultTrimFraction
+	if value := c.Query("trim"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < 0 || parsed >= 0.5 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "trim must be a number from 0 to below 0.5"})
+			return "", 0, false
+		}
+		trim = parsed
+	}
+
+	return aggregation, trim, true
+}
+
+// aggregateCell computes the statistics of the ratings of one cell
+func aggregateCell(values []float64, trim float64) models.MatrixCellAggregate {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+
+	cell := models.MatrixCellAggregate{Count: n}
+	if n == 0 {
+		return cell
+	}
+	cell.Min = sorted[0]
+	cell.Max = sorted[n-1]
+
+	var sum float64
+	for _, value := range sorted {
+		sum += value
+	}
+	cell.Mean = sum / float64(n)
+
+	var squares float64
+	for _, value := range sorted {
+		squares += (value - cell.Mean) * (value - cell.Mean)
+	}
+	cell.StdDev = math.Sqrt(squares / float64(n))
+
+	if n%2 == 1 {
+		cell.Median = sorted[n/2]
+	} else {
+		cell.Median = (sorted[n/2-1] + sorted[n/2]) / 2
+	}
+
+	cut := int(float64(n) * trim)
+	var trimmed float64
+	for _, value := range sorted[cut : n-cut] {
+		trimmed += value
+	}
+	cell.TrimmedMean = trimmed / float64(n-2*cut)
+
+	return cell
+}
+
+// aggregateMatrixRatings aggregates every cell and returns the cells with the rating used for scoring
+func aggregateMatrixRatings(cells matrixCellRatings, aggregation models.MatrixAggregation, trim float64) ([]models.MatrixCellAggregate, matrixRatings) {
+	// The rating scale isn't stored, the range of all ratings of the matrix stands in for it
+	scaleMin, scaleMax := math.Inf(1), math.Inf(-1)
+	for _, concepts := range cells {
+		for _, values := range concepts {
+			for _, value := range values {
+				scaleMin = math.Min(scaleMin, value)
+				scaleMax = math.Max(scaleMax, value)
+			}
+		}
+	}
+
+	aggregates := []models.MatrixCellAggregate{}
+	ratings := matrixRatings{}
+	for criteriaID, concepts := range cells {
+		ratings[criteriaID] = map[string]float64{}
+		for conceptID, values := range concepts {
+			cell := aggregateCell(values, trim)
+			cell.CriteriaID = criteriaID
+			cell.ConceptID = conceptID
+
+			switch aggregation {
+			case models.MatrixAggregationMedian:
+				cell.Value = cell.Median
+			case models.MatrixAggregationTrimmedMean:
+				cell.Value = cell.TrimmedMean
+			default:
+				cell.Value = cell.Mean
+			}
+
+			spread := 0.0
+			if scaleMax > scaleMin {
+				spread = cell.StdDev / (scaleMax - scaleMin)
+			}
+			switch {
+			case cell.Count < 2:
+				cell.Disagreement = models.MatrixDisagreementNone
+			case spread >= highDisagreement:
+				cell.Disagreement = models.MatrixDisagreementHigh
+			case spread >= mediumDisagreement:
+				cell.Disagreement = models.MatrixDisagreementMedium
+			default:
+				cell.Disagreement = models.MatrixDisagreementLow
+			}
+
+			aggregates = append(aggregates, cell)
+			ratings[criteriaID][conceptID] = cell.Value
+		}
+	}
+
+	sort.Slice(aggregates, func(i, j int) bool {
+		if aggregates[i].CriteriaID != aggregates[j].CriteriaID {
+			return aggregates[i].CriteriaID < aggregates[j].CriteriaID
+		}
+		return aggregates[i].ConceptID < aggregates[j].ConceptID
+	})
+
+	return aggregates, ratings
+}
+
+// loadMatrixUserRatings returns the ratings of the matrix, only those of the user when userID isn't empty.
+// Ratings of deleted criteria or concepts are left out.
+func loadMatrixUserRatings(tenantID string, matrixID string, userID string) ([]models.MatrixUserRating, error) {
+	rows, err := tenantManagement.DB.Query(`
+		SELECT r.id, r.criteria_id, r.concept_id, r.user_id, r.user_rating
+		FROM st_schema.matrix_user_ratings r
+		INNER JOIN st_schema.matrix_criteria cr ON cr.id = r.criteria_id AND cr.tenant_id = r.tenant_id
+		INNER JOIN st_schema.matrix_concepts co ON co.id = r.concept_id AND co.tenant_id = r.tenant_id
+		WHERE cr.matrix_id = $1 AND co.matrix_id = $1 AND r.tenant_id = $2
+		AND ($3::text = '' OR r.user_id::text = $3)
+		ORDER BY r.user_id, r.criteria_id, r.concept_id
+	`, matrixID, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ratings := []models.MatrixUserRating{}
+	for rows.Next() {
+		var rating models.MatrixUserRating
+		if err := rows.Scan(&rating.Id, &rating.CriteriaID, &rating.ConceptID, &rating.UserID, &rating.UserRating); err != nil {
+			return nil, err
+		}
+		rating.TenantId = &tenantID
+		ratings = append(ratings, rating)
+	}
+
+	return ratings, rows.Err()
+}
+
+// loadMatrixCellRatings groups the ratings of all users by cell
+func loadMatrixCellRatings(tenantID string, matrixID string) (matrixCellRatings, int, error) {
+	ratings, err := loadMatrixUserRatings(tenantID, matrixID, "")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	cells := matrixCellRatings{}
+	users := map[string]bool{}
+	for _, rating := range ratings {
+		if cells[rating.CriteriaID] == nil {
+			cells[rating.CriteriaID] = map[string][]float64{}
+		}
+		cells[rating.CriteriaID][rating.ConceptID] = append(cells[rating.CriteriaID][rating.ConceptID], float64(rating.UserRating))
+		users[rating.UserID] = true
+	}
+
+	return cells, len(users), nil
+}
+
+// GetMatrixUserRatings returns the ratings of every participant, user_id limits them to one user
+func GetMatrixUserRatings(c *gin.Context) {
+	_, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	matrixID, ok := utilities.ValidateQueryParam(c, "matrix_id")
+	if !ok {
+		return
+	}
+
+	ratings, err := loadMatrixUserRatings(tenantID, matrixID, c.Query("user_id"))
+	if err != nil {
+		log.Printf("ERROR: Failed to retrieve user ratings from the database: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return
+	}
+
+	participants := []models.MatrixParticipantRatings{}
+	for _, rating := range ratings {
+		if len(participants) == 0 || participants[len(participants)-1].UserID != rating.UserID {
+			participants = append(participants, models.MatrixParticipantRatings{UserID: rating.UserID})
+		}
+		last := &participants[len(participants)-1]
+		last.Ratings = append(last.Ratings, rating)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    participants,
+		"message": "Matrix user ratings retrieved successfully!",
+	})
+}
+
+// GetMatrixRatingsAggregate returns the group rating and the disagreement of every cell of the matrix
+func GetMatrixRatingsAggregate(c *gin.Context) {
+	_, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	matrixID, ok := utilities.ValidateQueryParam(c, "matrix_id")
+	if !ok {
+		return
+	}
+
+	aggregation, trim, ok := parseMatrixAggregation(c)
+	if !ok {
+		return
+	}
+
+	cells, participants, err := loadMatrixCellRatings(tenantID, matrixID)
+	if err != nil {
+		log.Printf("ERROR: Failed to retrieve user ratings from the database: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return
+	}
+
+	aggregates, _ := aggregateMatrixRatings(cells, aggregation, trim)
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": models.MatrixRatingsAggregate{
+			Aggregation:  aggregation,
+			TrimFraction: trim,
+			Participants: participants,
+			Cells:        aggregates,
+		},
+		"message": "Matrix ratings aggregated successfully!",
+	})
+}
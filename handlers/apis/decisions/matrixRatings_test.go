@@ -0,0 +1,102 @@
+package decisions
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregateCell(t *testing.T) {
+	tests := []struct {
+		name        string
+		values      []float64
+		trim        float64
+		wantMean    float64
+		wantMedian  float64
+		wantTrimmed float64
+		wantMin     float64
+		wantMax     float64
+		wantStdDev  float64
+	}{
+		{
+			name:        "single rating",
+			values:      []float64{4},
+			trim:        0.2,
+			wantMean:    4,
+			wantMedian:  4,
+			wantTrimmed: 4,
+			wantMin:     4,
+			wantMax:     4,
+		},
+		{
+			name:        "even count takes the middle pair for the median",
+			values:      []float64{4, 2},
+			trim:        0.2,
+			wantMean:    3,
+			wantMedian:  3,
+			wantTrimmed: 3,
+			wantMin:     2,
+			wantMax:     4,
+			wantStdDev:  1,
+		},
+		{
+			name:        "outlier is trimmed",
+			values:      []float64{10, 1, 3, 2, 4},
+			trim:        0.2,
+			wantMean:    4,
+			wantMedian:  3,
+			wantTrimmed: 3,
+			wantMin:     1,
+			wantMax:     10,
+			wantStdDev:  math.Sqrt(10),
+		},
+		{
+			name:        "no trim",
+			values:      []float64{10, 1, 3, 2, 4},
+			trim:        0,
+			wantMean:    4,
+			wantMedian:  3,
+			wantTrimmed: 4,
+			wantMin:     1,
+			wantMax:     10,
+			wantStdDev:  math.Sqrt(10),
+		},
+		{
+			name:        "trim rounds down to whole ratings",
+			values:      []float64{1, 2, 3, 10},
+			trim:        0.2,
+			wantMean:    4,
+			wantMedian:  2.5,
+			wantTrimmed: 4,
+			wantMin:     1,
+			wantMax:     10,
+			wantStdDev:  math.Sqrt(12.5),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := append([]float64(nil), tt.values...)
+			cell := aggregateCell(tt.values, tt.trim)
+
+			assert.Equal(t, len(tt.values), cell.Count)
+			assert.InDelta(t, tt.wantMean, cell.Mean, 1e-9)
+			assert.InDelta(t, tt.wantMedian, cell.Median, 1e-9)
+			assert.InDelta(t, tt.wantTrimmed, cell.TrimmedMean, 1e-9)
+			assert.Equal(t, tt.wantMin, cell.Min)
+			assert.Equal(t, tt.wantMax, cell.Max)
+			assert.InDelta(t, tt.wantStdDev, cell.StdDev, 1e-9)
+			// The ratings of the caller are left unsorted
+			assert.Equal(t, values, tt.values)
+		})
+	}
+}
+
+func TestAggregateCellWithoutRatings(t *testing.T) {
+	cell := aggregateCell(nil, 0.2)
+
+	assert.Equal(t, 0, cell.Count)
+	assert.Zero(t, cell.Mean)
+	assert.False(t, math.IsNaN(cell.TrimmedMean))
+}
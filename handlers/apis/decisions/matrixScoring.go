@@ -0,0 +1,213 @@
+package decisions
+
+import (
+	"math"
+	"sort"
+
+	"sententiawebapi/handlers/apis/tenantManagement"
+	models "sententiawebapi/handlers/models"
+)
+
+// Totals closer than this are a tie
+const scoreTolerance = 1e-9
+
+type matrixCriterion struct {
+	ID     string
+	Title  string
+	Weight float64
+}
+
+type matrixConcept struct {
+	ID    string
+	Title string
+}
+
+// matrixRatings holds the rating of a concept on a criterion: ratings[criteriaID][conceptID]
+type matrixRatings map[string]map[string]float64
+
+// scoreMatrix computes the weighted totals, ranks and the winner of the matrix
+func scoreMatrix(criteria []matrixCriterion, concepts []matrixConcept, ratings matrixRatings, withSensitivity bool) models.MatrixScores {
+	scores := models.MatrixScores{Concepts: []models.MatrixConceptScore{}}
+	for _, criterion := range criteria {
+		scores.TotalWeight += criterion.Weight
+	}
+
+	for _, concept := range concepts {
+		score := models.MatrixConceptScore{ConceptID: concept.ID, Title: concept.Title}
+		for _, criterion := range criteria {
+			rating, ok := ratings[criterion.ID][concept.ID]
+			if !ok {
+				score.MissingRatings++
+				continue
+			}
+			score.WeightedTotal += criterion.Weight * rating
+		}
+		if scores.TotalWeight != 0 {
+			score.NormalizedScore = score.WeightedTotal / scores.TotalWeight
+		}
+		scores.Concepts = append(scores.Concepts, score)
+	}
+
+	sort.SliceStable(scores.Concepts, func(i, j int) bool {
+		return scores.Concepts[i].WeightedTotal > scores.Concepts[j].WeightedTotal
+	})
+	for i := range scores.Concepts {
+		if i > 0 && scores.Concepts[i-1].WeightedTotal-scores.Concepts[i].WeightedTotal <= scoreTolerance {
+			scores.Concepts[i].Rank = scores.Concepts[i-1].Rank
+		} else {
+			scores.Concepts[i].Rank = i + 1
+		}
+	}
+
+	if len(scores.Concepts) == 0 {
+		return scores
+	}
+	winner := scores.Concepts[0]
+	if len(scores.Concepts) == 1 {
+		scores.WinnerID = &winner.ConceptID
+		return scores
+	}
+
+	scores.WinnerMargin = winner.WeightedTotal - scores.Concepts[1].WeightedTotal
+	if winner.WeightedTotal != 0 {
+		scores.MarginPercent = scores.WinnerMargin / math.Abs(winner.WeightedTotal) * 100
+	}
+	if scores.WinnerMargin <= scoreTolerance {
+		// Tied first places have no winner to flip
+		scores.WinnerMargin = 0
+		return scores
+	}
+	scores.WinnerID = &winner.ConceptID
+
+	if withSensitivity {
+		scores.Sensitivity = matrixSensitivity(criteria, scores.Concepts, ratings)
+	}
+
+	return scores
+}
+
+// matrixSensitivity finds for every criterion the closest weight at which a challenger ties with the winner.
+// The difference of two totals is linear in one weight, so the first tie is the nearest root over all challengers.
+func matrixSensitivity(criteria []matrixCriterion, ranked []models.MatrixConceptScore, ratings matrixRatings) []models.MatrixCriterionSensitivity {
+	winner := ranked[0]
+	sensitivity := make([]models.MatrixCriterionSensitivity, 0, len(criteria))
+
+	for _, criterion := range criteria {
+		result := models.MatrixCriterionSensitivity{
+			CriteriaID: criterion.ID,
+			Title:      criterion.Title,
+			Weight:     criterion.Weight,
+		}
+
+		bestChange := math.Inf(1)
+		for _, challenger := range ranked[1:] {
+			difference := winner.WeightedTotal - challenger.WeightedTotal
+			slope := ratings[criterion.ID][winner.ConceptID] - ratings[criterion.ID][challenger.ConceptID]
+			if slope == 0 {
+				continue
+			}
+
+			change := -difference / slope
+			if criterion.Weight+change < 0 || math.Abs(change) >= math.Abs(bestChange) {
+				continue
+			}
+
+			bestChange = change
+			flipWeight := criterion.Weight + change
+			conceptID := challenger.ConceptID
+			result.FlipWeight = &flipWeight
+			result.FlipsTo = &conceptID
+		}
+
+		if result.FlipWeight != nil {
+			result.WeightChange = &bestChange
+			if criterion.Weight != 0 {
+				percent := bestChange / criterion.Weight * 100
+				result.ChangePercent = &percent
+			}
+		}
+
+		sensitivity = append(sensitivity, result)
+	}
+
+	// The most sensitive criteria first, robust ones last
+	sort.SliceStable(sensitivity, func(i, j int) bool {
+		a, b := sensitivity[i].WeightChange, sensitivity[j].WeightChange
+		if a == nil || b == nil {
+			return a != nil
+		}
+		return math.Abs(*a) < math.Abs(*b)
+	})
+
+	return sensitivity
+}
+
+// loadMatrixScores scores a matrix of the tenant, the ratings of all users of a cell are aggregated to one
+func loadMatrixScores(tenantID string, matrixID string, aggregation models.MatrixAggregation, trim float64, withSensitivity bool) (*models.MatrixScores, error) {
+	criteria, concepts, err := loadMatrixData(tenantID, matrixID)
+	if err != nil {
+		return nil, err
+	}
+
+	cells, _, err := loadMatrixCellRatings(tenantID, matrixID)
+	if err != nil {
+		return nil, err
+	}
+	_, ratings := aggregateMatrixRatings(cells, aggregation, trim)
+
+	scores := scoreMatrix(criteria, concepts, ratings, withSensitivity)
+	return &scores, nil
+}
+
+func loadMatrixData(tenantID string, matrixID string) ([]matrixCriterion, []matrixConcept, error) {
+	criteria, err := loadMatrixCriteria(tenantID, matrixID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conceptRows, err := tenantManagement.DB.Query(`
+		SELECT id, title
+		FROM st_schema.matrix_concepts
+		WHERE matrix_id = $1 AND tenant_id = $2
+		ORDER BY title
+	`, matrixID, tenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer conceptRows.Close()
+
+	var concepts []matrixConcept
+	for conceptRows.Next() {
+		var concept matrixConcept
+		if err := conceptRows.Scan(&concept.ID, &concept.Title); err != nil {
+			return nil, nil, err
+		}
+		concepts = append(concepts, concept)
+	}
+
+	return criteria, concepts, conceptRows.Err()
+}
+
+func loadMatrixCriteria(tenantID string, matrixID string) ([]matrixCriterion, error) {
+	criteriaRows, err := tenantManagement.DB.Query(`
+		SELECT id, title, criteria_multiplier
+		FROM st_schema.matrix_criteria
+		WHERE matrix_id = $1 AND tenant_id = $2
+		ORDER BY title
+	`, matrixID, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer criteriaRows.Close()
+
+	var criteria []matrixCriterion
+	for criteriaRows.Next() {
+		var criterion matrixCriterion
+		if err := criteriaRows.Scan(&criterion.ID, &criterion.Title, &criterion.Weight); err != nil {
+			return nil, err
+		}
+		criteria = append(criteria, criterion)
+	}
+
+	return criteria, criteriaRows.Err()
+}
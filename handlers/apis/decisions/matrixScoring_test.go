@@ -0,0 +1,190 @@
+package decisions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScoreMatrix(t *testing.T) {
+	criteria := []matrixCriterion{
+		{ID: "cost", Title: "Cost", Weight: 2},
+		{ID: "speed", Title: "Speed", Weight: 1},
+	}
+	concepts := []matrixConcept{{ID: "a", Title: "A"}, {ID: "b", Title: "B"}, {ID: "c", Title: "C"}}
+
+	tests := []struct {
+		name          string
+		concepts      []matrixConcept
+		ratings       matrixRatings
+		wantOrder     []string
+		wantRanks     []int
+		wantTotals    []float64
+		wantMissing   []int
+		wantWinner    *string
+		wantMargin    float64
+		wantMarginPct float64
+	}{
+		{
+			name:     "no concepts",
+			concepts: nil,
+			ratings:  matrixRatings{},
+		},
+		{
+			name:       "single concept wins",
+			concepts:   concepts[:1],
+			ratings:    matrixRatings{"cost": {"a": 1}, "speed": {"a": 1}},
+			wantOrder:  []string{"a"},
+			wantRanks:  []int{1},
+			wantTotals: []float64{3},
+			wantWinner: stringPtr("a"),
+		},
+		{
+			name:          "ranked by weighted total",
+			concepts:      concepts,
+			ratings:       matrixRatings{"cost": {"a": 5, "b": 3, "c": 1}, "speed": {"a": 1, "b": 4, "c": 2}},
+			wantOrder:     []string{"a", "b", "c"},
+			wantRanks:     []int{1, 2, 3},
+			wantTotals:    []float64{11, 10, 4},
+			wantWinner:    stringPtr("a"),
+			wantMargin:    1,
+			wantMarginPct: 100.0 / 11,
+		},
+		{
+			name:       "tied first places have no winner",
+			concepts:   concepts,
+			ratings:    matrixRatings{"cost": {"a": 2, "b": 1, "c": 1}, "speed": {"a": 1, "b": 3, "c": 1}},
+			wantOrder:  []string{"a", "b", "c"},
+			wantRanks:  []int{1, 1, 3},
+			wantTotals: []float64{5, 5, 3},
+		},
+		{
+			name:        "missing ratings count as 0",
+			concepts:    concepts[:2],
+			ratings:     matrixRatings{"cost": {"a": 1, "b": 1}, "speed": {"b": 2}},
+			wantOrder:   []string{"b", "a"},
+			wantRanks:   []int{1, 2},
+			wantTotals:  []float64{4, 2},
+			wantMissing: []int{0, 1},
+			wantWinner:  stringPtr("b"),
+			wantMargin:  2,
+			// Margin relative to the winner total
+			wantMarginPct: 50,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scores := scoreMatrix(criteria, tt.concepts, tt.ratings, false)
+
+			assert.Equal(t, 3.0, scores.TotalWeight)
+			assert.Len(t, scores.Concepts, len(tt.wantOrder))
+			for i, concept := range scores.Concepts {
+				assert.Equal(t, tt.wantOrder[i], concept.ConceptID)
+				assert.Equal(t, tt.wantRanks[i], concept.Rank)
+				assert.InDelta(t, tt.wantTotals[i], concept.WeightedTotal, 1e-9)
+				assert.InDelta(t, tt.wantTotals[i]/3, concept.NormalizedScore, 1e-9)
+				if tt.wantMissing != nil {
+					assert.Equal(t, tt.wantMissing[i], concept.MissingRatings)
+				}
+			}
+			assert.Equal(t, tt.wantWinner, scores.WinnerID)
+			assert.InDelta(t, tt.wantMargin, scores.WinnerMargin, 1e-9)
+			assert.InDelta(t, tt.wantMarginPct, scores.MarginPercent, 1e-9)
+			assert.Nil(t, scores.Sensitivity)
+		})
+	}
+}
+
+func TestMatrixSensitivity(t *testing.T) {
+	tests := []struct {
+		name     string
+		criteria []matrixCriterion
+		ratings  matrixRatings
+		// By criteria ID, a nil flip weight means no weight >= 0 flips the winner
+		wantFlipWeight map[string]*float64
+		wantFlipsTo    map[string]*string
+		wantOrder      []string
+	}{
+		{
+			name: "closest flip first",
+			criteria: []matrixCriterion{
+				{ID: "cost", Weight: 2},
+				{ID: "speed", Weight: 1},
+			},
+			// a = 11, b = 10
+			ratings:        matrixRatings{"cost": {"a": 5, "b": 3}, "speed": {"a": 1, "b": 4}},
+			wantFlipWeight: map[string]*float64{"cost": floatPtr(1.5), "speed": floatPtr(4.0 / 3)},
+			wantFlipsTo:    map[string]*string{"cost": stringPtr("b"), "speed": stringPtr("b")},
+			wantOrder:      []string{"speed", "cost"},
+		},
+		{
+			name: "flip below a weight of 0 is ignored",
+			criteria: []matrixCriterion{
+				{ID: "cost", Weight: 1},
+				{ID: "speed", Weight: 1},
+			},
+			// a = 12, b = 2, lowering cost to 0 still leaves a ahead
+			ratings:        matrixRatings{"cost": {"a": 2, "b": 1}, "speed": {"a": 10, "b": 1}},
+			wantFlipWeight: map[string]*float64{"cost": nil, "speed": nil},
+			wantFlipsTo:    map[string]*string{"cost": nil, "speed": nil},
+			wantOrder:      []string{"cost", "speed"},
+		},
+		{
+			name: "equal ratings never flip",
+			criteria: []matrixCriterion{
+				{ID: "cost", Weight: 1},
+				{ID: "speed", Weight: 1},
+			},
+			// a = 5, b = 3, the whole difference comes from speed so b ties at a speed weight of 0
+			ratings:        matrixRatings{"cost": {"a": 2, "b": 2}, "speed": {"a": 3, "b": 1}},
+			wantFlipWeight: map[string]*float64{"cost": nil, "speed": floatPtr(0)},
+			wantFlipsTo:    map[string]*string{"cost": nil, "speed": stringPtr("b")},
+			wantOrder:      []string{"speed", "cost"},
+		},
+		{
+			name: "nearest challenger, not the runner-up",
+			criteria: []matrixCriterion{
+				{ID: "cost", Weight: 1},
+				{ID: "speed", Weight: 1},
+			},
+			// a = 10, b = 9, c = 8. Lowering cost ties c at 0.75 before b at 0
+			ratings:        matrixRatings{"cost": {"a": 9, "b": 8, "c": 1}, "speed": {"a": 1, "b": 1, "c": 7}},
+			wantFlipWeight: map[string]*float64{"cost": floatPtr(0.75), "speed": floatPtr(4.0 / 3)},
+			wantFlipsTo:    map[string]*string{"cost": stringPtr("c"), "speed": stringPtr("c")},
+			wantOrder:      []string{"cost", "speed"},
+		},
+	}
+
+	concepts := []matrixConcept{{ID: "a"}, {ID: "b"}, {ID: "c"}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scores := scoreMatrix(tt.criteria, concepts, tt.ratings, true)
+
+			var order []string
+			for _, result := range scores.Sensitivity {
+				order = append(order, result.CriteriaID)
+
+				want := tt.wantFlipWeight[result.CriteriaID]
+				if want == nil {
+					assert.Nil(t, result.FlipWeight, result.CriteriaID)
+					assert.Nil(t, result.WeightChange, result.CriteriaID)
+				} else if assert.NotNil(t, result.FlipWeight, result.CriteriaID) {
+					assert.InDelta(t, *want, *result.FlipWeight, 1e-9)
+					assert.InDelta(t, *want-result.Weight, *result.WeightChange, 1e-9)
+					assert.InDelta(t, (*want-result.Weight)/result.Weight*100, *result.ChangePercent, 1e-9)
+				}
+				assert.Equal(t, tt.wantFlipsTo[result.CriteriaID], result.FlipsTo, result.CriteriaID)
+			}
+			assert.Equal(t, tt.wantOrder, order)
+		})
+	}
+}
+
+func stringPtr(value string) *string {
+	return &value
+}
+
+func floatPtr(value float64) *float64 {
+	return &value
+}
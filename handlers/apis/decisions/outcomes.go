@@ -0,0 +1,180 @@
+package decisions
+
+import (
+	"database/sql"
+	"log"
+	"sort"
+
+	"sententiawebapi/handlers/apis/tenantManagement"
+	models "sententiawebapi/handlers/models"
+)
+
+// dbExecutor is implemented by *sql.DB and *sql.Tx
+type dbExecutor interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// computeOutcome ranks the options by their weighted sum, tied leaders have no suggested option
+func computeOutcome(options []models.DecisionOptionScore) models.DecisionOutcome {
+	sort.SliceStable(options, func(i, j int) bool { return options[i].WeightedSum > options[j].WeightedSum })
+
+	outcome := models.DecisionOutcome{Options: options}
+	if len(options) == 0 {
+		return outcome
+	}
+	if len(options) > 1 {
+		outcome.Margin = options[0].WeightedSum - options[1].WeightedSum
+		if outcome.Margin == 0 {
+			return outcome
+		}
+	}
+
+	outcome.SuggestedOption = &options[0].ID
+	outcome.SuggestedTitle = &options[0].Title
+	return outcome
+}
+
+func loadTBarOutcome(db dbExecutor, tenantID string, tbarID string) (*models.DecisionOutcome, error) {
+	rows, err := db.Query(`
+		SELECT o.id, o.option_title, COALESCE(SUM(a.argument_weight), 0), COUNT(a.id)
+		FROM st_schema.tbar_options o
+		LEFT JOIN st_schema.tbar_arguments a ON a.option_id = o.id AND a.tenant_id = o.tenant_id
+		WHERE o.tbar_analysis_id = $1 AND o.tenant_id = $2
+		GROUP BY o.id, o.option_title
+		ORDER BY o.id
+	`, tbarID, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	options := []models.DecisionOptionScore{}
+	for rows.Next() {
+		var option models.DecisionOptionScore
+		if err := rows.Scan(&option.ID, &option.Title, &option.WeightedSum, &option.Arguments); err != nil {
+			return nil, err
+		}
+		options = append(options, option)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	outcome := computeOutcome(options)
+	return &outcome, nil
+}
+
+func loadPncOutcome(db dbExecutor, tenantID string, pncID string) (*models.DecisionOutcome, error) {
+	rows, err := db.Query(`
+		SELECT side, COALESCE(SUM(argument_weight), 0), COUNT(*)
+		FROM st_schema.pnc_arguments
+		WHERE pnc_id = $1 AND tenant_id = $2
+		GROUP BY side
+	`, pncID, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Both sides are listed, a side without arguments weighs 0
+	options := []models.DecisionOptionScore{{ID: "pro", Title: "pro"}, {ID: "con", Title: "con"}}
+	for rows.Next() {
+		var side string
+		var weightedSum, arguments int
+		if err := rows.Scan(&side, &weightedSum, &arguments); err != nil {
+			return nil, err
+		}
+		for i := range options {
+			if options[i].ID == side {
+				options[i].WeightedSum = weightedSum
+				options[i].Arguments = arguments
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	outcome := computeOutcome(options)
+	return &outcome, nil
+}
+
+// syncTBarBetterOption sets the better option to the computed one when the analysis keeps it in sync.
+// It returns whether the analysis was synced and the better option it was set to, nil on a tie.
+func syncTBarBetterOption(db dbExecutor, tenantID string, tbarID string) (bool, *string, error) {
+	var autoBetterOption bool
+	err := db.QueryRow(`
+		SELECT COALESCE(auto_better_option, false) FROM st_schema.tbar_analysis WHERE id = $1 AND tenant_id = $2
+	`, tbarID, tenantID).Scan(&autoBetterOption)
+	if err == sql.ErrNoRows || (err == nil && !autoBetterOption) {
+		return false, nil, nil
+	}
+	if err != nil {
+		return false, nil, err
+	}
+
+	outcome, err := loadTBarOutcome(db, tenantID, tbarID)
+	if err != nil {
+		return false, nil, err
+	}
+
+	_, err = db.Exec(`
+		UPDATE st_schema.tbar_analysis SET tbar_better_option = $1, updated_at = NOW() WHERE id = $2 AND tenant_id = $3
+	`, outcome.SuggestedTitle, tbarID, tenantID)
+	if err != nil {
+		return false, nil, err
+	}
+
+	return true, outcome.SuggestedTitle, nil
+}
+
+func syncPncBetterOption(db dbExecutor, tenantID string, pncID string) (bool, *string, error) {
+	var autoBetterOption bool
+	err := db.QueryRow(`
+		SELECT COALESCE(auto_better_option, false) FROM st_schema.pnc_analysis WHERE id = $1 AND tenant_id = $2
+	`, pncID, tenantID).Scan(&autoBetterOption)
+	if err == sql.ErrNoRows || (err == nil && !autoBetterOption) {
+		return false, nil, nil
+	}
+	if err != nil {
+		return false, nil, err
+	}
+
+	outcome, err := loadPncOutcome(db, tenantID, pncID)
+	if err != nil {
+		return false, nil, err
+	}
+
+	_, err = db.Exec(`
+		UPDATE st_schema.pnc_analysis SET better_option = $1, updated_at = NOW() WHERE id = $2 AND tenant_id = $3
+	`, outcome.SuggestedTitle, pncID, tenantID)
+	if err != nil {
+		return false, nil, err
+	}
+
+	return true, outcome.SuggestedTitle, nil
+}
+
+// syncTBarBetterOptionOfOption syncs the T-bar of an option after one of its arguments changed.
+// The argument change is already saved, a failed sync is only logged.
+func syncTBarBetterOptionOfOption(tenantID string, optionID string) {
+	var tbarID string
+	err := tenantManagement.DB.QueryRow(`
+		SELECT tbar_analysis_id FROM st_schema.tbar_options WHERE id = $1 AND tenant_id = $2
+	`, optionID, tenantID).Scan(&tbarID)
+	if err == nil {
+		_, _, err = syncTBarBetterOption(tenantManagement.DB, tenantID, tbarID)
+	}
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("ERROR: Failed to sync the TBar better option: %v", err)
+	}
+}
+
+// syncPncBetterOptionAfterArgument syncs the analysis after one of its arguments changed, a failed sync is only logged
+func syncPncBetterOptionAfterArgument(tenantID string, pncID string) {
+	if _, _, err := syncPncBetterOption(tenantManagement.DB, tenantID, pncID); err != nil {
+		log.Printf("ERROR: Failed to sync the PNC better option: %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package decisions
+
+import (
+	"testing"
+
+	models "sententiawebapi/handlers/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComputeOutcome(t *testing.T) {
+	tests := []struct {
+		name          string
+		options       []models.DecisionOptionScore
+		wantOrder     []string
+		wantMargin    int
+		wantSuggested *string
+	}{
+		{
+			name:    "no options",
+			options: []models.DecisionOptionScore{},
+		},
+		{
+			name:          "single option is suggested",
+			options:       []models.DecisionOptionScore{{ID: "a", Title: "A", WeightedSum: 3}},
+			wantOrder:     []string{"a"},
+			wantSuggested: stringPtr("a"),
+		},
+		{
+			name: "highest weighted sum first",
+			options: []models.DecisionOptionScore{
+				{ID: "a", Title: "A", WeightedSum: 2},
+				{ID: "b", Title: "B", WeightedSum: 7},
+				{ID: "c", Title: "C", WeightedSum: 5},
+			},
+			wantOrder:     []string{"b", "c", "a"},
+			wantMargin:    2,
+			wantSuggested: stringPtr("b"),
+		},
+		{
+			name: "tied leaders have no suggestion",
+			options: []models.DecisionOptionScore{
+				{ID: "a", Title: "A", WeightedSum: 4},
+				{ID: "b", Title: "B", WeightedSum: 4},
+			},
+			wantOrder: []string{"a", "b"},
+		},
+		{
+			name: "negative sums",
+			options: []models.DecisionOptionScore{
+				{ID: "a", Title: "A", WeightedSum: -3},
+				{ID: "b", Title: "B", WeightedSum: -1},
+			},
+			wantOrder:     []string{"b", "a"},
+			wantMargin:    2,
+			wantSuggested: stringPtr("b"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outcome := computeOutcome(tt.options)
+
+			var order []string
+			for _, option := range outcome.Options {
+				order = append(order, option.ID)
+			}
+			assert.Equal(t, tt.wantOrder, order)
+			assert.Equal(t, tt.wantMargin, outcome.Margin)
+			assert.Equal(t, tt.wantSuggested, outcome.SuggestedOption)
+			if tt.wantSuggested != nil {
+				assert.Equal(t, outcome.Options[0].Title, *outcome.SuggestedTitle)
+			} else {
+				assert.Nil(t, outcome.SuggestedTitle)
+			}
+		})
+	}
+}
@@ -36,6 +36,9 @@ func NewPncAnalysis(c *gin.Context) {
 		return
 	}
 
+	// New decisions start as draft, the status is changed through the lifecycle
+	pnc.PNCStatus = utilities.Ptr(string(models.DecisionStateDraft))
+
 	row := tenantManagement.DB.QueryRow(`
 		INSERT INTO st_schema.pnc_analysis (
 			user_id,
@@ -45,12 +48,13 @@ func NewPncAnalysis(c *gin.Context) {
 			pnc_status,
 			category,
 			better_option,
+			auto_better_option,
 			assumptions,
 			final_decision,
 			architectural_decision_id,
 			implications,
 			project_id
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, COALESCE($8, false), $9, $10, $11, $12, $13) RETURNING id
 	`,
 		userID,
 		tenantID,
@@ -59,6 +63,7 @@ func NewPncAnalysis(c *gin.Context) {
 		pnc.PNCStatus,
 		pnc.Category,
 		pnc.BetterOption,
+		pnc.AutoBetterOption,
 		pnc.Assumptions,
 		pnc.FinalDecision,
 		pnc.ADecisionId,
@@ -118,6 +123,7 @@ func GetPncAnalysis(c *gin.Context) {
 			pnc_status,
 			category,
 			better_option,
+			COALESCE(auto_better_option, false),
 			assumptions,
 			final_decision,
 			architectural_decision_id,
@@ -138,6 +144,7 @@ func GetPncAnalysis(c *gin.Context) {
 		&pnc.PNCStatus,
 		&pnc.Category,
 		&pnc.BetterOption,
+		&pnc.AutoBetterOption,
 		&pnc.Assumptions,
 		&pnc.FinalDecision,
 		&pnc.ADecisionId,
@@ -155,9 +162,16 @@ func GetPncAnalysis(c *gin.Context) {
 		return
 	}
 
+	outcome, err := loadPncOutcome(tenantManagement.DB, tenantID, pnc.ID)
+	if err != nil {
+		log.Printf("ERROR: Failed to compute the analysis outcome: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error..."})
+		return
+	}
+
 	// Build and send the response
 	c.JSON(http.StatusOK, gin.H{
-		"data":    pnc,
+		"data":    models.PncAnalysisWithOutcome{PncAnalysis: pnc, Outcome: outcome},
 		"message": "PNC analysis retrieved successfully!",
 	})
 }
@@ -186,6 +200,7 @@ func GetAllPncAnalysis(c *gin.Context) {
 			pnc_status,
 			category,
 			better_option,
+			COALESCE(auto_better_option, false),
 			assumptions,
 			final_decision,
 			architectural_decision_id,
@@ -220,6 +235,7 @@ func GetAllPncAnalysis(c *gin.Context) {
 			&analysis.PNCStatus,
 			&analysis.Category,
 			&analysis.BetterOption,
+			&analysis.AutoBetterOption,
 			&analysis.Assumptions,
 			&analysis.FinalDecision,
 			&analysis.ADecisionId,
@@ -267,7 +283,7 @@ func UpdatePncAnalysis(c *gin.Context) {
 	}
 
 	// Get the analysis ID from the URL parameters
-	pnc.ID, ok = utilities.ValidateQueryParam(c, "pnc_id")
+	pncID, ok := utilities.ValidateQueryParam(c, "pnc_id")
 	if !ok {
 		return
 	}
@@ -279,6 +295,25 @@ func UpdatePncAnalysis(c *gin.Context) {
 		return
 	}
 
+	// The ID of the query is checked and updated, not one of the body
+	pnc.ID = pncID
+
+	tx, err := tenantManagement.DB.Begin()
+	if err != nil {
+		log.Printf("ERROR: Failed to start transaction: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+	defer tx.Rollback()
+
+	if !checkDecisionUpdate(c, tx, models.DecisionTypePnc, tenantID, pncID, decisionUpdate{
+		Status:        pnc.PNCStatus,
+		FinalDecision: pnc.FinalDecision,
+		Implications:  pnc.Implications,
+	}) {
+		return
+	}
+
 	// Use reflection to build the update query dynamically
 	v := reflect.ValueOf(pnc)
 	t := v.Type()
@@ -315,13 +350,13 @@ func UpdatePncAnalysis(c *gin.Context) {
 		AND
 			project_id = $%d
 		RETURNING
-			id, user_id, tenant_id, title, pnc_description, pnc_status, category, better_option, assumptions, final_decision, architectural_decision_id, implications, project_id
+			id, user_id, tenant_id, title, pnc_description, pnc_status, category, better_option, auto_better_option, assumptions, final_decision, architectural_decision_id, implications, project_id
 	`, setClause, argCounter, argCounter+1, argCounter+2)
 
 	args = append(args, pnc.ID, pnc.TenantID, pnc.ProjectID)
 
 	var updatedAnalysis models.PncAnalysis
-	err := tenantManagement.DB.QueryRow(query, args...).Scan(
+	err = tx.QueryRow(query, args...).Scan(
 		&updatedAnalysis.ID,
 		&updatedAnalysis.UserID,
 		&updatedAnalysis.TenantID,
@@ -330,6 +365,7 @@ func UpdatePncAnalysis(c *gin.Context) {
 		&updatedAnalysis.PNCStatus,
 		&updatedAnalysis.Category,
 		&updatedAnalysis.BetterOption,
+		&updatedAnalysis.AutoBetterOption,
 		&updatedAnalysis.Assumptions,
 		&updatedAnalysis.FinalDecision,
 		&updatedAnalysis.ADecisionId,
@@ -343,6 +379,20 @@ func UpdatePncAnalysis(c *gin.Context) {
 		return
 	}
 
+	if err := tx.Commit(); err != nil {
+		log.Printf("ERROR: Failed to commit the transaction: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	// A switched on sync replaces the better option with the computed one
+	synced, betterOption, err := syncPncBetterOption(tenantManagement.DB, tenantID, pnc.ID)
+	if err != nil {
+		log.Printf("ERROR: Failed to sync the PNC better option: %v", err)
+	} else if synced {
+		updatedAnalysis.BetterOption = betterOption
+	}
+
 	// Return the updated PNC analysis data
 	c.JSON(http.StatusOK, gin.H{
 		"data": gin.H{
@@ -408,6 +458,11 @@ func DeletePncAnalysis(c *gin.Context) {
 		return
 	}
 
+	if !checkDecisionDelete(c, tx, models.DecisionTypePnc, pnc.TenantID, pnc.ID) {
+		tx.Rollback()
+		return
+	}
+
 	// Delete the PNC analysis
 	_, err = tx.Exec("DELETE FROM st_schema.pnc_analysis WHERE id = $1 AND tenant_id = $2 AND project_id = $3", pnc.ID, pnc.TenantID, pnc.ProjectID)
 	if err != nil {
@@ -490,6 +545,8 @@ func NewPncArgument(c *gin.Context) {
 		return
 	}
 
+	syncPncBetterOptionAfterArgument(tenantID, pncArgument.PncID)
+
 	data := map[string]interface{}{
 		"id":      pncArgument.ID,
 		"details": pncArgument,
@@ -677,6 +734,8 @@ func UpdatePncArgument(c *gin.Context) {
 		return
 	}
 
+	syncPncBetterOptionAfterArgument(tenantID, updatedArgument.PncID)
+
 	// Return the updated PNC argument data
 	c.JSON(http.StatusOK, gin.H{
 		"data": gin.H{
@@ -736,6 +795,8 @@ func DeletePncArgument(c *gin.Context) {
 		return
 	}
 
+	syncPncBetterOptionAfterArgument(tenantID, deletedArgument.PncID)
+
 	// Respond with a success message and details of the deleted argument
 	c.JSON(http.StatusOK, gin.H{
 		"data": gin.H{
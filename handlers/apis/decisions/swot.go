@@ -39,6 +39,9 @@ func NewSwot(c *gin.Context) {
 		return
 	}
 
+	// New decisions start as draft, the status is changed through the lifecycle
+	swot.SwotStatus = utilities.Ptr(string(models.DecisionStateDraft))
+
 	row := tenantManagement.DB.QueryRow(
 		`INSERT INTO st_schema.swot_analysis (
 			user_id,
@@ -247,8 +250,7 @@ func UpdateSwot(c *gin.Context) {
 		return
 	}
 
-	swot.ID = new(string)
-	*swot.ID, ok = utilities.ValidateQueryParam(c, "swot_id")
+	swotID, ok := utilities.ValidateQueryParam(c, "swot_id")
 	if !ok {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "SWOT ID is required"})
 		return
@@ -260,6 +262,25 @@ func UpdateSwot(c *gin.Context) {
 		return
 	}
 
+	// The ID of the query is checked and updated, not one of the body
+	swot.ID = &swotID
+
+	tx, err := tenantManagement.DB.Begin()
+	if err != nil {
+		log.Printf("ERROR: Failed to start transaction: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update the SWOT analysis"})
+		return
+	}
+	defer tx.Rollback()
+
+	if !checkDecisionUpdate(c, tx, models.DecisionTypeSwot, tenantID, swotID, decisionUpdate{
+		Status:        swot.SwotStatus,
+		FinalDecision: swot.FinalDecision,
+		Implications:  swot.Implications,
+	}) {
+		return
+	}
+
 	v := reflect.ValueOf(swot)
 	t := v.Type()
 
@@ -305,7 +326,7 @@ func UpdateSwot(c *gin.Context) {
 
 	args = append(args, swot.ID, swot.TenantID, swot.ProjectID)
 
-	err := tenantManagement.DB.QueryRow(query, args...).Scan(
+	err = tx.QueryRow(query, args...).Scan(
 		&swot.ID,
 		&swot.Title,
 		&swot.SwotDescription,
@@ -326,6 +347,12 @@ func UpdateSwot(c *gin.Context) {
 		return
 	}
 
+	if err := tx.Commit(); err != nil {
+		log.Printf("ERROR: Failed to commit the transaction: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update the SWOT analysis"})
+		return
+	}
+
 	data := map[string]interface{}{
 		"details": swot,
 	}
@@ -367,6 +394,11 @@ func DeleteSwot(c *gin.Context) {
 		return
 	}
 
+	if !checkDecisionDelete(c, tx, models.DecisionTypeSwot, tenantID, *swot.ID) {
+		tx.Rollback()
+		return
+	}
+
 	err = tx.QueryRow(
 		`DELETE FROM st_schema.swot_analysis
 		 WHERE id = $1
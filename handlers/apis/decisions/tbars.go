@@ -1,6 +1,5 @@
 package decisions
 
-// TODO: Calculate the better option and automatically set every time a new argument is added or updated or removed
 // TODO: Simplify handling paramater lookup and validation
 // TODO: Cleanup the code and remove unnecessary comments, remove and improve error and HTTP response, everything should be logged to to syslog
 // TODO: Make sure to load all the parameter values into the data object file rather than declaring as vars
@@ -47,6 +46,9 @@ func NewTBar(c *gin.Context) {
 		return
 	}
 
+	// New decisions start as draft, the status is changed through the lifecycle
+	analysisWithOptions.TBarStatus = utilities.Ptr(string(models.DecisionStateDraft))
+
 	tx, err := tenantManagement.DB.Begin()
 	if err != nil {
 		log.Printf("ERROR: %v", err)
@@ -67,13 +69,14 @@ func NewTBar(c *gin.Context) {
 				tbar_status,
 				tbar_category,
 				tbar_better_option,
+				auto_better_option,
 				assumptions,
 				final_decision,
 				architectural_decision_id,
 				implications
 			)
 		VALUES
-			($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+			($1, $2, $3, $4, $5, $6, $7, $8, COALESCE($9, false), $10, $11, $12, $13)
 		RETURNING id
 	`,
 		analysisWithOptions.UserID,
@@ -84,6 +87,7 @@ func NewTBar(c *gin.Context) {
 		analysisWithOptions.TBarStatus,
 		analysisWithOptions.TBarCategory,
 		analysisWithOptions.TBarBetterOption,
+		analysisWithOptions.AutoBetterOption,
 		analysisWithOptions.Assumptions,
 		analysisWithOptions.FinalDecision,
 		analysisWithOptions.ADecisionId,
@@ -345,6 +349,7 @@ func GetTBar(c *gin.Context) {
 			tbar_status,
 			tbar_category,
 			tbar_better_option,
+			COALESCE(auto_better_option, false),
 			assumptions,
 			final_decision,
 			architectural_decision_id,
@@ -370,6 +375,7 @@ func GetTBar(c *gin.Context) {
 		&details.TBarStatus,
 		&details.TBarCategory,
 		&details.TBarBetterOption,
+		&details.AutoBetterOption,
 		&details.Assumptions,
 		&details.FinalDecision,
 		&details.ADecisionId,
@@ -418,6 +424,13 @@ func GetTBar(c *gin.Context) {
 		options = append(options, option)
 	}
 
+	outcome, err := loadTBarOutcome(tenantManagement.DB, tenantID, tbarID)
+	if err != nil {
+		log.Printf("ERROR: Failed to compute the TBar outcome: %v", err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve TBar outcome"})
+		return
+	}
+
 	// Create the response data
 	responseData := gin.H{
 		"data": gin.H{
@@ -431,6 +444,7 @@ func GetTBar(c *gin.Context) {
 					"TBarStatus":                details.TBarStatus,
 					"TBarCategory":              details.TBarCategory,
 					"TBarBetterOption":          details.TBarBetterOption,
+					"auto_better_option":        details.AutoBetterOption,
 					"assumptions":               details.Assumptions,
 					"final_decision":            details.FinalDecision,
 					"architectural_decision_id": details.ADecisionId,
@@ -439,6 +453,7 @@ func GetTBar(c *gin.Context) {
 				},
 				"id":      details.ID,
 				"options": options,
+				"outcome": outcome,
 			},
 		},
 		"message": "TBar analysis retrieved successfully!",
@@ -453,13 +468,14 @@ type TBarOption struct {
 	Title string `json:"title"`
 }
 
-// Modify updateData struct
-var updateData struct {
+// tbarUpdate is the payload of UpdateTBar, bound per request
+type tbarUpdate struct {
 	TBarTitle        *string     `json:"tbar_title" db:"tbar_title"`
 	TBarDescription  *string     `json:"tbar_description" db:"tbar_description"`
 	TBarStatus       *string     `json:"tbar_status" db:"tbar_status"`
 	TBarCategory     *string     `json:"tbar_category" db:"tbar_category"`
 	TBarBetterOption *string     `json:"tbar_better_option" db:"tbar_better_option"`
+	AutoBetterOption *bool       `json:"auto_better_option" db:"auto_better_option"`
 	Assumptions      *string     `json:"assumptions" db:"assumptions"`
 	FinalDecision    *string     `json:"final_decision" db:"final_decision"`
 	ADecisionId      *string     `json:"architectural_decision_id" db:"architectural_decision_id"`
@@ -488,6 +504,7 @@ func UpdateTBar(c *gin.Context) {
 		return
 	}
 
+	var updateData tbarUpdate
 	if err := c.ShouldBindJSON(&updateData); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload"})
 		return
@@ -501,6 +518,15 @@ func UpdateTBar(c *gin.Context) {
 		return
 	}
 
+	if !checkDecisionUpdate(c, tx, models.DecisionTypeTChart, tenantID, tbarID, decisionUpdate{
+		Status:        updateData.TBarStatus,
+		FinalDecision: updateData.FinalDecision,
+		Implications:  updateData.Implications,
+	}) {
+		tx.Rollback()
+		return
+	}
+
 	// Update TBar analysis fields using reflection
 	v := reflect.ValueOf(updateData)
 	t := v.Type()
@@ -541,7 +567,7 @@ func UpdateTBar(c *gin.Context) {
 			AND
 				id = $%d
 			RETURNING
-				id, user_id, tenant_id, tbar_title, tbar_description, tbar_status, tbar_category, tbar_better_option, assumptions, final_decision, architectural_decision_id, implications, updated_at, project_id
+				id, user_id, tenant_id, tbar_title, tbar_description, tbar_status, tbar_category, tbar_better_option, auto_better_option, assumptions, final_decision, architectural_decision_id, implications, updated_at, project_id
 		`, setClause, argCounter, argCounter+1, argCounter+2)
 
 		args = append(args, tenantID, projectID, tbarID)
@@ -555,6 +581,7 @@ func UpdateTBar(c *gin.Context) {
 			&updatedAnalysis.TBarStatus,
 			&updatedAnalysis.TBarCategory,
 			&updatedAnalysis.TBarBetterOption,
+			&updatedAnalysis.AutoBetterOption,
 			&updatedAnalysis.Assumptions,
 			&updatedAnalysis.FinalDecision,
 			&updatedAnalysis.ADecisionId,
@@ -603,6 +630,18 @@ func UpdateTBar(c *gin.Context) {
 		}
 	}
 
+	// Renamed options and a switched on sync change the better option
+	synced, betterOption, err := syncTBarBetterOption(tx, tenantID, tbarID)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("Failed to sync the better option: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update the TBar analysis"})
+		return
+	}
+	if synced && updatedAnalysis.ID != "" {
+		updatedAnalysis.TBarBetterOption = betterOption
+	}
+
 	// Commit the transaction
 	if err = tx.Commit(); err != nil {
 		log.Printf("Failed to commit transaction: %v", err)
@@ -754,6 +793,11 @@ func DeleteTBar(c *gin.Context) {
 		})
 	}
 
+	if !checkDecisionDelete(c, tx, models.DecisionTypeTChart, tenantID, tbarID) {
+		tx.Rollback()
+		return
+	}
+
 	// Delete the TBar itself
 	_, err = tx.Exec(`
         DELETE FROM st_schema.tbar_analysis
@@ -833,6 +877,8 @@ func NewTBarArgument(c *gin.Context) {
 		return
 	}
 
+	syncTBarBetterOptionOfOption(tenantID, argument.OptionID)
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "TBar argument was created successfully",
 		"data": gin.H{
@@ -988,6 +1034,8 @@ func UpdateTBarArgument(c *gin.Context) {
 		return
 	}
 
+	syncTBarBetterOptionOfOption(tenantID, argument.OptionID)
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "TBar argument was updated successfully",
 		"data": gin.H{
@@ -1042,6 +1090,8 @@ func DeleteTBarArgument(c *gin.Context) {
 		return
 	}
 
+	syncTBarBetterOptionOfOption(tenantID, argument.OptionID)
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "TBar argument deleted successfully",
 		"data": gin.H{
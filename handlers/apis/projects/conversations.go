@@ -7,6 +7,7 @@ package projects
 // 3. GetConversations - Retrieves all conversations from the 'conversation' table for a given project ID and user ID.
 // 4. UpdateConversation - Updates a conversation in the 'conversation' table based on the provided project ID, conversation ID, and user ID, setting a new 'conversation_config_template_id'.
 // 5. DeleteConversation - Deletes a conversation from the 'conversation' table using the provided user ID, project ID, and conversation ID.
+// 6. BranchConversation - Creates a new conversation continuing from an earlier assistant message of an existing conversation.
 
 // Local Functions are:
 // 1. checkExistingTemplate - Checks if a template already exists for this source ID and user.
@@ -139,7 +140,8 @@ func NewConversation(c *gin.Context) {
 		}
 	}
 
-	// Insert the new conversation into the database directly without a transaction
+	// Insert the new conversation into the database directly without a transaction.
+	// The conversation is pinned to the current template version, later template edits don't change it.
 	row := tenantManagement.DB.QueryRow(`
         INSERT INTO
             st_schema.conversation (
@@ -152,10 +154,12 @@ func NewConversation(c *gin.Context) {
 				agent_name,
                 title,
                 conversation_type,
-                description
+                description,
+				template_version
             )
         VALUES
-            ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+            ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10,
+			(SELECT MAX(version) FROM st_schema.prompt_config_template_versions WHERE template_id = $6))
         RETURNING
             id,
             project_id,
@@ -169,7 +173,9 @@ func NewConversation(c *gin.Context) {
             conversation_type,
             created_at,
             updated_at,
-            description
+            description,
+            parent_conversation_id,
+            parent_message_id
         `,
 		conversationObject.UserID,
 		conversationObject.TenantID,
@@ -197,6 +203,8 @@ func NewConversation(c *gin.Context) {
 		&conversationObject.CreatedAt,
 		&conversationObject.UpdatedAt,
 		&conversationObject.Description,
+		&conversationObject.ParentConversationId,
+		&conversationObject.ParentMessageId,
 	)
 	if err != nil {
 		log.Printf("Error scanning row: %v", err)
@@ -266,7 +274,9 @@ func GetConversation(c *gin.Context) {
 			conversation_type,
 			created_at,
 			updated_at,
-			description
+			description,
+			parent_conversation_id,
+			parent_message_id
 		FROM
 			st_schema.conversation
 		WHERE
@@ -292,6 +302,8 @@ func GetConversation(c *gin.Context) {
 		&conversation.CreatedAt,
 		&conversation.UpdatedAt,
 		&conversation.Description,
+		&conversation.ParentConversationId,
+		&conversation.ParentMessageId,
 	)
 
 	if err != nil {
@@ -355,7 +367,9 @@ func GetConversations(c *gin.Context) {
             conversation_type,
             created_at,
             updated_at,
-            description
+            description,
+            parent_conversation_id,
+            parent_message_id
         FROM
             st_schema.conversation
         WHERE
@@ -399,6 +413,8 @@ func GetConversations(c *gin.Context) {
 			&conversation.CreatedAt,
 			&conversation.UpdatedAt,
 			&conversation.Description,
+			&conversation.ParentConversationId,
+			&conversation.ParentMessageId,
 		)
 		if err != nil {
 			if isDevelopmentEnvironment() {
@@ -421,6 +437,8 @@ func GetConversations(c *gin.Context) {
 			"created_at":                    conversation.CreatedAt,
 			"updated_at":                    conversation.UpdatedAt,
 			"description":                   conversation.Description,
+			"parent_conversation_id":        conversation.ParentConversationId,
+			"parent_message_id":             conversation.ParentMessageId,
 		})
 	}
 	if err = rows.Err(); err != nil {
@@ -482,7 +500,9 @@ func UpdateConversation(c *gin.Context) {
 		UPDATE
 			st_schema.conversation
 		SET
-			conversation_config_template_id = $1, updated_at = NOW()
+			conversation_config_template_id = $1,
+			template_version = (SELECT MAX(version) FROM st_schema.prompt_config_template_versions WHERE template_id = $1),
+			updated_at = NOW()
 		WHERE
 			id = $2 AND tenant_id = $3 AND (project_id = $4 OR template_id = $4 OR community_template_id = $4)
 		RETURNING
@@ -498,7 +518,9 @@ func UpdateConversation(c *gin.Context) {
 			conversation_type,
 			created_at,
 			updated_at,
-			description
+			description,
+			parent_conversation_id,
+			parent_message_id
 	`
 
 	// Execute the update statement directly without a transaction
@@ -524,6 +546,8 @@ func UpdateConversation(c *gin.Context) {
 		&conversationObject.CreatedAt,
 		&conversationObject.UpdatedAt,
 		&conversationObject.Description,
+		&conversationObject.ParentConversationId,
+		&conversationObject.ParentMessageId,
 	)
 
 	if err != nil {
@@ -602,7 +626,9 @@ func DeleteConversation(c *gin.Context) {
             conversation_type,
             created_at,
             updated_at,
-            description
+            description,
+            parent_conversation_id,
+            parent_message_id
     `
 
 	// Execute the delete statement directly without a transaction
@@ -626,6 +652,8 @@ func DeleteConversation(c *gin.Context) {
 		&conversation.CreatedAt,
 		&conversation.UpdatedAt,
 		&conversation.Description,
+		&conversation.ParentConversationId,
+		&conversation.ParentMessageId,
 	)
 
 	if err != nil {
@@ -641,6 +669,150 @@ func DeleteConversation(c *gin.Context) {
 	})
 }
 
+type BranchConversationRequest struct {
+	MessageID string `json:"message_id" binding:"required"` // Assistant message (completion_prompt) the branch continues from
+	Title     string `json:"title"`
+}
+
+// BranchConversation forks the conversation from an earlier assistant message. The branch reuses
+// the OpenAI response of the message as previous_response_id, so the model sees the history up
+// to that message only. Messages inherited from a parent conversation can be branched as well,
+// the branch then records that parent as its parent conversation.
+func BranchConversation(c *gin.Context) {
+	userID, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	conversationID := c.Query("conversation_id")
+	if conversationID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Conversation ID is required"})
+		return
+	}
+
+	var branchReq BranchConversationRequest
+	if err := c.ShouldBindJSON(&branchReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Message ID is required"})
+		return
+	}
+
+	// The message has to be visible in the conversation, its own or inherited from a parent up to
+	// the fork point of the branch, the same segments the history shows (getHistorySegments)
+	var parentConversationID string
+	var responseID sql.NullString
+	err := tenantManagement.DB.QueryRow(`
+		WITH RECURSIVE chain AS (
+			SELECT id, parent_conversation_id, parent_message_id, NULL::timestamptz AS until
+			FROM st_schema.conversation
+			WHERE id = $1 AND tenant_id = $2
+			UNION ALL
+			SELECT parent.id, parent.parent_conversation_id, parent.parent_message_id, fork.created_at
+			FROM st_schema.conversation parent
+			JOIN chain ON parent.id = chain.parent_conversation_id
+			LEFT JOIN st_schema.completion_prompt fork ON fork.id = chain.parent_message_id
+			WHERE parent.tenant_id = $2
+		)
+		SELECT
+			message.conversation_id, message.response_id
+		FROM
+			st_schema.completion_prompt message
+		JOIN
+			chain ON chain.id = message.conversation_id
+		WHERE
+			message.id = $3 AND message.tenant_id = $2
+			AND (chain.until IS NULL OR message.created_at <= chain.until)
+	`, conversationID, tenantID, branchReq.MessageID).Scan(&parentConversationID, &responseID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found in the conversation"})
+		return
+	}
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	// Answers stored before branching existed and function call rounds have no response to continue
+	if !responseID.Valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Conversation cannot be branched from this message"})
+		return
+	}
+
+	var branch models.Conversation
+	err = tenantManagement.DB.QueryRow(`
+		INSERT INTO
+			st_schema.conversation (
+				user_id,
+				tenant_id,
+				project_id,
+				template_id,
+				community_template_id,
+				conversation_config_template_id,
+				agent_name,
+				title,
+				conversation_type,
+				description,
+				last_chat_completion_id,
+				parent_conversation_id,
+				parent_message_id,
+				template_version,
+				assistant_versions
+			)
+		SELECT
+			$1, tenant_id, project_id, template_id, community_template_id, conversation_config_template_id,
+			agent_name, COALESCE(NULLIF($2, ''), title || ' (branch)'), conversation_type, description,
+			$3, id, $4, template_version, assistant_versions
+		FROM
+			st_schema.conversation
+		WHERE
+			id = $5 AND tenant_id = $6
+		RETURNING
+			id,
+			project_id,
+			template_id,
+			community_template_id,
+			user_id,
+			tenant_id,
+			conversation_config_template_id,
+			agent_name,
+			title,
+			conversation_type,
+			created_at,
+			updated_at,
+			description,
+			parent_conversation_id,
+			parent_message_id
+	`, userID, branchReq.Title, responseID.String, branchReq.MessageID, parentConversationID, tenantID).Scan(
+		&branch.ID,
+		&branch.ProjectId,
+		&branch.TemplateId,
+		&branch.CommunityTemplateId,
+		&branch.UserID,
+		&branch.TenantID,
+		&branch.ConversationConfigurationId,
+		&branch.AgentName,
+		&branch.Title,
+		&branch.ConversationType,
+		&branch.CreatedAt,
+		&branch.UpdatedAt,
+		&branch.Description,
+		&branch.ParentConversationId,
+		&branch.ParentMessageId,
+	)
+	if err != nil {
+		log.Printf("Error creating conversation branch: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.DatabaseError})
+		return
+	}
+
+	branch.LastChatCompletionId = &responseID.String
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    branch,
+		"message": models.StatusSuccess,
+	})
+}
+
 func checkExistingTemplate(sourceID, tenantID string) (string, error) {
 	if sourceID == "" {
 		return "", nil
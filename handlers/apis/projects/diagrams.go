@@ -17,6 +17,7 @@ import (
 	"net/http"
 	"strings"
 
+	aiIndexing "sententiawebapi/handlers/apis/ai/indexing"
 	"sententiawebapi/handlers/apis/tenantManagement"
 	"sententiawebapi/handlers/models"
 	"sententiawebapi/utilities"
@@ -125,6 +126,15 @@ func NewDiagram(c *gin.Context) {
 		return
 	}
 
+	// Keep diagram_search in sync
+	aiIndexing.IndexDiagram(aiIndexing.DiagramRef{
+		Type:      models.ResourceGroupProject,
+		TenantID:  tenantID,
+		UserID:    userID,
+		ProjectID: projectID,
+		DiagramID: *diagram.ID,
+	}, diagram.Title, diagram.Design)
+
 	// Return the new diagram data
 	c.JSON(http.StatusOK, gin.H{
 		"data":    diagram,
@@ -399,6 +409,14 @@ func UpdateDiagram(c *gin.Context) {
 		return
 	}
 
+	aiIndexing.IndexDiagram(aiIndexing.DiagramRef{
+		Type:      models.ResourceGroupProject,
+		TenantID:  tenantID,
+		UserID:    userID,
+		ProjectID: projectID,
+		DiagramID: diagramID,
+	}, updatedDiagram.Title, updatedDiagram.Design)
+
 	// Return the updated diagram data
 	c.JSON(http.StatusOK, gin.H{
 		"data":    updatedDiagram,
@@ -634,6 +652,14 @@ func CloneDiagram(c *gin.Context) {
 		return
 	}
 
+	aiIndexing.IndexDiagram(aiIndexing.DiagramRef{
+		Type:      models.ResourceGroupProject,
+		TenantID:  tenantID,
+		UserID:    userID,
+		ProjectID: projectID,
+		DiagramID: *newDiagram.ID,
+	}, newDiagram.Title, newDiagram.Design)
+
 	c.JSON(http.StatusOK, gin.H{
 		"data":    newDiagram,
 		"message": "Diagram cloned successfully!",
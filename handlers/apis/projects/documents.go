@@ -15,6 +15,7 @@ import (
 	"log"
 	"net/http"
 
+	aiIndexing "sententiawebapi/handlers/apis/ai/indexing"
 	"sententiawebapi/handlers/apis/images"
 	"sententiawebapi/handlers/apis/tenantManagement"
 	"sententiawebapi/handlers/models"
@@ -83,6 +84,15 @@ func NewDocument(c *gin.Context) {
 		return
 	}
 
+	// Keep document_search in sync
+	aiIndexing.IndexDocument(aiIndexing.DocumentRef{
+		Type:       models.ResourceGroupProject,
+		TenantID:   tenantID,
+		UserID:     userID,
+		GroupID:    projectID,
+		DocumentID: *Document.ID,
+	}, Document.Content)
+
 	// Return the new document data
 	c.JSON(200, gin.H{
 		"data":    Document,
@@ -235,6 +245,14 @@ func CloneDocument(c *gin.Context) {
 		return
 	}
 
+	aiIndexing.IndexDocument(aiIndexing.DocumentRef{
+		Type:       models.ResourceGroupProject,
+		TenantID:   tenantID,
+		UserID:     userID,
+		GroupID:    projectID,
+		DocumentID: *newDocument.ID,
+	}, newDocument.Content)
+
 	c.JSON(http.StatusOK, gin.H{
 		"data":    newDocument,
 		"message": "Document cloned successfully!",
@@ -385,7 +403,7 @@ func GetDocuments(c *gin.Context) {
 
 func UpdateDocument(c *gin.Context) {
 	// Get the user ID from the context
-	_, tenantID, ok := utilities.ProcessIdentity(c)
+	userID, tenantID, ok := utilities.ProcessIdentity(c)
 	if !ok {
 		return
 	}
@@ -512,6 +530,14 @@ func UpdateDocument(c *gin.Context) {
 		return
 	}
 
+	aiIndexing.IndexDocument(aiIndexing.DocumentRef{
+		Type:       models.ResourceGroupProject,
+		TenantID:   tenantID,
+		UserID:     userID,
+		GroupID:    projectID,
+		DocumentID: documentID,
+	}, updatedDocument.Content)
+
 	// Return the updated document data
 	c.JSON(http.StatusOK, gin.H{
 		"data":    updatedDocument,
@@ -590,6 +616,13 @@ func DeleteDocument(c *gin.Context) {
 		return
 	}
 
+	aiIndexing.RemoveDocument(aiIndexing.DocumentRef{
+		Type:       models.ResourceGroupProject,
+		TenantID:   tenantID,
+		GroupID:    projectID,
+		DocumentID: documentID,
+	})
+
 	// Return a success response
 	c.JSON(http.StatusOK, gin.H{
 		"data":    document,
@@ -724,6 +757,14 @@ func NewDocumentFromTemplate(c *gin.Context) {
 		return
 	}
 
+	aiIndexing.IndexDocument(aiIndexing.DocumentRef{
+		Type:       models.ResourceGroupProject,
+		TenantID:   tenantID,
+		UserID:     userID,
+		GroupID:    projectID,
+		DocumentID: *document.ID,
+	}, document.Content)
+
 	c.JSON(http.StatusOK, gin.H{
 		"data":    document,
 		"message": "New project document created from template successfully!",
@@ -838,6 +879,14 @@ func NewDocumentFromPubTemplate(c *gin.Context) {
 		return
 	}
 
+	aiIndexing.IndexDocument(aiIndexing.DocumentRef{
+		Type:       models.ResourceGroupProject,
+		TenantID:   tenantID,
+		UserID:     userID,
+		GroupID:    projectID,
+		DocumentID: *document.ID,
+	}, document.Content)
+
 	c.JSON(http.StatusOK, gin.H{
 		"data":    document,
 		"message": "New project document created from public template successfully!",
@@ -8,6 +8,7 @@ import (
 	"sententiawebapi/handlers/apis/tenantManagement"
 	"sententiawebapi/handlers/models"
 	"sententiawebapi/utilities"
+	"slices"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -256,15 +257,20 @@ func (r *RequirementsApi) GetAll(db DBExecutor) ([]models.Requirement, error) {
 	return requirements, nil
 }
 
+// Allowed requirement values, also offered to the AI tools
+var (
+	RequirementCategories = []string{
+		"Design", "Development", "Deployment", "Business", "Compliance",
+		"Implementation", "Document", "Diagram", "Decision", "Strategy", "Tactical",
+	}
+	RequirementStatuses = []string{"Not Started", "In Progress", "Completed", "Delayed", "Blocked"}
+)
+
 func validateCategory(category *string) error {
 	if category == nil {
 		return nil
 	}
-	valid := map[string]struct{}{
-		"Design": {}, "Development": {}, "Deployment": {}, "Business": {}, "Compliance": {},
-		"Implementation": {}, "Document": {}, "Diagram": {}, "Decision": {}, "Strategy": {}, "Tactical": {},
-	}
-	if _, ok := valid[*category]; !ok {
+	if !slices.Contains(RequirementCategories, *category) {
 		return fmt.Errorf("invalid category: %s", *category)
 	}
 	return nil
@@ -274,10 +280,7 @@ func validateStatus(status *string) error {
 	if status == nil {
 		return nil
 	}
-	valid := map[string]struct{}{
-		"Not Started": {}, "In Progress": {}, "Completed": {}, "Delayed": {}, "Blocked": {},
-	}
-	if _, ok := valid[*status]; !ok {
+	if !slices.Contains(RequirementStatuses, *status) {
 		return fmt.Errorf("invalid status: %s", *status)
 	}
 	return nil
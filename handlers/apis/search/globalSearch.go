@@ -0,0 +1,427 @@
+package search
+
+// This package contains the tenant-wide search across all project entities.
+
+// Searched entities:
+// 1. project - st_schema.projects
+// 2. document - st_schema.project_documents (body taken from the indexed blocks)
+// 3. diagram - st_schema.diagrams (body taken from the diagram digest)
+// 4. tchart, pnc, swot, matrix - decision analyses
+// 5. requirement - st_schema.project_requirements
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	aiIndexing "sententiawebapi/handlers/apis/ai/indexing"
+	"sententiawebapi/handlers/apis/tenantManagement"
+	"sententiawebapi/handlers/models"
+	"sententiawebapi/utilities"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+
+	textSearchConfig = "english"
+
+	// Semantic matches below this similarity are treated as noise
+	minSemanticSimilarity = 0.35
+	semanticCandidates    = 200
+
+	rrfK = 60 // Reciprocal rank fusion constant, same as document_search
+)
+
+var entityTypes = []string{"project", "document", "diagram", "tchart", "pnc", "swot", "matrix", "requirement"}
+
+type SearchResult struct {
+	EntityType      string   `json:"entity_type"`
+	ID              string   `json:"id"`
+	ProjectID       string   `json:"project_id"`
+	ProjectTitle    *string  `json:"project_title"`
+	Title           string   `json:"title"`
+	TitleHighlight  string   `json:"title_highlight"`
+	Snippet         string   `json:"snippet"` // Matched fragments wrapped in <mark></mark>
+	UpdatedAt       *string  `json:"updated_at"`
+	Score           float64  `json:"score"`
+	SemanticMatched bool     `json:"semantic_matched"`
+	Distance        *float64 `json:"distance,omitempty"`
+}
+
+type TypeFacet struct {
+	EntityType string `json:"entity_type"`
+	Count      int    `json:"count"`
+}
+
+type ProjectFacet struct {
+	ProjectID    string  `json:"project_id"`
+	ProjectTitle *string `json:"project_title"`
+	Count        int     `json:"count"`
+}
+
+// searchVector is the weighted full-text vector of an entity. The GIN indexes in
+// migrations/global_search_indexes.sql are built on these exact expressions, keep them in sync.
+func searchVector(title string, body ...string) string {
+	parts := make([]string, len(body))
+	for i, column := range body {
+		parts[i] = fmt.Sprintf("COALESCE(%s, '')", column)
+	}
+
+	return fmt.Sprintf(`(setweight(to_tsvector('%[1]s', COALESCE(%[2]s, '')), 'A') || setweight(to_tsvector('%[1]s', %[3]s), 'B'))`,
+		textSearchConfig, title, strings.Join(parts, ` || E'\n' || `))
+}
+
+// searchTable is an entity type whose title and body are columns of a single table
+type searchTable struct {
+	entityType string
+	table      string
+	alias      string
+	projectID  string
+	title      string
+	updatedAt  string
+	body       []string
+}
+
+// textBranch selects the entities of the table that match the search phrase, rows are
+// (entity_type, id, project_id, title, body, updated_at, distance, vector)
+func (t searchTable) textBranch() string {
+	body := prefixColumns(t.alias, t.body)
+	vector := searchVector(t.alias+"."+t.title, body...)
+
+	return fmt.Sprintf(`
+		SELECT '%[1]s' AS entity_type, %[3]s.id, %[4]s AS project_id, COALESCE(%[3]s.%[5]s, '') AS title,
+			concat_ws(E'\n', %[6]s) AS body,
+			%[7]s AS updated_at, NULL::float8 AS distance, %[8]s AS vector
+		FROM %[2]s %[3]s
+		CROSS JOIN search
+		WHERE %[3]s.tenant_id = $1 AND %[8]s @@ search.query`,
+		t.entityType, t.table, t.alias, t.projectID, t.title, strings.Join(body, ", "), t.updatedAt, vector)
+}
+
+func decisionBody(description string) []string {
+	return []string{description, "assumptions", "final_decision", "implications"}
+}
+
+func prefixColumns(alias string, columns []string) []string {
+	prefixed := make([]string, len(columns))
+	for i, column := range columns {
+		prefixed[i] = alias + "." + column
+	}
+	return prefixed
+}
+
+// entitiesQuery returns the entities of the tenant matching the phrase, or the embedding when semantic.
+// Every branch filters on an indexed expression so the tenant is never scanned with to_tsvector.
+func entitiesQuery(semantic bool) string {
+	documentDistance, diagramDistance := "NULL::float8", "NULL::float8"
+	documentJoin, diagramJoin := "", ""
+	documentSemantic, diagramSemantic := "", ""
+	if semantic {
+		documentDistance, diagramDistance = "sd.distance", "sg.distance"
+		documentJoin = "LEFT JOIN semantic_documents sd ON sd.id = d.id"
+		diagramJoin = "LEFT JOIN semantic_diagrams sg ON sg.id = g.id"
+		documentSemantic = "OR d.id = ANY(ARRAY(SELECT id FROM semantic_documents))"
+		diagramSemantic = "OR g.id = ANY(ARRAY(SELECT id FROM semantic_diagrams))"
+	}
+
+	diagramVector := searchVector("g.title", "g.short_description", "g.diagram_digest")
+
+	branches := []string{
+		searchTable{"project", "st_schema.projects", "p", "p.id", "title", "p.updated_at::timestamptz", []string{"short_description", "description"}}.textBranch(),
+
+		// A document matches on its title or on one of its indexed blocks, the rank uses the whole body
+		fmt.Sprintf(`
+		SELECT 'document', d.id, d.project_id, COALESCE(d.title, ''),
+			COALESCE(blocks.body, ''),
+			d.updated_at::timestamptz, %[1]s,
+			setweight(to_tsvector('%[4]s', COALESCE(d.title, '')), 'A') || setweight(to_tsvector('%[4]s', COALESCE(blocks.body, '')), 'B')
+		FROM st_schema.project_documents d
+		CROSS JOIN search
+		LEFT JOIN LATERAL (
+			SELECT string_agg(v.content, E'\n' ORDER BY v.block_index) AS body
+			FROM st_schema.project_document_vectors v
+			WHERE v.tenant_id = d.tenant_id AND v.document_id = d.id
+		) blocks ON true
+		%[2]s
+		WHERE d.tenant_id = $1 AND (
+			to_tsvector('%[4]s', COALESCE(d.title, '')) @@ search.query
+			OR d.id = ANY(ARRAY(
+				SELECT v.document_id FROM st_schema.project_document_vectors v
+				WHERE v.tenant_id = $1 AND to_tsvector('%[4]s', v.content) @@ search.query
+			))
+			%[3]s
+		)`, documentDistance, documentJoin, documentSemantic, textSearchConfig),
+
+		fmt.Sprintf(`
+		SELECT 'diagram', g.id, g.project_id, COALESCE(g.title, ''),
+			concat_ws(E'\n', g.short_description, g.diagram_digest),
+			g.updated_at::timestamptz, %[1]s, %[4]s
+		FROM st_schema.diagrams g
+		CROSS JOIN search
+		%[2]s
+		WHERE g.tenant_id = $1 AND (%[4]s @@ search.query %[3]s)`, diagramDistance, diagramJoin, diagramSemantic, diagramVector),
+
+		searchTable{"tchart", "st_schema.tbar_analysis", "t", "t.project_id", "tbar_title", "t.updated_at::timestamptz", decisionBody("tbar_description")}.textBranch(),
+		searchTable{"pnc", "st_schema.pnc_analysis", "n", "n.project_id", "title", "n.updated_at::timestamptz", decisionBody("pnc_description")}.textBranch(),
+		searchTable{"swot", "st_schema.swot_analysis", "s", "s.project_id", "title", "s.updated_at::timestamptz", decisionBody("swot_description")}.textBranch(),
+		searchTable{"matrix", "st_schema.matrix_analysis", "m", "m.project_id", "title", "m.updated_at::timestamptz", decisionBody("matrix_description")}.textBranch(),
+		searchTable{"requirement", "st_schema.project_requirements", "r", "r.project_id", "title", "NULL::timestamptz", []string{"details"}}.textBranch(),
+	}
+
+	return strings.Join(branches, "\n\n\t\tUNION ALL\n")
+}
+
+// searchQuery computes the ranked matches once and returns the total, the page and both facets as one row.
+// $1 tenant ID, $2 search phrase, $3 query embedding (semantic only)
+func searchQuery(semantic bool, filter searchFilter, args []any, pageNum, pageSize int) (string, []any) {
+	var sb strings.Builder
+	sb.WriteString("WITH ")
+
+	if semantic {
+		sb.WriteString(fmt.Sprintf(`
+		semantic_documents AS (
+			SELECT hits.document_id AS id, MIN(hits.distance) AS distance
+			FROM (
+				SELECT v.document_id, v.embedding <#> $3::vector AS distance
+				FROM st_schema.project_document_vectors v
+				WHERE v.tenant_id = $1
+				ORDER BY distance
+				LIMIT %[1]d
+			) hits
+			WHERE hits.distance <= -%[2]f
+			GROUP BY hits.document_id
+		),
+		semantic_diagrams AS (
+			SELECT hits.id, hits.distance
+			FROM (
+				SELECT g.id, g.embedding <#> $3::vector AS distance
+				FROM st_schema.diagrams g
+				WHERE g.tenant_id = $1 AND g.embedding IS NOT NULL
+				ORDER BY distance
+				LIMIT %[1]d
+			) hits
+			WHERE hits.distance <= -%[2]f
+		),`, semanticCandidates, minSemanticSimilarity))
+	}
+
+	typeCondition, projectCondition, args := filter.conditions(args)
+	args = append(args, pageSize, (pageNum-1)*pageSize)
+
+	// Facets ignore their own filter so the client can show counts for the other options.
+	// Highlights are computed for the current page only.
+	sb.WriteString(fmt.Sprintf(`
+		search AS (
+			SELECT websearch_to_tsquery('%[2]s', $2) AS query
+		),
+		matched AS (
+			SELECT e.*, search.query,
+				CASE WHEN e.vector @@ search.query THEN ts_rank_cd(e.vector, search.query) END AS text_rank
+			FROM (%[1]s
+			) e
+			CROSS JOIN search
+		),
+		ranked AS MATERIALIZED (
+			SELECT m.entity_type, m.id, m.project_id, m.title, m.body, m.updated_at, m.distance, m.query,
+				CASE WHEN m.text_rank IS NOT NULL
+					THEN 1.0 / (%[3]d + RANK() OVER (ORDER BY m.text_rank DESC NULLS LAST)) ELSE 0 END +
+				CASE WHEN m.distance IS NOT NULL
+					THEN 1.0 / (%[3]d + RANK() OVER (ORDER BY m.distance ASC NULLS LAST)) ELSE 0 END AS score,
+				%[4]s AS type_match,
+				%[5]s AS project_match
+			FROM matched m
+		),
+		page AS (
+			SELECT r.*
+			FROM ranked r
+			WHERE r.type_match AND r.project_match
+			ORDER BY r.score DESC, r.updated_at DESC NULLS LAST, r.id
+			LIMIT $%[6]d OFFSET $%[7]d
+		)
+		SELECT
+			(SELECT COUNT(*) FROM ranked r WHERE r.type_match AND r.project_match),
+			COALESCE((
+				SELECT json_agg(json_build_object(
+					'entity_type', page.entity_type,
+					'id', page.id,
+					'project_id', page.project_id,
+					'project_title', p.title,
+					'title', page.title,
+					'title_highlight', ts_headline('%[2]s', page.title, page.query, 'HighlightAll=true, StartSel=<mark>, StopSel=</mark>'),
+					'snippet', ts_headline('%[2]s', page.body, page.query, 'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=30, MinWords=10, FragmentDelimiter=" … "'),
+					'updated_at', page.updated_at,
+					'score', page.score,
+					'semantic_matched', page.distance IS NOT NULL,
+					'distance', page.distance
+				) ORDER BY page.score DESC, page.updated_at DESC NULLS LAST, page.id)
+				FROM page
+				LEFT JOIN st_schema.projects p ON p.id = page.project_id
+			), '[]'),
+			COALESCE((
+				SELECT json_agg(json_build_object('entity_type', f.entity_type, 'count', f.count) ORDER BY f.count DESC, f.entity_type)
+				FROM (
+					SELECT r.entity_type, COUNT(*) AS count
+					FROM ranked r
+					WHERE r.project_match
+					GROUP BY r.entity_type
+				) f
+			), '[]'),
+			COALESCE((
+				SELECT json_agg(json_build_object('project_id', f.project_id, 'project_title', f.title, 'count', f.count) ORDER BY f.count DESC, f.title)
+				FROM (
+					SELECT r.project_id, p.title, COUNT(*) AS count
+					FROM ranked r
+					LEFT JOIN st_schema.projects p ON p.id = r.project_id
+					WHERE r.type_match
+					GROUP BY r.project_id, p.title
+				) f
+			), '[]')
+	`, entitiesQuery(semantic), textSearchConfig, rrfK, typeCondition, projectCondition, len(args)-1, len(args)))
+
+	return sb.String(), args
+}
+
+// GlobalSearch searches all project entities of the tenant.
+// Query params: q (required), types (comma separated entity types), project_id,
+// semantic (true/false), page, page_size
+func GlobalSearch(c *gin.Context) {
+	userID, tenantID, ok := utilities.ProcessIdentity(c)
+	if !ok {
+		return
+	}
+
+	phrase := strings.TrimSpace(c.Query("q"))
+	if phrase == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Search query (q) is required"})
+		return
+	}
+
+	var types []string
+	if rawTypes := c.Query("types"); rawTypes != "" {
+		for _, t := range strings.Split(rawTypes, ",") {
+			t = strings.TrimSpace(t)
+			if !isEntityType(t) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown entity type: %s", t)})
+				return
+			}
+			types = append(types, t)
+		}
+	}
+
+	projectID := c.Query("project_id")
+	semantic := c.Query("semantic") == "true"
+
+	pageNum, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || pageNum < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page number"})
+		return
+	}
+
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(defaultPageSize)))
+	if err != nil || pageSize < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page size"})
+		return
+	}
+	pageSize = min(pageSize, maxPageSize)
+
+	args := []any{tenantID, phrase}
+	if semantic {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+		var searchProjectID *string
+		if projectID != "" {
+			searchProjectID = &projectID
+		}
+		vector, err := aiIndexing.EmbedQuery(ctx, tenantID, userID, searchProjectID, phrase)
+		cancel()
+		if err != nil {
+			// Keyword results are still useful without the embedding
+			log.Printf("Global search embedding failed, falling back to keyword search: %v", err)
+			semantic = false
+		} else {
+			args = append(args, vector)
+		}
+	}
+
+	query, args := searchQuery(semantic, searchFilter{types: types, projectID: projectID}, args, pageNum, pageSize)
+
+	var totalCount int
+	var resultsJSON, typeFacetsJSON, projectFacetsJSON []byte
+	err = tenantManagement.DB.QueryRowContext(c.Request.Context(), query, args...).Scan(&totalCount, &resultsJSON, &typeFacetsJSON, &projectFacetsJSON)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+		return
+	}
+
+	results := []SearchResult{}
+	typeFacets := []TypeFacet{}
+	projectFacets := []ProjectFacet{}
+	for _, decode := range []struct {
+		raw    []byte
+		target any
+	}{{resultsJSON, &results}, {typeFacetsJSON, &typeFacets}, {projectFacetsJSON, &projectFacets}} {
+		if err := json.Unmarshal(decode.raw, decode.target); err != nil {
+			log.Printf("ERROR: Failed to decode search results: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": models.InternalServerError})
+			return
+		}
+	}
+
+	totalPages := int(math.Ceil(float64(totalCount) / float64(pageSize)))
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": results,
+		"facets": gin.H{
+			"entity_types": typeFacets,
+			"projects":     projectFacets,
+		},
+		"pagination": gin.H{
+			"current_page": pageNum,
+			"total_pages":  totalPages,
+			"page_size":    pageSize,
+			"total_items":  totalCount,
+		},
+		"semantic": semantic,
+		"message":  "Search completed successfully!",
+	})
+}
+
+type searchFilter struct {
+	types     []string
+	projectID string
+}
+
+// conditions appends the filter values to args and returns the entity type and project conditions on "matched m"
+func (f searchFilter) conditions(args []any) (string, string, []any) {
+	args = append([]any{}, args...)
+	typeCondition, projectCondition := "TRUE", "TRUE"
+
+	if len(f.types) > 0 {
+		args = append(args, pq.Array(f.types))
+		typeCondition = fmt.Sprintf("m.entity_type = ANY($%d)", len(args))
+	}
+	if f.projectID != "" {
+		args = append(args, f.projectID)
+		projectCondition = fmt.Sprintf("m.project_id = $%d", len(args))
+	}
+
+	return typeCondition, projectCondition, args
+}
+
+func isEntityType(value string) bool {
+	for _, t := range entityTypes {
+		if t == value {
+			return true
+		}
+	}
+	return false
+}
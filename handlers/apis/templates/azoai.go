@@ -7,6 +7,7 @@ package templates
 // 3. GetTenantAiTemplates - Retrieves all AI templates for a tenant
 // 4. UpdateTenantAiTemplate - Updates a specific AI template
 // 5. DeleteTenantAiTemplate - Deletes a specific AI template
+// Configuration changes are saved as versions, see the aiVersions package.
 
 // Local functions:
 // 1. isDevelopmentEnvironment - Checks if the current environment is development or not.
@@ -14,11 +15,14 @@ package templates
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 
+	aiFunctions "sententiawebapi/handlers/apis/ai/functions"
+	aiVersions "sententiawebapi/handlers/apis/ai/versions"
 	"sententiawebapi/handlers/apis/tenantManagement"
 	"sententiawebapi/handlers/models"
 	"sententiawebapi/utilities"
@@ -42,6 +46,17 @@ func NewTenantAiTemplate(c *gin.Context) {
 		return
 	}
 
+	if template.Configuration != nil {
+		if err := aiFunctions.ValidateToolSettings(template.Configuration.Tools); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := aiFunctions.ValidateTemplateVariables(template.Configuration.SystemConfig); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	// Override/set critical fields after JSON binding
 	template.UserID = &userID
 	template.TenantID = &tenantID
@@ -58,8 +73,17 @@ func NewTenantAiTemplate(c *gin.Context) {
 		return
 	}
 
+	// The template and its first version are saved together
+	tx, err := tenantManagement.DB.Begin()
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	defer tx.Rollback()
+
 	var newID string
-	err = tenantManagement.DB.QueryRow(`
+	err = tx.QueryRow(`
         INSERT INTO st_schema.prompt_config_template
         (
             user_id, tenant_id, title, description, category, ai_vendor, ai_model,
@@ -96,7 +120,26 @@ func NewTenantAiTemplate(c *gin.Context) {
 		return
 	}
 
+	if template.Changelog == nil {
+		template.Changelog = new(string)
+		*template.Changelog = "Initial version"
+	}
+
+	version, err := aiVersions.CreateTemplateVersion(tx, tenantID, newID, &userID, template.Changelog)
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
 	template.ID = &newID
+	template.Version = &version
 
 	c.JSON(http.StatusCreated, gin.H{
 		"data":    template,
@@ -265,7 +308,7 @@ func GetTenantAiTemplates(c *gin.Context) {
 // @Failure 500 {object} string "Internal Server Error"
 // @Router /api/tenantAiTemplate [put]
 func UpdateTenantAiTemplate(c *gin.Context) {
-	_, tenantID, ok := utilities.ProcessIdentity(c)
+	userID, tenantID, ok := utilities.ProcessIdentity(c)
 	if !ok {
 		return
 	}
@@ -282,6 +325,17 @@ func UpdateTenantAiTemplate(c *gin.Context) {
 		return
 	}
 
+	if updatedTemplate.Configuration != nil {
+		if err := aiFunctions.ValidateToolSettings(updatedTemplate.Configuration.Tools); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := aiFunctions.ValidateTemplateVariables(updatedTemplate.Configuration.SystemConfig); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	// Set update config
 	setParts := []string{}
 	args := []interface{}{}
@@ -346,9 +400,31 @@ func UpdateTenantAiTemplate(c *gin.Context) {
     `, setClause, argCounter, argCounter+1)
 	args = append(args, templateId, tenantID)
 
+	// A changed configuration or model is saved as a new version in the same transaction
+	tx, err := tenantManagement.DB.Begin()
+	if err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Templates saved before versioning keep their previous configuration as version 1
+	changesVersion := updatedTemplate.Configuration != nil || updatedTemplate.AiModel != nil
+	if changesVersion {
+		if _, err := aiVersions.BackfillTemplateVersion(tx, tenantID, templateId); errors.Is(err, aiVersions.ErrTemplateNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+			return
+		} else if err != nil {
+			log.Printf(models.DatabaseError, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+	}
+
 	var returnTemplate models.TenantAiTemplate
 	var rawConfig []byte
-	err = tenantManagement.DB.QueryRow(query, args...).Scan(
+	err = tx.QueryRow(query, args...).Scan(
 		&returnTemplate.ID,
 		&returnTemplate.SourceID,
 		&returnTemplate.UserID,
@@ -374,6 +450,23 @@ func UpdateTenantAiTemplate(c *gin.Context) {
 		return
 	}
 
+	if changesVersion {
+		version, err := aiVersions.CreateTemplateVersion(tx, tenantID, templateId, &userID, updatedTemplate.Changelog)
+		if err != nil {
+			log.Printf(models.DatabaseError, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+		returnTemplate.Version = &version
+		returnTemplate.Changelog = updatedTemplate.Changelog
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf(models.DatabaseError, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
 	// Unmarshal the configuration JSON
 	if err := json.Unmarshal(rawConfig, &returnTemplate.Configuration); err != nil {
 		log.Printf("JSON Unmarshal Err: %v", err)
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"strings"
 
+	aiIndexing "sententiawebapi/handlers/apis/ai/indexing"
 	"sententiawebapi/handlers/apis/tenantManagement"
 	"sententiawebapi/handlers/models"
 	"sententiawebapi/utilities"
@@ -82,6 +83,14 @@ func NewInternalDiagramTemplate(c *gin.Context) {
 		return
 	}
 
+	// Keep diagram_search in sync
+	aiIndexing.IndexDiagram(aiIndexing.DiagramRef{
+		Type:      models.ResourceGroupTemplate,
+		TenantID:  tenantID,
+		UserID:    userID,
+		DiagramID: *diagram.ID,
+	}, diagram.Title, diagram.Design)
+
 	// Return the new diagram data
 	c.JSON(200, gin.H{
 		"data":    diagram,
@@ -247,7 +256,7 @@ func GetInternalDiagramTemplates(c *gin.Context) {
 // This function updates an existing diagram template
 func UpdateInternalDiagramTemplate(c *gin.Context) {
 	// Get the tenant ID from the context
-	_, tenantID, ok := utilities.ProcessIdentity(c)
+	userID, tenantID, ok := utilities.ProcessIdentity(c)
 
 	if !ok {
 		return
@@ -379,6 +388,13 @@ func UpdateInternalDiagramTemplate(c *gin.Context) {
 		return
 	}
 
+	aiIndexing.IndexDiagram(aiIndexing.DiagramRef{
+		Type:      models.ResourceGroupTemplate,
+		TenantID:  tenantID,
+		UserID:    userID,
+		DiagramID: diagramTemplateID,
+	}, updatedTemplate.Title, updatedTemplate.Design)
+
 	// Return the updated diagram template data
 	c.JSON(http.StatusOK, gin.H{
 		"data":    updatedTemplate,
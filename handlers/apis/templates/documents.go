@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"strings"
 
+	aiIndexing "sententiawebapi/handlers/apis/ai/indexing"
 	"sententiawebapi/handlers/apis/tenantManagement"
 	"sententiawebapi/handlers/models"
 	"sententiawebapi/utilities"
@@ -72,6 +73,15 @@ func NewInternalDocumentTemplate(c *gin.Context) {
 		return
 	}
 
+	// Keep document_search in sync
+	aiIndexing.IndexDocument(aiIndexing.DocumentRef{
+		Type:       models.ResourceGroupTemplate,
+		TenantID:   tenantID,
+		UserID:     userID,
+		GroupID:    projectTemplateID,
+		DocumentID: *Document.ID,
+	}, Document.Content)
+
 	// Return the new document data
 	c.JSON(200, gin.H{
 		"data":    Document,
@@ -233,7 +243,7 @@ func GetInternalDocumentTemplates(c *gin.Context) {
 // This function updates an existing internal document template
 func UpdateInternalDocumentTemplate(c *gin.Context) {
 	// Get the tenant ID from the context
-	_, tenantID, ok := utilities.ProcessIdentity(c)
+	userID, tenantID, ok := utilities.ProcessIdentity(c)
 	if !ok {
 		return
 	}
@@ -367,6 +377,14 @@ func UpdateInternalDocumentTemplate(c *gin.Context) {
 		return
 	}
 
+	aiIndexing.IndexDocument(aiIndexing.DocumentRef{
+		Type:       models.ResourceGroupTemplate,
+		TenantID:   tenantID,
+		UserID:     userID,
+		GroupID:    projectTemplateID,
+		DocumentID: documentTemplateID,
+	}, updatedTemplate.Content)
+
 	// Return the updated document template data
 	c.JSON(http.StatusOK, gin.H{
 		"data":    updatedTemplate,
@@ -444,6 +462,13 @@ func DeleteInternalDocumentTemplate(c *gin.Context) {
 		return
 	}
 
+	aiIndexing.RemoveDocument(aiIndexing.DocumentRef{
+		Type:       models.ResourceGroupTemplate,
+		TenantID:   tenantID,
+		GroupID:    projectTemplateID,
+		DocumentID: documentTemplateID,
+	})
+
 	// Return a success response
 	c.JSON(http.StatusOK, gin.H{
 		"data":    document,
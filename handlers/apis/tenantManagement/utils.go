@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"sententiawebapi/handlers/models"
+	"sententiawebapi/utilities"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
@@ -23,7 +24,7 @@ func CreateTenantResourceGroup(tenantOpenAiResource models.TenantOpenAiResource,
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
 	defer cancel()
 
-	clientFactory, err := armresources.NewClientFactory(tenantOpenAiResource.SubscriptionID, cred, nil)
+	clientFactory, err := armresources.NewClientFactory(tenantOpenAiResource.SubscriptionID, cred, utilities.ArmClientOptions())
 	if err != nil {
 		return err
 	}
@@ -63,7 +64,7 @@ func CreateAzOaiAccount(tenantOpenAiResource models.TenantOpenAiResource) (*armc
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
 	defer cancel()
 
-	clientFactory, err := armcognitiveservices.NewClientFactory(tenantOpenAiResource.SubscriptionID, cred, nil)
+	clientFactory, err := armcognitiveservices.NewClientFactory(tenantOpenAiResource.SubscriptionID, cred, utilities.ArmClientOptions())
 	if err != nil {
 		return nil, err
 	}
@@ -100,7 +101,7 @@ func CreateAzOaiModelDeployment(tenantOpenAiResource models.TenantOpenAiResource
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute) // Set a 30 minute timeout
 	defer cancel()
 
-	clientFactory, err := armcognitiveservices.NewClientFactory(tenantOpenAiResource.SubscriptionID, cred, nil)
+	clientFactory, err := armcognitiveservices.NewClientFactory(tenantOpenAiResource.SubscriptionID, cred, utilities.ArmClientOptions())
 	if err != nil {
 		log.Printf("Error creating client factory: %v", err)
 		return nil, err
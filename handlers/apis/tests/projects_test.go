@@ -277,6 +277,10 @@ func executeRequest(t *testing.T, req TestRequest) *httptest.ResponseRecorder {
 	case "DELETE/api/swotArgument":
 		r.DELETE("/api/swotArgument", jwtMiddleware, decisions.DeleteSwotArgument)
 
+	// Decision Lifecycle Routes
+	case "POST/api/decisionLifecycle/transition":
+		r.POST("/api/decisionLifecycle/transition", jwtMiddleware, decisions.TransitionDecision)
+
 	// Tenant AI Template Routes
 	case "POST/api/tenantAiTemplate":
 		r.POST("/api/tenantAiTemplate", jwtMiddleware, templates.NewTenantAiTemplate)
@@ -326,6 +330,21 @@ func executeRequest(t *testing.T, req TestRequest) *httptest.ResponseRecorder {
 	return rr
 }
 
+// transitionDecision moves a decision to another lifecycle state, the status of an analysis only changes through the lifecycle
+func transitionDecision(t *testing.T, decisionType string, decisionID string, to string) *httptest.ResponseRecorder {
+	return executeRequest(t, TestRequest{
+		Method: "POST",
+		Path:   "/api/decisionLifecycle/transition",
+		Body: map[string]interface{}{
+			"to": to,
+		},
+		QueryParams: map[string]string{
+			"decision_type": decisionType,
+			"decision_id":   decisionID,
+		},
+	})
+}
+
 func extractIDFromResponse(t *testing.T, rr *httptest.ResponseRecorder) string {
 	var responseMap map[string]interface{}
 	err := json.Unmarshal(rr.Body.Bytes(), &responseMap)
@@ -867,7 +886,6 @@ func TestUpdateTBar(t *testing.T) {
 	updateData := map[string]interface{}{
 		"tbar_title":         "Updated TBar Analysis",
 		"tbar_description":   "Updated comparison of architectural approaches",
-		"tbar_status":        "Completed",
 		"tbar_category":      "Architecture",
 		"tbar_better_option": "Option A",
 		"final_decision":     "Selected Microservices Architecture",
@@ -884,6 +902,9 @@ func TestUpdateTBar(t *testing.T) {
 		},
 	})
 
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = transitionDecision(t, "tchart", createdTBarID, "proposed")
 	assert.Equal(t, http.StatusOK, rr.Code)
 	recordFailure(t, "TestUpdateTBar")
 }
@@ -918,7 +939,23 @@ func TestDeleteTBar(t *testing.T) {
 		},
 	})
 
+	// Proposed decisions are part of the decision record
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	rr = transitionDecision(t, "tchart", createdTBarID, "draft")
 	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = executeRequest(t, TestRequest{
+		Method: "DELETE",
+		Path:   "/api/tbar",
+		QueryParams: map[string]string{
+			"project_id": createdProjectID,
+			"tbar_id":    createdTBarID,
+		},
+	})
+
+	// Also after it went back to draft
+	assert.Equal(t, http.StatusConflict, rr.Code)
 	recordFailure(t, "TestDeleteTBar")
 }
 
@@ -1059,7 +1096,6 @@ func TestUpdatePnc(t *testing.T) {
 	updateData := map[string]interface{}{
 		"title":           "Updated Pros & Cons Analysis",
 		"pnc_description": "Updated analysis of deployment options",
-		"pnc_status":      "Completed",
 		"category":        "Technology",
 		"better_option":   "Option A",
 		"final_decision":  "Selected cloud deployment",
@@ -1076,6 +1112,9 @@ func TestUpdatePnc(t *testing.T) {
 		},
 	})
 
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = transitionDecision(t, "pnc", createdPncID, "proposed")
 	assert.Equal(t, http.StatusOK, rr.Code)
 	recordFailure(t, "TestUpdatePnc")
 }
@@ -1109,7 +1148,23 @@ func TestDeletePnc(t *testing.T) {
 		},
 	})
 
+	// Proposed decisions are part of the decision record
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	rr = transitionDecision(t, "pnc", createdPncID, "draft")
 	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = executeRequest(t, TestRequest{
+		Method: "DELETE",
+		Path:   "/api/pnc",
+		QueryParams: map[string]string{
+			"project_id": createdProjectID,
+			"pnc_id":     createdPncID,
+		},
+	})
+
+	// Also after it went back to draft
+	assert.Equal(t, http.StatusConflict, rr.Code)
 	recordFailure(t, "TestDeletePnc")
 }
 
@@ -1250,7 +1305,6 @@ func TestUpdateSwot(t *testing.T) {
 	updateData := map[string]interface{}{
 		"title":            "Updated SWOT Analysis",
 		"swot_description": "Updated cloud architecture analysis",
-		"swot_status":      "Completed",
 		"category":         "Technology",
 		"assumptions":      "Cloud infrastructure must be highly scalable",
 		"final_decision":   "Proceed with cloud deployment",
@@ -1267,6 +1321,9 @@ func TestUpdateSwot(t *testing.T) {
 		},
 	})
 
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = transitionDecision(t, "swot", createdSwotID, "proposed")
 	assert.Equal(t, http.StatusOK, rr.Code)
 	recordFailure(t, "TestUpdateSwot")
 }
@@ -1301,7 +1358,23 @@ func TestDeleteSwot(t *testing.T) {
 		},
 	})
 
+	// Proposed decisions are part of the decision record
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	rr = transitionDecision(t, "swot", createdSwotID, "draft")
 	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = executeRequest(t, TestRequest{
+		Method: "DELETE",
+		Path:   "/api/swot",
+		QueryParams: map[string]string{
+			"project_id": createdProjectID,
+			"swot_id":    createdSwotID,
+		},
+	})
+
+	// Also after it went back to draft
+	assert.Equal(t, http.StatusConflict, rr.Code)
 	recordFailure(t, "TestDeleteSwot")
 }
 
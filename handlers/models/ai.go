@@ -29,6 +29,8 @@ type TenantAiTemplate struct {
 	FirstName         *string          `json:"first_name"`
 	LastName          *string          `json:"last_name"`
 	UserPicture       *string          `json:"user_picture"`
+	Version           *int             `json:"version,omitempty"`   // Version created by the request
+	Changelog         *string          `json:"changelog,omitempty"` // Note of the version created by the request
 }
 
 type SpAiTemplate struct {
@@ -53,6 +55,8 @@ type AiConfiguration struct {
 	SystemConfig  string  `json:"system_config"`  // This is the system configuration, prompt engineering
 	TopP          float64 `json:"top_p"`
 	MaxTokens     int     `json:"max_tokens"`
+
+	Tools map[string]bool `json:"tools,omitempty"` // AI tool switches by tool name, tools not listed are enabled
 }
 
 type DocumentSelection struct {
@@ -92,6 +96,8 @@ type CompletionRequest struct {
 	PromptTokens     int32       `json:"prompt_tokens"`
 	CompletionTokens int32       `json:"completion_tokens"`
 	TotalTokens      int32       `json:"total_tokens"`
+	Citations        []Citation  `json:"citations"`
+	ResponseID       *string     `json:"response_id"` // OpenAI response of a final answer, conversations can be branched from it
 	CreatedAt        *time.Time  `json:"created_at"`
 }
 
@@ -109,15 +115,29 @@ type CompletionRequestResource struct {
 	TotalTokens      int       `json:"total_tokens"`
 }
 
+type TokenUsageStatus string
+
+const (
+	TokenUsageCompleted TokenUsageStatus = "completed"
+	TokenUsageCancelled TokenUsageStatus = "cancelled" // Client disconnected or the call timed out, token counts are partial
+	TokenUsageFiltered  TokenUsageStatus = "filtered"  // Output stopped by the content filter, the request fell back to another model
+)
+
 type TenantTokenUsageRequest struct {
-	UserID           string      `json:"user_id"`
-	TenantID         string      `json:"tenant_id"`
-	ConversationID   *string     `json:"conversation_id"`
-	AiVendor         string      `json:"ai_vendor"`
-	AiModel          string      `json:"ai_model"`
-	Tools            interface{} `json:"tools"`
-	PromptTokens     int32       `json:"prompt_tokens"`
-	CompletionTokens int32       `json:"completion_tokens"`
+	UserID           string           `json:"user_id"`
+	TenantID         string           `json:"tenant_id"`
+	ConversationID   *string          `json:"conversation_id"`
+	AiVendor         string           `json:"ai_vendor"`
+	AiModel          string           `json:"ai_model"`        // Model that served the request
+	RequestedModel   string           `json:"requested_model"` // Primary model of the route, differs from ai_model after a fallback
+	Tools            interface{}      `json:"tools"`
+	PromptTokens     int32            `json:"prompt_tokens"`
+	CompletionTokens int32            `json:"completion_tokens"`
+	CachedTokens     int32            `json:"cached_tokens"` // Part of prompt_tokens read from the prompt cache
+	Status           TokenUsageStatus `json:"status"`        // Empty means completed
+	Feature          AiTaskType       `json:"feature"`
+	ProjectID        *string          `json:"project_id"`
+	Cost             float64          `json:"cost"` // Calculated from the model price catalogue
 }
 
 type TenantTokenUsageResource struct {
@@ -127,11 +147,24 @@ type TenantTokenUsageResource struct {
 }
 
 type MessageResource struct {
-	ID         string              `json:"id"`
-	Role       string              `json:"role"`
-	CreatedAt  time.Time           `json:"created_at"`
-	Content    string              `json:"content"`
-	Selections []DocumentSelection `json:"selections"`
+	ID             string              `json:"id"`
+	ConversationID string              `json:"conversation_id"` // Differs from the requested conversation for messages inherited by a branch
+	Role           string              `json:"role"`
+	CreatedAt      time.Time           `json:"created_at"`
+	Content        string              `json:"content"`
+	Selections     []DocumentSelection `json:"selections"`
+	Citations      []Citation          `json:"citations,omitempty"` // Assistant messages only
+	Branchable     bool                `json:"branchable"`          // Assistant message a new branch can start from
+}
+
+// ConversationBranch is a node of the branch tree, the root is the original conversation
+type ConversationBranch struct {
+	ID                   string                `json:"id"`
+	Title                string                `json:"title"`
+	ParentConversationID *string               `json:"parent_conversation_id"`
+	ParentMessageID      *string               `json:"parent_message_id"` // Assistant message the branch starts after
+	CreatedAt            time.Time             `json:"created_at"`
+	Branches             []*ConversationBranch `json:"branches"`
 }
 
 // type Message struct {
@@ -139,12 +172,87 @@ type MessageResource struct {
 // 	Content string `json:"content"`
 // }
 
+type AiProviderType string
+
+const (
+	AiProviderOpenAI     AiProviderType = "openai"     // Public OpenAI API (default)
+	AiProviderAzure      AiProviderType = "azure"      // Azure OpenAI resource of the tenant
+	AiProviderCompatible AiProviderType = "compatible" // Any OpenAI-compatible API, e.g. a local inference server
+)
+
+// OpenAiConfig is the config_schema of the tenant in st_schema.ai_providers
 type OpenAiConfig struct {
+	Provider        AiProviderType  `json:"provider"` // Empty means openai
 	OpenAIProjectID string          `json:"openai_project_id"`
-	OpenAIApiKey    string          `json:"openai_api_key"`
+	OpenAIApiKey    string          `json:"openai_api_key"` // Azure without a key authenticates with the managed identity of the API
 	OpenAIApiKeyID  string          `json:"openai_api_key_id"`
 	Assistants      json.RawMessage `json:"assistants"`
 	VectorStores    json.RawMessage `json:"vector_stores"`
+
+	AzureEndpoint   string            `json:"azure_endpoint,omitempty"`    // https://<resource>.openai.azure.com
+	AzureApiVersion string            `json:"azure_api_version,omitempty"` // Defaults to a version supporting the Responses API
+	BaseURL         string            `json:"base_url,omitempty"`          // Compatible providers, e.g. http://localhost:11434/v1
+	Deployments     map[string]string `json:"deployments,omitempty"`       // OpenAI model name -> Azure deployment or model name of the provider
+
+	ModelRouting map[AiTaskType]ModelRoute `json:"model_routing,omitempty"` // Tasks not listed use the default routes
+
+	Redaction RedactionConfig `json:"redaction,omitzero"`
+}
+
+// RedactionConfig controls which sensitive spans are replaced before text is sent to the model.
+// The built-in rules are on unless disabled.
+type RedactionConfig struct {
+	Disabled      bool            `json:"disabled,omitempty"`       // Turns the redaction off for the tenant
+	DisabledRules []string        `json:"disabled_rules,omitempty"` // Names of built-in rules, e.g. IP_ADDRESS
+	Rules         []RedactionRule `json:"rules,omitempty"`          // Tenant rules, applied after the built-in ones
+}
+
+type RedactionRule struct {
+	Name    string `json:"name"`    // Label of the placeholders, e.g. CUSTOMER_ID
+	Pattern string `json:"pattern"` // Regular expression, a group named "value" limits the redaction to the group
+}
+
+type AiTaskType string
+
+const (
+	AiTaskChat         AiTaskType = "chat"          // Prompts and conversations
+	AiTaskMagician     AiTaskType = "magician"      // Document magician text operations
+	AiTaskSchemaDesign AiTaskType = "schema_design" // Database design generation
+	AiTaskEmbeddings   AiTaskType = "embeddings"    // Search and indexing embeddings
+	AiTaskEvaluation   AiTaskType = "evaluation"    // Template test runs and their rubric grading
+)
+
+// ModelRoute is the primary model of a task and the fallbacks tried in order when it is
+// rate limited, times out or its output is filtered
+type ModelRoute struct {
+	Primary   string   `json:"primary"`
+	Fallbacks []string `json:"fallbacks,omitempty"`
+}
+
+// Models returns the primary model followed by the fallbacks, without duplicates
+func (r ModelRoute) Models() []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, model := range append([]string{r.Primary}, r.Fallbacks...) {
+		if model == "" || seen[model] {
+			continue
+		}
+		seen[model] = true
+		out = append(out, model)
+	}
+	return out
+}
+
+// WithPrimary returns the route with another primary model, e.g. the model of an assistant.
+// The original primary becomes the first fallback.
+func (r ModelRoute) WithPrimary(model string) ModelRoute {
+	if model == "" || model == r.Primary {
+		return r
+	}
+	return ModelRoute{
+		Primary:   model,
+		Fallbacks: append([]string{r.Primary}, r.Fallbacks...),
+	}
 }
 
 // AssistantParams is a custom implementation of responses.ResponseNewParams that properly handles JSON unmarshaling
@@ -225,6 +333,79 @@ type ChatContext struct {
 	UserID         string
 	TenantID       string
 	ConversationID *string
+	AssistantName  string     // Assistant config of the prompt, needed to resume after pending actions
+	ModelRoute     ModelRoute // Models the prompt may be served by, the primary is the requested model
+	Feature        AiTaskType // Task the token usage is reported under
+	Redactor       TextRedactor
+	ToolSettings   map[string]bool // Tool switches of the conversation template, nil enables all tools
 
 	ResourceIdentifier
+
+	// Sources returned by search functions while answering the current prompt
+	Citations []Citation
+}
+
+// TextRedactor replaces sensitive spans with placeholders before text is sent to the model
+// and puts the original values back into what the model returns
+type TextRedactor interface {
+	Redact(text string) string
+	Restore(text string) string
+	RestoreJSON(text string) string // Restores placeholders inside JSON string values, e.g. function call arguments
+}
+
+// RedactionAudit is the number of redacted spans of one AI request, the values are never stored
+type RedactionAudit struct {
+	ID             string         `json:"id"`
+	UserID         string         `json:"user_id"`
+	ConversationID *string        `json:"conversation_id"`
+	ProjectID      *string        `json:"project_id"`
+	Feature        AiTaskType     `json:"feature"`
+	Counts         map[string]int `json:"counts"` // By rule name
+	Total          int            `json:"total"`
+	CreatedAt      time.Time      `json:"created_at"`
+}
+
+// Citation points from an AI answer back to the fragment it was based on
+type Citation struct {
+	ResourceType ResourceType `json:"resource_type"`
+	ResourceID   string       `json:"resource_id"`
+	Title        string       `json:"title"`
+	BlockID      *string      `json:"block_id,omitempty"` // Document block (chunk) the fragment comes from
+	Distance     float64      `json:"distance"`
+}
+
+type PendingActionStatus string
+
+const (
+	PendingActionPending   PendingActionStatus = "pending"   // Waiting for the user decision
+	PendingActionExecuting PendingActionStatus = "executing" // Approved, the tool is running
+	PendingActionApproved  PendingActionStatus = "approved"  // Approved and executed
+	PendingActionRejected  PendingActionStatus = "rejected"  // Rejected by the user
+	PendingActionFailed    PendingActionStatus = "failed"    // Approved, but the execution failed
+	PendingActionCompleted PendingActionStatus = "completed" // Read-only call of the same response, executed right away
+)
+
+// PendingAction is a function call held back until the user approves it.
+// All calls of the paused response are stored, the read-only ones already completed.
+type PendingAction struct {
+	ID             string              `json:"id"`
+	ConversationID string              `json:"conversation_id"`
+	ResponseID     string              `json:"response_id"`
+	CallID         string              `json:"call_id"`
+	ToolName       string              `json:"tool_name"`
+	Arguments      string              `json:"arguments"` // Raw JSON arguments produced by the model
+	Preview        *ActionPreview      `json:"preview,omitempty"`
+	Status         PendingActionStatus `json:"status"`
+	Output         *string             `json:"output,omitempty"` // function_call_output sent back to the model
+	Comment        *string             `json:"comment,omitempty"`
+	CreatedAt      time.Time           `json:"created_at"`
+	DecidedAt      *time.Time          `json:"decided_at,omitempty"`
+}
+
+// ActionPreview shows the user what a mutating tool call would change
+type ActionPreview struct {
+	Summary      string `json:"summary"`
+	ResourceType string `json:"resource_type"`
+	Before       any    `json:"before"` // nil when the resource is created
+	After        any    `json:"after"`
 }
@@ -0,0 +1,26 @@
+package models
+
+import "encoding/json"
+
+// Assistant is a Solution Pilot assistant, prompts select it by name
+type Assistant struct {
+	Name     string          `json:"name"`
+	Config   json.RawMessage `json:"config"` // AssistantParams of the active version
+	IsActive bool            `json:"is_active"`
+	Version  *int            `json:"version"` // Active version, nil for assistants never saved through the API
+}
+
+type AssistantRequest struct {
+	Name      string          `json:"name"`   // Only read on create
+	Config    json.RawMessage `json:"config"` // AssistantParams, saving it creates a new version
+	IsActive  *bool           `json:"is_active"`
+	Changelog *string         `json:"changelog"`
+}
+
+// AssistantDryRunRequest builds the params of a prompt without sending it.
+// Config previews an unsaved config, Version an earlier one, the active config is used otherwise.
+type AssistantDryRunRequest struct {
+	Prompt  string          `json:"prompt"`
+	Config  json.RawMessage `json:"config"`
+	Version *int            `json:"version"`
+}
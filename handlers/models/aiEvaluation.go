@@ -0,0 +1,135 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type EvalAssertionType string
+
+const (
+	EvalAssertionContains    EvalAssertionType = "contains"     // Output contains the value
+	EvalAssertionNotContains EvalAssertionType = "not_contains" // Output doesn't contain the value
+	EvalAssertionRegex       EvalAssertionType = "regex"        // Output matches the regular expression
+	EvalAssertionJSONSchema  EvalAssertionType = "json_schema"  // Output is JSON valid against the schema
+	EvalAssertionRubric      EvalAssertionType = "rubric"       // A model grades the output against the rubric
+)
+
+func (t EvalAssertionType) IsValid() bool {
+	switch t {
+	case EvalAssertionContains, EvalAssertionNotContains, EvalAssertionRegex, EvalAssertionJSONSchema, EvalAssertionRubric:
+		return true
+	default:
+		return false
+	}
+}
+
+// EvalAssertion is an expectation on the output of a test case
+type EvalAssertion struct {
+	Type          EvalAssertionType `json:"type"`
+	Value         string            `json:"value,omitempty"`  // Text, regular expression or rubric
+	Schema        json.RawMessage   `json:"schema,omitempty"` // JSON schema of json_schema assertions
+	CaseSensitive bool              `json:"case_sensitive,omitempty"`
+}
+
+type AiTemplateTestCaseRequest struct {
+	Name       string          `json:"name" binding:"required"`
+	Prompt     string          `json:"prompt" binding:"required"`
+	ProjectID  *string         `json:"project_id"` // Project context of the prompt, used by template variables and tools
+	Assertions []EvalAssertion `json:"assertions" binding:"required,min=1"`
+}
+
+type AiTemplateTestCase struct {
+	ID         string          `json:"id"`
+	TemplateID string          `json:"template_id"`
+	TenantID   string          `json:"tenant_id"`
+	Name       string          `json:"name"`
+	Prompt     string          `json:"prompt"`
+	ProjectID  *string         `json:"project_id"`
+	Assertions []EvalAssertion `json:"assertions"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+}
+
+// EvalVariant is a template configuration to run the test cases against.
+// Without a template ID and a configuration the current configuration of the tested template is used.
+type EvalVariant struct {
+	Label         string           `json:"label"`
+	TemplateID    *string          `json:"template_id"`   // Another template of the tenant, e.g. a draft clone
+	Version       *int             `json:"version"`       // Saved version of the tested template or of TemplateID
+	Configuration *AiConfiguration `json:"configuration"` // Unsaved configuration
+}
+
+type AiEvalRunRequest struct {
+	Variants []EvalVariant `json:"variants"` // Empty runs the current configuration only
+}
+
+type EvalRunStatus string
+
+const (
+	EvalRunRunning   EvalRunStatus = "running" // Test cases are still evaluated in the background
+	EvalRunCompleted EvalRunStatus = "completed"
+	EvalRunFailed    EvalRunStatus = "failed" // The run itself failed, not its assertions
+)
+
+type AiEvalRun struct {
+	ID               string           `json:"id"`
+	TemplateID       string           `json:"template_id"`
+	TenantID         string           `json:"tenant_id"`
+	UserID           string           `json:"user_id"`
+	Label            string           `json:"label"`
+	SourceTemplateID *string          `json:"source_template_id"` // Template the configuration was taken from
+	SourceVersion    *int             `json:"source_version"`     // Version of the source template, nil for its configuration at the time
+	Configuration    *AiConfiguration `json:"configuration"`
+	Model            string           `json:"model"`
+	Status           EvalRunStatus    `json:"status"`
+	Passed           int              `json:"passed"`
+	Failed           int              `json:"failed"`
+	PromptTokens     int64            `json:"prompt_tokens"`
+	CompletionTokens int64            `json:"completion_tokens"`
+	Cost             float64          `json:"cost"`
+	CreatedAt        time.Time        `json:"created_at"`
+	Results          []AiEvalResult   `json:"results,omitempty"`
+}
+
+type EvalAssertionResult struct {
+	Type   EvalAssertionType `json:"type"`
+	Passed bool              `json:"passed"`
+	Detail string            `json:"detail,omitempty"` // Why it failed, the reasoning of rubric grades
+	Score  *float64          `json:"score,omitempty"`  // Rubric grade, 0-1
+}
+
+type AiEvalResult struct {
+	ID               string                `json:"id"`
+	RunID            string                `json:"run_id"`
+	CaseID           string                `json:"case_id"`
+	CaseName         string                `json:"case_name"`
+	Output           string                `json:"output"`
+	Model            string                `json:"model"`
+	PromptTokens     int64                 `json:"prompt_tokens"`
+	CompletionTokens int64                 `json:"completion_tokens"`
+	Cost             float64               `json:"cost"`
+	LatencyMs        int64                 `json:"latency_ms"`
+	Passed           bool                  `json:"passed"`
+	Assertions       []EvalAssertionResult `json:"assertions"`
+	Error            *string               `json:"error"` // The prompt failed, the case counts as failed
+}
+
+type EvalCaseChange string
+
+const (
+	EvalCaseFixed     EvalCaseChange = "fixed"     // Failed in the base run, passes in the candidate
+	EvalCaseRegressed EvalCaseChange = "regressed" // Passed in the base run, fails in the candidate
+	EvalCaseUnchanged EvalCaseChange = "unchanged"
+	EvalCaseAdded     EvalCaseChange = "added"   // Only in the candidate run
+	EvalCaseRemoved   EvalCaseChange = "removed" // Only in the base run
+)
+
+// AiEvalComparisonRow puts the results of one test case of two runs side by side
+type AiEvalComparisonRow struct {
+	CaseID    string         `json:"case_id"`
+	CaseName  string         `json:"case_name"`
+	Base      *AiEvalResult  `json:"base"`
+	Candidate *AiEvalResult  `json:"candidate"`
+	Change    EvalCaseChange `json:"change"`
+}
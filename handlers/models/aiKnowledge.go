@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+type KnowledgeFileStatus string
+
+const (
+	KnowledgeFileInProgress KnowledgeFileStatus = "in_progress" // Being chunked and embedded
+	KnowledgeFileCompleted  KnowledgeFileStatus = "completed"   // Searchable
+	KnowledgeFileFailed     KnowledgeFileStatus = "failed"
+	KnowledgeFileCancelled  KnowledgeFileStatus = "cancelled"
+)
+
+// KnowledgeBase is a named vector store of the tenant, OpenAiConfig.VectorStores maps the name to the store ID
+type KnowledgeBase struct {
+	Name          string               `json:"name"`
+	VectorStoreID string               `json:"vector_store_id"`
+	Status        string               `json:"status"` // expired, in_progress or completed
+	UsageBytes    int64                `json:"usage_bytes"`
+	FileCounts    *KnowledgeFileCounts `json:"file_counts"` // Nil when the store could not be read
+	ProjectIDs    []string             `json:"project_ids"` // Projects grounding their prompts in the store
+}
+
+// KnowledgeFileCounts is the indexing status of the files of a knowledge base
+type KnowledgeFileCounts struct {
+	InProgress int64 `json:"in_progress"`
+	Completed  int64 `json:"completed"`
+	Failed     int64 `json:"failed"`
+	Cancelled  int64 `json:"cancelled"`
+	Total      int64 `json:"total"`
+}
+
+// KnowledgeFile is a file uploaded into a knowledge base
+type KnowledgeFile struct {
+	ID            string              `json:"id"`
+	TenantID      string              `json:"tenant_id"`
+	StoreName     string              `json:"store_name"`
+	VectorStoreID string              `json:"vector_store_id"`
+	FileID        string              `json:"file_id"` // OpenAI file
+	Filename      string              `json:"filename"`
+	ContentType   string              `json:"content_type"`
+	SizeBytes     int64               `json:"size_bytes"`
+	Status        KnowledgeFileStatus `json:"status"`
+	LastError     *string             `json:"last_error"`
+	UploadedBy    string              `json:"uploaded_by"`
+	CreatedAt     time.Time           `json:"created_at"`
+	UpdatedAt     time.Time           `json:"updated_at"`
+}
+
+type ProjectKnowledgeBasesRequest struct {
+	Stores []string `json:"stores"` // Knowledge base names, replaces the current ones
+}
@@ -0,0 +1,76 @@
+package models
+
+import "time"
+
+// ModelPrice is a row of the model price catalogue, prices are per 1K tokens
+type ModelPrice struct {
+	Model            string  `json:"model"` // Also matches dated versions, e.g. gpt-4o-mini-2024-07-18
+	InputPer1K       float64 `json:"input_per_1k"`
+	CachedInputPer1K float64 `json:"cached_input_per_1k"`
+	OutputPer1K      float64 `json:"output_per_1k"`
+	Currency         string  `json:"currency"`
+}
+
+type BudgetScope string
+
+const (
+	BudgetScopeTenant  BudgetScope = "tenant"
+	BudgetScopeUser    BudgetScope = "user"
+	BudgetScopeProject BudgetScope = "project"
+)
+
+func (s BudgetScope) IsValid() bool {
+	switch s {
+	case BudgetScopeTenant, BudgetScopeUser, BudgetScopeProject:
+		return true
+	default:
+		return false
+	}
+}
+
+type BudgetState string
+
+const (
+	BudgetStateOk       BudgetState = "ok"
+	BudgetStateWarning  BudgetState = "warning"  // Soft threshold reached, AI calls continue
+	BudgetStateExceeded BudgetState = "exceeded" // Hard threshold reached, AI calls are refused
+)
+
+// AiBudgetRequest creates or updates a monthly budget, thresholds are percentages of the limit
+type AiBudgetRequest struct {
+	Scope         BudgetScope `json:"scope" binding:"required"`
+	ScopeID       *string     `json:"scope_id"`                         // User or project ID, empty for the tenant budget
+	MonthlyLimit  float64     `json:"monthly_limit" binding:"required"` // Stored in cents, at least 0.01
+	SoftThreshold *float64    `json:"soft_threshold"`                   // Defaults to 80
+	HardThreshold *float64    `json:"hard_threshold"`                   // Nil never stops the calls
+}
+
+type AiBudget struct {
+	ID            string      `json:"id"`
+	TenantID      string      `json:"tenant_id"`
+	Scope         BudgetScope `json:"scope"`
+	ScopeID       *string     `json:"scope_id"`
+	MonthlyLimit  float64     `json:"monthly_limit"`
+	SoftThreshold float64     `json:"soft_threshold"`
+	HardThreshold *float64    `json:"hard_threshold"`
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+
+	// Spending of the current month
+	Spent   float64     `json:"spent"`
+	Percent float64     `json:"percent"`
+	State   BudgetState `json:"state"`
+}
+
+// AiUsageReportRow is one group of the usage report, fields not grouped by are empty
+type AiUsageReportRow struct {
+	UserID           *string `json:"user_id,omitempty"`
+	Model            *string `json:"model,omitempty"`
+	Feature          *string `json:"feature,omitempty"`
+	Day              *string `json:"day,omitempty"` // YYYY-MM-DD
+	Requests         int64   `json:"requests"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CachedTokens     int64   `json:"cached_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AiTemplateVersion is an immutable snapshot of a tenant AI template configuration
+type AiTemplateVersion struct {
+	ID            string           `json:"id"`
+	TemplateID    string           `json:"template_id"`
+	TenantID      string           `json:"tenant_id"`
+	Version       int              `json:"version"`
+	AiModel       *string          `json:"ai_model"`
+	Configuration *AiConfiguration `json:"configuration"`
+	Changelog     *string          `json:"changelog"`
+	CreatedBy     *string          `json:"created_by"`
+	CreatedAt     time.Time        `json:"created_at"`
+}
+
+// AssistantVersion is an immutable snapshot of an assistant config, the active one is copied to st_schema.assistants
+type AssistantVersion struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Version   int             `json:"version"`
+	Config    json.RawMessage `json:"config"`
+	Changelog *string         `json:"changelog"`
+	CreatedBy *string         `json:"created_by"`
+	CreatedAt time.Time       `json:"created_at"`
+	Active    bool            `json:"active"`
+}
+
+type VersionRollbackRequest struct {
+	Changelog string `json:"changelog"` // Defaults to "Rollback to version N"
+}
+
+// ConfigChangeType is the kind of a change between two configurations
+type ConfigChangeType string
+
+const (
+	ConfigChangeAdded   ConfigChangeType = "added"
+	ConfigChangeRemoved ConfigChangeType = "removed"
+	ConfigChangeChanged ConfigChangeType = "changed"
+)
+
+// ConfigChange is one difference between two configurations
+type ConfigChange struct {
+	Path string           `json:"path"` // e.g. tools.web_search or function_calls[0].name
+	Type ConfigChangeType `json:"type"`
+	From any              `json:"from,omitempty"`
+	To   any              `json:"to,omitempty"`
+}
+
+// ConversationVersionPins are the template and assistant versions a conversation is answered with
+type ConversationVersionPins struct {
+	ConversationID        string         `json:"conversation_id"`
+	TemplateID            *string        `json:"template_id"`
+	TemplateVersion       *int           `json:"template_version"`        // Nil until the first prompt pins the current version
+	LatestTemplateVersion *int           `json:"latest_template_version"` // Differs from the pinned version after template edits
+	AssistantVersions     map[string]int `json:"assistant_versions"`      // By assistant name
+}
+
+// ConversationVersionPinRequest pins a conversation to other versions, Upgrade moves every pin to the latest version
+type ConversationVersionPinRequest struct {
+	TemplateVersion   *int           `json:"template_version"`
+	AssistantVersions map[string]int `json:"assistant_versions"`
+	Upgrade           bool           `json:"upgrade"`
+}
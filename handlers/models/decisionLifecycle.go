@@ -0,0 +1,88 @@
+package models
+
+import "time"
+
+type DecisionType string
+
+const (
+	DecisionTypeTChart DecisionType = "tchart" // Served by /api/tbar, like ResourceTypeTChart
+	DecisionTypePnc    DecisionType = "pnc"
+	DecisionTypeSwot   DecisionType = "swot"
+	DecisionTypeMatrix DecisionType = "matrix"
+)
+
+// DecisionState is the lifecycle state of a decision, it is mirrored to the status of the analysis
+type DecisionState string
+
+const (
+	DecisionStateDraft      DecisionState = "draft"
+	DecisionStateProposed   DecisionState = "proposed"
+	DecisionStateInReview   DecisionState = "in_review"
+	DecisionStateAccepted   DecisionState = "accepted"
+	DecisionStateRejected   DecisionState = "rejected"
+	DecisionStateSuperseded DecisionState = "superseded"
+)
+
+type DecisionVerdict string
+
+const (
+	DecisionVerdictApprove DecisionVerdict = "approve"
+	DecisionVerdictReject  DecisionVerdict = "reject"
+)
+
+// DecisionLifecycle is the state of a decision with the record of its reviews and transitions
+type DecisionLifecycle struct {
+	DecisionType       DecisionType         `json:"decision_type"`
+	DecisionID         string               `json:"decision_id"`
+	State              DecisionState        `json:"state"`
+	RequiredApprovals  int                  `json:"required_approvals"`
+	ReviewRound        int                  `json:"review_round"` // Incremented every time the decision goes into review
+	Approvals          int                  `json:"approvals"`    // Approvals of the current review round
+	SupersededByType   *DecisionType        `json:"superseded_by_type"`
+	SupersededByID     *string              `json:"superseded_by_id"`
+	AllowedTransitions []DecisionState      `json:"allowed_transitions"` // Transitions the API accepts from the current state
+	Reviewers          []DecisionReviewer   `json:"reviewers"`
+	Reviews            []DecisionReview     `json:"reviews"`     // Of all review rounds, oldest first
+	Transitions        []DecisionTransition `json:"transitions"` // Oldest first
+}
+
+type DecisionReviewer struct {
+	ReviewerID string    `json:"reviewer_id"`
+	AssignedBy string    `json:"assigned_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type DecisionReview struct {
+	ID          string          `json:"id"`
+	ReviewerID  string          `json:"reviewer_id"`
+	ReviewRound int             `json:"review_round"`
+	Verdict     DecisionVerdict `json:"verdict"`
+	Comment     *string         `json:"comment"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+type DecisionTransition struct {
+	ID        string        `json:"id"`
+	FromState DecisionState `json:"from_state"`
+	ToState   DecisionState `json:"to_state"`
+	UserID    string        `json:"user_id"`
+	Comment   *string       `json:"comment"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+type DecisionTransitionRequest struct {
+	To               DecisionState `json:"to" binding:"required"`
+	Comment          *string       `json:"comment"`
+	SupersededByType *DecisionType `json:"superseded_by_type"` // The decision replacing an accepted one
+	SupersededByID   *string       `json:"superseded_by_id"`
+}
+
+type DecisionReviewersRequest struct {
+	ReviewerIDs       []string `json:"reviewer_ids" binding:"required"`
+	RequiredApprovals int      `json:"required_approvals" binding:"required,min=1"`
+}
+
+type DecisionReviewRequest struct {
+	Verdict DecisionVerdict `json:"verdict" binding:"required"`
+	Comment *string         `json:"comment"`
+}
@@ -0,0 +1,21 @@
+package models
+
+// DecisionOutcome is the better option computed from the weighted arguments of a T-bar or pros/cons analysis
+type DecisionOutcome struct {
+	Options         []DecisionOptionScore `json:"options"`          // Highest weighted sum first
+	Margin          int                   `json:"margin"`           // Weighted sum of the first option minus the second
+	SuggestedOption *string               `json:"suggested_option"` // Option ID of a T-bar, pro or con of a pros/cons analysis, nil on a tie
+	SuggestedTitle  *string               `json:"suggested_title"`  // Value written to the better option when it is kept in sync
+}
+
+type DecisionOptionScore struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	WeightedSum int    `json:"weighted_sum"`
+	Arguments   int    `json:"arguments"`
+}
+
+type PncAnalysisWithOutcome struct {
+	PncAnalysis
+	Outcome *DecisionOutcome `json:"outcome"`
+}
@@ -18,6 +18,7 @@ type TBarAnalysis struct {
 	TBarStatus       *string `db:"tbar_status" json:"tbar_status"`
 	TBarCategory     *string `db:"tbar_category" json:"tbar_category"`
 	TBarBetterOption *string `db:"tbar_better_option" json:"tbar_better_option"`
+	AutoBetterOption *bool   `db:"auto_better_option" json:"auto_better_option"` // Keep the better option in sync with the weighted arguments
 	Assumptions      *string `db:"assumptions" json:"assumptions"`
 	FinalDecision    *string `db:"final_decision" json:"final_decision"`
 	ADecisionId      *string `db:"architectural_decision_id" json:"architectural_decision_id"`
@@ -47,19 +48,20 @@ type TBarArgument struct {
 
 // Pros and Cons Analysis
 type PncAnalysis struct {
-	ID             string  `json:"id" db:"id"`
-	UserID         string  `json:"user_id" db:"user_id"`
-	TenantID       string  `json:"tenant_id" db:"tenant_id"`
-	Title          *string `json:"title" db:"title"`
-	PNCDescription *string `json:"pnc_description" db:"pnc_description"`
-	PNCStatus      *string `json:"pnc_status" db:"pnc_status"`
-	Category       *string `json:"category" db:"category"`
-	BetterOption   *string `json:"better_option" db:"better_option"`
-	Assumptions    *string `json:"assumptions" db:"assumptions"`
-	FinalDecision  *string `json:"final_decision" db:"final_decision"`
-	ADecisionId    *string `json:"architectural_decision_id" db:"architectural_decision_id"`
-	Implications   *string `json:"implications" db:"implications"`
-	ProjectID      string  `json:"project_id" db:"project_id"`
+	ID               string  `json:"id" db:"id"`
+	UserID           string  `json:"user_id" db:"user_id"`
+	TenantID         string  `json:"tenant_id" db:"tenant_id"`
+	Title            *string `json:"title" db:"title"`
+	PNCDescription   *string `json:"pnc_description" db:"pnc_description"`
+	PNCStatus        *string `json:"pnc_status" db:"pnc_status"`
+	Category         *string `json:"category" db:"category"`
+	BetterOption     *string `json:"better_option" db:"better_option"`
+	AutoBetterOption *bool   `json:"auto_better_option" db:"auto_better_option"` // Keep the better option in sync with the weighted arguments
+	Assumptions      *string `json:"assumptions" db:"assumptions"`
+	FinalDecision    *string `json:"final_decision" db:"final_decision"`
+	ADecisionId      *string `json:"architectural_decision_id" db:"architectural_decision_id"`
+	Implications     *string `json:"implications" db:"implications"`
+	ProjectID        string  `json:"project_id" db:"project_id"`
 }
 
 // Pros and Cons Argument
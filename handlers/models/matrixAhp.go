@@ -0,0 +1,52 @@
+package models
+
+// MatrixPairwiseComparison is a judgement of how much more important criterion A is than criterion B,
+// on the Saaty scale from 1/9 (B is extremely more important) over 1 (equal) to 9 (A is extremely more important)
+type MatrixPairwiseComparison struct {
+	CriteriaAID string  `json:"criteria_a_id" binding:"required"`
+	CriteriaBID string  `json:"criteria_b_id" binding:"required"`
+	Value       float64 `json:"value" binding:"required"`
+	UserID      string  `json:"user_id,omitempty"`
+}
+
+type MatrixPairwiseRequest struct {
+	Comparisons       []MatrixPairwiseComparison `json:"comparisons" binding:"required,dive"`
+	AllowInconsistent bool                       `json:"allow_inconsistent"` // Save judgements above the consistency threshold, they are flagged
+}
+
+// MatrixAhpResult is the priority vector derived from the pairwise comparisons of a matrix
+type MatrixAhpResult struct {
+	Weights          []MatrixAhpWeight          `json:"weights"`
+	LambdaMax        float64                    `json:"lambda_max"`
+	ConsistencyIndex float64                    `json:"consistency_index"`
+	ConsistencyRatio float64                    `json:"consistency_ratio"`
+	Consistent       bool                       `json:"consistent"` // Consistency ratio at most 0.1
+	Complete         bool                       `json:"complete"`   // Weights are only derived when every pair is compared
+	MissingPairs     []MatrixPairwiseComparison `json:"missing_pairs"`
+	MostInconsistent *MatrixAhpJudgement        `json:"most_inconsistent,omitempty"`
+	Participants     []MatrixAhpParticipant     `json:"participants,omitempty"`
+}
+
+type MatrixAhpWeight struct {
+	CriteriaID string  `json:"criteria_id"`
+	Title      string  `json:"title"`
+	Weight     float64 `json:"weight"`              // Share of the priority vector, the weights sum to 1
+	Multiplier int     `json:"criteria_multiplier"` // Weight in percent as written to the criteria
+}
+
+// MatrixAhpJudgement is the comparison that deviates most from the derived weights
+type MatrixAhpJudgement struct {
+	CriteriaAID    string  `json:"criteria_a_id"`
+	CriteriaBID    string  `json:"criteria_b_id"`
+	Value          float64 `json:"value"`
+	SuggestedValue float64 `json:"suggested_value"` // Ratio of the derived weights, the value consistent with the others
+}
+
+// MatrixAhpParticipant is the consistency of the judgements of one user
+type MatrixAhpParticipant struct {
+	UserID           string  `json:"user_id"`
+	Comparisons      int     `json:"comparisons"`
+	Complete         bool    `json:"complete"`
+	ConsistencyRatio float64 `json:"consistency_ratio"` // Only computed for complete judgements
+	Consistent       bool    `json:"consistent"`
+}
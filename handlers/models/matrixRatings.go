@@ -0,0 +1,46 @@
+package models
+
+type MatrixAggregation string
+
+const (
+	MatrixAggregationMean        MatrixAggregation = "mean"
+	MatrixAggregationMedian      MatrixAggregation = "median"
+	MatrixAggregationTrimmedMean MatrixAggregation = "trimmed_mean"
+)
+
+type MatrixDisagreement string
+
+const (
+	MatrixDisagreementNone   MatrixDisagreement = "none" // A single rating
+	MatrixDisagreementLow    MatrixDisagreement = "low"
+	MatrixDisagreementMedium MatrixDisagreement = "medium"
+	MatrixDisagreementHigh   MatrixDisagreement = "high"
+)
+
+// MatrixParticipantRatings are the ratings one user gave in a decision matrix
+type MatrixParticipantRatings struct {
+	UserID  string             `json:"user_id"`
+	Ratings []MatrixUserRating `json:"ratings"`
+}
+
+// MatrixRatingsAggregate is the group view of the ratings in a decision matrix
+type MatrixRatingsAggregate struct {
+	Aggregation  MatrixAggregation     `json:"aggregation"` // Method used for the value of a cell
+	TrimFraction float64               `json:"trim_fraction"`
+	Participants int                   `json:"participants"`
+	Cells        []MatrixCellAggregate `json:"cells"`
+}
+
+type MatrixCellAggregate struct {
+	CriteriaID   string             `json:"criteria_id"`
+	ConceptID    string             `json:"concept_id"`
+	Count        int                `json:"count"`
+	Value        float64            `json:"value"` // The rating of the cell by the selected aggregation
+	Mean         float64            `json:"mean"`
+	Median       float64            `json:"median"`
+	TrimmedMean  float64            `json:"trimmed_mean"`
+	Min          float64            `json:"min"`
+	Max          float64            `json:"max"`
+	StdDev       float64            `json:"std_dev"`      // Population standard deviation
+	Disagreement MatrixDisagreement `json:"disagreement"` // Standard deviation relative to the range of all ratings in the matrix
+}
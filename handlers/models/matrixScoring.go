@@ -0,0 +1,32 @@
+package models
+
+// MatrixScores is the weighted result of a decision matrix
+type MatrixScores struct {
+	Concepts      []MatrixConceptScore         `json:"concepts"`              // Ordered by rank
+	WinnerID      *string                      `json:"winner_id"`             // Nil without concepts or when the first places tie
+	WinnerMargin  float64                      `json:"winner_margin"`         // Weighted total of the winner minus the runner-up
+	MarginPercent float64                      `json:"winner_margin_percent"` // Margin relative to the winner total
+	TotalWeight   float64                      `json:"total_weight"`
+	Sensitivity   []MatrixCriterionSensitivity `json:"sensitivity,omitempty"`
+}
+
+type MatrixConceptScore struct {
+	ConceptID       string  `json:"concept_id"`
+	Title           string  `json:"title"`
+	WeightedTotal   float64 `json:"weighted_total"`   // Sum of weight * rating over the criteria
+	NormalizedScore float64 `json:"normalized_score"` // Weighted total divided by the total weight, on the rating scale
+	Rank            int     `json:"rank"`             // Tied concepts share a rank
+	MissingRatings  int     `json:"missing_ratings"`  // Criteria without a rating, they count as 0
+}
+
+// MatrixCriterionSensitivity tells how far the weight of one criterion has to move, all other
+// weights unchanged, before another concept ties with the winner
+type MatrixCriterionSensitivity struct {
+	CriteriaID    string   `json:"criteria_id"`
+	Title         string   `json:"title"`
+	Weight        float64  `json:"weight"`
+	FlipWeight    *float64 `json:"flip_weight"`    // Weight at which the winner ties, nil when no weight >= 0 flips it
+	WeightChange  *float64 `json:"weight_change"`  // FlipWeight - Weight
+	ChangePercent *float64 `json:"change_percent"` // Change relative to the current weight, nil for a weight of 0
+	FlipsTo       *string  `json:"flips_to"`       // Concept that ties with the winner at FlipWeight
+}
@@ -53,6 +53,8 @@ type Conversation struct {
 	CreatedAt                   time.Time `json:"created_at"`
 	UpdatedAt                   time.Time `json:"updated_at"`
 	Description                 *string   `json:"description"`
+	ParentConversationId        *string   `json:"parent_conversation_id"` // Set for branches
+	ParentMessageId             *string   `json:"parent_message_id"`      // Assistant message the branch starts after
 }
 
 // Table st_schema.cm_public_templates_comments
@@ -3,6 +3,9 @@ package routes
 import (
 	"sententiawebapi/handlers/apis/ai"
 	aiFunctions "sententiawebapi/handlers/apis/ai/functions"
+	aiKnowledge "sententiawebapi/handlers/apis/ai/knowledge"
+	aiRedaction "sententiawebapi/handlers/apis/ai/redaction"
+	aiVersions "sententiawebapi/handlers/apis/ai/versions"
 	"sententiawebapi/handlers/apis/community"
 	"sententiawebapi/handlers/apis/templates"
 	"sententiawebapi/handlers/models"
@@ -21,13 +24,41 @@ func InitOpenAiPromptRoutes(router *gin.Engine, auth *middlewares.AuthMiddleware
 	// New Ai Endpoints
 	router.POST("/api/newPrompt", auth.RequireRole(models.UserRoleMember), ai.NewPromptHandler)
 	router.GET("/api/newChatHistory", auth.RequireRole(models.UserRoleMember), ai.NewChatHistoryHandler)
-	// router.GET("/api/newAiStream", auth.ValidateQueryParamToken("SENDGRID_KEY", models.UserRoleMember), ai.NewStreamHandler)
+
+	// Mutating AI tool calls wait for the user approval
+	router.GET("/api/aiPendingActions", auth.RequireRole(models.UserRoleMember), aiFunctions.GetPendingActionsHandler)
+	router.POST("/api/aiPendingAction/decision", auth.RequireRole(models.UserRoleMember), ai.PendingActionDecisionHandler)
+	router.GET("/api/newAiStream", auth.ValidateQueryParamToken(ai.StreamTokenSecretEnv, models.UserRoleMember), ai.NewStreamHandler) // SSE for prompts sent with "stream": true
+	router.DELETE("/api/newAiStream", auth.RequireRole(models.UserRoleMember), ai.CancelStreamHandler)
 
 	router.POST("/api/documentSearch", auth.RequireRole(models.UserRoleMember), aiFunctions.DocumentSearchHandler)
 	router.POST("/api/diagramSearch", auth.RequireRole(models.UserRoleMember), aiFunctions.DiagramSearchHandler)
 	router.POST("/api/projectInfo", auth.RequireRole(models.UserRoleMember), aiFunctions.GetProjectInfoHandler)
+	router.GET("/api/aiTools", auth.RequireRole(models.UserRoleMember), aiFunctions.ToolsHandler)                         // Tools that can be switched per AI template
+	router.GET("/api/aiTemplateVariables", auth.RequireRole(models.UserRoleMember), aiFunctions.TemplateVariablesHandler) // {{variables}} of the template system config
+
+	// Test cases and evaluation runs of tenant AI templates
+	router.GET("/api/aiTemplateTestCases", auth.RequireRole(models.UserRoleMember), ai.GetTemplateTestCasesHandler)
+	router.POST("/api/aiTemplateTestCase", auth.RequireRole(models.UserRoleMember), ai.NewTemplateTestCaseHandler)
+	router.PUT("/api/aiTemplateTestCase", auth.RequireRole(models.UserRoleMember), ai.UpdateTemplateTestCaseHandler)
+	router.DELETE("/api/aiTemplateTestCase", auth.RequireRole(models.UserRoleMember), ai.DeleteTemplateTestCaseHandler)
+	router.POST("/api/aiTemplateEvalRun", auth.RequireRole(models.UserRoleMember), ai.RunTemplateEvaluationHandler)
+	router.GET("/api/aiTemplateEvalRuns", auth.RequireRole(models.UserRoleMember), ai.GetTemplateEvalRunsHandler)
+	router.GET("/api/aiTemplateEvalRun", auth.RequireRole(models.UserRoleMember), ai.GetTemplateEvalRunHandler)
+	router.GET("/api/aiTemplateEvalCompare", auth.RequireRole(models.UserRoleMember), ai.CompareTemplateEvalRunsHandler) // base=<run id>&candidate=<run id>
 
 	router.POST("api/databaseSchema", auth.RequireRole(models.UserRoleMember), ai.GenerateDatabaseDesignHandler)
+
+	// AI costs and monthly budgets
+	router.GET("/api/aiModelPrices", auth.RequireRole(models.UserRoleMember), ai.GetModelPricesHandler)
+	router.GET("/api/aiBudgets", auth.RequireRole(models.UserRoleMember), ai.GetAiBudgetsHandler)
+	router.POST("/api/aiBudget", auth.RequireRole(models.UserRoleAdmin), ai.NewAiBudgetHandler)
+	router.PUT("/api/aiBudget", auth.RequireRole(models.UserRoleAdmin), ai.UpdateAiBudgetHandler)
+	router.DELETE("/api/aiBudget", auth.RequireRole(models.UserRoleAdmin), ai.DeleteAiBudgetHandler)
+	router.GET("/api/aiUsageReport", auth.RequireRole(models.UserRoleAdmin), ai.AiUsageReportHandler) // group_by=user,model,feature,day
+
+	// Sensitive spans redacted before prompts leave the API, counts only
+	router.GET("/api/aiRedactionAudit", auth.RequireRole(models.UserRoleAdmin), aiRedaction.GetRedactionAuditHandler)
 }
 
 func InitDocumentMagicianRoutes(router *gin.Engine, auth *middlewares.AuthMiddleware) {
@@ -58,6 +89,35 @@ func InitAzOaiRoutes(router *gin.Engine, auth *middlewares.AuthMiddleware) {
 	router.PUT("/api/tenantAiTemplate/unpublish", auth.RequireRole(models.UserRoleMember), community.UnpublishTenantAiPromptTemplate)
 	router.POST("/api/tenantAiTemplate/clone", auth.RequireRole(models.UserRoleMember), community.ClonePublicAiPromptTemplate)
 
+	// Every saved configuration is an immutable version, conversations stay on the version they are pinned to
+	router.GET("/api/tenantAiTemplate/versions", auth.RequireRole(models.UserRoleMember), aiVersions.GetTemplateVersionsHandler)
+	router.GET("/api/tenantAiTemplate/version", auth.RequireRole(models.UserRoleMember), aiVersions.GetTemplateVersionHandler)
+	router.GET("/api/tenantAiTemplate/diff", auth.RequireRole(models.UserRoleMember), aiVersions.DiffTemplateVersionsHandler)  // from=<version>&to=<version, default latest>
+	router.POST("/api/tenantAiTemplate/rollback", auth.RequireRole(models.UserRoleMember), aiVersions.RollbackTemplateHandler) // Saves the version as the newest one
+	router.GET("/api/aiAssistant/versions", auth.RequireRole(models.UserRoleMember), aiVersions.GetAssistantVersionsHandler)
+	router.GET("/api/aiAssistant/diff", auth.RequireRole(models.UserRoleMember), aiVersions.DiffAssistantVersionsHandler)
+	router.POST("/api/aiAssistant/rollback", auth.RequirePlatformOperator(), aiVersions.RollbackAssistantHandler)
+	router.GET("/api/conversation/versions", auth.RequireRole(models.UserRoleMember), aiVersions.GetConversationVersionsHandler)
+	router.PUT("/api/conversation/versions", auth.RequireRole(models.UserRoleMember), aiVersions.PinConversationVersionsHandler) // Pin other versions or upgrade to the latest
+
+	// Knowledge bases are the named vector stores of the tenant, file search of assistants and projects uses them
+	router.GET("/api/aiKnowledgeBases", auth.RequireRole(models.UserRoleMember), aiKnowledge.GetKnowledgeBasesHandler)
+	router.GET("/api/aiKnowledgeBase/files", auth.RequireRole(models.UserRoleMember), aiKnowledge.GetKnowledgeFilesHandler) // store=<name>
+	router.GET("/api/aiKnowledgeBase/file", auth.RequireRole(models.UserRoleMember), aiKnowledge.GetKnowledgeFileStatusHandler)
+	router.POST("/api/aiKnowledgeBase/file", auth.RequireRole(models.UserRoleAdmin), aiKnowledge.UploadKnowledgeFileHandler) // store=<name>, creates the store when missing
+	router.DELETE("/api/aiKnowledgeBase/file", auth.RequireRole(models.UserRoleAdmin), aiKnowledge.DeleteKnowledgeFileHandler)
+	router.GET("/api/project/knowledgeBases", auth.RequireRole(models.UserRoleMember), aiKnowledge.GetProjectKnowledgeBasesHandler)
+	router.PUT("/api/project/knowledgeBases", auth.RequireRole(models.UserRoleMember), aiKnowledge.UpdateProjectKnowledgeBasesHandler) // Project owner or admin
+
+	// Solution Pilot assistants, selected by name in prompts. They are shared by all tenants,
+	// only platform operators change them.
+	router.GET("/api/aiAssistants", auth.RequireRole(models.UserRoleAdmin), ai.GetAssistantsHandler)
+	router.GET("/api/aiAssistant", auth.RequireRole(models.UserRoleAdmin), ai.GetAssistantHandler)
+	router.POST("/api/aiAssistant", auth.RequirePlatformOperator(), ai.NewAssistantHandler)
+	router.PUT("/api/aiAssistant", auth.RequirePlatformOperator(), ai.UpdateAssistantHandler)
+	router.DELETE("/api/aiAssistant", auth.RequirePlatformOperator(), ai.DeactivateAssistantHandler)
+	router.POST("/api/aiAssistant/dryRun", auth.RequireRole(models.UserRoleAdmin), ai.AssistantDryRunHandler) // Merged params of a prompt, nothing is sent
+
 	// All Soulution Pilot AI template handlers
 	router.GET("/api/spAiTemplate", auth.RequireRole(models.UserRoleMember), community.GetSpAiTemplate)
 	router.GET("/api/spAiTemplates", auth.RequireRole(models.UserRoleMember), community.GetSpAiTemplates)
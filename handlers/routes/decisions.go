@@ -47,7 +47,7 @@ func InitDecisionRoutes(router *gin.Engine, auth *middlewares.AuthMiddleware) {
 	// Decision Matrix Endpoints
 	// Matrix Object Enpoint
 	router.POST("/api/matrix", auth.RequireRole(models.UserRoleMember), decisions.NewMatrix)
-	router.GET("/api/matrix", auth.RequireRole(models.UserRoleMember), decisions.GetMatrix)
+	router.GET("/api/matrix", auth.RequireRole(models.UserRoleMember), decisions.GetMatrix) // scores=true adds the weighted results, sensitivity=true also the sensitivity analysis, aggregation picks the group rating
 	router.GET("/api/matrixs", auth.RequireRole(models.UserRoleMember), decisions.GetAllMatrixs)
 	router.PUT("/api/matrix", auth.RequireRole(models.UserRoleMember), decisions.UpdateMatrix)
 	router.DELETE("/api/matrix", auth.RequireRole(models.UserRoleMember), decisions.DeleteMatrix)
@@ -68,4 +68,18 @@ func InitDecisionRoutes(router *gin.Engine, auth *middlewares.AuthMiddleware) {
 
 	// Matrix User Rating
 	router.PUT("/api/matrixUserRating", auth.RequireRole(models.UserRoleMember), decisions.UpdateMatrixUserRating)
+	router.GET("/api/matrixUserRatings", auth.RequireRole(models.UserRoleMember), decisions.GetMatrixUserRatings)                // user_id limits the ratings to one participant
+	router.GET("/api/matrixUserRatings/aggregate", auth.RequireRole(models.UserRoleMember), decisions.GetMatrixRatingsAggregate) // aggregation=mean|median|trimmed_mean, trim=0.2
+
+	// Matrix AHP Endpoints, criteria weights from pairwise comparisons
+	router.GET("/api/matrixAhp", auth.RequireRole(models.UserRoleMember), decisions.GetMatrixAhp)
+	router.GET("/api/matrixAhp/comparisons", auth.RequireRole(models.UserRoleMember), decisions.GetMatrixPairwiseComparisons) // user_id limits the comparisons to one participant
+	router.PUT("/api/matrixAhp/comparisons", auth.RequireRole(models.UserRoleMember), decisions.UpdateMatrixPairwiseComparisons)
+	router.PUT("/api/matrixAhp/apply", auth.RequireRole(models.UserRoleMember), decisions.ApplyMatrixAhpWeights) // force=true applies inconsistent judgements
+
+	// Decision Lifecycle Endpoints, decision_type is tbar, pnc, swot or matrix
+	router.GET("/api/decisionLifecycle", auth.RequireRole(models.UserRoleMember), decisions.GetDecisionLifecycle)
+	router.POST("/api/decisionLifecycle/transition", auth.RequireRole(models.UserRoleMember), decisions.TransitionDecision)
+	router.PUT("/api/decisionReviewers", auth.RequireRole(models.UserRoleMember), decisions.UpdateDecisionReviewers)
+	router.POST("/api/decisionReview", auth.RequireRole(models.UserRoleMember), decisions.ReviewDecision) // approvals of the assigned reviewers accept the decision
 }
@@ -32,6 +32,7 @@ func InitProjectRoutes(router *gin.Engine, auth *middlewares.AuthMiddleware) {
 	router.GET("/api/conversations", auth.RequireRole(models.UserRoleMember), projects.GetConversations)
 	router.PUT("/api/conversation", auth.RequireRole(models.UserRoleMember), projects.UpdateConversation)
 	router.DELETE("/api/conversation", auth.RequireRole(models.UserRoleMember), projects.DeleteConversation)
+	router.POST("/api/conversation/branch", auth.RequireRole(models.UserRoleMember), projects.BranchConversation) // Fork from an earlier assistant message
 
 	// Creates new document from private templates
 	router.POST("/api/documentTemplate", auth.RequireRole(models.UserRoleMember), projects.NewDocumentFromTemplate)
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"sententiawebapi/handlers/apis/search"
+	"sententiawebapi/handlers/models"
+	"sententiawebapi/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+func InitSearchRoutes(router *gin.Engine, auth *middlewares.AuthMiddleware) {
+	// Tenant-wide search across projects, documents, diagrams, decisions and requirements
+	router.GET("/api/search", auth.RequireRole(models.UserRoleMember), search.GlobalSearch)
+}
@@ -261,6 +261,7 @@ func main() {
 
 	// Health Endpoints
 	router.GET("/api/health", utilities.HealthCheck)
+	router.GET("/api/health/details", auth.RequirePlatformOperator(), utilities.HealthDetails) // Breakers by name with their last error
 
 	// Tenant Management
 	routes.InitTenantManagement(router, auth)
@@ -272,6 +273,9 @@ func main() {
 	routes.InitDecisionRoutes(router, auth)
 	routes.InitDiagramsRoutes(router, auth)
 
+	// Search Endpoints
+	routes.InitSearchRoutes(router, auth)
+
 	// Ai Features Endpoints
 	// Azure Open AI
 
@@ -353,6 +357,19 @@ func main() {
 		log.Printf("[VAR]AUTH0_CLIENT_SECRET=[NOT CONFIGURED]")
 	}
 
+	// AI Related
+	log.Println("=== AI Variables ===")
+	if os.Getenv("AI_STREAM_TOKEN_SECRET") != "" {
+		log.Printf("[VAR]AI_STREAM_TOKEN_SECRET=[CONFIGURED]")
+	} else {
+		log.Printf("[VAR]AI_STREAM_TOKEN_SECRET=[NOT CONFIGURED]")
+	}
+	if os.Getenv("AI_REDACTION_SECRET") != "" {
+		log.Printf("[VAR]AI_REDACTION_SECRET=[CONFIGURED]")
+	} else {
+		log.Printf("[VAR]AI_REDACTION_SECRET=[NOT CONFIGURED]")
+	}
+
 	log.Println("==========================")
 
 	// Verify database connections before starting
@@ -73,6 +73,24 @@ func (auth *AuthMiddleware) ValidateQueryParamToken(secretEnvKey string, require
 	}
 }
 
+// a middleware that validates JWT and only lets platform operators through,
+// used for settings and diagnostics shared by all tenants
+func (auth *AuthMiddleware) RequirePlatformOperator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth.validator.ValidateJwt()(c)
+		if c.IsAborted() {
+			return
+		}
+
+		ValidatePlatformOperator()(c)
+		if c.IsAborted() {
+			return
+		}
+
+		c.Next()
+	}
+}
+
 // gets the claims from the token
 func (auth *AuthMiddleware) GetTokenClaims(c *gin.Context) validator.ValidatedClaims {
 	return auth.validator.GetTokenClaims(c)
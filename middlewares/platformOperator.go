@@ -0,0 +1,39 @@
+package middlewares
+
+import (
+	"net/http"
+	"os"
+	"sententiawebapi/handlers/models"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PlatformOperatorsEnv lists the user IDs of the platform operators, comma separated.
+// Tenant roles don't apply, a tenant admin only manages its own tenant.
+const PlatformOperatorsEnv = "PLATFORM_OPERATOR_IDS"
+
+func isPlatformOperator(userID string) bool {
+	for _, operatorID := range strings.Split(os.Getenv(PlatformOperatorsEnv), ",") {
+		if operatorID = strings.TrimSpace(operatorID); operatorID != "" && operatorID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidatePlatformOperator checks that the user of the validated JWT is a platform operator
+func ValidatePlatformOperator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get(models.UserId)
+		if id, ok := userID.(string); !ok || !isPlatformOperator(id) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "Insufficient permissions",
+				"code":  "PLATFORM_OPERATOR_REQUIRED",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,44 @@
+-- AI cost accounting and monthly budgets (handlers/apis/ai/usage, handlers/apis/ai/budgets.go).
+-- Every token usage row stores its cost, budgets sum the costs of the current month.
+-- Rows recorded before the columns existed have no cost and count as free.
+
+ALTER TABLE st_schema.tenant_token_usage
+    ADD COLUMN IF NOT EXISTS cached_tokens integer NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS feature text,
+    ADD COLUMN IF NOT EXISTS project_id uuid,
+    ADD COLUMN IF NOT EXISTS cost numeric(14, 6) NOT NULL DEFAULT 0;
+
+-- Monthly spending of a budget and the usage report
+CREATE INDEX IF NOT EXISTS tenant_token_usage_created_idx
+    ON st_schema.tenant_token_usage (tenant_id, created_at);
+
+-- Prices per 1K tokens, models missing here use the list prices of handlers/apis/ai/usage/prices.go
+CREATE TABLE IF NOT EXISTS st_schema.ai_model_prices (
+    model text PRIMARY KEY,
+    input_per_1k numeric(14, 8) NOT NULL,
+    cached_input_per_1k numeric(14, 8) NOT NULL DEFAULT 0,
+    output_per_1k numeric(14, 8) NOT NULL DEFAULT 0,
+    currency text NOT NULL DEFAULT 'USD'
+);
+
+-- scope_id is the user or the project of the budget, tenant budgets have none
+CREATE TABLE IF NOT EXISTS st_schema.ai_budgets (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id uuid NOT NULL,
+    scope text NOT NULL CHECK (scope IN ('tenant', 'user', 'project')),
+    scope_id uuid,
+    monthly_limit numeric(14, 2) NOT NULL CHECK (monthly_limit > 0),
+    soft_threshold numeric(6, 2) NOT NULL DEFAULT 80,
+    hard_threshold numeric(6, 2),
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    CHECK ((scope = 'tenant') = (scope_id IS NULL))
+);
+
+-- One budget per scope, also the lookup of the budgets that apply to a call
+CREATE UNIQUE INDEX IF NOT EXISTS ai_budgets_scope_idx
+    ON st_schema.ai_budgets (tenant_id, scope, scope_id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS ai_budgets_tenant_scope_idx
+    ON st_schema.ai_budgets (tenant_id)
+    WHERE scope = 'tenant';
@@ -0,0 +1,33 @@
+-- Files of the tenant knowledge bases and the knowledge bases of projects (handlers/apis/ai/knowledge).
+-- The knowledge bases themselves are OpenAI vector stores, OpenAiConfig.VectorStores maps their names to the store IDs.
+
+CREATE TABLE IF NOT EXISTS st_schema.ai_knowledge_files (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id uuid NOT NULL,
+    store_name text NOT NULL,
+    vector_store_id text NOT NULL,
+    file_id text NOT NULL,
+    filename text NOT NULL,
+    content_type text NOT NULL,
+    size_bytes bigint NOT NULL DEFAULT 0,
+    status text NOT NULL CHECK (status IN ('in_progress', 'completed', 'failed', 'cancelled')),
+    last_error text,
+    uploaded_by uuid NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS ai_knowledge_files_store_idx
+    ON st_schema.ai_knowledge_files (tenant_id, store_name, created_at);
+
+-- Knowledge bases prompts of the project are grounded in, they go with their project
+CREATE TABLE IF NOT EXISTS st_schema.project_knowledge_bases (
+    tenant_id uuid NOT NULL,
+    project_id uuid NOT NULL REFERENCES st_schema.projects (id) ON DELETE CASCADE,
+    store_name text NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (tenant_id, project_id, store_name)
+);
+
+CREATE INDEX IF NOT EXISTS project_knowledge_bases_store_idx
+    ON st_schema.project_knowledge_bases (tenant_id, store_name);
@@ -0,0 +1,33 @@
+-- Mutating AI tool calls waiting for the user approval (handlers/apis/ai/functions/pendingActions.go).
+-- One row per function call of the paused response, the read-only calls of the same response are
+-- stored as completed. Rows go with their conversation.
+
+CREATE TABLE IF NOT EXISTS st_schema.ai_pending_actions (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    conversation_id uuid NOT NULL REFERENCES st_schema.conversation (id) ON DELETE CASCADE,
+    response_id text NOT NULL,
+    call_id text NOT NULL,
+    position integer NOT NULL,
+    tool_name text NOT NULL,
+    arguments text NOT NULL,
+    preview jsonb,
+    status text NOT NULL CHECK (status IN ('pending', 'executing', 'approved', 'rejected', 'failed', 'completed')),
+    output text,
+    citations jsonb,
+    decision_comment text,
+    assistant_name text NOT NULL DEFAULT '',
+    resource_group_type text NOT NULL,
+    resource_group_id uuid,
+    resource_type text,
+    resource_id uuid,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    decided_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS ai_pending_actions_conversation_idx
+    ON st_schema.ai_pending_actions (tenant_id, conversation_id, status);
+
+CREATE INDEX IF NOT EXISTS ai_pending_actions_response_idx
+    ON st_schema.ai_pending_actions (tenant_id, response_id, position);
@@ -0,0 +1,18 @@
+-- Redactions of the AI requests (handlers/apis/ai/redaction/audit.go).
+-- One row per request with redactions, counts holds the redacted spans by rule. The values
+-- themselves are never stored here.
+
+CREATE TABLE IF NOT EXISTS st_schema.ai_redaction_audit (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    conversation_id uuid,
+    project_id uuid,
+    feature text,
+    counts jsonb NOT NULL,
+    total integer NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS ai_redaction_audit_tenant_idx
+    ON st_schema.ai_redaction_audit (tenant_id, created_at);
@@ -0,0 +1,27 @@
+-- Placeholders of redacted values by conversation (handlers/apis/ai/redaction/placeholders.go).
+-- Earlier turns stay in the model context, the values are kept so placeholders the model repeats
+-- in later turns are still restored. Values are stored sealed with AES-GCM, the key is derived
+-- from AI_REDACTION_SECRET. Rows go with their conversation.
+
+CREATE TABLE IF NOT EXISTS st_schema.ai_redaction_placeholders (
+    conversation_id uuid NOT NULL REFERENCES st_schema.conversation (id) ON DELETE CASCADE,
+    placeholder text NOT NULL,
+    tenant_id uuid NOT NULL,
+    encrypted_value bytea NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (conversation_id, placeholder)
+);
+
+-- Tables created with the plain text value column: the values are dropped, not migrated.
+-- Their placeholders are kept unrestored in the output of later turns.
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = 'st_schema' AND table_name = 'ai_redaction_placeholders' AND column_name = 'value'
+    ) THEN
+        DELETE FROM st_schema.ai_redaction_placeholders;
+        ALTER TABLE st_schema.ai_redaction_placeholders DROP COLUMN value;
+        ALTER TABLE st_schema.ai_redaction_placeholders ADD COLUMN IF NOT EXISTS encrypted_value bytea NOT NULL;
+    END IF;
+END $$;
@@ -0,0 +1,65 @@
+-- Test cases of tenant AI templates and their evaluation runs (handlers/apis/ai/evaluations.go).
+-- Results keep the name of their test case, they stay comparable after the case is changed or deleted.
+-- Test cases and runs go with their template.
+
+CREATE TABLE IF NOT EXISTS st_schema.ai_template_test_cases (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    template_id uuid NOT NULL REFERENCES st_schema.prompt_config_template (id) ON DELETE CASCADE,
+    tenant_id uuid NOT NULL,
+    name text NOT NULL,
+    prompt text NOT NULL,
+    project_id uuid,
+    assertions jsonb NOT NULL DEFAULT '[]',
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS ai_template_test_cases_template_idx
+    ON st_schema.ai_template_test_cases (tenant_id, template_id, created_at);
+
+CREATE TABLE IF NOT EXISTS st_schema.ai_template_eval_runs (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    template_id uuid NOT NULL REFERENCES st_schema.prompt_config_template (id) ON DELETE CASCADE,
+    tenant_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    label text NOT NULL,
+    source_template_id uuid,
+    source_version integer,
+    configuration jsonb,
+    model text NOT NULL,
+    status text NOT NULL CHECK (status IN ('running', 'completed', 'failed')),
+    passed integer NOT NULL DEFAULT 0,
+    failed integer NOT NULL DEFAULT 0,
+    prompt_tokens bigint NOT NULL DEFAULT 0,
+    completion_tokens bigint NOT NULL DEFAULT 0,
+    cost numeric(14, 6) NOT NULL DEFAULT 0,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS ai_template_eval_runs_template_idx
+    ON st_schema.ai_template_eval_runs (tenant_id, template_id, created_at);
+
+-- Running runs of a tenant, counted against the limit and failed once stale
+CREATE INDEX IF NOT EXISTS ai_template_eval_runs_running_idx
+    ON st_schema.ai_template_eval_runs (tenant_id, created_at)
+    WHERE status = 'running';
+
+CREATE TABLE IF NOT EXISTS st_schema.ai_template_eval_results (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    run_id uuid NOT NULL REFERENCES st_schema.ai_template_eval_runs (id) ON DELETE CASCADE,
+    tenant_id uuid NOT NULL,
+    case_id uuid NOT NULL,
+    case_name text NOT NULL,
+    output text NOT NULL DEFAULT '',
+    model text NOT NULL DEFAULT '',
+    prompt_tokens bigint NOT NULL DEFAULT 0,
+    completion_tokens bigint NOT NULL DEFAULT 0,
+    cost numeric(14, 6) NOT NULL DEFAULT 0,
+    latency_ms bigint NOT NULL DEFAULT 0,
+    passed boolean NOT NULL,
+    assertions jsonb NOT NULL DEFAULT '[]',
+    error text
+);
+
+CREATE INDEX IF NOT EXISTS ai_template_eval_results_run_idx
+    ON st_schema.ai_template_eval_results (run_id);
@@ -0,0 +1,36 @@
+-- Version history of tenant AI templates and assistants (handlers/apis/ai/versions).
+-- Versions are immutable snapshots, prompt_config_template and assistants hold the latest/active one.
+-- Conversations are pinned to the versions they started with.
+
+CREATE TABLE IF NOT EXISTS st_schema.prompt_config_template_versions (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    template_id uuid NOT NULL REFERENCES st_schema.prompt_config_template (id) ON DELETE CASCADE,
+    tenant_id uuid NOT NULL,
+    version integer NOT NULL,
+    ai_model text,
+    configuration jsonb,
+    changelog text,
+    created_by uuid,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    UNIQUE (template_id, version)
+);
+
+CREATE TABLE IF NOT EXISTS st_schema.assistant_versions (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    name text NOT NULL,
+    version integer NOT NULL,
+    config jsonb NOT NULL,
+    changelog text,
+    created_by uuid,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    UNIQUE (name, version)
+);
+
+-- Number of the active version, NULL until the assistant is first versioned
+ALTER TABLE st_schema.assistants
+    ADD COLUMN IF NOT EXISTS version integer;
+
+-- template_version is NULL until the conversation is pinned, assistant_versions maps assistant names to versions
+ALTER TABLE st_schema.conversation
+    ADD COLUMN IF NOT EXISTS template_version integer,
+    ADD COLUMN IF NOT EXISTS assistant_versions jsonb;
@@ -0,0 +1,6 @@
+-- Assistants are selected by name (handlers/apis/ai/assistants.go), creating an assistant with
+-- an existing name is refused with 409. Their version column comes with ai_versions.sql.
+-- Run this file outside of a transaction (CREATE INDEX CONCURRENTLY).
+
+CREATE UNIQUE INDEX CONCURRENTLY IF NOT EXISTS assistants_name_idx
+    ON st_schema.assistants (name);
@@ -0,0 +1,4 @@
+-- Sources of AI answers (handlers/apis/ai/newHistory.go), NULL for answers without any.
+
+ALTER TABLE st_schema.completion_prompt
+    ADD COLUMN IF NOT EXISTS citations jsonb;
@@ -0,0 +1,15 @@
+-- Conversation branches (handlers/apis/projects/conversations.go, handlers/apis/ai/newHistory.go).
+-- A branch points to its parent conversation and the assistant message it starts after, a branch
+-- of a deleted parent keeps its own messages. Only answers with a response_id can be branched from.
+-- CONCURRENTLY keeps the table writable while the index builds, so run this file outside of a
+-- transaction.
+
+ALTER TABLE st_schema.conversation
+    ADD COLUMN IF NOT EXISTS parent_conversation_id uuid REFERENCES st_schema.conversation (id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS parent_message_id uuid REFERENCES st_schema.completion_prompt (id) ON DELETE SET NULL;
+
+ALTER TABLE st_schema.completion_prompt
+    ADD COLUMN IF NOT EXISTS response_id text;
+
+CREATE INDEX CONCURRENTLY IF NOT EXISTS conversation_parent_idx
+    ON st_schema.conversation (parent_conversation_id) WHERE parent_conversation_id IS NOT NULL;
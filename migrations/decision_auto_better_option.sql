@@ -0,0 +1,9 @@
+-- Opt-in sync of the better option of T-bar and pros/cons analyses (handlers/apis/decisions/outcomes.go).
+-- When set, argument changes rewrite tbar_better_option or better_option with the computed outcome.
+-- Creating an analysis without the flag stores NULL, the handlers read it as false.
+
+ALTER TABLE st_schema.tbar_analysis
+    ADD COLUMN IF NOT EXISTS auto_better_option boolean DEFAULT false;
+
+ALTER TABLE st_schema.pnc_analysis
+    ADD COLUMN IF NOT EXISTS auto_better_option boolean DEFAULT false;
@@ -0,0 +1,64 @@
+-- Shared lifecycle of T-chart, pros/cons, SWOT and matrix decisions (handlers/apis/decisions/lifecycle.go).
+-- decision_id points into the table of the decision_type, so there are no foreign keys to the analyses.
+-- Reviews and transitions are the decision record. Only drafts that were never proposed can be deleted, a decision
+-- that was proposed once keeps its record, also after it went back to draft.
+-- Run decision_lifecycle_legacy_status.sql after this file.
+
+CREATE TABLE IF NOT EXISTS st_schema.decision_lifecycles (
+    decision_type text NOT NULL CHECK (decision_type IN ('tchart', 'pnc', 'swot', 'matrix')),
+    decision_id uuid NOT NULL,
+    tenant_id uuid NOT NULL,
+    state text NOT NULL DEFAULT 'draft'
+        CHECK (state IN ('draft', 'proposed', 'in_review', 'accepted', 'rejected', 'superseded')),
+    required_approvals integer NOT NULL DEFAULT 1 CHECK (required_approvals >= 1),
+    review_round integer NOT NULL DEFAULT 0, -- Incremented every time the decision goes into review
+    superseded_by_type text CHECK (superseded_by_type IN ('tchart', 'pnc', 'swot', 'matrix')),
+    superseded_by_id uuid,
+    updated_by uuid,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (decision_type, decision_id),
+    CHECK ((superseded_by_type IS NULL) = (superseded_by_id IS NULL))
+);
+
+CREATE TABLE IF NOT EXISTS st_schema.decision_reviewers (
+    decision_type text NOT NULL,
+    decision_id uuid NOT NULL,
+    tenant_id uuid NOT NULL,
+    reviewer_id uuid NOT NULL,
+    assigned_by uuid NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (decision_type, decision_id, reviewer_id),
+    FOREIGN KEY (decision_type, decision_id) REFERENCES st_schema.decision_lifecycles (decision_type, decision_id) ON DELETE CASCADE
+);
+
+-- One verdict per reviewer and review round
+CREATE TABLE IF NOT EXISTS st_schema.decision_reviews (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    decision_type text NOT NULL,
+    decision_id uuid NOT NULL,
+    tenant_id uuid NOT NULL,
+    reviewer_id uuid NOT NULL,
+    review_round integer NOT NULL,
+    verdict text NOT NULL CHECK (verdict IN ('approve', 'reject')),
+    comment text,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    UNIQUE (decision_type, decision_id, reviewer_id, review_round),
+    FOREIGN KEY (decision_type, decision_id) REFERENCES st_schema.decision_lifecycles (decision_type, decision_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS st_schema.decision_transitions (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    decision_type text NOT NULL,
+    decision_id uuid NOT NULL,
+    tenant_id uuid NOT NULL,
+    from_state text NOT NULL,
+    to_state text NOT NULL,
+    user_id uuid NOT NULL,
+    comment text,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    FOREIGN KEY (decision_type, decision_id) REFERENCES st_schema.decision_lifecycles (decision_type, decision_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS decision_transitions_decision_idx
+    ON st_schema.decision_transitions (decision_type, decision_id, created_at);
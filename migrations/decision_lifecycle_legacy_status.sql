@@ -0,0 +1,57 @@
+-- Moves the free-form statuses the analyses had before the lifecycle (handlers/apis/decisions/lifecycle.go)
+-- to lifecycle states. Decisions mapped past draft get a lifecycle with a transition naming the legacy status,
+-- every other status becomes draft. Run after decision_lifecycle.sql, running it again changes nothing.
+
+CREATE OR REPLACE FUNCTION pg_temp.legacy_decision_state(status text) RETURNS text
+LANGUAGE sql IMMUTABLE AS $$
+    SELECT CASE
+        WHEN s IN ('proposed', 'submitted') THEN 'proposed'
+        WHEN s IN ('in review', 'under review', 'review', 'pending review', 'pending approval') THEN 'in_review'
+        WHEN s IN ('accepted', 'approved', 'completed', 'complete', 'done', 'decided', 'final', 'closed') THEN 'accepted'
+        WHEN s IN ('rejected', 'declined') THEN 'rejected'
+        WHEN s IN ('superseded', 'replaced', 'deprecated') THEN 'superseded'
+        ELSE 'draft'
+    END
+    FROM (SELECT regexp_replace(lower(trim(status)), '[\s_-]+', ' ', 'g') AS s) normalized
+$$;
+
+DO $$
+DECLARE
+    d record;
+BEGIN
+    FOR d IN
+        SELECT * FROM (VALUES
+            ('tchart', 'st_schema.tbar_analysis', 'tbar_status'),
+            ('pnc', 'st_schema.pnc_analysis', 'pnc_status'),
+            ('swot', 'st_schema.swot_analysis', 'swot_status'),
+            ('matrix', 'st_schema.matrix_analysis', 'matrix_status')
+        ) AS decision_tables (decision_type, table_name, status_column)
+    LOOP
+        -- Decisions that were in or past review count as reviewed once
+        EXECUTE format($sql$
+            WITH migrated AS (
+                INSERT INTO st_schema.decision_lifecycles (decision_type, decision_id, tenant_id, state, required_approvals, review_round, updated_by)
+                SELECT %3$L, a.id, a.tenant_id, pg_temp.legacy_decision_state(a.%2$I), 1,
+                    CASE WHEN pg_temp.legacy_decision_state(a.%2$I) = 'proposed' THEN 0 ELSE 1 END,
+                    a.user_id
+                FROM %1$s a
+                WHERE pg_temp.legacy_decision_state(a.%2$I) <> 'draft'
+                ON CONFLICT (decision_type, decision_id) DO NOTHING
+                RETURNING decision_id, state
+            )
+            INSERT INTO st_schema.decision_transitions (decision_type, decision_id, tenant_id, from_state, to_state, user_id, comment)
+            SELECT %3$L, a.id, a.tenant_id, 'draft', m.state, a.user_id, format('Migrated from the legacy status %%L', a.%2$I)
+            FROM migrated m
+            INNER JOIN %1$s a ON a.id = m.decision_id
+        $sql$, d.table_name, d.status_column, d.decision_type);
+
+        -- The status mirrors the lifecycle, decisions without one are drafts
+        EXECUTE format($sql$
+            UPDATE %1$s a
+            SET %2$I = COALESCE(l.state, 'draft')
+            FROM %1$s self
+            LEFT JOIN st_schema.decision_lifecycles l ON l.decision_type = %3$L AND l.decision_id = self.id
+            WHERE self.id = a.id AND a.%2$I IS DISTINCT FROM COALESCE(l.state, 'draft')
+        $sql$, d.table_name, d.status_column, d.decision_type);
+    END LOOP;
+END $$;
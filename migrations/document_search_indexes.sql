@@ -0,0 +1,13 @@
+-- Full-text indexes of the template vector tables behind hybrid document_search
+-- (handlers/apis/ai/functions/documentSearch.go), project_document_vectors is indexed in
+-- global_search_indexes.sql. The expression must match the keyword query exactly, otherwise the
+-- planner can't use it. CONCURRENTLY keeps the tables writable while the indexes build, so run
+-- this file outside of a transaction.
+
+CREATE INDEX CONCURRENTLY IF NOT EXISTS document_template_vectors_search_idx ON st_schema.document_template_vectors USING gin (
+    to_tsvector('english', content)
+);
+
+CREATE INDEX CONCURRENTLY IF NOT EXISTS cm_document_template_vectors_search_idx ON st_schema.cm_document_template_vectors USING gin (
+    to_tsvector('english', content)
+);
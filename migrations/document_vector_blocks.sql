@@ -0,0 +1,31 @@
+-- Block-level chunks of the document vector tables (handlers/apis/ai/indexing/documents.go).
+-- Rows indexed before these columns existed have no block_id and are replaced on the next save
+-- of their document. CONCURRENTLY keeps the tables writable while the indexes build, so run this
+-- file outside of a transaction.
+
+ALTER TABLE st_schema.project_document_vectors
+    ADD COLUMN IF NOT EXISTS block_id text,
+    ADD COLUMN IF NOT EXISTS block_type text,
+    ADD COLUMN IF NOT EXISTS block_index integer,
+    ADD COLUMN IF NOT EXISTS content_hash text;
+
+ALTER TABLE st_schema.document_template_vectors
+    ADD COLUMN IF NOT EXISTS block_id text,
+    ADD COLUMN IF NOT EXISTS block_type text,
+    ADD COLUMN IF NOT EXISTS block_index integer,
+    ADD COLUMN IF NOT EXISTS content_hash text;
+
+ALTER TABLE st_schema.cm_document_template_vectors
+    ADD COLUMN IF NOT EXISTS block_id text,
+    ADD COLUMN IF NOT EXISTS block_type text,
+    ADD COLUMN IF NOT EXISTS block_index integer,
+    ADD COLUMN IF NOT EXISTS content_hash text;
+
+CREATE INDEX CONCURRENTLY IF NOT EXISTS project_document_vectors_block_idx
+    ON st_schema.project_document_vectors (tenant_id, document_id, block_id);
+
+CREATE INDEX CONCURRENTLY IF NOT EXISTS document_template_vectors_block_idx
+    ON st_schema.document_template_vectors (tenant_id, document_template_id, block_id);
+
+CREATE INDEX CONCURRENTLY IF NOT EXISTS cm_document_template_vectors_block_idx
+    ON st_schema.cm_document_template_vectors (tenant_id, cm_document_template_id, block_id);
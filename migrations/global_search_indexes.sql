@@ -0,0 +1,40 @@
+-- Full-text indexes behind /api/search (handlers/apis/search/globalSearch.go) and document_search.
+-- The expressions must match searchVector and the document queries exactly, otherwise the planner
+-- can't use them. CONCURRENTLY keeps the tables writable while the indexes build, so run this file
+-- outside of a transaction.
+
+CREATE INDEX CONCURRENTLY IF NOT EXISTS project_documents_title_search_idx ON st_schema.project_documents USING gin (
+    to_tsvector('english', COALESCE(title, ''))
+);
+
+CREATE INDEX CONCURRENTLY IF NOT EXISTS project_document_vectors_search_idx ON st_schema.project_document_vectors USING gin (
+    to_tsvector('english', content)
+);
+
+CREATE INDEX CONCURRENTLY IF NOT EXISTS projects_search_idx ON st_schema.projects USING gin (
+    (setweight(to_tsvector('english', COALESCE(title, '')), 'A') || setweight(to_tsvector('english', COALESCE(short_description, '') || E'\n' || COALESCE(description, '')), 'B'))
+);
+
+CREATE INDEX CONCURRENTLY IF NOT EXISTS diagrams_search_idx ON st_schema.diagrams USING gin (
+    (setweight(to_tsvector('english', COALESCE(title, '')), 'A') || setweight(to_tsvector('english', COALESCE(short_description, '') || E'\n' || COALESCE(diagram_digest, '')), 'B'))
+);
+
+CREATE INDEX CONCURRENTLY IF NOT EXISTS tbar_analysis_search_idx ON st_schema.tbar_analysis USING gin (
+    (setweight(to_tsvector('english', COALESCE(tbar_title, '')), 'A') || setweight(to_tsvector('english', COALESCE(tbar_description, '') || E'\n' || COALESCE(assumptions, '') || E'\n' || COALESCE(final_decision, '') || E'\n' || COALESCE(implications, '')), 'B'))
+);
+
+CREATE INDEX CONCURRENTLY IF NOT EXISTS pnc_analysis_search_idx ON st_schema.pnc_analysis USING gin (
+    (setweight(to_tsvector('english', COALESCE(title, '')), 'A') || setweight(to_tsvector('english', COALESCE(pnc_description, '') || E'\n' || COALESCE(assumptions, '') || E'\n' || COALESCE(final_decision, '') || E'\n' || COALESCE(implications, '')), 'B'))
+);
+
+CREATE INDEX CONCURRENTLY IF NOT EXISTS swot_analysis_search_idx ON st_schema.swot_analysis USING gin (
+    (setweight(to_tsvector('english', COALESCE(title, '')), 'A') || setweight(to_tsvector('english', COALESCE(swot_description, '') || E'\n' || COALESCE(assumptions, '') || E'\n' || COALESCE(final_decision, '') || E'\n' || COALESCE(implications, '')), 'B'))
+);
+
+CREATE INDEX CONCURRENTLY IF NOT EXISTS matrix_analysis_search_idx ON st_schema.matrix_analysis USING gin (
+    (setweight(to_tsvector('english', COALESCE(title, '')), 'A') || setweight(to_tsvector('english', COALESCE(matrix_description, '') || E'\n' || COALESCE(assumptions, '') || E'\n' || COALESCE(final_decision, '') || E'\n' || COALESCE(implications, '')), 'B'))
+);
+
+CREATE INDEX CONCURRENTLY IF NOT EXISTS project_requirements_search_idx ON st_schema.project_requirements USING gin (
+    (setweight(to_tsvector('english', COALESCE(title, '')), 'A') || setweight(to_tsvector('english', COALESCE(details, '')), 'B'))
+);
@@ -0,0 +1,21 @@
+-- AHP pairwise comparisons of matrix criteria (handlers/apis/decisions/matrixAhp.go).
+-- Every participant judges each pair once, pairs are stored in canonical order with the value from A's side.
+-- Applying the derived weights writes matrix_criteria.criteria_multiplier and criteria_multiplier_title.
+-- Comparisons go with their matrix and their criteria.
+
+CREATE TABLE IF NOT EXISTS st_schema.matrix_pairwise_comparisons (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    matrix_id uuid NOT NULL REFERENCES st_schema.matrix_analysis (id) ON DELETE CASCADE,
+    criteria_a_id uuid NOT NULL REFERENCES st_schema.matrix_criteria (id) ON DELETE CASCADE,
+    criteria_b_id uuid NOT NULL REFERENCES st_schema.matrix_criteria (id) ON DELETE CASCADE,
+    user_id uuid NOT NULL,
+    tenant_id uuid NOT NULL,
+    value double precision NOT NULL CHECK (value >= 1.0 / 9 - 1e-9 AND value <= 9 + 1e-9),
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    CHECK (criteria_a_id <> criteria_b_id),
+    UNIQUE (matrix_id, criteria_a_id, criteria_b_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS matrix_pairwise_comparisons_matrix_idx
+    ON st_schema.matrix_pairwise_comparisons (tenant_id, matrix_id);
@@ -0,0 +1,35 @@
+-- One rating per participant and matrix cell, UpdateMatrixUserRating (handlers/apis/decisions/matrix.go)
+-- upserts on this index and stamps updated_at. CONCURRENTLY keeps the table writable while the index
+-- builds, so run this file outside of a transaction. The file can be run again after a failed build.
+
+ALTER TABLE st_schema.matrix_user_ratings
+    ADD COLUMN IF NOT EXISTS updated_at timestamptz;
+
+ALTER TABLE st_schema.matrix_user_ratings
+    ALTER COLUMN updated_at SET DEFAULT now();
+
+-- A failed CONCURRENTLY build leaves an invalid index behind, IF NOT EXISTS would skip it
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM pg_index i
+        WHERE i.indexrelid = to_regclass('st_schema.matrix_user_ratings_cell_user_idx')
+            AND NOT i.indisvalid
+    ) THEN
+        DROP INDEX st_schema.matrix_user_ratings_cell_user_idx;
+    END IF;
+END $$;
+
+-- Duplicates left by concurrent saves are removed, the rating updated last is kept. Ratings saved before
+-- updated_at existed have none and lose against stamped ones, ties are broken by id.
+DELETE FROM st_schema.matrix_user_ratings r
+USING st_schema.matrix_user_ratings newer
+WHERE newer.criteria_id = r.criteria_id
+    AND newer.concept_id = r.concept_id
+    AND newer.user_id = r.user_id
+    AND newer.tenant_id = r.tenant_id
+    AND (COALESCE(newer.updated_at, '-infinity'), newer.id) > (COALESCE(r.updated_at, '-infinity'), r.id);
+
+CREATE UNIQUE INDEX CONCURRENTLY IF NOT EXISTS matrix_user_ratings_cell_user_idx ON st_schema.matrix_user_ratings (
+    criteria_id, concept_id, user_id, tenant_id
+);
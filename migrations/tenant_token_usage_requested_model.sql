@@ -0,0 +1,5 @@
+-- Model a request asked for when a fallback model served it (handlers/apis/ai/newPrompt.go),
+-- NULL when the requested model answered.
+
+ALTER TABLE st_schema.tenant_token_usage
+    ADD COLUMN IF NOT EXISTS requested_model text;
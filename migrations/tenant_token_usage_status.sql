@@ -0,0 +1,5 @@
+-- Outcome of the generation a token usage row belongs to (handlers/apis/ai/newPrompt.go).
+-- Rows recorded before the column existed were completed generations.
+
+ALTER TABLE st_schema.tenant_token_usage
+    ADD COLUMN IF NOT EXISTS status text NOT NULL DEFAULT 'completed';
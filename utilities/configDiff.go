@@ -0,0 +1,89 @@
+package utilities
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"sententiawebapi/handlers/models"
+)
+
+// DiffConfigs returns the differences between two JSON configurations, objects are compared
+// by key and arrays by index. Changes are sorted by path.
+func DiffConfigs(from []byte, to []byte) ([]models.ConfigChange, error) {
+	var fromValue, toValue any
+	if len(from) > 0 {
+		if err := json.Unmarshal(from, &fromValue); err != nil {
+			return nil, err
+		}
+	}
+	if len(to) > 0 {
+		if err := json.Unmarshal(to, &toValue); err != nil {
+			return nil, err
+		}
+	}
+
+	changes := []models.ConfigChange{}
+	diffValues("", fromValue, toValue, &changes)
+
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+
+	return changes, nil
+}
+
+func diffValues(path string, from any, to any, changes *[]models.ConfigChange) {
+	switch {
+	case from == nil && to == nil:
+		return
+	case from == nil:
+		*changes = append(*changes, models.ConfigChange{Path: path, Type: models.ConfigChangeAdded, To: to})
+		return
+	case to == nil:
+		*changes = append(*changes, models.ConfigChange{Path: path, Type: models.ConfigChangeRemoved, From: from})
+		return
+	}
+
+	fromObject, fromIsObject := from.(map[string]any)
+	toObject, toIsObject := to.(map[string]any)
+	if fromIsObject && toIsObject {
+		keys := make(map[string]bool)
+		for key := range fromObject {
+			keys[key] = true
+		}
+		for key := range toObject {
+			keys[key] = true
+		}
+		for key := range keys {
+			diffValues(joinConfigPath(path, key), fromObject[key], toObject[key], changes)
+		}
+		return
+	}
+
+	fromArray, fromIsArray := from.([]any)
+	toArray, toIsArray := to.([]any)
+	if fromIsArray && toIsArray {
+		for i := 0; i < max(len(fromArray), len(toArray)); i++ {
+			var fromItem, toItem any
+			if i < len(fromArray) {
+				fromItem = fromArray[i]
+			}
+			if i < len(toArray) {
+				toItem = toArray[i]
+			}
+			diffValues(fmt.Sprintf("%s[%d]", path, i), fromItem, toItem, changes)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(from, to) {
+		*changes = append(*changes, models.ConfigChange{Path: path, Type: models.ConfigChangeChanged, From: from, To: to})
+	}
+}
+
+func joinConfigPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,96 @@
+package utilities
+
+import (
+	"testing"
+
+	"sententiawebapi/handlers/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffConfigs(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		to   string
+		want []models.ConfigChange
+	}{
+		{
+			name: "equal",
+			from: `{"model":"gpt-4o","tools":{"web_search":true}}`,
+			to:   `{"tools":{"web_search":true},"model":"gpt-4o"}`,
+			want: []models.ConfigChange{},
+		},
+		{
+			name: "both empty",
+			want: []models.ConfigChange{},
+		},
+		{
+			name: "first version",
+			to:   `{"model":"gpt-4o"}`,
+			want: []models.ConfigChange{
+				{Path: "", Type: models.ConfigChangeAdded, To: map[string]any{"model": "gpt-4o"}},
+			},
+		},
+		{
+			name: "changed, added and removed keys sorted by path",
+			from: `{"temperature":0.2,"model":"gpt-4o","tools":{"web_search":true}}`,
+			to:   `{"temperature":0.7,"tools":{"web_search":false,"code":true}}`,
+			want: []models.ConfigChange{
+				{Path: "model", Type: models.ConfigChangeRemoved, From: "gpt-4o"},
+				{Path: "temperature", Type: models.ConfigChangeChanged, From: 0.2, To: 0.7},
+				{Path: "tools.code", Type: models.ConfigChangeAdded, To: true},
+				{Path: "tools.web_search", Type: models.ConfigChangeChanged, From: true, To: false},
+			},
+		},
+		{
+			name: "arrays are compared by index",
+			from: `{"function_calls":[{"name":"search"},{"name":"create"}]}`,
+			to:   `{"function_calls":[{"name":"search"},{"name":"update"},{"name":"delete"}]}`,
+			want: []models.ConfigChange{
+				{Path: "function_calls[1].name", Type: models.ConfigChangeChanged, From: "create", To: "update"},
+				{Path: "function_calls[2]", Type: models.ConfigChangeAdded, To: map[string]any{"name": "delete"}},
+			},
+		},
+		{
+			name: "shorter array",
+			from: `{"stop":["a","b"]}`,
+			to:   `{"stop":["a"]}`,
+			want: []models.ConfigChange{
+				{Path: "stop[1]", Type: models.ConfigChangeRemoved, From: "b"},
+			},
+		},
+		{
+			name: "type change",
+			from: `{"instructions":"Be brief"}`,
+			to:   `{"instructions":{"text":"Be brief"}}`,
+			want: []models.ConfigChange{
+				{Path: "instructions", Type: models.ConfigChangeChanged, From: "Be brief", To: map[string]any{"text": "Be brief"}},
+			},
+		},
+		{
+			name: "null value counts as missing",
+			from: `{"max_tokens":null}`,
+			to:   `{"max_tokens":1024}`,
+			want: []models.ConfigChange{
+				{Path: "max_tokens", Type: models.ConfigChangeAdded, To: float64(1024)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, err := DiffConfigs([]byte(tt.from), []byte(tt.to))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, changes)
+		})
+	}
+}
+
+func TestDiffConfigsInvalid(t *testing.T) {
+	_, err := DiffConfigs([]byte(`{"model":`), []byte(`{}`))
+	assert.Error(t, err)
+
+	_, err = DiffConfigs([]byte(`{}`), []byte(`[`))
+	assert.Error(t, err)
+}
@@ -54,3 +54,13 @@ func ProcessIdentity(c *gin.Context) (userID string, tenantID string, ok bool) {
 
 	return userID, tenantID, true
 }
+
+// IsTenantAdmin reports whether the role validated by the tenant middleware is admin
+func IsTenantAdmin(c *gin.Context) bool {
+	role, ok := c.Get("userRole")
+	if !ok {
+		return false
+	}
+	userRole, ok := role.(models.UserRole)
+	return ok && userRole == models.UserRoleAdmin
+}
@@ -0,0 +1,132 @@
+package utilities
+
+// Every AI task has a primary model and ordered fallbacks. Tenants override the defaults with
+// "model_routing" in st_schema.ai_providers.config_schema, e.g.
+//
+//	"model_routing": {"chat": {"primary": "gpt-4.1", "fallbacks": ["gpt-4o-mini"]}}
+//
+// A fallback is tried when the model is rate limited, times out or its output is filtered.
+// Other errors (invalid request, authentication) would fail with every model and are returned.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"sententiawebapi/handlers/models"
+
+	"github.com/openai/openai-go"
+)
+
+var defaultModelRoutes = map[models.AiTaskType]models.ModelRoute{
+	models.AiTaskChat:         {Primary: "gpt-4o-mini", Fallbacks: []string{"gpt-4.1-mini"}},
+	models.AiTaskMagician:     {Primary: "gpt-4o-mini", Fallbacks: []string{"gpt-4.1-mini"}},
+	models.AiTaskSchemaDesign: {Primary: "o3", Fallbacks: []string{"o4-mini"}},
+	models.AiTaskEvaluation:   {Primary: "gpt-4o-mini", Fallbacks: []string{"gpt-4.1-mini"}},
+	// Stored vectors are only comparable with the same model, fallbacks must be other deployments of it
+	models.AiTaskEmbeddings: {Primary: openai.EmbeddingModelTextEmbedding3Small},
+}
+
+// ErrContentFiltered is returned for responses stopped by the content filter of the provider
+var ErrContentFiltered = errors.New("response was stopped by the content filter")
+
+// fallbackStopped marks errors no other model should be tried for
+type fallbackStopped struct {
+	err error
+}
+
+func (e *fallbackStopped) Error() string { return e.err.Error() }
+func (e *fallbackStopped) Unwrap() error { return e.err }
+
+// StopModelFallback prevents the fallback after err, e.g. when a part of the output was already streamed
+func StopModelFallback(err error) error {
+	return &fallbackStopped{err: err}
+}
+
+// ResolveModelRoute returns the route of the task, the tenant config overrides the defaults
+func ResolveModelRoute(config *models.OpenAiConfig, task models.AiTaskType) models.ModelRoute {
+	route := defaultModelRoutes[task]
+	if config == nil {
+		return route
+	}
+
+	if tenantRoute, ok := config.ModelRouting[task]; ok {
+		if tenantRoute.Primary == "" {
+			tenantRoute.Primary = route.Primary
+		}
+		return tenantRoute
+	}
+
+	return route
+}
+
+// SupportsTemperature reports whether the model accepts a sampling temperature. Reasoning
+// models (o1, o3, o4-mini, gpt-5) reject requests that set one.
+func SupportsTemperature(model string) bool {
+	name := strings.ToLower(model)
+	if len(name) > 1 && name[0] == 'o' && name[1] >= '0' && name[1] <= '9' {
+		return false
+	}
+	return !strings.HasPrefix(name, "gpt-5") || strings.HasPrefix(name, "gpt-5-chat")
+}
+
+// IsModelFallbackError reports whether the next model of the route should be tried after err.
+// ctx is the context of the whole call, its cancellation never falls back.
+func IsModelFallbackError(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+
+	var stopped *fallbackStopped
+	if errors.As(err, &stopped) {
+		return false
+	}
+
+	// Timeout of the single attempt
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrContentFiltered) {
+		return true
+	}
+
+	var apiErr *openai.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	switch apiErr.StatusCode {
+	case http.StatusTooManyRequests, http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return true
+	}
+
+	code := strings.ToLower(apiErr.Code)
+	return strings.Contains(code, "content_filter") || strings.Contains(code, "content_policy")
+}
+
+// CallWithModelFallback calls the models of the route in order until one of them succeeds or
+// fails with an error a fallback can't help. Returns the result and the model that served it.
+func CallWithModelFallback[T any](ctx context.Context, route models.ModelRoute, call func(model string) (T, error)) (T, string, error) {
+	var result T
+	var err error
+
+	routeModels := route.Models()
+	if len(routeModels) == 0 {
+		return result, "", fmt.Errorf("no model configured")
+	}
+
+	for i, model := range routeModels {
+		result, err = call(model)
+		if err == nil {
+			return result, model, nil
+		}
+
+		if i == len(routeModels)-1 || !IsModelFallbackError(ctx, err) {
+			break
+		}
+
+		log.Printf("Model %s failed, falling back to %s: %v", model, routeModels[i+1], err)
+	}
+
+	return result, "", err
+}
@@ -0,0 +1,27 @@
+package utilities
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSupportsTemperature(t *testing.T) {
+	tests := []struct {
+		model string
+		want  bool
+	}{
+		{"gpt-4o-mini", true},
+		{"gpt-4.1", true},
+		{"o3", false},
+		{"o4-mini", false},
+		{"o1-preview", false},
+		{"gpt-5-mini", false},
+		{"gpt-5-chat-latest", true},
+		{"omni-deployment", true},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, SupportsTemperature(tt.model), tt.model)
+	}
+}
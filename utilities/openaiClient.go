@@ -0,0 +1,157 @@
+package utilities
+
+// The AI provider of a tenant is selected by "provider" in st_schema.ai_providers.config_schema:
+//   - openai (default): public OpenAI API authenticated with openai_api_key
+//   - azure: Azure OpenAI resource of the tenant at azure_endpoint. Without openai_api_key the
+//     managed identity of the API is used (the same identity that provisions the resources)
+//   - compatible: any OpenAI-compatible API at base_url, such as a local inference server
+//
+// The code keeps using OpenAI model names, "deployments" maps them to the Azure deployment
+// names or to the model names of the compatible provider.
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"sententiawebapi/handlers/models"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/azure"
+	"github.com/openai/openai-go/option"
+)
+
+// Responses API is only available in the preview versions of Azure OpenAI
+const defaultAzureApiVersion = "2025-03-01-preview"
+
+var (
+	azureCredential     azcore.TokenCredential
+	azureCredentialErr  error
+	azureCredentialOnce sync.Once
+)
+
+// NewOpenAiClient creates the client of the tenant AI provider
+func NewOpenAiClient(config *models.OpenAiConfig) (*openai.Client, error) {
+	var options []option.RequestOption
+
+	switch config.Provider {
+	case models.AiProviderOpenAI, "":
+		options = append(options, option.WithAPIKey(config.OpenAIApiKey))
+
+	case models.AiProviderAzure:
+		if config.AzureEndpoint == "" {
+			return nil, fmt.Errorf("azure provider requires azure_endpoint")
+		}
+
+		apiVersion := config.AzureApiVersion
+		if apiVersion == "" {
+			apiVersion = defaultAzureApiVersion
+		}
+
+		// Must run before the azure middleware, it moves the model into the deployment path
+		if len(config.Deployments) > 0 {
+			options = append(options, option.WithMiddleware(modelMappingMiddleware(config.Deployments)))
+		}
+		options = append(options, azure.WithEndpoint(config.AzureEndpoint, apiVersion))
+
+		if config.OpenAIApiKey != "" {
+			options = append(options, azure.WithAPIKey(config.OpenAIApiKey))
+		} else {
+			credential, err := getAzureCredential()
+			if err != nil {
+				return nil, fmt.Errorf("failed to get Azure credential: %w", err)
+			}
+			options = append(options, azure.WithTokenCredential(credential))
+		}
+
+	case models.AiProviderCompatible:
+		if config.BaseURL == "" {
+			return nil, fmt.Errorf("compatible provider requires base_url")
+		}
+
+		options = append(options, option.WithBaseURL(config.BaseURL))
+		if config.OpenAIApiKey != "" {
+			options = append(options, option.WithAPIKey(config.OpenAIApiKey))
+		}
+		if len(config.Deployments) > 0 {
+			options = append(options, option.WithMiddleware(modelMappingMiddleware(config.Deployments)))
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown AI provider: %s", config.Provider)
+	}
+
+	// Retries are done by the resilient transport of the dependency
+	options = append(options,
+		option.WithHTTPClient(ResilientHTTPClient(DependencyOpenAI)),
+		option.WithMaxRetries(0),
+	)
+
+	client := openai.NewClient(options...)
+
+	return &client, nil
+}
+
+// GetOpenAiClient loads the provider config of the tenant and creates its client
+func GetOpenAiClient(db *sql.DB, tenantID string) (*openai.Client, *models.OpenAiConfig, error) {
+	config, err := GetOpenAiConfig(db, tenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client, err := NewOpenAiClient(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return client, config, nil
+}
+
+// The credential caches its tokens, one instance is shared by all tenants
+func getAzureCredential() (azcore.TokenCredential, error) {
+	azureCredentialOnce.Do(func() {
+		azureCredential, azureCredentialErr = azidentity.NewDefaultAzureCredential(nil)
+	})
+	return azureCredential, azureCredentialErr
+}
+
+// modelMappingMiddleware replaces the model of JSON requests with its mapped name
+func modelMappingMiddleware(modelNames map[string]string) option.Middleware {
+	return func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+		if req.Body == nil || !strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+			return next(req)
+		}
+
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+
+		var payload map[string]json.RawMessage
+		var model string
+		if json.Unmarshal(body, &payload) == nil && json.Unmarshal(payload["model"], &model) == nil {
+			if mapped, ok := modelNames[model]; ok {
+				payload["model"], _ = json.Marshal(mapped)
+				if rewritten, err := json.Marshal(payload); err == nil {
+					body = rewritten
+				}
+			}
+		}
+
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+
+		return next(req)
+	}
+}
@@ -0,0 +1,468 @@
+package utilities
+
+// Outbound calls to OpenAI and Azure go through a resilient HTTP transport of their dependency:
+//   - bulkhead: limits the concurrent calls until their response headers arrive, a slow upstream
+//     can't take all connections of the API
+//   - circuit breaker (per host): after repeated failures calls fail fast until a probe succeeds
+//   - retries: jittered exponential backoff for transient errors, Retry-After of the upstream wins.
+//     Non-idempotent calls (POST /responses) are repeated only on rate limits and on connection
+//     errors before the request was written, a repeat must not bill a completion twice.
+//
+// The SDK retries are disabled for these clients, the transport is the only retry layer.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+)
+
+type Dependency string
+
+const (
+	DependencyOpenAI   Dependency = "openai"   // OpenAI, Azure OpenAI and compatible providers
+	DependencyKeyVault Dependency = "keyvault" // Azure Key Vault secrets
+	DependencyARM      Dependency = "arm"      // Azure Resource Manager
+)
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half_open" // One probe call decides whether the breaker closes
+)
+
+var (
+	ErrCircuitOpen  = errors.New("circuit breaker is open")
+	ErrBulkheadFull = errors.New("too many concurrent calls")
+)
+
+type resiliencePolicy struct {
+	MaxRetries    int
+	BaseDelay     time.Duration
+	MaxDelay      time.Duration // Longer Retry-After is not waited for, the error is returned
+	MaxConcurrent int           // Bulkhead size
+	MaxQueueWait  time.Duration // Wait for a free bulkhead slot
+
+	FailureThreshold int           // Consecutive failures opening the breaker
+	OpenDuration     time.Duration // Time before an open breaker lets a probe through
+}
+
+var resiliencePolicies = map[Dependency]resiliencePolicy{
+	DependencyOpenAI: {
+		MaxRetries:       3,
+		BaseDelay:        500 * time.Millisecond,
+		MaxDelay:         20 * time.Second,
+		MaxConcurrent:    64,
+		MaxQueueWait:     10 * time.Second,
+		FailureThreshold: 5,
+		OpenDuration:     30 * time.Second,
+	},
+	DependencyKeyVault: {
+		MaxRetries:       3,
+		BaseDelay:        200 * time.Millisecond,
+		MaxDelay:         10 * time.Second,
+		MaxConcurrent:    16,
+		MaxQueueWait:     5 * time.Second,
+		FailureThreshold: 5,
+		OpenDuration:     30 * time.Second,
+	},
+	DependencyARM: {
+		MaxRetries:       4,
+		BaseDelay:        1 * time.Second,
+		MaxDelay:         30 * time.Second,
+		MaxConcurrent:    8,
+		MaxQueueWait:     30 * time.Second,
+		FailureThreshold: 5,
+		OpenDuration:     60 * time.Second,
+	},
+}
+
+type circuitBreaker struct {
+	mu        sync.Mutex
+	name      string
+	state     BreakerState
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	lastError string
+	policy    resiliencePolicy
+}
+
+type callOutcome int
+
+const (
+	outcomeSuccess callOutcome = iota
+	outcomeFailure
+	outcomeIgnored // Cancelled by the caller, says nothing about the upstream
+)
+
+// allow reports whether a call may be sent, an open breaker lets one probe through after OpenDuration
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.policy.OpenDuration {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return true
+	case BreakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+func (b *circuitBreaker) record(outcome callOutcome, cause string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen {
+		b.probing = false
+	}
+
+	switch outcome {
+	case outcomeSuccess:
+		if b.state != BreakerClosed {
+			log.Printf("Circuit breaker %s closed", b.name)
+		}
+		b.state = BreakerClosed
+		b.failures = 0
+	case outcomeFailure:
+		b.failures++
+		b.lastError = cause
+		if b.state == BreakerHalfOpen || b.failures >= b.policy.FailureThreshold {
+			if b.state != BreakerOpen {
+				log.Printf("Circuit breaker %s opened after %d failures: %s", b.name, b.failures, cause)
+			}
+			b.state = BreakerOpen
+			b.openedAt = time.Now()
+		}
+	}
+}
+
+type bulkhead struct {
+	slots    chan struct{}
+	rejected atomic.Int64
+}
+
+func (b *bulkhead) acquire(ctx context.Context, wait time.Duration) error {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		b.rejected.Add(1)
+		return ErrBulkheadFull
+	}
+}
+
+func (b *bulkhead) release() {
+	<-b.slots
+}
+
+var (
+	resilienceMu    sync.Mutex
+	circuitBreakers = make(map[string]*circuitBreaker)
+	bulkheads       = make(map[Dependency]*bulkhead)
+	resilientHTTP   = make(map[Dependency]*http.Client)
+)
+
+func getCircuitBreaker(dependency Dependency, host string) *circuitBreaker {
+	name := string(dependency) + ":" + host
+
+	resilienceMu.Lock()
+	defer resilienceMu.Unlock()
+
+	breaker, ok := circuitBreakers[name]
+	if !ok {
+		breaker = &circuitBreaker{name: name, state: BreakerClosed, policy: resiliencePolicies[dependency]}
+		circuitBreakers[name] = breaker
+	}
+	return breaker
+}
+
+// ResilientHTTPClient returns the shared HTTP client of the dependency
+func ResilientHTTPClient(dependency Dependency) *http.Client {
+	resilienceMu.Lock()
+	defer resilienceMu.Unlock()
+
+	if client, ok := resilientHTTP[dependency]; ok {
+		return client
+	}
+
+	depPolicy := resiliencePolicies[dependency]
+	bh := &bulkhead{slots: make(chan struct{}, depPolicy.MaxConcurrent)}
+	bulkheads[dependency] = bh
+
+	// No client timeout, streamed responses are limited by the request context
+	client := &http.Client{
+		Transport: &resilientTransport{
+			dependency: dependency,
+			policy:     depPolicy,
+			bulkhead:   bh,
+			next:       http.DefaultTransport,
+		},
+	}
+	resilientHTTP[dependency] = client
+
+	return client
+}
+
+// AzureClientOptions routes the calls of an Azure SDK client through the resilient transport
+func AzureClientOptions(dependency Dependency) policy.ClientOptions {
+	return policy.ClientOptions{
+		Transport: ResilientHTTPClient(dependency),
+		Retry:     policy.RetryOptions{MaxRetries: -1},
+	}
+}
+
+// KeyVaultClientOptions is AzureClientOptions for the Key Vault secrets clients
+func KeyVaultClientOptions() *azsecrets.ClientOptions {
+	return &azsecrets.ClientOptions{ClientOptions: AzureClientOptions(DependencyKeyVault)}
+}
+
+// ArmClientOptions is AzureClientOptions for the resource manager clients
+func ArmClientOptions() *arm.ClientOptions {
+	return &arm.ClientOptions{ClientOptions: AzureClientOptions(DependencyARM)}
+}
+
+type resilientTransport struct {
+	dependency Dependency
+	policy     resiliencePolicy
+	bulkhead   *bulkhead
+	next       http.RoundTripper
+}
+
+func (t *resilientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	breaker := getCircuitBreaker(t.dependency, req.URL.Host)
+	if !breaker.allow() {
+		return nil, fmt.Errorf("%w: %s", ErrCircuitOpen, breaker.name)
+	}
+
+	if err := t.bulkhead.acquire(ctx, t.policy.MaxQueueWait); err != nil {
+		breaker.record(outcomeIgnored, "")
+		return nil, fmt.Errorf("%s: %w", t.dependency, err)
+	}
+	release := sync.OnceFunc(t.bulkhead.release)
+
+	// Requests without a replayable body can be sent only once
+	canRetry := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	idempotent := isIdempotent(req.Method)
+
+	for attempt := 0; ; attempt++ {
+		attemptReq := req
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				release()
+				breaker.record(outcomeIgnored, "")
+				return nil, err
+			}
+			attemptReq = req.Clone(ctx)
+			attemptReq.Body = body
+		}
+
+		// Once the headers are written the upstream may be processing the request
+		var sent atomic.Bool
+		attemptReq = attemptReq.WithContext(httptrace.WithClientTrace(attemptReq.Context(), &httptrace.ClientTrace{
+			WroteHeaders: func() { sent.Store(true) },
+		}))
+
+		resp, err := t.next.RoundTrip(attemptReq)
+
+		delay, retry := t.retryDelay(ctx, resp, err, attempt, idempotent || !sent.Load())
+		if !retry || !canRetry {
+			breaker.record(callResult(ctx, resp, err))
+			// The slot is freed once the headers arrive, long streamed responses don't starve other calls
+			release()
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			release()
+			breaker.record(outcomeIgnored, "")
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// retryDelay returns the wait before the next attempt and whether the call should be retried.
+// repeatable is false for non-idempotent requests the upstream may have received, those are
+// retried only on rate limits which are rejected before any work is done.
+func (t *resilientTransport) retryDelay(ctx context.Context, resp *http.Response, err error, attempt int, repeatable bool) (time.Duration, bool) {
+	if attempt >= t.policy.MaxRetries || ctx.Err() != nil {
+		return 0, false
+	}
+
+	if err != nil && !repeatable {
+		return 0, false
+	}
+	if err == nil && !isRetryableStatus(resp.StatusCode) {
+		return 0, false
+	}
+	if err == nil && !repeatable && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	delay, ok := retryAfter(resp)
+	if ok {
+		if delay > t.policy.MaxDelay {
+			return 0, false
+		}
+	} else {
+		// Exponential backoff with equal jitter
+		backoff := min(t.policy.BaseDelay<<attempt, t.policy.MaxDelay)
+		delay = backoff/2 + rand.N(backoff/2+1)
+	}
+
+	// No point in waiting when the caller gives up before the next attempt
+	if deadline, hasDeadline := ctx.Deadline(); hasDeadline && time.Now().Add(delay).After(deadline) {
+		return 0, false
+	}
+
+	return delay, true
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func isRetryableStatus(status int) bool {
+	switch status {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests,
+		http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryAfter reads the wait requested by the upstream, OpenAI sends retry-after-ms as well
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+
+	if ms, err := strconv.ParseFloat(resp.Header.Get("Retry-After-Ms"), 64); err == nil && ms >= 0 {
+		return time.Duration(ms * float64(time.Millisecond)), true
+	}
+
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+
+	return 0, false
+}
+
+// callResult classifies the final result of a call for the circuit breaker.
+// Rate limits and client errors mean the upstream is reachable.
+func callResult(ctx context.Context, resp *http.Response, err error) (callOutcome, string) {
+	if err != nil {
+		if ctx.Err() != nil {
+			return outcomeIgnored, ""
+		}
+		return outcomeFailure, err.Error()
+	}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return outcomeFailure, resp.Status
+	}
+	return outcomeSuccess, ""
+}
+
+type CircuitBreakerStatus struct {
+	Name      string       `json:"name"`
+	State     BreakerState `json:"state"`
+	Failures  int          `json:"failures"`
+	OpenedAt  *time.Time   `json:"opened_at,omitempty"`
+	LastError string       `json:"last_error,omitempty"`
+}
+
+type BulkheadStatus struct {
+	Dependency Dependency `json:"dependency"`
+	InUse      int        `json:"in_use"`
+	Capacity   int        `json:"capacity"`
+	Rejected   int64      `json:"rejected"`
+}
+
+// ResilienceStatus returns the state of all circuit breakers and bulkheads, sorted by name
+func ResilienceStatus() ([]CircuitBreakerStatus, []BulkheadStatus) {
+	resilienceMu.Lock()
+	defer resilienceMu.Unlock()
+
+	breakers := make([]CircuitBreakerStatus, 0, len(circuitBreakers))
+	for _, breaker := range circuitBreakers {
+		breaker.mu.Lock()
+		status := CircuitBreakerStatus{
+			Name:      breaker.name,
+			State:     breaker.state,
+			Failures:  breaker.failures,
+			LastError: breaker.lastError,
+		}
+		if breaker.state != BreakerClosed {
+			openedAt := breaker.openedAt
+			status.OpenedAt = &openedAt
+		}
+		breaker.mu.Unlock()
+		breakers = append(breakers, status)
+	}
+	sort.Slice(breakers, func(i, j int) bool { return breakers[i].Name < breakers[j].Name })
+
+	bulkheadStatuses := make([]BulkheadStatus, 0, len(bulkheads))
+	for dependency, bh := range bulkheads {
+		bulkheadStatuses = append(bulkheadStatuses, BulkheadStatus{
+			Dependency: dependency,
+			InUse:      len(bh.slots),
+			Capacity:   cap(bh.slots),
+			Rejected:   bh.rejected.Load(),
+		})
+	}
+	sort.Slice(bulkheadStatuses, func(i, j int) bool { return bulkheadStatuses[i].Dependency < bulkheadStatuses[j].Dependency })
+
+	return breakers, bulkheadStatuses
+}
@@ -0,0 +1,462 @@
+package utilities
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type stubResponse struct {
+	status int
+	header map[string]string
+	err    error
+	unsent bool // err happened before the request headers were written
+}
+
+// stubTransport answers with the scripted responses, the last one repeats
+type stubTransport struct {
+	mu        sync.Mutex
+	responses []stubResponse
+	bodies    []string
+}
+
+func (s *stubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var body string
+	if req.Body != nil {
+		data, _ := io.ReadAll(req.Body)
+		body = string(data)
+	}
+	s.bodies = append(s.bodies, body)
+
+	next := s.responses[min(len(s.bodies), len(s.responses))-1]
+	if trace := httptrace.ContextClientTrace(req.Context()); trace != nil && !next.unsent {
+		trace.WroteHeaders()
+	}
+	if next.err != nil {
+		return nil, next.err
+	}
+
+	resp := &http.Response{
+		StatusCode: next.status,
+		Status:     http.StatusText(next.status),
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}
+	for key, value := range next.header {
+		resp.Header.Set(key, value)
+	}
+	return resp, nil
+}
+
+func (s *stubTransport) calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.bodies)
+}
+
+var testPolicy = resiliencePolicy{
+	MaxRetries:       2,
+	BaseDelay:        time.Millisecond,
+	MaxDelay:         50 * time.Millisecond,
+	MaxConcurrent:    2,
+	MaxQueueWait:     10 * time.Millisecond,
+	FailureThreshold: 2,
+	OpenDuration:     time.Minute,
+}
+
+// newTestTransport registers the policy under a dependency of the test, every test gets its own breakers
+func newTestTransport(t *testing.T, policy resiliencePolicy, next http.RoundTripper) *resilientTransport {
+	dependency := Dependency("test:" + t.Name())
+
+	resilienceMu.Lock()
+	resiliencePolicies[dependency] = policy
+	resilienceMu.Unlock()
+	t.Cleanup(func() {
+		resilienceMu.Lock()
+		delete(resiliencePolicies, dependency)
+		delete(circuitBreakers, string(dependency)+":upstream.test")
+		resilienceMu.Unlock()
+	})
+
+	return &resilientTransport{
+		dependency: dependency,
+		policy:     policy,
+		bulkhead:   &bulkhead{slots: make(chan struct{}, policy.MaxConcurrent)},
+		next:       next,
+	}
+}
+
+func send(transport http.RoundTripper, method string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, "https://upstream.test/v1/responses", body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := transport.RoundTrip(req)
+	if resp != nil {
+		resp.Body.Close()
+	}
+	return resp, err
+}
+
+func TestResilientTransportRetries(t *testing.T) {
+	errReset := errors.New("connection reset")
+
+	tests := []struct {
+		name       string
+		method     string // GET when empty
+		responses  []stubResponse
+		body       io.Reader
+		wantCalls  int
+		wantStatus int
+		wantErr    error
+	}{
+		{
+			name:       "success",
+			responses:  []stubResponse{{status: http.StatusOK}},
+			wantCalls:  1,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "transient status is retried",
+			responses:  []stubResponse{{status: http.StatusServiceUnavailable}, {status: http.StatusOK}},
+			wantCalls:  2,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "rate limit is retried",
+			responses:  []stubResponse{{status: http.StatusTooManyRequests, header: map[string]string{"Retry-After-Ms": "1"}}, {status: http.StatusOK}},
+			wantCalls:  2,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "network error is retried",
+			responses:  []stubResponse{{err: errReset}, {status: http.StatusOK}},
+			wantCalls:  2,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "retries are limited",
+			responses:  []stubResponse{{status: http.StatusBadGateway}},
+			wantCalls:  3,
+			wantStatus: http.StatusBadGateway,
+		},
+		{
+			name:      "last error is returned",
+			responses: []stubResponse{{err: errReset}},
+			wantCalls: 3,
+			wantErr:   errReset,
+		},
+		{
+			name:       "client error is not retried",
+			responses:  []stubResponse{{status: http.StatusBadRequest}},
+			wantCalls:  1,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Retry-After longer than the max delay is not waited for",
+			responses:  []stubResponse{{status: http.StatusTooManyRequests, header: map[string]string{"Retry-After": "60"}}, {status: http.StatusOK}},
+			wantCalls:  1,
+			wantStatus: http.StatusTooManyRequests,
+		},
+		{
+			name:       "body that can't be replayed is sent once",
+			method:     http.MethodPost,
+			responses:  []stubResponse{{status: http.StatusTooManyRequests}, {status: http.StatusOK}},
+			body:       io.MultiReader(strings.NewReader("payload")),
+			wantCalls:  1,
+			wantStatus: http.StatusTooManyRequests,
+		},
+		{
+			name:       "transient status is not retried for POST",
+			method:     http.MethodPost,
+			responses:  []stubResponse{{status: http.StatusServiceUnavailable}, {status: http.StatusOK}},
+			wantCalls:  1,
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:       "rate limit is retried for POST",
+			method:     http.MethodPost,
+			responses:  []stubResponse{{status: http.StatusTooManyRequests}, {status: http.StatusOK}},
+			wantCalls:  2,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "network error before sending is retried for POST",
+			method:     http.MethodPost,
+			responses:  []stubResponse{{err: errReset, unsent: true}, {status: http.StatusOK}},
+			wantCalls:  2,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:      "network error after sending is not retried for POST",
+			method:    http.MethodPost,
+			responses: []stubResponse{{err: errReset}, {status: http.StatusOK}},
+			wantCalls: 1,
+			wantErr:   errReset,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := testPolicy
+			policy.FailureThreshold = 10
+			stub := &stubTransport{responses: tt.responses}
+			transport := newTestTransport(t, policy, stub)
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+
+			resp, err := send(transport, method, tt.body)
+
+			assert.Equal(t, tt.wantCalls, stub.calls())
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, resp)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			}
+			assert.Empty(t, transport.bulkhead.slots, "all bulkhead slots are released")
+		})
+	}
+}
+
+func TestResilientTransportReplaysBody(t *testing.T) {
+	stub := &stubTransport{responses: []stubResponse{{status: http.StatusTooManyRequests}, {status: http.StatusOK}}}
+	transport := newTestTransport(t, testPolicy, stub)
+
+	resp, err := send(transport, http.MethodPost, strings.NewReader("payload"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{"payload", "payload"}, stub.bodies)
+}
+
+func TestResilientTransportCancelledWhileWaiting(t *testing.T) {
+	policy := testPolicy
+	policy.BaseDelay = time.Minute
+	policy.MaxDelay = time.Minute
+	stub := &stubTransport{responses: []stubResponse{{status: http.StatusServiceUnavailable, header: map[string]string{"Retry-After": "30"}}}}
+	transport := newTestTransport(t, policy, stub)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://upstream.test/v1/models", nil)
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	resp, err := transport.RoundTrip(req)
+
+	assert.Nil(t, resp)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, stub.calls())
+
+	// The cancelled call says nothing about the upstream
+	breaker := getCircuitBreaker(transport.dependency, "upstream.test")
+	assert.Equal(t, BreakerClosed, breaker.state)
+	assert.Zero(t, breaker.failures)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	policy := testPolicy
+	policy.MaxRetries = 0
+	stub := &stubTransport{responses: []stubResponse{{status: http.StatusServiceUnavailable}}}
+	transport := newTestTransport(t, policy, stub)
+	breaker := getCircuitBreaker(transport.dependency, "upstream.test")
+
+	// Failures below the threshold keep it closed
+	_, err := send(transport, http.MethodGet, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, BreakerClosed, breaker.state)
+
+	// The threshold opens it and calls fail fast without reaching the upstream
+	_, err = send(transport, http.MethodGet, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, BreakerOpen, breaker.state)
+	assert.Equal(t, "Service Unavailable", breaker.lastError)
+
+	_, err = send(transport, http.MethodGet, nil)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, 2, stub.calls())
+
+	// After the open duration one probe goes through, a failed probe opens it again
+	breaker.openedAt = time.Now().Add(-policy.OpenDuration)
+	_, err = send(transport, http.MethodGet, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, stub.calls())
+	assert.Equal(t, BreakerOpen, breaker.state)
+
+	// A successful probe closes it
+	breaker.openedAt = time.Now().Add(-policy.OpenDuration)
+	stub.responses = []stubResponse{{status: http.StatusOK}}
+	_, err = send(transport, http.MethodGet, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, BreakerClosed, breaker.state)
+	assert.Zero(t, breaker.failures)
+}
+
+func TestCircuitBreakerSingleProbe(t *testing.T) {
+	breaker := &circuitBreaker{name: "test", state: BreakerOpen, openedAt: time.Now().Add(-time.Hour), policy: testPolicy}
+
+	assert.True(t, breaker.allow())
+	assert.Equal(t, BreakerHalfOpen, breaker.state)
+	assert.False(t, breaker.allow(), "a second call waits for the probe")
+
+	// A probe cancelled by its caller lets the next call probe
+	breaker.record(outcomeIgnored, "")
+	assert.Equal(t, BreakerHalfOpen, breaker.state)
+	assert.True(t, breaker.allow())
+}
+
+func TestCallResult(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		status int
+		err    error
+		want   callOutcome
+	}{
+		{"success", context.Background(), http.StatusOK, nil, outcomeSuccess},
+		{"rate limit means the upstream is reachable", context.Background(), http.StatusTooManyRequests, nil, outcomeSuccess},
+		{"client error", context.Background(), http.StatusNotFound, nil, outcomeSuccess},
+		{"server error", context.Background(), http.StatusBadGateway, nil, outcomeFailure},
+		{"network error", context.Background(), 0, errors.New("dial tcp: timeout"), outcomeFailure},
+		{"cancelled by the caller", cancelled, 0, context.Canceled, outcomeIgnored},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp *http.Response
+			if tt.err == nil {
+				resp = &http.Response{StatusCode: tt.status}
+			}
+			outcome, _ := callResult(tt.ctx, resp, tt.err)
+			assert.Equal(t, tt.want, outcome)
+		})
+	}
+}
+
+// blockingTransport answers once unblock is closed
+type blockingTransport struct {
+	started chan struct{}
+	unblock chan struct{}
+}
+
+func (b *blockingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	b.started <- struct{}{}
+	<-b.unblock
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}, nil
+}
+
+func TestBulkhead(t *testing.T) {
+	policy := testPolicy
+	policy.MaxConcurrent = 1
+	upstream := &blockingTransport{started: make(chan struct{}, 1), unblock: make(chan struct{})}
+	transport := newTestTransport(t, policy, upstream)
+
+	held := make(chan *http.Response)
+	go func() {
+		req, _ := http.NewRequest(http.MethodGet, "https://upstream.test/v1/models", nil)
+		resp, _ := transport.RoundTrip(req)
+		held <- resp
+	}()
+	<-upstream.started
+
+	// The slot is held while the first call waits for its response headers
+	_, err := send(transport, http.MethodGet, nil)
+	assert.ErrorIs(t, err, ErrBulkheadFull)
+	assert.Equal(t, int64(1), transport.bulkhead.rejected.Load())
+
+	// Headers arrived, the slot is free while the body is still open
+	close(upstream.unblock)
+	resp := <-held
+	assert.Empty(t, transport.bulkhead.slots)
+
+	_, err = send(transport, http.MethodGet, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, transport.bulkhead.slots)
+	resp.Body.Close()
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header map[string]string
+		want   time.Duration
+		wantOk bool
+	}{
+		{"no header", nil, 0, false},
+		{"milliseconds", map[string]string{"Retry-After-Ms": "1500"}, 1500 * time.Millisecond, true},
+		{"milliseconds win", map[string]string{"Retry-After-Ms": "200", "Retry-After": "5"}, 200 * time.Millisecond, true},
+		{"seconds", map[string]string{"Retry-After": "2"}, 2 * time.Second, true},
+		{"date in the past", map[string]string{"Retry-After": "Mon, 02 Jan 2006 15:04:05 GMT"}, 0, true},
+		{"invalid", map[string]string{"Retry-After": "soon"}, 0, false},
+		{"negative", map[string]string{"Retry-After": "-1"}, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{Header: make(http.Header)}
+			for key, value := range tt.header {
+				resp.Header.Set(key, value)
+			}
+			delay, ok := retryAfter(resp)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.want, delay)
+		})
+	}
+
+	_, ok := retryAfter(nil)
+	assert.False(t, ok)
+}
+
+func TestRetryDelay(t *testing.T) {
+	transport := &resilientTransport{policy: testPolicy}
+	unavailable := &http.Response{StatusCode: http.StatusServiceUnavailable, Header: make(http.Header)}
+
+	for attempt := range testPolicy.MaxRetries {
+		backoff := min(testPolicy.BaseDelay<<attempt, testPolicy.MaxDelay)
+		delay, retry := transport.retryDelay(context.Background(), unavailable, nil, attempt, true)
+		assert.True(t, retry)
+		assert.GreaterOrEqual(t, delay, backoff/2)
+		assert.LessOrEqual(t, delay, backoff)
+	}
+
+	_, retry := transport.retryDelay(context.Background(), unavailable, nil, testPolicy.MaxRetries, true)
+	assert.False(t, retry, "retries are limited")
+
+	// A request the upstream may have received is repeated only on rate limits
+	_, retry = transport.retryDelay(context.Background(), unavailable, nil, 0, false)
+	assert.False(t, retry)
+	rateLimited := &http.Response{StatusCode: http.StatusTooManyRequests, Header: make(http.Header)}
+	_, retry = transport.retryDelay(context.Background(), rateLimited, nil, 0, false)
+	assert.True(t, retry)
+
+	// No retry when the caller's deadline passes before the next attempt
+	transport.policy.BaseDelay = time.Second
+	transport.policy.MaxDelay = time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, retry = transport.retryDelay(ctx, unavailable, nil, 0, true)
+	assert.False(t, retry)
+}
@@ -28,7 +28,36 @@ func HealthCheck(c *gin.Context) {
 	// 	c.JSON(500, gin.H{"error": "Service is unhealthy"})
 	// 	return
 	// }
-	c.JSON(200, gin.H{"message": "Service is healthy"})
+	// Open breakers degrade the service but don't fail the health check, the API itself is up.
+	// The check is public, breaker names hold tenant endpoints so only the counts by state are returned.
+	breakers, _ := ResilienceStatus()
+	status := "healthy"
+	states := map[BreakerState]int{BreakerClosed: 0, BreakerOpen: 0, BreakerHalfOpen: 0}
+	for _, breaker := range breakers {
+		states[breaker.State]++
+		if breaker.State != BreakerClosed {
+			status = "degraded"
+		}
+	}
+
+	c.JSON(200, gin.H{
+		"message":          "Service is healthy",
+		"status":           status,
+		"circuit_breakers": states,
+	})
+}
+
+// HealthDetails returns every circuit breaker and bulkhead with its last error, for platform operators only
+func HealthDetails(c *gin.Context) {
+	breakers, bulkheads := ResilienceStatus()
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"circuit_breakers": breakers,
+			"bulkheads":        bulkheads,
+		},
+		"message": "Health details retrieved successfully!",
+	})
 }
 
 // Used for resolving test response status